@@ -34,7 +34,7 @@ def update_nodes(nodes, url):
 # Main function to orchestrate the tasks
 def main():
     base_url = "http://localhost:8080/ComputeNode"
-    payload = get_payload(base_url)
+    payload = get_payload(base_url)["items"]
     
     nodes_with_eth0_no_ip = [
         node for node in payload if 'network_interfaces' in node and any(
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// envPrefix starts the environment variable of every serve flag, e.g. ORCH_RATE_LIMIT_RPS
+// for -rate-limit-rps.  Flags on the command line take precedence over the environment.
+const envPrefix = "ORCH_"
+
+// envNames are the environment variables whose names don't follow from their flag
+var envNames = map[string]string{
+	"dir": envPrefix + "SNAPSHOT_DIR",
+	"db":  envPrefix + "DB_PATH",
+}
+
+// secretFlags have their values left out of the startup log
+var secretFlags = map[string]bool{
+	"jwt-secret": true,
+	"csm-jwt":    true,
+}
+
+// Where the value of a flag came from
+const (
+	sourceDefault = "default"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// envName returns the environment variable that configures the flag name
+func envName(name string) string {
+	if env, ok := envNames[name]; ok {
+		return env
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// resolveEnv sets the flags of fs that weren't given on the command line from the
+// environment, as read through lookup, and returns the source of every flag's value.  It must
+// be called after fs is parsed.
+func resolveEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) (map[string]string, error) {
+	sources := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = sourceFlag
+	})
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if sources[f.Name] != "" {
+			return
+		}
+		sources[f.Name] = sourceDefault
+		value, ok := lookup(envName(f.Name))
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), err))
+			return
+		}
+		sources[f.Name] = sourceEnv
+	})
+	if len(errs) > 0 {
+		return sources, errs[0]
+	}
+	return sources, nil
+}
+
+// splitList splits a comma separated flag value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// logConfig reports the effective value of every flag of fs and where it came from
+func logConfig(fs *flag.FlagSet, sources map[string]string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "***"
+		}
+		log.Info().
+			Str("setting", f.Name).
+			Str("value", value).
+			Str("source", sources[f.Name]).
+			Str("env", envName(f.Name)).
+			Msg("Configuration")
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestResolveEnv(t *testing.T) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", ":8080", "")
+	dir := fs.String("dir", "snapshots/", "")
+	freq := fs.Duration("snapshot-freq", time.Hour, "")
+	db := fs.String("db", "data.db", "")
+	if err := fs.Parse([]string{"-listen", ":9090"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"ORCH_LISTEN":        ":7070",
+		"ORCH_SNAPSHOT_DIR":  "/var/lib/orch",
+		"ORCH_SNAPSHOT_FREQ": "5m",
+	}
+	sources, err := resolveEnv(fs, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("resolveEnv failed: %v", err)
+	}
+
+	if *listen != ":9090" || sources["listen"] != sourceFlag {
+		t.Errorf("expected the flag to override the environment, got %q from %s", *listen, sources["listen"])
+	}
+	if *dir != "/var/lib/orch" || sources["dir"] != sourceEnv {
+		t.Errorf("expected -dir from ORCH_SNAPSHOT_DIR, got %q from %s", *dir, sources["dir"])
+	}
+	if *freq != 5*time.Minute || sources["snapshot-freq"] != sourceEnv {
+		t.Errorf("expected -snapshot-freq from ORCH_SNAPSHOT_FREQ, got %s from %s", *freq, sources["snapshot-freq"])
+	}
+	if *db != "data.db" || sources["db"] != sourceDefault {
+		t.Errorf("expected the default -db, got %q from %s", *db, sources["db"])
+	}
+
+	fs = flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Duration("snapshot-freq", time.Hour, "")
+	if _, err := resolveEnv(fs, func(name string) (string, bool) {
+		return "hourly", name == "ORCH_SNAPSHOT_FREQ"
+	}); err == nil {
+		t.Errorf("expected an error for an invalid ORCH_SNAPSHOT_FREQ")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSingleModelDefinitions guards against the models drifting apart again.  The pkg/
+// packages are canonical, so each of these types must be declared exactly once, there.
+func TestSingleModelDefinitions(t *testing.T) {
+	canonical := map[string]string{
+		"ComputeNode":    "pkg/nodes",
+		"BMC":            "pkg/nodes",
+		"NodeCollection": "pkg/nodes",
+		"NodeXname":      "pkg/xnames",
+		"BMCXname":       "pkg/xnames",
+	}
+	found := make(map[string][]string)
+
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != "." && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				name := spec.(*ast.TypeSpec).Name.Name
+				if _, ok := canonical[name]; ok {
+					found[name] = append(found[name], filepath.ToSlash(filepath.Dir(path)))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk the module: %v", err)
+	}
+
+	for name, dir := range canonical {
+		if len(found[name]) != 1 || found[name][0] != dir {
+			t.Errorf("expected %s to be defined once in %s, found it in %v", name, dir, found[name])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// BundleStorage is implemented by storage backends that can export and import the whole
+// inventory as a storage.Bundle
+type BundleStorage interface {
+	ExportBundle() (storage.Bundle, error)
+	ImportBundle(bundle storage.Bundle, mode storage.ConflictMode) error
+}
+
+func exportBundle(bundles BundleStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bundle, err := bundles.ExportBundle()
+		if err != nil {
+			log.Error().Err(err).Msg("Error exporting bundle")
+			http.Error(w, "error exporting bundle", http.StatusInternalServerError)
+			return
+		}
+		render.JSON(w, r, bundle)
+	}
+}
+
+// importBundle restores a bundle.  The on-conflict query parameter chooses what happens to
+// objects that already exist: fail (the default), skip or overwrite.
+func importBundle(bundles BundleStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode, err := storage.ParseConflictMode(r.URL.Query().Get("on-conflict"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var bundle storage.Bundle
+		if err := render.DecodeJSON(r.Body, &bundle); err != nil {
+			http.Error(w, err.Error(), openchami_middleware.BodyErrorStatus(err))
+			return
+		}
+
+		err = bundles.ImportBundle(bundle, mode)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, storage.ErrBundleConflict), errors.Is(err, storage.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, storage.ErrUnsupportedBundleVersion):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Error().Err(err).Msg("Error importing bundle")
+			http.Error(w, "error importing bundle", http.StatusInternalServerError)
+		}
+	}
+}
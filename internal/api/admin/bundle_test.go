@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/storage"
+)
+
+type fakeBundleStorage struct {
+	mode storage.ConflictMode
+	err  error
+}
+
+func (f *fakeBundleStorage) ExportBundle() (storage.Bundle, error) {
+	return storage.Bundle{Version: storage.BundleVersion}, nil
+}
+
+func (f *fakeBundleStorage) ImportBundle(bundle storage.Bundle, mode storage.ConflictMode) error {
+	f.mode = mode
+	return f.err
+}
+
+func TestImportBundle(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		err      error
+		wantCode int
+		wantMode storage.ConflictMode
+	}{
+		{name: "default mode", wantCode: http.StatusNoContent, wantMode: storage.ConflictFail},
+		{name: "skip", query: "?on-conflict=skip", wantCode: http.StatusNoContent, wantMode: storage.ConflictSkip},
+		{name: "unknown mode", query: "?on-conflict=merge", wantCode: http.StatusBadRequest},
+		{name: "conflict", err: fmt.Errorf("%w: x", storage.ErrBundleConflict), wantCode: http.StatusConflict, wantMode: storage.ConflictFail},
+		{name: "version", err: storage.ErrUnsupportedBundleVersion, wantCode: http.StatusBadRequest, wantMode: storage.ConflictFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundles := &fakeBundleStorage{err: tt.err}
+			r := chi.NewRouter()
+			r.Mount("/admin", AdminRoutes(r, bundles, nil))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/admin/import-bundle"+tt.query, strings.NewReader(`{"version": 1}`))
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if bundles.mode != tt.wantMode {
+				t.Errorf("expected mode %q, got %q", tt.wantMode, bundles.mode)
+			}
+		})
+	}
+}
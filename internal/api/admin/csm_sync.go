@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/internal/storage/csm"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog/log"
+)
+
+// CSMTarget is where POST /sync/csm pushes the nodes to, a csm.CSMStorage outside of tests
+type CSMTarget interface {
+	SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode, nid int) error
+}
+
+// Outcomes of pushing one node to CSM.  A node that timed out may well have been stored by
+// a CSM that was merely slow, unlike one that failed.
+const (
+	CSMSynced   = "synced"
+	CSMFailed   = "failed"
+	CSMTimedOut = "timed_out"
+)
+
+// CSMSyncResult is the outcome of pushing one node to CSM
+type CSMSyncResult struct {
+	ID     uuid.UUID `json:"id"`
+	XName  string    `json:"xname,omitempty"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// CSMSyncResponse reports a sync node by node, along with the totals.  Failed counts the
+// nodes that timed out too.
+type CSMSyncResponse struct {
+	Synced int             `json:"synced"`
+	Failed int             `json:"failed"`
+	Nodes  []CSMSyncResult `json:"nodes"`
+}
+
+// syncCSM pushes every local node to CSM.  A node that CSM refuses doesn't stop the others,
+// so the response is a 200 listing what happened to each node.  Only failing to read the
+// local nodes, or the client going away, cuts the sync short.  The nodes are read before any
+// is pushed, so that no cursor is held open while waiting on CSM.
+func syncCSM(inventory storage.NodeStorage, target CSMTarget) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		all, err := inventory.SearchComputeNodes()
+		if err != nil {
+			log.Error().Err(err).Msg("Error reading nodes to sync to CSM")
+			http.Error(w, "error reading nodes to sync", http.StatusInternalServerError)
+			return
+		}
+
+		result := CSMSyncResponse{Nodes: []CSMSyncResult{}}
+		for _, node := range all {
+			if err := r.Context().Err(); err != nil {
+				log.Warn().Err(err).Int("synced", result.Synced).Int("failed", result.Failed).Msg("CSM sync cut short")
+				return
+			}
+			outcome := CSMSyncResult{ID: node.ID, XName: node.XName.String(), Status: CSMSynced}
+			if err := target.SaveComputeNode(node.ID, node, node.NID); err != nil {
+				log.Warn().Err(err).Str("node_id", node.ID.String()).Str("xname", outcome.XName).Msg("Error syncing node to CSM")
+				outcome.Status = CSMFailed
+				if errors.Is(err, csm.ErrTimeout) {
+					outcome.Status = CSMTimedOut
+				}
+				outcome.Error = err.Error()
+				result.Failed++
+			} else {
+				result.Synced++
+			}
+			result.Nodes = append(result.Nodes, outcome)
+		}
+
+		log.Info().Int("synced", result.Synced).Int("failed", result.Failed).Msg("Synced nodes to CSM")
+		render.JSON(w, r, result)
+	}
+}
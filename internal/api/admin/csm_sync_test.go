@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage/csm"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+// fakeCSM refuses the nodes in reject, times out on those in hang and records the NIDs of
+// the others
+type fakeCSM struct {
+	reject map[string]bool
+	hang   map[string]bool
+	saved  map[string]int
+}
+
+func (f *fakeCSM) SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode, nid int) error {
+	if f.reject[node.XName.String()] {
+		return errors.New("POST v2/State/Components/: 400 Bad Request")
+	}
+	if f.hang[node.XName.String()] {
+		return fmt.Errorf("%w: context deadline exceeded", csm.ErrTimeout)
+	}
+	f.saved[node.XName.String()] = nid
+	return nil
+}
+
+func TestSyncCSM(t *testing.T) {
+	inventory := memory.NewInMemoryStorage()
+	for i, xname := range []string{"x1000c0s0b0n0", "x1000c0s1b0n0", "x1000c0s2b0n0", "x1000c0s3b0n0"} {
+		node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname(xname), NID: i + 1, Hostname: xname, Architecture: "x86_64"}
+		if err := inventory.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := &fakeCSM{reject: map[string]bool{"x1000c0s1b0n0": true}, hang: map[string]bool{"x1000c0s3b0n0": true}, saved: map[string]int{}}
+
+	r := chi.NewRouter()
+	r.Mount("/admin", AdminRoutes(r, nil, nil, WithCSMSync(inventory, target)))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/sync/csm", nil))
+	var result CSMSyncResponse
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&result) != nil {
+		t.Fatalf("expected status 200 syncing, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if result.Synced != 2 || result.Failed != 2 || len(result.Nodes) != 4 {
+		t.Fatalf("expected 2 nodes synced and 2 failed, got %+v", result)
+	}
+	wantStatus := map[string]string{"x1000c0s0b0n0": CSMSynced, "x1000c0s1b0n0": CSMFailed, "x1000c0s2b0n0": CSMSynced, "x1000c0s3b0n0": CSMTimedOut}
+	for _, outcome := range result.Nodes {
+		if outcome.Status != wantStatus[outcome.XName] || (outcome.Error != "") != (outcome.Status != CSMSynced) {
+			t.Errorf("unexpected outcome for %s: %+v", outcome.XName, outcome)
+		}
+	}
+	if target.saved["x1000c0s0b0n0"] != 1 || target.saved["x1000c0s2b0n0"] != 3 {
+		t.Errorf("expected the nodes to be pushed with their NIDs, got %v", target.saved)
+	}
+}
+
+func TestSyncCSMNotConfigured(t *testing.T) {
+	r := chi.NewRouter()
+	r.Mount("/admin", AdminRoutes(r, nil, nil))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/sync/csm", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without a CSM target, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// RouteInfo describes a single mounted route and how many middlewares wrap it.
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Route       string `json:"route"`
+	Middlewares int    `json:"middlewares"`
+}
+
+// listRoutes walks the router at request time so that it reflects every route mounted on it,
+// including the admin routes themselves.
+func listRoutes(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		table := []RouteInfo{}
+		err := chi.Walk(routes, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			table = append(table, RouteInfo{
+				Method:      method,
+				Route:       route,
+				Middlewares: len(middlewares),
+			})
+			return nil
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Error walking routes")
+			http.Error(w, "error walking routes", http.StatusInternalServerError)
+			return
+		}
+		render.JSON(w, r, table)
+	}
+}
+
+// RouterOption configures the admin routes
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	inventory storage.NodeStorage
+	csm       CSMTarget
+}
+
+// WithCSMSync mounts POST /sync/csm, which pushes every node in inventory to csm
+func WithCSMSync(inventory storage.NodeStorage, csm CSMTarget) RouterOption {
+	return func(c *routerConfig) {
+		c.inventory = inventory
+		c.csm = csm
+	}
+}
+
+// AdminRoutes returns the administrative routes.  root is the top level router so that
+// the route table covers the whole API rather than just the admin subtree.  The bundle routes
+// are only mounted when bundles is not nil and the snapshot routes when it also implements
+// SnapshotStorage.
+func AdminRoutes(root chi.Routes, bundles BundleStorage, authMiddlewares []func(http.Handler) http.Handler, opts ...RouterOption) chi.Router {
+	var config routerConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	r := chi.NewRouter()
+
+	r.With(authMiddlewares...).Get("/routes", listRoutes(root))
+	if bundles != nil {
+		r.With(authMiddlewares...).Get("/export-bundle", exportBundle(bundles))
+		r.With(authMiddlewares...).Post("/import-bundle", importBundle(bundles))
+	}
+	if snapshots, ok := bundles.(SnapshotStorage); ok {
+		r.With(authMiddlewares...).Post("/snapshot", takeSnapshot(snapshots))
+		r.With(authMiddlewares...).Get("/snapshot/latest", downloadLatestSnapshot(snapshots))
+	}
+	if config.csm != nil {
+		r.With(authMiddlewares...).Post("/sync/csm", syncCSM(config.inventory, config.csm))
+	}
+
+	return r
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/api/openchami"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+)
+
+func TestListRoutes(t *testing.T) {
+	r := chi.NewRouter()
+	r.Mount("/inventory", openchami.NodeRoutes(nil, nil))
+	r.Mount("/smd", smd.SMDComponentRoutes(nil, nil))
+	r.Mount("/admin", AdminRoutes(r, nil, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var table []RouteInfo
+	if err := json.NewDecoder(rec.Body).Decode(&table); err != nil {
+		t.Fatalf("failed to decode route table: %v", err)
+	}
+
+	want := []RouteInfo{
+		{Method: http.MethodPost, Route: "/inventory/ComputeNode"},
+		{Method: http.MethodGet, Route: "/inventory/ComputeNode/{nodeID}"},
+		{Method: http.MethodGet, Route: "/smd/State/Components/{xname}"},
+		{Method: http.MethodGet, Route: "/admin/routes"},
+	}
+	for _, w := range want {
+		found := false
+		for _, route := range table {
+			if route.Method == w.Method && route.Route == w.Route {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected %s %s in the route table", w.Method, w.Route)
+		}
+	}
+}
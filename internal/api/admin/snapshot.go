@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-chi/render"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotStorage is implemented by storage backends that write on-disk snapshots.  Both
+// methods return the snapshot directory.
+type SnapshotStorage interface {
+	Snapshot(ctx context.Context) (string, error)
+	LatestSnapshot() (string, error)
+}
+
+// SnapshotResponse is returned after an on-demand snapshot
+type SnapshotResponse struct {
+	Path string `json:"path"`
+}
+
+func takeSnapshot(snapshots SnapshotStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, err := snapshots.Snapshot(r.Context())
+		switch {
+		case err == nil:
+			render.Status(r, http.StatusCreated)
+			render.JSON(w, r, SnapshotResponse{Path: path})
+		case errors.Is(err, storage.ErrSnapshotInProgress), errors.Is(err, storage.ErrNoSnapshotPath):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error().Err(err).Msg("Error taking snapshot")
+			http.Error(w, "error taking snapshot", http.StatusInternalServerError)
+		}
+	}
+}
+
+// downloadLatestSnapshot streams the most recent snapshot directory as a tar archive whose
+// entries are under the snapshot's directory name
+func downloadLatestSnapshot(snapshots SnapshotStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dir, err := snapshots.LatestSnapshot()
+		switch {
+		case errors.Is(err, storage.ErrNoSnapshot), errors.Is(err, storage.ErrNoSnapshotPath):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			log.Error().Err(err).Msg("Error finding latest snapshot")
+			http.Error(w, "error finding latest snapshot", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(dir)+`.tar"`)
+		if err := writeTar(w, dir); err != nil {
+			// The headers are gone by now, all that is left is to cut the archive short
+			log.Error().Err(err).Str("path", dir).Msg("Error streaming snapshot")
+		}
+	}
+}
+
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	root := filepath.Dir(dir)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
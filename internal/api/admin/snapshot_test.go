@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/storage"
+)
+
+type fakeSnapshotStorage struct {
+	fakeBundleStorage
+	dir string
+	err error
+}
+
+func (f *fakeSnapshotStorage) Snapshot(ctx context.Context) (string, error) {
+	return f.dir, f.err
+}
+
+func (f *fakeSnapshotStorage) LatestSnapshot() (string, error) {
+	return f.dir, f.err
+}
+
+func TestSnapshotRoutes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "2024-06-01T12-00-00")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"schema.sql": "CREATE TABLE t (i INTEGER);\n", "load.sql": "COPY t FROM 't.parquet';\n"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots := &fakeSnapshotStorage{dir: dir}
+	r := chi.NewRouter()
+	r.Mount("/admin", AdminRoutes(r, snapshots, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/snapshot", nil))
+	var created SnapshotResponse
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&created) != nil || created.Path != dir {
+		t.Fatalf("unexpected snapshot response %d: %+v", rec.Code, created)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/snapshot/latest", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-tar" {
+		t.Fatalf("unexpected download response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	tr := tar.NewReader(rec.Body)
+	found := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		found[header.Name] = string(content)
+	}
+	if _, ok := found["2024-06-01T12-00-00/"]; !ok {
+		t.Errorf("expected the snapshot directory in the archive, got %v", found)
+	}
+	for name, content := range files {
+		if got := found["2024-06-01T12-00-00/"+name]; got != content {
+			t.Errorf("archived %s = %q, want %q", name, got, content)
+		}
+	}
+
+	snapshots.err = storage.ErrSnapshotInProgress
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/snapshot", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 while a snapshot is running, got %d", rec.Code)
+	}
+
+	snapshots.err = storage.ErrNoSnapshot
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/snapshot/latest", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without snapshots, got %d", rec.Code)
+	}
+}
@@ -2,60 +2,257 @@ package openchami
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
 	"github.com/openchami/node-orchestrator/internal/storage"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
-	"github.com/openchami/node-orchestrator/pkg/xnames"
+	"github.com/rs/zerolog/log"
 )
 
-func postBMC(storage storage.NodeStorage) http.HandlerFunc {
+func postBMC(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var newBMC nodes.BMC
 		if err := json.NewDecoder(r.Body).Decode(&newBMC); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			render.Render(w, r, response.ErrInvalidRequest(err))
 			return
 		}
-		if newBMC.LocationString != "" {
-			if !xnames.IsValidBMCXName(newBMC.LocationString) {
-				http.Error(w, "invalid XName", http.StatusBadRequest)
+		if !checkFormats(w, r, "BMC", &newBMC) {
+			return
+		}
+		if newBMC.XName.String() != "" {
+			if _, err := newBMC.XName.Valid(); err != nil {
+				response.Error(w, r, "invalid XName", http.StatusBadRequest)
+				return
 			}
 			// Check if the XName already exists
-			_, err := storage.LookupBMCByXName(newBMC.LocationString)
+			_, err := storage.LookupBMCByXName(newBMC.XName.String())
 			if err == nil {
-				http.Error(w, "XName already exists", http.StatusConflict)
+				response.Error(w, r, "XName already exists", http.StatusConflict)
+				return
+			}
+		}
+		// A redacted password can only stand for the password of a BMC that is already stored
+		if newBMC.Password == nodes.RedactedPassword {
+			stored, found := lookupStoredBMC(storage, newBMC.XName.String(), newBMC.MACAddress)
+			if !found {
+				response.Error(w, r, "password "+nodes.RedactedPassword+" is the redaction placeholder", http.StatusBadRequest)
 				return
 			}
+			newBMC.KeepPassword(stored)
 		}
 
 		newBMC.ID = uuid.New()
-		storage.SaveBMC(newBMC.ID, newBMC)
-		json.NewEncoder(w).Encode(newBMC)
+		newBMC.CreatedAt = time.Time{}
+		newBMC.Touch(time.Now())
+		if err := storage.SaveBMC(newBMC.ID, newBMC); err != nil {
+			log.Error().Err(err).Msg("Error saving BMC")
+			storageError(w, r, err, "error saving BMC", http.StatusInternalServerError)
+			return
+		}
+		broker.Publish(bmcEvent(events.ActionCreated, newBMC))
+		json.NewEncoder(w).Encode(newBMC.Redacted())
+	}
+}
+
+// Statuses of the BMCs in a bulk request
+const (
+	BMCCreated = "created"
+	BMCMatched = "matched"
+)
+
+// BMCBulkResult is the outcome for one BMC of a bulk request.  A matched BMC is the stored
+// record that the requested one was deduplicated against.
+type BMCBulkResult struct {
+	Status string    `json:"status"`
+	BMC    nodes.BMC `json:"bmc"`
+}
+
+// postBMCs creates the BMCs in the request body that don't exist yet.  A BMC whose xname or
+// MAC address is already stored, or appears earlier in the request, is matched to that BMC
+// instead.  Every BMC is validated before any is saved, and the new ones are saved together.
+func postBMCs(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var bmcs []nodes.BMC
+		if err := json.NewDecoder(r.Body).Decode(&bmcs); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if !checkFormats(w, r, "BMC list", bmcs) {
+			return
+		}
+
+		now := time.Now()
+		results := make([]BMCBulkResult, len(bmcs))
+		byXName := map[string]nodes.BMC{}
+		byMAC := map[string]nodes.BMC{}
+		var created []nodes.BMC
+		for i, bmc := range bmcs {
+			xname := bmc.XName.String()
+			if xname != "" {
+				if _, err := bmc.XName.Valid(); err != nil {
+					response.Error(w, r, fmt.Sprintf("BMC %d: invalid XName %q", i, xname), http.StatusBadRequest)
+					return
+				}
+			}
+			if _, err := net.ParseMAC(bmc.MACAddress); err != nil {
+				response.Error(w, r, fmt.Sprintf("BMC %d: invalid MAC address %q", i, bmc.MACAddress), http.StatusBadRequest)
+				return
+			}
+			mac := bmc.MACAddress
+
+			if existing, ok := byXName[xname]; ok && xname != "" {
+				results[i] = BMCBulkResult{Status: BMCMatched, BMC: existing.Redacted()}
+				continue
+			}
+			if existing, ok := byMAC[mac]; ok {
+				results[i] = BMCBulkResult{Status: BMCMatched, BMC: existing.Redacted()}
+				continue
+			}
+			existing, found := lookupStoredBMC(storage, xname, bmc.MACAddress)
+			if found {
+				results[i] = BMCBulkResult{Status: BMCMatched, BMC: existing.Redacted()}
+			} else {
+				bmc.ID = uuid.New()
+				bmc.CreatedAt = time.Time{}
+				bmc.Touch(now)
+				existing = bmc
+				created = append(created, bmc)
+				results[i] = BMCBulkResult{Status: BMCCreated, BMC: bmc.Redacted()}
+			}
+			if xname != "" {
+				byXName[xname] = existing
+			}
+			byMAC[mac] = existing
+		}
+
+		if len(created) > 0 {
+			if err := storage.SaveBMCs(created); err != nil {
+				storageError(w, r, err, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, bmc := range created {
+				broker.Publish(bmcEvent(events.ActionCreated, bmc))
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(results)
 	}
 }
 
-func updateBMC(storage storage.NodeStorage) http.HandlerFunc {
+// lookupStoredBMC finds a stored BMC by xname, if there is one, or else by MAC address
+func lookupStoredBMC(storage storage.NodeStorage, xname, mac string) (nodes.BMC, bool) {
+	if xname != "" {
+		if bmc, err := storage.LookupBMCByXName(xname); err == nil {
+			return bmc, true
+		}
+	}
+	if mac == "" {
+		return nodes.BMC{}, false
+	}
+	bmc, err := storage.LookupBMCByMACAddress(mac)
+	return bmc, err == nil
+}
+
+func updateBMC(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bmcID, err := uuid.Parse(chi.URLParam(r, "bmcID"))
 		if err != nil {
-			http.Error(w, "malformed node ID", http.StatusBadRequest)
+			response.Error(w, r, "malformed BMC ID", http.StatusBadRequest)
 			return
 		}
 		var updateBMC nodes.BMC
 		if err := json.NewDecoder(r.Body).Decode(&updateBMC); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			render.Render(w, r, response.ErrInvalidRequest(err))
 			return
 		}
-		if _, err := storage.GetBMC(bmcID); err == nil {
-			updateBMC.ID = bmcID
-			storage.SaveBMC(bmcID, updateBMC)
-			json.NewEncoder(w).Encode(updateBMC)
-		} else {
-			http.Error(w, "BMC not found", http.StatusNotFound)
+		if !checkFormats(w, r, "BMC", &updateBMC) {
+			return
+		}
+		if updateBMC.XName.String() != "" {
+			if _, err := updateBMC.XName.Valid(); err != nil {
+				response.Error(w, r, "invalid XName", http.StatusBadRequest)
+				return
+			}
+		}
+		existing, err := storage.GetBMC(bmcID)
+		if err != nil {
+			storageError(w, r, err, "BMC not found", http.StatusNotFound)
+			return
+		}
+		if updateBMC.XName.String() != "" {
+			if other, err := storage.LookupBMCByXName(updateBMC.XName.String()); err == nil && other.ID != bmcID {
+				response.Error(w, r, "BMC "+other.ID.String()+" already has XName "+updateBMC.XName.String(), http.StatusConflict)
+				return
+			}
+		}
+		updateBMC.ID = bmcID
+		updateBMC.CreatedAt = existing.CreatedAt
+		updateBMC.KeepPassword(existing)
+		updateBMC.Touch(time.Now())
+		if err := storage.SaveBMC(bmcID, updateBMC); err != nil {
+			log.Error().Err(err).Msg("Error saving BMC")
+			storageError(w, r, err, "error saving BMC", http.StatusInternalServerError)
+			return
 		}
+		broker.Publish(bmcEvent(events.ActionUpdated, updateBMC))
+		json.NewEncoder(w).Encode(updateBMC.Redacted())
+	}
+}
 
+// bmcSearchOptions turns the query of GET /bmc into storage search options.  prefix matches
+// the start of the xname, e.g. x1000c0 for the BMCs of a chassis, and ip either address.
+func bmcSearchOptions(query url.Values) ([]storage.BMCSearchOption, error) {
+	var searchOptions []storage.BMCSearchOption
+	if xname := query.Get("xname"); xname != "" {
+		searchOptions = append(searchOptions, storage.WithBMCXName(xname))
+	}
+	if prefix := query.Get("prefix"); prefix != "" {
+		searchOptions = append(searchOptions, storage.WithBMCXNamePrefix(prefix))
+	}
+	if mac := query.Get("mac"); mac != "" {
+		if _, err := net.ParseMAC(mac); err != nil {
+			return nil, fmt.Errorf("invalid mac %q", mac)
+		}
+		searchOptions = append(searchOptions, storage.WithBMCMACAddress(nodes.CanonicalMAC(mac)))
+	}
+	if ip := query.Get("ip"); ip != "" {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid ip %q", ip)
+		}
+		searchOptions = append(searchOptions, storage.WithBMCIPAddress(ip))
+	}
+	return searchOptions, nil
+}
+
+// searchBMCs lists the BMCs matching the query, all of them without one
+func searchBMCs(myStorage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		searchOptions, err := bmcSearchOptions(r.URL.Query())
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bmcs, err := myStorage.SearchBMCs(searchOptions...)
+		if err != nil {
+			log.Error().Err(err).Msg("Error searching BMCs")
+			storageError(w, r, err, "error searching BMCs", http.StatusInternalServerError)
+			return
+		}
+		redacted := make([]nodes.BMC, 0, len(bmcs))
+		for _, bmc := range bmcs {
+			redacted = append(redacted, bmc.Redacted())
+		}
+		json.NewEncoder(w).Encode(redacted)
 	}
 }
 
@@ -63,31 +260,64 @@ func getBMC(storage storage.NodeStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bmcID, err := uuid.Parse(chi.URLParam(r, "bmcID"))
 		if err != nil {
-			http.Error(w, "malformed node ID", http.StatusBadRequest)
+			response.Error(w, r, "malformed BMC ID", http.StatusBadRequest)
 			return
 		}
 		bmc, err := storage.GetBMC(bmcID)
 		if err == nil {
-			json.NewEncoder(w).Encode(bmc)
+			json.NewEncoder(w).Encode(bmc.Redacted())
 		} else {
-			http.Error(w, "node not found", http.StatusNotFound)
+			storageError(w, r, err, "BMC not found", http.StatusNotFound)
 		}
 	}
 }
 
-func deleteBMC(storage storage.NodeStorage) http.HandlerFunc {
+// getNodeBMC returns the stored BMC of a node.  The 404 body tells a missing node apart from
+// a node without a BMC.
+func getNodeBMC(storage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		if node.BMC == nil || node.BMC.ID == uuid.Nil {
+			response.Error(w, r, "node has no BMC", http.StatusNotFound)
+			return
+		}
+		bmc, err := storage.GetBMC(node.BMC.ID)
+		if err != nil {
+			storageError(w, r, err, "BMC "+node.BMC.ID.String()+" of the node not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(bmc.Redacted())
+	}
+}
+
+func deleteBMC(myStorage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bmcID, err := uuid.Parse(chi.URLParam(r, "bmcID"))
 		if err != nil {
-			http.Error(w, "malformed node ID", http.StatusBadRequest)
+			response.Error(w, r, "malformed BMC ID", http.StatusBadRequest)
 			return
 		}
-		err = storage.DeleteBMC(bmcID)
-		if err == nil {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Deleted BMC with ID: " + bmcID.String()))
-		} else {
-			http.Error(w, "node not found", http.StatusNotFound)
+		err = myStorage.DeleteBMC(bmcID)
+		if errors.Is(err, storage.ErrNotFound) {
+			response.Error(w, r, "BMC not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Error deleting BMC")
+			storageError(w, r, err, "error deleting BMC", http.StatusInternalServerError)
+			return
 		}
+		broker.Publish(bmcEvent(events.ActionDeleted, nodes.BMC{ID: bmcID}))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Deleted BMC with ID: " + bmcID.String()))
 	}
 }
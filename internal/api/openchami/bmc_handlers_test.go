@@ -0,0 +1,341 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestResponsesRedactPasswords(t *testing.T) {
+	r, store := newTestRouter(t)
+
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s1b0"), Username: "root", Password: "bmc-secret"}
+	if err := store.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x3000c0s1b0n0"), BMC: &bmc}
+	if err := store.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc/"+bmc.ID.String(), nil))
+	var gotBMC nodes.BMC
+	if err := json.NewDecoder(rec.Body).Decode(&gotBMC); err != nil {
+		t.Fatalf("failed to decode BMC: %v", err)
+	}
+	if gotBMC.Password != nodes.RedactedPassword {
+		t.Errorf("expected a redacted BMC password, got %q", gotBMC.Password)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/"+node.ID.String(), nil))
+	var gotNode nodes.ComputeNode
+	if err := json.NewDecoder(rec.Body).Decode(&gotNode); err != nil {
+		t.Fatalf("failed to decode node: %v", err)
+	}
+	if gotNode.BMC == nil || gotNode.BMC.Password != nodes.RedactedPassword {
+		t.Errorf("expected the embedded BMC password to be redacted, got %+v", gotNode.BMC)
+	}
+
+	// Redaction only applies to responses
+	stored, err := store.GetBMC(bmc.ID)
+	if err != nil || stored.Password != "bmc-secret" {
+		t.Errorf("expected the stored password to be untouched, got %q, %v", stored.Password, err)
+	}
+}
+
+// A BMC or node read from the API and sent back unchanged keeps its stored passwords
+func TestPutBackRedactedPasswords(t *testing.T) {
+	r, store := newTestRouter(t)
+
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s1b0"), MACAddress: "de:ad:be:ef:00:01", Username: "root", Password: "bmc-secret"}
+	if err := store.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x3000c0s1b0n0"), Architecture: nodes.ArchX86_64, BMC: &bmc}
+	node.Hostname = "nid001"
+	node.Spec.BMCPassword = "spec-secret"
+	if err := store.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	roundTrip := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := rec.Body.String()
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := roundTrip("/inventory/bmc/" + bmc.ID.String()); rec.Code != http.StatusOK {
+		t.Fatalf("expected the BMC to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, err := store.GetBMC(bmc.ID); err != nil || stored.Password != "bmc-secret" {
+		t.Errorf("expected the BMC password to be kept, got %q, %v", stored.Password, err)
+	}
+
+	if rec := roundTrip("/inventory/ComputeNode/" + node.ID.String()); rec.Code != http.StatusOK {
+		t.Fatalf("expected the node to be updated, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, err := store.GetComputeNode(node.ID)
+	if err != nil || stored.Spec.BMCPassword != "spec-secret" || stored.BMC == nil || stored.BMC.Password != "bmc-secret" {
+		t.Errorf("expected the node passwords to be kept, got %+v, %v", stored, err)
+	}
+
+	// A new BMC has no stored password for the placeholder to stand for
+	rec := httptest.NewRecorder()
+	body := `{"xname": "x3000c0s2b0", "mac_address": "de:ad:be:ef:00:02", "password": "***"}`
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/bmc", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a new BMC with a redacted password, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetNodeBMC(t *testing.T) {
+	r, store := newTestRouter(t)
+	withBMC := createNode(t, r, "x1000c0s7b1n0")
+	withoutBMC := nodes.ComputeNode{ID: uuid.New(), Hostname: "no-bmc", Architecture: nodes.ArchX86_64}
+	if err := store.SaveComputeNode(withoutBMC.ID, withoutBMC); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/"+withBMC.ID.String()+"/bmc", nil))
+	var bmc nodes.BMC
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&bmc) != nil {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if bmc.ID != withBMC.BMC.ID || bmc.XName.String() != "x1000c0s7b1" {
+		t.Errorf("unexpected BMC %+v", bmc)
+	}
+
+	for _, tt := range []struct {
+		nodeID string
+		want   string
+	}{
+		{uuid.New().String(), "node not found"},
+		{withoutBMC.ID.String(), "node has no BMC"},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/"+tt.nodeID+"/bmc", nil))
+		var body struct {
+			Error string `json:"error"`
+		}
+		if rec.Code != http.StatusNotFound || json.NewDecoder(rec.Body).Decode(&body) != nil || body.Error != tt.want {
+			t.Errorf("expected 404 %q, got %d %+v", tt.want, rec.Code, body)
+		}
+	}
+}
+
+func TestPostBMCsBulk(t *testing.T) {
+	r, store := newTestRouter(t)
+
+	stored := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s1b0"), MACAddress: "02:00:00:00:00:01", Password: "secret"}
+	if err := store.SaveBMC(stored.ID, stored); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+
+	body := `[
+		{"xname": "x3000c0s1b0", "mac_address": "02:00:00:00:00:01"},
+		{"xname": "x3000c0s2b0", "mac_address": "02:00:00:00:00:02", "password": "secret"},
+		{"mac_address": "02:00:00:00:00:02"}
+	]`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/bmc/bulk", strings.NewReader(body)))
+	var results []BMCBulkResult
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&results) != nil || len(results) != 3 {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if results[0].Status != BMCMatched || results[0].BMC.ID != stored.ID {
+		t.Errorf("expected the first BMC to match %s, got %+v", stored.ID, results[0])
+	}
+	if results[1].Status != BMCCreated || results[1].BMC.ID == uuid.Nil || results[1].BMC.Password != nodes.RedactedPassword {
+		t.Errorf("expected the second BMC to be created, got %+v", results[1])
+	}
+	if results[2].Status != BMCMatched || results[2].BMC.ID != results[1].BMC.ID {
+		t.Errorf("expected the third BMC to match the second by MAC, got %+v", results[2])
+	}
+	if _, err := store.GetBMC(results[1].BMC.ID); err != nil {
+		t.Errorf("created BMC was not stored: %v", err)
+	}
+
+	for _, body := range []string{
+		`[{"xname": "x3000c0s3b0", "mac_address": "02:00:00:00:00:03"}, {"xname": "nope", "mac_address": "02:00:00:00:00:04"}]`,
+		`[{"xname": "x3000c0s3b0", "mac_address": "02:00:00:00:00:03"}, {"xname": "x3000c0s4b0", "mac_address": "nope"}]`,
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/bmc/bulk", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	if _, err := store.LookupBMCByXName("x3000c0s3b0"); err == nil {
+		t.Errorf("expected a rejected request to store nothing")
+	}
+}
+
+func TestSearchBMCs(t *testing.T) {
+	r, store := newTestRouter(t)
+	for _, bmc := range []nodes.BMC{
+		{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c0s0b0"), MACAddress: "02:00:00:00:00:01", IPv4Address: "10.1.0.1", Password: "secret"},
+		{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c0s1b0"), MACAddress: "02:00:00:00:00:02", IPv6Address: "fd00::2"},
+		{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c1s0b0"), MACAddress: "02:00:00:00:00:03", IPv4Address: "10.1.0.3"},
+		{ID: uuid.New(), XName: xnames.NewBMCXname("x001c0s0b0"), MACAddress: "02:00:00:00:00:04"},
+	} {
+		if err := store.SaveBMC(bmc.ID, bmc); err != nil {
+			t.Fatalf("failed to save BMC: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		query  string
+		xnames []string
+	}{
+		{"", []string{"x1000c0s0b0", "x1000c0s1b0", "x1000c1s0b0", "x001c0s0b0"}},
+		{"xname=x1000c0s1b0", []string{"x1000c0s1b0"}},
+		{"prefix=x1000c0", []string{"x1000c0s0b0", "x1000c0s1b0"}},
+		{"mac=02:00:00:00:00:0A", nil},
+		{"mac=02:00:00:00:00:03", []string{"x1000c1s0b0"}},
+		{"ip=fd00::2", []string{"x1000c0s1b0"}},
+		{"ip=10.1.0.1&prefix=x1000c1", nil},
+		{"prefix=x1c0", []string{"x001c0s0b0"}},
+		{"prefix=x0001c0s", []string{"x001c0s0b0"}},
+		{"prefix=x1", []string{"x1000c0s0b0", "x1000c0s1b0", "x1000c1s0b0"}},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc?"+tt.query, nil))
+		var bmcs []nodes.BMC
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&bmcs) != nil {
+			t.Errorf("%q: unexpected response %d: %s", tt.query, rec.Code, rec.Body.String())
+			continue
+		}
+		got := map[string]bool{}
+		for _, bmc := range bmcs {
+			got[bmc.XName.String()] = true
+			if bmc.Password != "" && bmc.Password != nodes.RedactedPassword {
+				t.Errorf("%q: expected redacted passwords, got %q", tt.query, bmc.Password)
+			}
+		}
+		if len(got) != len(tt.xnames) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.xnames, got)
+			continue
+		}
+		for _, xname := range tt.xnames {
+			if !got[xname] {
+				t.Errorf("%q: expected %v, got %v", tt.query, tt.xnames, got)
+			}
+		}
+	}
+
+	for _, query := range []string{"mac=nope", "ip=10.1.0"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %q, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestPostBMCErrors(t *testing.T) {
+	r, store := newTestRouter(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/bmc", strings.NewReader(body)))
+		return rec
+	}
+
+	// An invalid xname is answered once and nothing is saved
+	rec := post(`{"xname": "x1000c0s1", "mac_address": "de:ad:be:ef:00:01"}`)
+	decoder := json.NewDecoder(rec.Body)
+	var body struct {
+		Error string `json:"error"`
+	}
+	if rec.Code != http.StatusBadRequest || decoder.Decode(&body) != nil || body.Error != "invalid XName" || decoder.More() {
+		t.Errorf("expected a single 400 for an invalid xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if bmcs, _ := store.SearchBMCs(); len(bmcs) != 0 {
+		t.Errorf("expected no BMC saved for an invalid xname, got %+v", bmcs)
+	}
+
+	if rec := post(`{"xname": "x1000c0s1b0", "mac_address": "de:ad:be:ef:00:02"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 creating a BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"xname": "x1000c0s1b0", "mac_address": "de:ad:be:ef:00:03"}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a duplicate xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if bmcs, _ := store.SearchBMCs(); len(bmcs) != 1 {
+		t.Errorf("expected only the first BMC saved, got %+v", bmcs)
+	}
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+		want         string
+	}{
+		{http.MethodGet, "/inventory/bmc/not-a-uuid", http.StatusBadRequest, "malformed BMC ID"},
+		{http.MethodGet, "/inventory/bmc/" + uuid.NewString(), http.StatusNotFound, "BMC not found"},
+		{http.MethodPut, "/inventory/bmc/" + uuid.NewString(), http.StatusNotFound, "BMC not found"},
+		{http.MethodDelete, "/inventory/bmc/" + uuid.NewString(), http.StatusNotFound, "BMC not found"},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`)))
+		var body struct {
+			Error string `json:"error"`
+		}
+		if rec.Code != tt.status || json.NewDecoder(rec.Body).Decode(&body) != nil || body.Error != tt.want {
+			t.Errorf("%s %s: expected %d %q, got %d %+v", tt.method, tt.path, tt.status, tt.want, rec.Code, body)
+		}
+	}
+}
+
+func TestUpdateBMCXName(t *testing.T) {
+	r, store := newTestRouter(t)
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c0s1b0"), MACAddress: "de:ad:be:ef:00:01"}
+	if err := store.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	put := func(xname string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := `{"xname": "` + xname + `", "mac_address": "de:ad:be:ef:00:01"}`
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/bmc/"+bmc.ID.String(), strings.NewReader(body)))
+		return rec
+	}
+
+	rec := put("x1000c0s2b1")
+	var updated nodes.BMC
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&updated) != nil || updated.XName.String() != "x1000c0s2b1" {
+		t.Fatalf("expected a BMC xname to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A node xname is not a BMC xname
+	if rec := put("x1000c0s2b1n0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a node xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, err := store.GetBMC(bmc.ID); err != nil || stored.XName.String() != "x1000c0s2b1" {
+		t.Errorf("expected the refused update to leave the BMC alone, got %+v (%v)", stored, err)
+	}
+
+	// Nor can it take the xname of another BMC
+	other := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c0s3b0"), MACAddress: "de:ad:be:ef:00:02"}
+	if err := store.SaveBMC(other.ID, other); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	if rec := put("x1000c0s3b0"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for another BMC's xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, err := store.GetBMC(bmc.ID); err != nil || stored.XName.String() != "x1000c0s2b1" {
+		t.Errorf("expected the refused update to leave the BMC alone, got %+v (%v)", stored, err)
+	}
+	// Keeping its own xname is fine
+	if rec := put("x1000c0s2b1"); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 keeping the xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
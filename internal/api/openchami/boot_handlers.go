@@ -0,0 +1,152 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog/log"
+)
+
+// putNextBootData stages the boot configuration in the body as the node's next one, replacing
+// whatever was staged before
+func putNextBootData(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		var next nodes.BootData
+		if err := json.NewDecoder(r.Body).Decode(&next); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if next.KernelURL == "" {
+			response.Error(w, r, "kernel_url is required", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+
+		node.NextBootData = &next
+		saveNode(w, r, storage, broker, node)
+	}
+}
+
+// changeBootData applies change, PromoteBootData or RollbackBootData, to a node.  A node with
+// nothing to promote or roll back to is a conflict.
+func changeBootData(storage storage.NodeStorage, broker *events.Broker, change func(*nodes.ComputeNode) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+
+		if err := change(&node); err != nil {
+			response.Error(w, r, err.Error(), http.StatusConflict)
+			return
+		}
+		saveNode(w, r, storage, broker, node)
+	}
+}
+
+// saveNode stores a node changed by one of the sub-resource handlers and answers with it, in
+// the status set with render.Status if any
+func saveNode(w http.ResponseWriter, r *http.Request, storage storage.NodeStorage, broker *events.Broker, node nodes.ComputeNode) {
+	node.Touch(time.Now())
+	if err := storage.UpdateComputeNode(node.ID, node); err != nil {
+		log.Error().Err(err).Msg("Error saving node")
+		storageError(w, r, err, "error saving node", http.StatusInternalServerError)
+		return
+	}
+	broker.Publish(nodeEvent(events.ActionUpdated, node))
+	render.JSON(w, r, node.Redacted())
+}
+
+// getBootParameters serves the boot parameters of a node BSS style, as a list, for the node
+// with the xname in name, the boot MAC address in mac or the NID in nid.  With pending=true a
+// node with a staged boot configuration is served that instead of its current one.
+func getBootParameters(myStorage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pending := false
+		if value := query.Get("pending"); value != "" {
+			var err error
+			if pending, err = strconv.ParseBool(value); err != nil {
+				response.Error(w, r, "invalid pending value", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var node nodes.ComputeNode
+		var err error
+		switch {
+		case query.Get("name") != "":
+			node, err = myStorage.LookupComputeNodeByXName(query.Get("name"))
+		case query.Get("mac") != "":
+			node, err = myStorage.LookupComputeNodeByMACAddress(query.Get("mac"))
+		case query.Get("nid") != "":
+			nid, parseErr := strconv.Atoi(query.Get("nid"))
+			if parseErr != nil {
+				response.Error(w, r, "invalid nid", http.StatusBadRequest)
+				return
+			}
+			node, err = myStorage.LookupComputeNodeByNID(nid)
+		default:
+			response.Error(w, r, "one of name, mac or nid is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+
+		boot := node.BootData
+		if pending && node.NextBootData != nil {
+			boot = node.NextBootData
+		}
+		if boot == nil {
+			response.Error(w, r, "node has no boot configuration", http.StatusNotFound)
+			return
+		}
+		render.JSON(w, r, []smd.BootParams{bootParams(node, *boot)})
+	}
+}
+
+// bootParams describes booting node from boot the way BSS does
+func bootParams(node nodes.ComputeNode, boot nodes.BootData) smd.BootParams {
+	params := smd.BootParams{
+		Kernel: boot.KernelURL,
+		Initrd: boot.ImageURL,
+		Params: boot.KernelCommandLine,
+	}
+	if node.XName.String() != "" {
+		params.Hosts = []string{node.XName.String()}
+	}
+	if node.BootMac != "" {
+		params.Macs = []string{node.BootMac}
+	}
+	if node.NID > 0 {
+		params.Nids = []int32{int32(node.NID)}
+	}
+	return params
+}
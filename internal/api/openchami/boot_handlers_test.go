@@ -0,0 +1,84 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestBootDataStaging(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001", NID: 1, XName: xnames.NewNodeXname("x1000c0s1b0n0"), BootMac: "de:ad:be:ef:00:01", Architecture: nodes.ArchX86_64,
+		BootData: &nodes.BootData{KernelURL: "http://boot/vmlinuz-a", KernelCommandLine: "console=ttyS0"}}
+	store.SaveComputeNode(node.ID, node)
+	r := chi.NewRouter()
+	r.Mount("/inventory", NodeRoutes(store, nil))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	kernel := func(query string) string {
+		t.Helper()
+		rec := send(http.MethodGet, "/inventory/bootparameters?"+query, "")
+		var params []smd.BootParams
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&params) != nil || len(params) != 1 {
+			t.Fatalf("expected one set of boot parameters for %s, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		return params[0].Kernel
+	}
+	bootPath := "/inventory/ComputeNode/" + node.ID.String() + "/boot/"
+
+	if rec := send(http.MethodPost, bootPath+"promote", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 promoting with nothing staged, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, bootPath+"next", `{"kernel_command_line": "quiet"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 staging without a kernel, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, bootPath+"next", `{"kernel_url": "http://boot/vmlinuz-b"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 staging, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if got := kernel("name=x1000c0s1b0n0"); got != "http://boot/vmlinuz-a" {
+		t.Errorf("expected the current kernel without pending, got %q", got)
+	}
+	if got := kernel("mac=de:ad:be:ef:00:01&pending=true"); got != "http://boot/vmlinuz-b" {
+		t.Errorf("expected the staged kernel with pending=true, got %q", got)
+	}
+
+	if rec := send(http.MethodPost, bootPath+"promote", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 promoting, got %d: %s", rec.Code, rec.Body.String())
+	}
+	promoted, _ := store.GetComputeNode(node.ID)
+	if promoted.NextBootData != nil || promoted.LastGoodBootData == nil || promoted.LastGoodBootData.KernelURL != "http://boot/vmlinuz-a" {
+		t.Errorf("expected the old kernel kept as last known good, got %+v", promoted)
+	}
+	if got := kernel("nid=1&pending=true"); got != "http://boot/vmlinuz-b" {
+		t.Errorf("expected the promoted kernel, falling back from pending, got %q", got)
+	}
+
+	if rec := send(http.MethodPost, bootPath+"rollback", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 rolling back, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := kernel("nid=1"); got != "http://boot/vmlinuz-a" {
+		t.Errorf("expected the last known good kernel after rolling back, got %q", got)
+	}
+	if rec := send(http.MethodPost, bootPath+"rollback", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 rolling back twice, got %d", rec.Code)
+	}
+
+	for query, want := range map[string]int{"": http.StatusBadRequest, "nid=one": http.StatusBadRequest, "name=x1000c0s1b0n0&pending=maybe": http.StatusBadRequest, "name=x9c0s0b0n0": http.StatusNotFound} {
+		if rec := send(http.MethodGet, "/inventory/bootparameters?"+query, ""); rec.Code != want {
+			t.Errorf("expected status %d for %q, got %d", want, query, rec.Code)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package openchami
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -9,33 +10,84 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
-	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/storage"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
 	"github.com/openchami/node-orchestrator/pkg/xnames"
 	"github.com/rs/zerolog/log"
 )
 
-func createCollection(manager *nodes.CollectionManager) http.HandlerFunc {
+// UnknownNodesResponse is the 400 body for a collection listing xnames that no node has
+type UnknownNodesResponse struct {
+	*response.ErrResponse
+	UnknownNodes []string `json:"unknown_nodes"`
+}
+
+// unknownNodes returns the xnames of members that no stored node has
+func unknownNodes(storage storage.NodeStorage, members []xnames.NodeXname) []string {
+	var unknown []string
+	for _, member := range members {
+		if _, err := storage.LookupComputeNodeByXName(member.String()); err != nil {
+			unknown = append(unknown, member.String())
+		}
+	}
+	return unknown
+}
+
+// checkCollectionNodes refuses a collection with members that aren't nodes, unless lenient
+// is set, in which case they are only logged.  It renders the error response and returns
+// false if the collection is refused.
+func checkCollectionNodes(w http.ResponseWriter, r *http.Request, storage storage.NodeStorage, collection *nodes.NodeCollection, lenient bool) bool {
+	unknown := unknownNodes(storage, collection.Nodes)
+	if len(unknown) == 0 {
+		return true
+	}
+	if lenient {
+		log.Warn().
+			Str("collection", collection.Name).
+			Strs("unknown_nodes", unknown).
+			Str("request_id", middleware.GetReqID(r.Context())).
+			Msg("Collection lists xnames without a node")
+		return true
+	}
+	render.Render(w, r, UnknownNodesResponse{
+		ErrResponse:  response.ErrInvalidRequest(fmt.Errorf("%d of the collection's nodes do not exist", len(unknown))),
+		UnknownNodes: unknown,
+	})
+	return false
+}
+
+func createCollection(manager *nodes.CollectionManager, storage storage.NodeStorage, lenient bool, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var collection nodes.NodeCollection
 		if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
 			log.Error().
 				Err(fmt.Errorf("error binding collection: %v", err)).
 				Msg("Error binding collection")
-			render.Render(w, r, ErrInvalidRequest(err))
+			render.Render(w, r, response.ErrInvalidRequest(err))
 			return
 		}
-		claims, err := extract_claims(r)
+		if err := checkCollectionType(&collection); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if !checkCollectionNodes(w, r, storage, &collection, lenient) {
+			return
+		}
+		subject, err := subjectClaim(r)
 		if err != nil {
 			log.Error().
 				Err(fmt.Errorf("error extracting claims: %w", err)).
 				Msg("Error extracting claims")
+			render.Render(w, r, response.ErrUnauthorized(err))
+			return
 		}
 
-		collection.CreatorSubject = claims["sub"].(string)
+		collection.CreatorSubject = subject
 
 		if err := manager.CreateCollection(&collection); err != nil {
-			render.Render(w, r, ErrInvalidRequest(err))
+			render.Render(w, r, collectionError(err))
 			return
 		}
 		log.Info().
@@ -47,14 +99,27 @@ func createCollection(manager *nodes.CollectionManager) http.HandlerFunc {
 			Strs("nodes", xnames.XnameSliceString(collection.Nodes)).
 			Str("request_id", middleware.GetReqID(r.Context())).
 			Str("request_uri", r.RequestURI).
-			Str("jwt_subject", claims["sub"].(string)).
+			Str("jwt_subject", subject).
 			Msg("Collection created")
+		broker.Publish(collectionEvent(events.ActionCreated, collection))
 
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, collection)
 	}
 }
 
+// checkCollectionType defaults an empty type to ad-hoc and rejects unknown types, which
+// would otherwise silently escape the constraints of the type they were meant to be.
+func checkCollectionType(collection *nodes.NodeCollection) error {
+	if collection.Type == "" {
+		collection.Type = nodes.DefaultType
+	}
+	if !collection.Type.Valid() {
+		return fmt.Errorf("unknown collection type %q", collection.Type)
+	}
+	return nil
+}
+
 func extract_claims(r *http.Request) (map[string]interface{}, error) {
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
@@ -63,41 +128,65 @@ func extract_claims(r *http.Request) (map[string]interface{}, error) {
 	return claims, nil
 }
 
+// subjectClaim returns the sub claim of the request's token.  A missing token or a sub that
+// is absent or not a string is an error rather than a panic.
+func subjectClaim(r *http.Request) (string, error) {
+	claims, err := extract_claims(r)
+	if err != nil {
+		return "", err
+	}
+	subject, ok := claims["sub"].(string)
+	if !ok || subject == "" {
+		return "", fmt.Errorf("token has no sub claim")
+	}
+	return subject, nil
+}
+
 func getCollection(manager *nodes.CollectionManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		identifier := chi.URLParam(r, "identifier")
 		collection, exists := manager.GetCollection(identifier)
 		if !exists {
-			http.Error(w, "Collection not found", http.StatusNotFound)
+			render.Render(w, r, response.ErrNotFound(errors.New("collection not found")))
 			return
 		}
 		render.JSON(w, r, collection)
 	}
 }
 
-func updateCollection(manager *nodes.CollectionManager) http.HandlerFunc {
+func updateCollection(manager *nodes.CollectionManager, storage storage.NodeStorage, lenient bool, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		identifier := chi.URLParam(r, "identifier")
-		claims, err := extract_claims(r)
+		subject, err := subjectClaim(r)
 		if err != nil {
 			log.Error().Err(err).Msg("Error extracting claims")
+			render.Render(w, r, response.ErrUnauthorized(err))
+			return
 		}
+		// render.Bind would decode the body once and NodeCollection.Bind again, finding it empty
 		var collection nodes.NodeCollection
-		if err := render.Bind(r, &collection); err != nil {
-			render.Render(w, r, ErrInvalidRequest(err))
+		if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if err := checkCollectionType(&collection); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if !checkCollectionNodes(w, r, storage, &collection, lenient) {
 			return
 		}
 
 		existingCollection, exists := manager.GetCollection(identifier)
 		if !exists {
-			http.Error(w, "Collection not found", http.StatusNotFound)
+			render.Render(w, r, response.ErrNotFound(errors.New("collection not found")))
 			return
 		}
 
 		collection.ID = existingCollection.ID // Ensure the ID remains the same
 
 		if err := manager.UpdateCollection(&collection); err != nil {
-			render.Render(w, r, ErrInvalidRequest(err))
+			render.Render(w, r, collectionError(err))
 			return
 		}
 		log.Info().
@@ -109,61 +198,41 @@ func updateCollection(manager *nodes.CollectionManager) http.HandlerFunc {
 			Strs("nodes", xnames.XnameSliceString(collection.Nodes)).
 			Str("request_id", middleware.GetReqID(r.Context())).
 			Str("request_uri", r.RequestURI).
-			Str("jwt_subject", claims["sub"].(string)).
+			Str("jwt_subject", subject).
 			Msg("Collection updated")
+		broker.Publish(collectionEvent(events.ActionUpdated, collection))
 
 		render.Status(r, http.StatusOK)
 		render.JSON(w, r, collection)
 	}
 }
 
-func deleteCollection(manager *nodes.CollectionManager) http.HandlerFunc {
+func deleteCollection(manager *nodes.CollectionManager, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Resolve the identifier like getCollection does, so a name or alias works as well as the ID
 		identifier := chi.URLParam(r, "identifier")
-		identifierUUID, err := uuid.Parse(identifier)
-		if err != nil {
-			log.Error().Err(err).Msg("Error parsing identifier")
-			render.Render(w, r, ErrInvalidRequest(err))
+		collection, exists := manager.GetCollection(identifier)
+		if !exists {
+			render.Render(w, r, response.ErrNotFound(errors.New("collection not found")))
 			return
 		}
 
-		if err := manager.DeleteCollection(identifierUUID); err != nil {
+		if err := manager.DeleteCollection(collection.ID); err != nil {
 			log.Error().Err(err).Msg("Error deleting collection")
-			render.Render(w, r, ErrInternalServer)
+			render.Render(w, r, response.ErrInternalServer(err))
 			return
 		}
+		broker.Publish(collectionEvent(events.ActionDeleted, nodes.NodeCollection{ID: collection.ID}))
 
-		render.Status(r, http.StatusNoContent)
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// ErrResponse renderer type for handling all sorts of errors.
-//
-// In the best case scenario, the excellent github.com/pkg/errors package
-// helps reveal information on the error, setting it on Err, and in the Render()
-// method, using it to set the application-specific error code in AppCode.
-type ErrResponse struct {
-	Err            error `json:"-"` // low-level runtime error
-	HTTPStatusCode int   `json:"-"` // http response status code
-
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
-}
-
-func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
-	render.Status(r, e.HTTPStatusCode)
-	return nil
-}
-
-func ErrInvalidRequest(err error) render.Renderer {
-	return &ErrResponse{
-		Err:            err,
-		HTTPStatusCode: 400,
-		StatusText:     "Invalid request.",
-		ErrorText:      err.Error(),
+// collectionError is the response to a collection the manager refused: a conflict for a name
+// or alias that is taken or a node that another partition or tenant has, a bad request otherwise
+func collectionError(err error) render.Renderer {
+	if errors.Is(err, nodes.ErrNameInUse) || errors.Is(err, nodes.ErrNodeInOtherCollection) {
+		return response.ErrConflict(err)
 	}
+	return response.ErrInvalidRequest(err)
 }
-
-var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found."}
-var ErrInternalServer = &ErrResponse{HTTPStatusCode: 500, StatusText: "Internal server error."}
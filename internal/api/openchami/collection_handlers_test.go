@@ -0,0 +1,330 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+	"github.com/rs/zerolog"
+)
+
+func TestCreateCollectionType(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(nil, nil))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/inventory/NodeCollection", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"name": "typo", "type": "partiton", "nodes": []}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown type, got %d", rec.Code)
+	}
+
+	rec := post(`{"name": "untyped", "nodes": []}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var collection nodes.NodeCollection
+	if err := json.NewDecoder(rec.Body).Decode(&collection); err != nil {
+		t.Fatalf("failed to decode collection: %v", err)
+	}
+	if collection.Type != nodes.DefaultType {
+		t.Errorf("expected an empty type to default to %q, got %q", nodes.DefaultType, collection.Type)
+	}
+}
+
+func TestCreateCollectionWithoutSubject(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(nil, nil))
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		{name: "no token"},
+		{name: "missing sub", claims: map[string]interface{}{"iss": "test"}},
+		{name: "non-string sub", claims: map[string]interface{}{"sub": 42}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/inventory/NodeCollection", strings.NewReader(`{"name": "compute", "nodes": []}`))
+			if tt.claims != nil {
+				_, token, _ := tokenAuth.Encode(tt.claims)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteNodeInCollection(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	node := createNode(t, r, "x1000c0s1b0n0")
+	other := createNode(t, r, "x1000c0s1b0n1")
+
+	req := httptest.NewRequest(http.MethodPost, "/inventory/NodeCollection", strings.NewReader(`{"name": "compute", "type": "job", "nodes": ["x1000c0s1b0n0", "x1000c0s1b0n1"]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 creating the collection, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	del := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+		return rec
+	}
+
+	rec = del("/inventory/ComputeNode/" + node.ID.String())
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting a node in a collection, got %d", rec.Code)
+	}
+	var conflict NodeInCollectionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&conflict); err != nil {
+		t.Fatalf("failed to decode conflict: %v", err)
+	}
+	if len(conflict.Collections) != 1 || conflict.Collections[0].Name != "compute" {
+		t.Errorf("expected the conflict to list the compute collection, got %+v", conflict.Collections)
+	}
+	if _, err := store.GetComputeNode(node.ID); err != nil {
+		t.Errorf("expected the node to survive a refused delete: %v", err)
+	}
+
+	if rec := del("/inventory/ComputeNode/" + node.ID.String() + "?force=true"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a forced delete, got %d", rec.Code)
+	}
+	if _, err := store.GetComputeNode(node.ID); err == nil {
+		t.Errorf("expected the node to be deleted")
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/NodeCollection/compute", nil))
+	var collection nodes.NodeCollection
+	if err := json.NewDecoder(rec.Body).Decode(&collection); err != nil {
+		t.Fatalf("failed to decode collection: %v", err)
+	}
+	if len(collection.Nodes) != 1 || collection.Nodes[0].String() != other.XName.String() {
+		t.Errorf("expected only %s to remain in the collection, got %v", other.XName, collection.Nodes)
+	}
+}
+
+func TestCollectionUnknownNodes(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+	store := memory.NewInMemoryStorage()
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001", XName: xnames.NewNodeXname("x1000c0s1b0n0")}
+	store.SaveComputeNode(node.ID, node)
+
+	for _, lenient := range []bool{false, true} {
+		r := chi.NewRouter()
+		r.Use(jwtauth.Verifier(tokenAuth))
+		r.Mount("/inventory", NodeRoutes(store, nil, WithLenientCollectionNodes(lenient)))
+
+		send := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		phantom := `{"name": "compute", "nodes": ["x1000c0s1b0n0", "x1000c0s9b0n0"]}`
+		rec := send(http.MethodPost, "/inventory/NodeCollection", phantom)
+		if lenient {
+			if rec.Code != http.StatusCreated {
+				t.Errorf("expected 201 for unknown nodes when lenient, got %d: %s", rec.Code, rec.Body.String())
+			}
+			continue
+		}
+		var body UnknownNodesResponse
+		if rec.Code != http.StatusBadRequest || json.NewDecoder(rec.Body).Decode(&body) != nil ||
+			len(body.UnknownNodes) != 1 || body.UnknownNodes[0] != "x1000c0s9b0n0" {
+			t.Errorf("expected 400 listing x1000c0s9b0n0, got %d %+v", rec.Code, body)
+		}
+
+		if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "compute", "nodes": ["x1000c0s1b0n0"]}`); rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for known nodes, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec := send(http.MethodPut, "/inventory/NodeCollection/compute", phantom); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 updating to unknown nodes, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// A node is in at most one partition and one tenant, while ad-hoc collections overlap freely
+func TestExclusiveCollectionTypes(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(memory.NewInMemoryStorage(), nil, WithLenientCollectionNodes(true)))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "p1", "type": "partition", "nodes": ["x1000c0s1b0n0", "x1000c0s1b0n1"]}`, http.StatusCreated},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "p2", "type": "partition", "nodes": ["x1000c0s2b0n0", "x1000c0s1b0n1"]}`, http.StatusConflict},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "p3", "type": "partition", "nodes": ["x1000c0s2b0n0"]}`, http.StatusCreated},
+		{http.MethodPut, "/inventory/NodeCollection/p3", `{"name": "p3", "type": "partition", "nodes": ["x1000c0s2b0n0", "x1000c0s1b0n0"]}`, http.StatusConflict},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "t1", "type": "tenant", "nodes": ["x1000c0s1b0n1"]}`, http.StatusCreated},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "a1", "nodes": ["x1000c0s1b0n1"]}`, http.StatusCreated},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "a2", "nodes": ["x1000c0s1b0n1"]}`, http.StatusCreated},
+		// Updating a partition doesn't conflict with its own nodes, and frees the ones it drops
+		{http.MethodPut, "/inventory/NodeCollection/p1", `{"name": "p1", "type": "partition", "nodes": ["x1000c0s1b0n0"]}`, http.StatusOK},
+		{http.MethodPost, "/inventory/NodeCollection", `{"name": "p2", "type": "partition", "nodes": ["x1000c0s1b0n1"]}`, http.StatusCreated},
+		// Deleting a partition frees its nodes
+		{http.MethodDelete, "/inventory/NodeCollection/p1", "", http.StatusNoContent},
+		{http.MethodPut, "/inventory/NodeCollection/p3", `{"name": "p3", "type": "partition", "nodes": ["x1000c0s2b0n0", "x1000c0s1b0n0"]}`, http.StatusOK},
+	} {
+		if rec := send(tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tt.method, tt.path, tt.body, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestDeleteCollectionByName(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(nil, nil))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, identifier := range []string{"compute", "batch"} {
+		if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "compute", "alias": "batch", "nodes": []}`); rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec := send(http.MethodDelete, "/inventory/NodeCollection/"+identifier, ""); rec.Code != http.StatusNoContent {
+			t.Errorf("expected 204 deleting by %s, got %d: %s", identifier, rec.Code, rec.Body.String())
+		}
+		if rec := send(http.MethodGet, "/inventory/NodeCollection/compute", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected the collection deleted by %s to be gone, got %d", identifier, rec.Code)
+		}
+	}
+
+	if rec := send(http.MethodDelete, "/inventory/NodeCollection/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting an unknown collection, got %d", rec.Code)
+	}
+	// A name another collection goes by as its alias is taken
+	if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "compute", "alias": "batch", "nodes": []}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "batch", "nodes": []}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a name taken as an alias, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSearchNodesByCollection(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+
+	store := memory.NewInMemoryStorage()
+	for i, xname := range []string{"x1000c0s1b0n0", "x1000c0s1b0n1", "x1000c0s2b0n0"} {
+		node := nodes.ComputeNode{ID: uuid.New(), NID: i + 1, XName: xnames.NewNodeXname(xname)}
+		store.SaveComputeNode(node.ID, node)
+	}
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "compute", "alias": "batch", "nodes": ["x1000c0s1b0n0", "x1000c0s2b0n0"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 creating the collection, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "/inventory/NodeCollection", `{"name": "empty", "nodes": []}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 creating the collection, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	search := func(query string) []string {
+		t.Helper()
+		rec := send(http.MethodGet, "/inventory/ComputeNode?"+query, "")
+		var found response.PagedResponse[nodes.ComputeNode]
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&found) != nil {
+			t.Fatalf("unexpected response for %s, %d: %s", query, rec.Code, rec.Body.String())
+		}
+		names := make([]string, len(found.Items))
+		for i, node := range found.Items {
+			names[i] = node.XName.String()
+		}
+		sort.Strings(names)
+		return names
+	}
+	if got := search("collection=batch"); len(got) != 2 || got[0] != "x1000c0s1b0n0" || got[1] != "x1000c0s2b0n0" {
+		t.Errorf("expected the two members of the collection, got %v", got)
+	}
+	if got := search("collection=compute&nid=3"); len(got) != 1 || got[0] != "x1000c0s2b0n0" {
+		t.Errorf("expected the collection combined with other filters, got %v", got)
+	}
+	if got := search("collection=empty"); len(got) != 0 {
+		t.Errorf("expected no nodes for an empty collection, got %v", got)
+	}
+	if rec := send(http.MethodGet, "/inventory/ComputeNode?collection=missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown collection, got %d", rec.Code)
+	}
+}
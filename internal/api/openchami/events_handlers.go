@@ -0,0 +1,139 @@
+package openchami
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog/log"
+)
+
+// eventKeepalive is how often an idle stream gets a comment, so that proxies don't close it
+const eventKeepalive = 15 * time.Second
+
+// RouterOption configures the node routes
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	broker                 *events.Broker
+	lenientCollectionNodes bool
+}
+
+// WithEvents publishes every change made through the node routes to broker
+func WithEvents(broker *events.Broker) RouterOption {
+	return func(c *routerConfig) {
+		c.broker = broker
+	}
+}
+
+// WithLenientCollectionNodes accepts collections listing xnames that no node has, logging a
+// warning instead of refusing them
+func WithLenientCollectionNodes(lenient bool) RouterOption {
+	return func(c *routerConfig) {
+		c.lenientCollectionNodes = lenient
+	}
+}
+
+func newRouterConfig(opts []RouterOption) routerConfig {
+	var config routerConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+func nodeEvent(action string, node nodes.ComputeNode) events.Event {
+	e := events.Event{Type: events.TypeNode, Action: action, ID: node.ID.String()}
+	if action != events.ActionDeleted {
+		e.Data = node.Redacted()
+	}
+	return e
+}
+
+func bmcEvent(action string, bmc nodes.BMC) events.Event {
+	e := events.Event{Type: events.TypeBMC, Action: action, ID: bmc.ID.String()}
+	if action != events.ActionDeleted {
+		e.Data = bmc.Redacted()
+	}
+	return e
+}
+
+func collectionEvent(action string, collection nodes.NodeCollection) events.Event {
+	e := events.Event{Type: events.TypeCollection, Action: action, ID: collection.ID.String()}
+	if action != events.ActionDeleted {
+		e.Data = collection
+	}
+	return e
+}
+
+// streamEvents sends the events of broker as Server-Sent Events until the client goes away or
+// the broker is closed.  type=node limits the stream to node events and may be repeated or
+// comma separated.
+func streamEvents(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var types []string
+		for _, param := range r.URL.Query()["type"] {
+			for _, t := range strings.Split(param, ",") {
+				switch t {
+				case events.TypeNode, events.TypeBMC, events.TypeCollection:
+					types = append(types, t)
+				default:
+					response.Error(w, r, fmt.Sprintf("invalid type %q", t), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			response.Error(w, r, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		stream, cancel := broker.Subscribe(types...)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		// Lets the client know it is subscribed before the first event
+		fmt.Fprint(w, ": subscribed\n\n")
+		flusher.Flush()
+
+		keepalive := time.NewTicker(eventKeepalive)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			case e, ok := <-stream:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					log.Error().Err(err).Str("event", e.Name()).Msg("Error encoding event")
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name(), data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// EventRoutes serves the stream of the changes published to broker
+func EventRoutes(broker *events.Broker) chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", streamEvents(broker))
+	return r
+}
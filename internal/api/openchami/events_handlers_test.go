@@ -0,0 +1,72 @@
+package openchami
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/rs/zerolog"
+)
+
+func TestStreamEvents(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	broker := events.NewBroker()
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(store, nil, WithEvents(broker)))
+	r.Mount("/events", EventRoutes(broker))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?type=foo")
+	if err != nil {
+		t.Fatalf("failed to request events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown type, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/events?type=node")
+	if err != nil {
+		t.Fatalf("failed to subscribe to events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": subscribed\n" {
+		t.Fatalf("expected the subscription comment, got %q", line)
+	}
+
+	// The BMC is filtered out, so the node is the first event
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/bmc", strings.NewReader(`{"mac_address": "de:ad:be:ef:00:01"}`)))
+	node := createNode(t, r, "x1000c0s1b0n0")
+
+	reader.ReadString('\n')
+	if line, _ := reader.ReadString('\n'); line != "event: node.created\n" {
+		t.Fatalf("expected a node.created event, got %q", line)
+	}
+	line, _ := reader.ReadString('\n')
+	var e events.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+		t.Fatalf("failed to decode event %q: %v", line, err)
+	}
+	if e.ID != node.ID.String() || e.Data == nil {
+		t.Errorf("expected the created node, got %+v", e)
+	}
+}
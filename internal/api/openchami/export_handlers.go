@@ -0,0 +1,109 @@
+package openchami
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// nodeExportColumns are the columns of a CSV export.  They include every column of an import,
+// so an export can be imported into another inventory as is: the nodes keep their NIDs but
+// get new IDs, as the id column is ignored.
+var nodeExportColumns = []string{"id", "hostname", "xname", "nid", "architecture", "boot_mac", "boot_ipv4_address", "bmc_mac", "bmc_ip"}
+
+func nodeExportRecord(node nodes.ComputeNode) []string {
+	var bmcMac, bmcIP string
+	if node.BMC != nil {
+		bmcMac, bmcIP = node.BMC.MACAddress, node.BMC.IPv4Address
+	}
+	return []string{
+		node.ID.String(),
+		node.Hostname,
+		node.XName.String(),
+		strconv.Itoa(node.NID),
+		node.Architecture,
+		node.BootMac,
+		node.BootIPv4Address,
+		bmcMac,
+		bmcIP,
+	}
+}
+
+// exportNodes streams the nodes matching the ComputeNode search parameters as CSV
+// (format=csv, the default) or as one redacted JSON node per line (format=jsonl).  Nodes are
+// written as they are read from storage, so an error after the first node can only cut the
+// export short.
+func exportNodes(myStorage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		searchOptions, err := nodeSearchOptions(query)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var write func(nodes.ComputeNode) error
+		var flush func() error
+		switch format := query.Get("format"); format {
+		case "", "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="nodes.csv"`)
+			writer := csv.NewWriter(w)
+			write = func(node nodes.ComputeNode) error {
+				return writer.Write(nodeExportRecord(node))
+			}
+			flush = func() error {
+				writer.Flush()
+				return writer.Error()
+			}
+			if err := writer.Write(nodeExportColumns); err != nil {
+				return
+			}
+		case "jsonl":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="nodes.jsonl"`)
+			encoder := json.NewEncoder(w)
+			write = func(node nodes.ComputeNode) error {
+				return encoder.Encode(node.Redacted())
+			}
+			flush = func() error { return nil }
+		default:
+			response.Error(w, r, "format must be csv or jsonl, not "+format, http.StatusBadRequest)
+			return
+		}
+
+		count := 0
+		err = myStorage.StreamComputeNodes(func(node nodes.ComputeNode) error {
+			count++
+			return write(node)
+		}, searchOptions...)
+		if err != nil && count == 0 {
+			// Nothing has reached the client yet, the CSV header is still buffered
+			log.Error().Err(err).Msg("Error exporting nodes")
+			w.Header().Del("Content-Disposition")
+			storageError(w, r, err, "error exporting nodes", http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			log.Error().Err(err).Int("num_nodes", count).Msg("Error exporting nodes")
+		}
+
+		if requestLogger, ok := r.Context().Value(openchami_middleware.LoggerKey).(*zerolog.Logger); ok {
+			*requestLogger = requestLogger.With().
+				Int("num_nodes", count).
+				Str("event_type", "export_nodes").
+				Logger()
+		}
+	}
+}
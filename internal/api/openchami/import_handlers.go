@@ -0,0 +1,195 @@
+package openchami
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog"
+)
+
+// nodeImportColumns are the columns a node import CSV must have in its header, in any order
+var nodeImportColumns = []string{"hostname", "xname", "architecture", "boot_mac", "bmc_mac", "bmc_ip"}
+
+// NodeImportResult reports what happened to one row of an import
+type NodeImportResult struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// NodeImportResponse summarizes an import, row by row
+type NodeImportResponse struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Results []NodeImportResult `json:"results"`
+}
+
+// importNodes creates a node for each row of a CSV body:
+//
+//	hostname,xname,architecture,boot_mac,bmc_mac,bmc_ip
+//	nid001,x1000c0s0b0n0,x86_64,de:ad:be:ef:00:01,de:ad:be:ef:10:01,172.16.0.1
+//
+// Each row is checked and created the same way as the body of POST /ComputeNode, including
+// ?allow_bmc_mismatch, and a bad row does not stop the rows after it.  An optional nid column
+// keeps the NIDs of an export, and any other column, such as the id of an export, is ignored.
+// The response lists the ID or error of every row by its line number and is a 201 when every
+// row was created or a 207 when any failed.
+func importNodes(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+			response.Error(w, r, "Content-Type must be text/csv", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		reader := csv.NewReader(r.Body)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("missing CSV header")
+			}
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		columns, err := nodeImportHeader(header)
+		if err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+
+		result := NodeImportResponse{Results: []NodeImportResult{}}
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if openchami_middleware.BodyErrorStatus(err) == http.StatusRequestEntityTooLarge {
+				render.Render(w, r, response.ErrInvalidRequest(err))
+				return
+			}
+			if err != nil {
+				row := NodeImportResult{Error: err.Error()}
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					row.Line = parseErr.StartLine
+				}
+				result.Failed++
+				result.Results = append(result.Results, row)
+				// A row with the wrong number of fields is skipped, anything else leaves the reader lost
+				if errors.Is(err, csv.ErrFieldCount) {
+					continue
+				}
+				break
+			}
+
+			line, _ := reader.FieldPos(0)
+			row := NodeImportResult{Line: line}
+			node, err := nodeFromImportRecord(columns, record)
+			if err == nil && node.BMC != nil && r.URL.Query().Get("allow_bmc_mismatch") != "true" {
+				if mismatches := bmcXNameMismatches(node.XName, node.BMC.XName); len(mismatches) > 0 {
+					err = fmt.Errorf("BMC %s does not match the hierarchy of node %s", node.BMC.XName, node.XName)
+				}
+			}
+			if err == nil {
+				_, err = createComputeNode(storage, &node)
+			}
+			if err != nil {
+				row.Error = err.Error()
+				result.Failed++
+			} else {
+				row.ID = node.ID.String()
+				result.Created++
+				broker.Publish(nodeEvent(events.ActionCreated, node))
+			}
+			result.Results = append(result.Results, row)
+		}
+
+		sublogger := r.Context().Value(openchami_middleware.LoggerKey).(*zerolog.Logger)
+		sublogger.Info().
+			Int("created", result.Created).
+			Int("failed", result.Failed).
+			Str("event_type", "import_nodes").
+			Msg("Imported nodes")
+
+		status := http.StatusCreated
+		if result.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		render.Status(r, status)
+		render.JSON(w, r, result)
+	}
+}
+
+// nodeImportHeader maps each import column to its index in header
+func nodeImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var missing []string
+	for _, name := range nodeImportColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV header is missing columns: %s", strings.Join(missing, ","))
+	}
+	return columns, nil
+}
+
+// nodeFromImportRecord decodes a row as the POST /ComputeNode body with its non-empty fields,
+// so that it is validated like one.  The BMC of a row has no credentials.
+func nodeFromImportRecord(columns map[string]int, record []string) (nodes.ComputeNode, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	body := map[string]interface{}{
+		"hostname":     field("hostname"),
+		"architecture": field("architecture"),
+	}
+	if xname := field("xname"); xname != "" {
+		body["xname"] = xname
+	}
+	if bootMac := field("boot_mac"); bootMac != "" {
+		body["boot_mac"] = bootMac
+	}
+	// A NID that isn't a number is left for the schema to refuse
+	if nid := field("nid"); nid != "" {
+		if n, err := strconv.Atoi(nid); err == nil {
+			body["nid"] = n
+		} else {
+			body["nid"] = nid
+		}
+	}
+	if field("bmc_mac") != "" || field("bmc_ip") != "" {
+		bmc := map[string]interface{}{"mac_address": field("bmc_mac"), "username": "", "password": ""}
+		if ip := field("bmc_ip"); ip != "" {
+			bmc["ipv4_address"] = ip
+		}
+		body["bmc"] = bmc
+	}
+
+	var node nodes.ComputeNode
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return node, err
+	}
+	return node, validateNode(raw, &node)
+}
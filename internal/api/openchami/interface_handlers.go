@@ -0,0 +1,166 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog/log"
+)
+
+// postNodeInterface adds the network interface in the body to a node.  Its MAC address may not
+// be in use by the node or any other node.
+func postNodeInterface(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		var nic nodes.NetworkInterface
+		if !decodeInterface(w, r, &nic, "") {
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		if interfaceIndex(node, nic.MACAddress) >= 0 {
+			response.Error(w, r, "node already has an interface with MAC "+nic.MACAddress, http.StatusConflict)
+			return
+		}
+		if !checkMACUnused(w, r, storage, nodeID, nic.MACAddress) {
+			return
+		}
+
+		node.NetworkInterfaces = append(node.NetworkInterfaces, nic)
+		render.Status(r, http.StatusCreated)
+		saveNode(w, r, storage, broker, node)
+	}
+}
+
+// putNodeInterface replaces the node's network interface on the MAC address in the URL.  A body
+// without a MAC address keeps the one in the URL, and a new one has to be unused like in
+// postNodeInterface.
+func putNodeInterface(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		mac := nodes.CanonicalMAC(chi.URLParam(r, "mac"))
+		var nic nodes.NetworkInterface
+		if !decodeInterface(w, r, &nic, mac) {
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		i := interfaceIndex(node, mac)
+		if i < 0 {
+			response.Error(w, r, "node has no interface with MAC "+mac, http.StatusNotFound)
+			return
+		}
+		if !strings.EqualFold(nic.MACAddress, mac) {
+			if interfaceIndex(node, nic.MACAddress) >= 0 {
+				response.Error(w, r, "node already has an interface with MAC "+nic.MACAddress, http.StatusConflict)
+				return
+			}
+			if !checkMACUnused(w, r, storage, nodeID, nic.MACAddress) {
+				return
+			}
+		}
+
+		node.NetworkInterfaces[i] = nic
+		saveNode(w, r, storage, broker, node)
+	}
+}
+
+// deleteNodeInterface removes the node's network interface on the MAC address in the URL
+func deleteNodeInterface(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		mac := nodes.CanonicalMAC(chi.URLParam(r, "mac"))
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		i := interfaceIndex(node, mac)
+		if i < 0 {
+			response.Error(w, r, "node has no interface with MAC "+mac, http.StatusNotFound)
+			return
+		}
+
+		node.NetworkInterfaces = slices.Delete(node.NetworkInterfaces, i, i+1)
+		saveNode(w, r, storage, broker, node)
+	}
+}
+
+// decodeInterface decodes the network interface in the request body, taking defaultMAC as its
+// MAC address if it has none, and checks its addresses.  It renders the error response and
+// returns false if the body is unusable.
+func decodeInterface(w http.ResponseWriter, r *http.Request, nic *nodes.NetworkInterface, defaultMAC string) bool {
+	if err := json.NewDecoder(r.Body).Decode(nic); err != nil {
+		render.Render(w, r, response.ErrInvalidRequest(err))
+		return false
+	}
+	if nic.MACAddress == "" {
+		nic.MACAddress = defaultMAC
+	}
+	if nic.MACAddress == "" {
+		response.Error(w, r, "mac_address is required", http.StatusBadRequest)
+		return false
+	}
+	return checkFormats(w, r, "network interface", nic)
+}
+
+// interfaceIndex is the position of the node's network interface on mac, in any case, or -1
+func interfaceIndex(node nodes.ComputeNode, mac string) int {
+	return slices.IndexFunc(node.NetworkInterfaces, func(nic nodes.NetworkInterface) bool {
+		return strings.EqualFold(nic.MACAddress, mac)
+	})
+}
+
+// checkMACUnused renders a 409 and returns false if a node other than nodeID has an interface
+// on mac or boots from it
+func checkMACUnused(w http.ResponseWriter, r *http.Request, myStorage storage.NodeStorage, nodeID uuid.UUID, mac string) bool {
+	found, err := myStorage.SearchComputeNodes(storage.WithNICMAC(mac))
+	if err != nil {
+		log.Error().Err(err).Msg("Error searching nodes by NIC MAC")
+		storageError(w, r, err, "error searching nodes", http.StatusInternalServerError)
+		return false
+	}
+	booting, err := myStorage.LookupComputeNodesByMACAddresses([]string{mac})
+	if err != nil {
+		log.Error().Err(err).Msg("Error looking up nodes by MAC address")
+		storageError(w, r, err, "error looking up nodes", http.StatusInternalServerError)
+		return false
+	}
+	for _, other := range booting {
+		found = append(found, other)
+	}
+	for _, other := range found {
+		if other.ID != nodeID {
+			response.Error(w, r, "Compute Node "+other.ID.String()+" already uses MAC "+mac, http.StatusConflict)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+func TestNodeInterfaces(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001", NetworkInterfaces: []nodes.NetworkInterface{
+		{InterfaceName: "eth0", MACAddress: "de:ad:be:ef:00:01"},
+	}}
+	other := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid002", BootMac: "de:ad:be:ef:00:20", NetworkInterfaces: []nodes.NetworkInterface{
+		{InterfaceName: "eth0", MACAddress: "de:ad:be:ef:00:21"},
+	}}
+	store.SaveComputeNode(node.ID, node)
+	store.SaveComputeNode(other.ID, other)
+	r := chi.NewRouter()
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, "/inventory/ComputeNode/"+node.ID.String()+"/interfaces"+path, strings.NewReader(body)))
+		return rec
+	}
+	interfaces := func(rec *httptest.ResponseRecorder) []nodes.NetworkInterface {
+		t.Helper()
+		var updated nodes.ComputeNode
+		if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+			t.Fatalf("failed to decode node: %v", err)
+		}
+		return updated.NetworkInterfaces
+	}
+
+	rec := send(http.MethodPost, "", `{"interface_name": "eth1", "mac_address": "de:ad:be:ef:00:02", "ipv4_address": "10.0.0.2"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 adding an interface, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if nics := interfaces(rec); len(nics) != 2 || nics[1].IPv4Address != "10.0.0.2" {
+		t.Errorf("expected the interface to be added, got %+v", nics)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"malformed MAC", http.MethodPost, "", `{"interface_name": "eth2", "mac_address": "not-a-mac"}`, http.StatusBadRequest},
+		{"malformed IPv4", http.MethodPost, "", `{"interface_name": "eth2", "mac_address": "de:ad:be:ef:00:03", "ipv4_address": "10.0.0"}`, http.StatusBadRequest},
+		{"missing MAC", http.MethodPost, "", `{"interface_name": "eth2"}`, http.StatusBadRequest},
+		{"MAC of this node", http.MethodPost, "", `{"interface_name": "eth2", "mac_address": "DE:AD:BE:EF:00:01"}`, http.StatusConflict},
+		{"NIC MAC of another node", http.MethodPost, "", `{"interface_name": "eth2", "mac_address": "de:ad:be:ef:00:21"}`, http.StatusConflict},
+		{"boot MAC of another node", http.MethodPost, "", `{"interface_name": "eth2", "mac_address": "de:ad:be:ef:00:20"}`, http.StatusConflict},
+		{"moved onto another node's MAC", http.MethodPut, "/de:ad:be:ef:00:02", `{"interface_name": "eth1", "mac_address": "de:ad:be:ef:00:21"}`, http.StatusConflict},
+		{"unknown interface", http.MethodPut, "/de:ad:be:ef:00:09", `{"interface_name": "eth9"}`, http.StatusNotFound},
+		{"delete unknown interface", http.MethodDelete, "/de:ad:be:ef:00:09", "", http.StatusNotFound},
+	} {
+		if rec := send(tt.method, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The MAC in the URL is kept when the body leaves it out
+	rec = send(http.MethodPut, "/DE:AD:BE:EF:00:02", `{"interface_name": "eth1", "ipv6_address": "fd00::2"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 replacing an interface, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if nics := interfaces(rec); len(nics) != 2 || nics[1].IPv6Address != "fd00::2" || nics[1].IPv4Address != "" || !strings.EqualFold(nics[1].MACAddress, "de:ad:be:ef:00:02") {
+		t.Errorf("expected the interface to be replaced, got %+v", nics)
+	}
+
+	rec = send(http.MethodDelete, "/de:ad:be:ef:00:01", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 deleting an interface, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, _ := store.GetComputeNode(node.ID)
+	if len(stored.NetworkInterfaces) != 1 || stored.NetworkInterfaces[0].InterfaceName != "eth1" {
+		t.Errorf("expected only eth1 to be left, got %+v", stored.NetworkInterfaces)
+	}
+}
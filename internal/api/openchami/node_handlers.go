@@ -2,13 +2,20 @@ package openchami
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/events"
 	"github.com/openchami/node-orchestrator/internal/storage"
 	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
@@ -24,81 +31,111 @@ func mustInt(i int, e error) int {
 	return i
 }
 
-func postNode(storage storage.NodeStorage) http.HandlerFunc {
+// inferBMCXName derives the xname of the BMC that manages a node.  Nodes on a multi-node
+// blade share a BMC, so the node position is dropped here and only carried by the node xname.
+func inferBMCXName(nodeXName xnames.NodeXname) xnames.BMCXname {
+	components := xnames.XNameComponents{
+		Cabinet:     mustInt(nodeXName.Cabinet()),
+		Chassis:     mustInt(nodeXName.Chassis()),
+		Slot:        mustInt(nodeXName.Slot()),
+		BMCPosition: mustInt(nodeXName.BMCPosition()),
+	}
+	return xnames.NewBMCXname(components.BMCXName())
+}
+
+// XNameMismatch is a component of a BMC xname that differs from the node the BMC is given to
+type XNameMismatch struct {
+	Component string `json:"component"`
+	Node      int    `json:"node"`
+	BMC       int    `json:"bmc"`
+}
+
+// BMCMismatchResponse is the 400 body for a node given a BMC that can't be the one managing it
+type BMCMismatchResponse struct {
+	*response.ErrResponse
+	Mismatches []XNameMismatch `json:"mismatches"`
+}
+
+// bmcXNameMismatches compares the cabinet, chassis, slot and BMC position of a node xname with
+// those of a BMC xname.  Xnames that don't parse are left for the xname validation to refuse.
+func bmcXNameMismatches(node xnames.NodeXname, bmc xnames.BMCXname) []XNameMismatch {
+	n, err := xnames.ParseXName(node.String())
+	if err != nil || n.Type != xnames.TypeNode {
+		return nil
+	}
+	b, err := xnames.ParseXName(bmc.String())
+	if err != nil || b.Type != xnames.TypeBMC {
+		return nil
+	}
+	var mismatches []XNameMismatch
+	for _, c := range []XNameMismatch{
+		{"cabinet", n.Cabinet, b.Cabinet},
+		{"chassis", n.Chassis, b.Chassis},
+		{"slot", n.Slot, b.Slot},
+		{"bmc_position", n.BMCPosition, b.BMCPosition},
+	} {
+		if c.Node != c.BMC {
+			mismatches = append(mismatches, c)
+		}
+	}
+	return mismatches
+}
+
+// postNode creates a ComputeNode and responds with it as stored.  Whenever the node ends up
+// linked to a BMC, whether supplied in the request, matched to an existing BMC by xname or MAC
+// address, or inferred from the node xname, the stored BMC is embedded under "bmc" so clients
+// can reference it by its "id" (and "xname" when it has one) without a second lookup:
+//
+//	{"id": "...", "xname": "x1000c0s7b1n0", ..., "bmc": {"id": "...", "xname": "x1000c0s7b1", ...}}
+//
+// With an Idempotency-Key header or ?upsert=true, creation is keyed on the node xname so that
+// clients can safely retry: re-posting a node that already exists with the same xname answers
+// 200 with the stored node, or 409 if the payload differs from it.  The value of the
+// Idempotency-Key header is not used, only the xname identifies the node being created, so
+// nodes without an xname are always created.
+//
+// A BMC supplied with an xname has to sit in the node's cabinet, chassis and slot at the node's
+// BMC position, or the node is refused with the components that differ.  ?allow_bmc_mismatch=true
+// accepts it anyway, for topologies where a BMC manages nodes outside its own slot.
+func postNode(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var newNode nodes.ComputeNode
 		var nodeXName xnames.NodeXname
 
-		if err := render.DecodeJSON(r.Body, &newNode); err != nil {
-			log.Error().Err(err).Msg("Error decoding request body")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeNode(w, r, &newNode) {
 			return
 		}
-		// If the LocationString validates as an Xname, check if it is valid
-		if newNode.LocationString != "" {
-			nodeXName = xnames.NodeXname{Value: newNode.LocationString}
-			if _, err := nodeXName.Valid(); err != nil {
-				log.Print("Invalid XName ", nodeXName.String(), err)
-				http.Error(w, "Invalid XName "+err.Error(), http.StatusBadRequest)
-				return
-			}
-
-			if _, err := storage.LookupComputeNodeByXName(nodeXName.String()); err == nil {
-				log.Print("Duplicate XName", nodeXName.String())
-				http.Error(w, "Compute Node with the same XName already exists", http.StatusBadRequest)
+		if newNode.BMC != nil && r.URL.Query().Get("allow_bmc_mismatch") != "true" {
+			if mismatches := bmcXNameMismatches(newNode.XName, newNode.BMC.XName); len(mismatches) > 0 {
+				render.Render(w, r, BMCMismatchResponse{
+					ErrResponse: response.ErrInvalidRequest(fmt.Errorf("BMC %s does not match the hierarchy of node %s, use allow_bmc_mismatch=true to accept it", newNode.BMC.XName, newNode.XName)),
+					Mismatches:  mismatches,
+				})
 				return
 			}
 		}
-
-		// Deal with the BMC. If it has been provided already, check if it is valid
-		if newNode.BMC != nil {
-			if newNode.BMC.LocationString != "" && !xnames.IsValidBMCXName(newNode.BMC.LocationString) {
-				http.Error(w, "invalid BMC XName", http.StatusBadRequest)
+		if idempotentCreate(r) && newNode.XName.String() != "" {
+			if existing, err := storage.LookupComputeNodeByXName(newNode.XName.String()); err == nil {
+				if !matchesStoredNode(storage, newNode, existing) {
+					response.Error(w, r, fmt.Sprintf("Compute Node %s already exists with a different payload", newNode.XName), http.StatusConflict)
+					return
+				}
+				render.JSON(w, r, existing.Redacted())
 				return
 			}
-
-			if existingBMC, err := storage.LookupBMCByXName(newNode.BMC.LocationString); err == nil {
-				newNode.BMC.ID = existingBMC.ID
-			} else if existingBMC, err := storage.LookupBMCByMACAddress(newNode.BMC.MACAddress); err == nil {
-				newNode.BMC.ID = existingBMC.ID
-			}
-
-			if newNode.BMC.ID == uuid.Nil {
-				newNode.BMC.ID = uuid.New()
-				storage.SaveBMC(newNode.BMC.ID, *newNode.BMC)
-			}
-		}
-
-		// If the BMC has not been provided, check to see if it can be inferred from the XName and create it if necessary
-		if newNode.BMC == nil && nodeXName.String() != "" {
-			bmcXname := fmt.Sprintf("x%dc%ds%db%d",
-				mustInt(nodeXName.Cabinet()),
-				mustInt(nodeXName.Chassis()),
-				mustInt(nodeXName.Slot()),
-				mustInt(nodeXName.BMCPosition()),
-			)
-			if existingBMC, err := storage.LookupBMCByXName(bmcXname); err == nil {
-				newNode.BMC = &existingBMC
-			}
-			newNode.BMC = &nodes.BMC{
-				ID:             uuid.New(),
-				LocationString: xnames.BMCXname{Value: bmcXname}.String(),
-			}
-			storage.SaveBMC(newNode.BMC.ID, *newNode.BMC)
 		}
-
-		newNode.ID = uuid.New()
-		if err := storage.SaveComputeNode(newNode.ID, newNode); err != nil {
-			log.Print("Error saving node", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if status, err := createComputeNode(storage, &newNode); err != nil {
+			storageError(w, r, err, err.Error(), status)
 			return
 		}
+		nodeXName = newNode.XName
 
 		sublogger := r.Context().Value(openchami_middleware.LoggerKey).(*zerolog.Logger)
 
 		sublog := sublogger.With().
 			Str("node_id", newNode.ID.String()).
 			Str("xname", nodeXName.String()).
+			Int("nid", newNode.NID).
 			Str("hostname", newNode.Hostname).
 			Str("arch", newNode.Architecture).
 			Str("boot_mac", newNode.BootMac).
@@ -108,14 +145,172 @@ func postNode(storage storage.NodeStorage) http.HandlerFunc {
 		if newNode.BMC != nil {
 			sublog.With().
 				Str("bmc_mac", newNode.BMC.MACAddress).
-				Str("bmc_xname", newNode.BMC.LocationString).
+				Str("bmc_xname", newNode.BMC.XName.String()).
 				Str("bmc_id", newNode.BMC.ID.String()).
 				Logger()
 		}
+		broker.Publish(nodeEvent(events.ActionCreated, newNode))
 
 		render.Status(r, http.StatusCreated)
-		render.JSON(w, r, newNode)
+		render.JSON(w, r, newNode.Redacted())
+	}
+}
+
+// idempotentCreate reports whether the client asked for node creation keyed on the xname.
+// Any Idempotency-Key asks for it, whatever its value.
+func idempotentCreate(r *http.Request) bool {
+	return r.Header.Get("Idempotency-Key") != "" || r.URL.Query().Get("upsert") == "true"
+}
+
+// matchesStoredNode reports whether requested describes the stored node.  The fields that
+// createComputeNode fills in, the ID and timestamps, and the NID and lifecycle state when a
+// request leaves them out, are taken from stored before comparing, as are passwords sent back
+// redacted.  The BMC is compared by the ID of the stored BMC that linkBMC would link, and a
+// request without one matches whichever BMC the node has.
+func matchesStoredNode(myStorage storage.NodeStorage, requested, stored nodes.ComputeNode) bool {
+	if requested.BMC != nil {
+		bmc, found := lookupStoredBMC(myStorage, requested.BMC.XName.String(), requested.BMC.MACAddress)
+		if !found || stored.BMC == nil || bmc.ID != stored.BMC.ID {
+			return false
+		}
+	}
+	requested.BMC, stored.BMC = nil, nil
+
+	if arch, err := nodes.NormalizeArchitecture(requested.Architecture); err == nil {
+		requested.Architecture = arch
+	}
+	requested.ID = stored.ID
+	requested.CreatedAt, requested.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	if requested.NID == 0 {
+		requested.NID = stored.NID
+	}
+	if requested.LifecycleState == "" {
+		requested.LifecycleState = stored.LifecycleState
+	}
+	requested.KeepPasswords(stored)
+
+	want, err := json.Marshal(requested)
+	if err != nil {
+		return false
+	}
+	got, err := json.Marshal(stored)
+	if err != nil {
+		return false
+	}
+	return string(want) == string(got)
+}
+
+// createComputeNode validates newNode, links it to its BMC and saves it under a new ID.  On
+// failure it returns the HTTP status that describes the error.
+func createComputeNode(storage storage.NodeStorage, newNode *nodes.ComputeNode) (int, error) {
+	arch, err := nodes.NormalizeArchitecture(newNode.Architecture)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	newNode.Architecture = arch
+	if err := nodes.ValidateLabels(newNode.Labels); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if newNode.LifecycleState == "" {
+		newNode.LifecycleState = nodes.LifecycleDiscovered
+	} else if !newNode.LifecycleState.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("invalid lifecycle state %q", newNode.LifecycleState)
+	}
+	// The timestamps are the server's to set, whatever the request says
+	now := time.Now()
+	newNode.CreatedAt = time.Time{}
+	// Only a promotion sets the last known good boot configuration
+	newNode.LastGoodBootData = nil
+
+	// If an XName has been provided, check if it is valid
+	nodeXName := newNode.XName
+	if nodeXName.String() != "" {
+		if _, err := nodeXName.Valid(); err != nil {
+			log.Print("Invalid XName ", nodeXName.String(), err)
+			return http.StatusBadRequest, fmt.Errorf("Invalid XName %w", err)
+		}
+
+		if _, err := storage.LookupComputeNodeByXName(nodeXName.String()); err == nil {
+			log.Print("Duplicate XName", nodeXName.String())
+			return http.StatusBadRequest, errors.New("Compute Node with the same XName already exists")
+		}
+	}
+
+	// Hostnames end up in DNS, so two nodes cannot share one
+	if newNode.Hostname != "" {
+		if _, err := storage.LookupComputeNodeByHostname(newNode.Hostname); err == nil {
+			log.Print("Duplicate hostname ", newNode.Hostname)
+			return http.StatusConflict, errors.New("Compute Node with the same hostname already exists")
+		}
+	}
+
+	if status, err := linkBMC(storage, newNode, now); err != nil {
+		return status, err
+	}
+
+	if newNode.NID == 0 {
+		nid, err := storage.AllocateNID()
+		if err != nil {
+			log.Error().Err(err).Msg("Error allocating NID")
+			return http.StatusInternalServerError, err
+		}
+		newNode.NID = nid
+	}
+
+	newNode.ID = uuid.New()
+	newNode.Touch(now)
+	if err := storage.SaveComputeNode(newNode.ID, *newNode); err != nil {
+		log.Error().Err(err).Msg("Error saving node")
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
+
+// linkBMC points node.BMC at a stored BMC, as postNode does: the BMC matching the xname or else
+// the MAC address of node.BMC, or the one inferred from the node xname if node.BMC is nil.  A
+// BMC that isn't stored yet is created.  On failure it returns the HTTP status that describes
+// the error.
+func linkBMC(storage storage.NodeStorage, node *nodes.ComputeNode, now time.Time) (int, error) {
+	// Deal with the BMC. If it has been provided already, check if it is valid
+	if node.BMC != nil {
+		bmcXName := node.BMC.XName.String()
+		if bmcXName != "" {
+			if _, err := node.BMC.XName.Valid(); err != nil {
+				return http.StatusBadRequest, errors.New("invalid BMC XName")
+			}
+		}
+
+		if existingBMC, found := lookupStoredBMC(storage, bmcXName, node.BMC.MACAddress); found {
+			node.BMC = &existingBMC
+		} else {
+			node.BMC.ID = uuid.New()
+			node.BMC.CreatedAt = time.Time{}
+			node.BMC.Touch(now)
+			if err := storage.SaveBMC(node.BMC.ID, *node.BMC); err != nil {
+				log.Error().Err(err).Msg("Error saving BMC")
+				return http.StatusInternalServerError, err
+			}
+		}
 	}
+
+	// If the BMC has not been provided, check to see if it can be inferred from the XName and create it if necessary
+	if node.BMC == nil && node.XName.String() != "" {
+		bmcXname := inferBMCXName(node.XName)
+		if existingBMC, err := storage.LookupBMCByXName(bmcXname.String()); err == nil {
+			node.BMC = &existingBMC
+		} else {
+			node.BMC = &nodes.BMC{
+				ID:    uuid.New(),
+				XName: bmcXname,
+			}
+			node.BMC.Touch(now)
+			if err := storage.SaveBMC(node.BMC.ID, *node.BMC); err != nil {
+				log.Error().Err(err).Msg("Error saving inferred BMC")
+				return http.StatusInternalServerError, err
+			}
+		}
+	}
+	return http.StatusOK, nil
 }
 
 func getNode(storage storage.NodeStorage) http.HandlerFunc {
@@ -123,65 +318,150 @@ func getNode(storage storage.NodeStorage) http.HandlerFunc {
 		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
 		if err != nil {
 			log.Error().Err(err).Msg("Error parsing node ID")
-			http.Error(w, "malformed node ID", http.StatusBadRequest)
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
 			return
 		}
 		node, err := storage.GetComputeNode(nodeID)
 		if err != nil {
-			http.Error(w, "node not found", http.StatusNotFound)
+			storageError(w, r, err, "node not found", http.StatusNotFound)
 		} else {
-			json.NewEncoder(w).Encode(node)
+			json.NewEncoder(w).Encode(node.Redacted())
 		}
 	}
 }
 
-func searchNodes(myStorage storage.NodeStorage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		query := r.URL.Query()
-		var searchOptions []storage.NodeSearchOption
-		xname := query.Get("xname")
-		if xname != "" {
-			searchOptions = append(searchOptions, storage.WithXName(xname))
+// nodeSearchOptions turns the search parameters of a ComputeNode query into storage options.
+// label=key=value may be repeated and every label must match.
+func nodeSearchOptions(query url.Values) ([]storage.NodeSearchOption, error) {
+	var searchOptions []storage.NodeSearchOption
+	if xname := query.Get("xname"); xname != "" {
+		searchOptions = append(searchOptions, storage.WithXName(xname))
+	}
+	if hostname := query.Get("hostname"); hostname != "" {
+		searchOptions = append(searchOptions, storage.WithHostname(hostname))
+	}
+	if nid := query.Get("nid"); nid != "" {
+		n, err := strconv.Atoi(nid)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid nid %q", nid)
 		}
-		hostname := query.Get("hostname")
-		if hostname != "" {
-			searchOptions = append(searchOptions, storage.WithHostname(hostname))
+		searchOptions = append(searchOptions, storage.WithNID(n))
+	}
+	if arch := query.Get("arch"); arch != "" {
+		searchOptions = append(searchOptions, storage.WithArch(arch))
+	}
+	if bootMac := query.Get("boot_mac"); bootMac != "" {
+		searchOptions = append(searchOptions, storage.WithBootMAC(bootMac))
+	}
+	if bmcMac := query.Get("bmc_mac"); bmcMac != "" {
+		searchOptions = append(searchOptions, storage.WithBMCMAC(bmcMac))
+	}
+	// boot_ipv4 and boot_ipv6 look a node up by the address it boots with, e.g. from a DHCP lease
+	if bootIPv4 := query.Get("boot_ipv4"); bootIPv4 != "" {
+		if ip := net.ParseIP(bootIPv4); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid boot_ipv4 %q", bootIPv4)
 		}
-		arch := query.Get("arch")
-		if arch != "" {
-			searchOptions = append(searchOptions, storage.WithArch(arch))
+		searchOptions = append(searchOptions, storage.WithBootIPv4(bootIPv4))
+	}
+	if bootIPv6 := query.Get("boot_ipv6"); bootIPv6 != "" {
+		if ip := net.ParseIP(bootIPv6); ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid boot_ipv6 %q", bootIPv6)
 		}
-		bootMac := query.Get("boot_mac")
-		if bootMac != "" {
-			searchOptions = append(searchOptions, storage.WithBootMAC(bootMac))
+		searchOptions = append(searchOptions, storage.WithBootIPv6(bootIPv6))
+	}
+	if state := query.Get("lifecycle_state"); state != "" {
+		if !nodes.LifecycleState(state).Valid() {
+			return nil, fmt.Errorf("invalid lifecycle_state %q", state)
 		}
-		bmcMac := query.Get("bmc_mac")
-		if bmcMac != "" {
-			searchOptions = append(searchOptions, storage.WithBMCMAC(bmcMac))
+		searchOptions = append(searchOptions, storage.WithLifecycleState(nodes.LifecycleState(state)))
+	}
+	// nic_firmware, nic_model and nic_mac match any of the node's network interfaces
+	if firmware := query.Get("nic_firmware"); firmware != "" {
+		searchOptions = append(searchOptions, storage.WithNICFirmware(firmware))
+	}
+	if model := query.Get("nic_model"); model != "" {
+		searchOptions = append(searchOptions, storage.WithNICModel(model))
+	}
+	if mac := query.Get("nic_mac"); mac != "" {
+		if _, err := net.ParseMAC(mac); err != nil {
+			return nil, fmt.Errorf("invalid nic_mac %q", mac)
+		}
+		searchOptions = append(searchOptions, storage.WithNICMAC(nodes.CanonicalMAC(mac)))
+	}
+	if after := query.Get("created_after"); after != "" {
+		t, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_after %q, expected an RFC 3339 time", after)
+		}
+		searchOptions = append(searchOptions, storage.WithCreatedAfter(t))
+	}
+	if after := query.Get("updated_after"); after != "" {
+		t, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid updated_after %q, expected an RFC 3339 time", after)
+		}
+		searchOptions = append(searchOptions, storage.WithUpdatedAfter(t))
+	}
+	if query.Get("missingIPV4") == "true" {
+		searchOptions = append(searchOptions, storage.WithMissingIPV4())
+	}
+	if query.Get("missingIPV6") == "true" {
+		searchOptions = append(searchOptions, storage.WithMissingIPV6())
+	}
+	for _, selector := range query["label"] {
+		key, value, err := nodes.ParseLabel(selector)
+		if err != nil {
+			return nil, err
+		}
+		searchOptions = append(searchOptions, storage.WithLabel(key, value))
+	}
+	return searchOptions, nil
+}
+
+// searchNodes answers ComputeNode searches.  collection=<name-or-id> limits the search to the
+// members of a collection, fetched in one storage query.
+func searchNodes(myStorage storage.NodeStorage, manager *nodes.CollectionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		query := r.URL.Query()
+		searchOptions, err := nodeSearchOptions(query)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
 		}
-		missingIPV4 := query.Get("missingIPV4")
-		if missingIPV4 == "true" {
-			searchOptions = append(searchOptions, storage.WithMissingIPV4())
+		limit, offset, err := response.PageParams(query)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
 		}
-		missingIPV6 := query.Get("missingIPV4")
-		if missingIPV6 == "true" {
-			searchOptions = append(searchOptions, storage.WithMissingIPV6())
+		if identifier := query.Get("collection"); identifier != "" {
+			collection, ok := manager.GetCollection(identifier)
+			if !ok {
+				render.Render(w, r, response.ErrNotFound(errors.New("collection not found")))
+				return
+			}
+			searchOptions = append(searchOptions, storage.WithXNames(xnames.XnameSliceString(collection.Nodes)))
 		}
 		log.Debug().
-			Str("xname", xname).
-			Str("hostname", hostname).
-			Str("arch", arch).
-			Str("boot_mac", bootMac).
+			Str("xname", query.Get("xname")).
+			Str("hostname", query.Get("hostname")).
+			Str("arch", query.Get("arch")).
+			Str("boot_mac", query.Get("boot_mac")).
 			Str("request_id", middleware.GetReqID(r.Context())).
 			Str("path", r.URL.Path).
 			Str("query", r.URL.RawQuery).
 			Msg("Dispatching ComputeNode search to Storage")
 
-		nodes, err := myStorage.SearchComputeNodes(searchOptions...)
+		total, err := myStorage.CountMatchingComputeNodes(searchOptions...)
+		if err != nil {
+			log.Error().Err(err).Msg("Error counting nodes")
+			storageError(w, r, err, "error searching nodes", http.StatusInternalServerError)
+			return
+		}
+		nodes, err := myStorage.SearchComputeNodes(append(searchOptions, storage.WithPage(limit, offset))...)
 		if err != nil {
 			log.Error().Err(err).Msg("Error searching nodes")
-			http.Error(w, "error searching nodes", http.StatusInternalServerError)
+			storageError(w, r, err, "error searching nodes", http.StatusInternalServerError)
 			return
 		}
 
@@ -195,101 +475,660 @@ func searchNodes(myStorage storage.NodeStorage) http.HandlerFunc {
 
 		}
 
-		json.NewEncoder(w).Encode(nodes)
+		// Projection trims each node to the requested fields, e.g. fields=id,xname,status.power_state
+		if fields := parseFields(query.Get("fields")); len(fields) > 0 {
+			projected := make([]map[string]interface{}, 0, len(nodes))
+			for _, node := range nodes {
+				p, err := projectFields(node.Redacted(), fields)
+				if err != nil {
+					log.Error().Err(err).Msg("Error projecting node fields")
+					response.Error(w, r, "error searching nodes", http.StatusInternalServerError)
+					return
+				}
+				projected = append(projected, p)
+			}
+			response.ListPage(w, r, projected, total, limit, offset)
+			return
+		}
+
+		for i := range nodes {
+			nodes[i] = nodes[i].Redacted()
+		}
+		response.ListPage(w, r, nodes, total, limit, offset)
 	}
 }
 
-func updateNode(storage storage.NodeStorage) http.HandlerFunc {
+// NodeCloneRequest holds what sets a clone apart from the node it is cloned from
+type NodeCloneRequest struct {
+	Hostname string           `json:"hostname"`
+	XName    xnames.NodeXname `json:"xname,omitempty"`
+	BootMac  string           `json:"boot_mac,omitempty"`
+}
+
+// cloneNode creates a node from an existing one, e.g. to add a rack of identical nodes.  The
+// architecture, boot data, labels and spec are copied, while everything tied to the source's
+// hardware or identity is left for the clone to get its own: the BMC follows from the new
+// xname, a NID is allocated, and network interfaces, boot addresses and status are not copied.
+func cloneNode(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
 		if err != nil {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, "malformed node ID")
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		var req NodeCloneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if req.Hostname == "" {
+			response.Error(w, r, "a clone needs its own hostname", http.StatusBadRequest)
+			return
+		}
+		if req.BootMac != "" {
+			if _, err := net.ParseMAC(req.BootMac); err != nil {
+				response.Error(w, r, "invalid boot MAC "+req.BootMac, http.StatusBadRequest)
+				return
+			}
+			req.BootMac = nodes.CanonicalMAC(req.BootMac)
+			if other, err := storage.LookupComputeNodeByMACAddress(req.BootMac); err == nil {
+				response.Error(w, r, "Compute Node "+other.ID.String()+" already boots from "+req.BootMac, http.StatusConflict)
+				return
+			}
+		}
+		if req.XName.String() != "" {
+			if other, err := storage.LookupComputeNodeByXName(req.XName.String()); err == nil {
+				response.Error(w, r, "Compute Node "+other.ID.String()+" already has XName "+req.XName.String(), http.StatusConflict)
+				return
+			}
+		}
+
+		source, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+
+		clone := source
+		clone.Hostname = req.Hostname
+		clone.XName = req.XName
+		clone.BootMac = req.BootMac
+		clone.NID = 0
+		clone.BMC = nil
+		clone.NetworkInterfaces = nil
+		clone.BootIPv4Address = ""
+		clone.BootIPv6Address = ""
+		clone.Status = nodes.ComputeNodeStatus{}
+		clone.LifecycleState = ""
+		clone.NextBootData = nil
+		clone.Spec.Hostname = req.Hostname
+		clone.Spec.BootMac = req.BootMac
+		clone.Spec.BootIPv4Address = ""
+		clone.Spec.BootIPv6Address = ""
+		clone.Spec.NetworkInterfaces = nil
+		if source.Labels != nil {
+			clone.Labels = make(map[string]string, len(source.Labels))
+			for key, value := range source.Labels {
+				clone.Labels[key] = value
+			}
+		}
+
+		if status, err := createComputeNode(storage, &clone); err != nil {
+			storageError(w, r, err, err.Error(), status)
+			return
+		}
+
+		log.Info().
+			Str("node_id", clone.ID.String()).
+			Str("source_id", source.ID.String()).
+			Str("xname", clone.XName.String()).
+			Str("hostname", clone.Hostname).
+			Str("request_id", middleware.GetReqID(r.Context())).
+			Msg("Node cloned")
+		broker.Publish(nodeEvent(events.ActionCreated, clone))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, clone.Redacted())
+	}
+}
+
+// lookupNodesByMAC answers a batch of boot MAC lookups, e.g. from a DHCP server, with a map
+// from each MAC in the request body to its node, or null if no node boots from it:
+//
+//	["de:ad:be:ef:00:01", "de:ad:be:ef:00:02"] -> {"de:ad:be:ef:00:01": {...}, "de:ad:be:ef:00:02": null}
+func lookupNodesByMAC(storage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var macs []string
+		if err := json.NewDecoder(r.Body).Decode(&macs); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		canonical := make([]string, len(macs))
+		for i, mac := range macs {
+			if _, err := net.ParseMAC(mac); err != nil {
+				response.Error(w, r, fmt.Sprintf("MAC %d: invalid MAC address %q", i, mac), http.StatusBadRequest)
+				return
+			}
+			canonical[i] = nodes.CanonicalMAC(mac)
+		}
+
+		found, err := storage.LookupComputeNodesByMACAddresses(canonical)
+		if err != nil {
+			log.Error().Err(err).Msg("Error looking up nodes by MAC address")
+			storageError(w, r, err, "error looking up nodes", http.StatusInternalServerError)
+			return
+		}
+		result := make(map[string]*nodes.ComputeNode, len(macs))
+		for i, mac := range macs {
+			result[mac] = nil
+			if node, ok := found[canonical[i]]; ok {
+				redacted := node.Redacted()
+				result[mac] = &redacted
+			}
+		}
+		render.JSON(w, r, result)
+	}
+}
+
+func updateNode(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
 			return
 		}
 
 		var updateNode nodes.ComputeNode
-		if err := render.DecodeJSON(r.Body, &updateNode); err != nil {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, err.Error())
+		if !decodeNode(w, r, &updateNode) {
+			return
+		}
+
+		arch, err := nodes.NormalizeArchitecture(updateNode.Architecture)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateNode.Architecture = arch
+		if err := nodes.ValidateLabels(updateNode.Labels); err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// If an XName has been provided, check if it is valid
+		if updateNode.XName.String() != "" {
+			if _, err := updateNode.XName.Valid(); err != nil {
+				response.Error(w, r, "invalid XName "+updateNode.XName.String(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		existingNode, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
 			return
 		}
+		updateNode.ID = nodeID
 
-		// If the LocationString validates as an Xname, check if it is valid
-		if !(updateNode.LocationString == xnames.NodeXname{Value: updateNode.LocationString}.String()) {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, "invalid XName "+updateNode.LocationString)
+		// A node read back from GET carries redacted passwords, which must not be stored
+		updateNode.KeepPasswords(existingNode)
+		if updateNode.BMC != nil && updateNode.BMC.Password == nodes.RedactedPassword {
+			if stored, err := storage.GetBMC(updateNode.BMC.ID); err == nil {
+				updateNode.BMC.KeepPassword(stored)
+			}
+		}
+
+		// Leaving the NID out keeps it, rather than releasing it for the next node
+		if updateNode.NID == 0 {
+			updateNode.NID = existingNode.NID
+		}
+
+		// The lifecycle state only moves along its allowed transitions, whichever endpoint moves it
+		if updateNode.LifecycleState == "" {
+			updateNode.LifecycleState = existingNode.LifecycleState
+		} else if err := existingNode.LifecycleState.Transition(updateNode.LifecycleState); err != nil {
+			response.Error(w, r, err.Error(), lifecycleErrorStatus(err))
 			return
 		}
 
+		if updateNode.XName.String() != "" {
+			if other, err := storage.LookupComputeNodeByXName(updateNode.XName.String()); err == nil && other.ID != nodeID {
+				response.Error(w, r, "Compute Node "+other.ID.String()+" already has XName "+updateNode.XName.String(), http.StatusConflict)
+				return
+			}
+		}
+
+		if updateNode.Hostname != "" {
+			if other, err := storage.LookupComputeNodeByHostname(updateNode.Hostname); err == nil && other.ID != nodeID {
+				response.Error(w, r, "Compute Node "+other.ID.String()+" already has hostname "+updateNode.Hostname, http.StatusConflict)
+				return
+			}
+		}
+
+		// A relocated node takes its BMC along, so the BMC xname has to follow the node xname
+		if updateNode.XName.String() != "" && updateNode.XName.Key() != existingNode.XName.Key() {
+			if updateNode.BMC == nil {
+				updateNode.BMC = existingNode.BMC
+			}
+			if err := refreshBMCXName(storage, &updateNode); err != nil {
+				log.Error().Err(err).Msg("Error refreshing BMC xname")
+				storageError(w, r, err, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		updateNode.CreatedAt = existingNode.CreatedAt
+		updateNode.LastGoodBootData = existingNode.LastGoodBootData
+		updateNode.Touch(time.Now())
 		err = storage.UpdateComputeNode(nodeID, updateNode)
 		if err != nil {
-			render.Status(r, http.StatusNotFound)
-			render.JSON(w, r, "node not found")
+			log.Error().Err(err).Msg("Error updating node")
+			storageError(w, r, err, "error updating node", http.StatusInternalServerError)
 			return
 		}
 
-		log.Info().
+		event := log.Info().
 			Str("node_id", updateNode.ID.String()).
-			Str("node_xname", updateNode.LocationString).
+			Str("node_xname", updateNode.XName.String()).
 			Str("node_hostname", updateNode.Hostname).
 			Str("node_arch", updateNode.Architecture).
-			Str("node_boot_mac", updateNode.BootMac).
-			Str("bmc_mac", updateNode.BMC.MACAddress).
-			Str("bmc_xname", updateNode.BMC.LocationString).
-			Str("bmc_id", updateNode.BMC.ID.String()).
+			Str("node_boot_mac", updateNode.BootMac)
+		if updateNode.BMC != nil {
+			event = event.
+				Str("bmc_mac", updateNode.BMC.MACAddress).
+				Str("bmc_xname", updateNode.BMC.XName.String()).
+				Str("bmc_id", updateNode.BMC.ID.String())
+		}
+		event.
 			Str("request_id", middleware.GetReqID(r.Context())).
 			Msg("Node updated")
+		broker.Publish(nodeEvent(events.ActionUpdated, updateNode))
 
 		render.Status(r, http.StatusOK)
-		render.JSON(w, r, updateNode)
+		render.JSON(w, r, updateNode.Redacted())
+	}
+}
+
+// refreshBMCXName re-derives the BMC xname from the node xname and points node.BMC at it.
+// If a BMC already exists at the derived xname the node is linked to that BMC.  Otherwise the
+// node's current BMC is renamed when the node is its only user, and a BMC is created when the
+// current one still serves other nodes or the node doesn't have one yet.
+func refreshBMCXName(storage storage.NodeStorage, node *nodes.ComputeNode) error {
+	if node.XName.String() == "" {
+		return nil
+	}
+	bmcXname := inferBMCXName(node.XName)
+	if node.BMC != nil && node.BMC.XName.String() == bmcXname.String() {
+		return nil
+	}
+
+	if existingBMC, err := storage.LookupBMCByXName(bmcXname.String()); err == nil {
+		node.BMC = &existingBMC
+		return nil
+	}
+
+	shared := false
+	if node.BMC != nil && node.BMC.ID != uuid.Nil {
+		var err error
+		if shared, err = bmcServesOthers(storage, node.BMC.ID, node.ID); err != nil {
+			return err
+		}
+	}
+	if node.BMC == nil || node.BMC.ID == uuid.Nil || shared {
+		node.BMC = &nodes.BMC{ID: uuid.New(), XName: bmcXname}
+		node.BMC.Touch(time.Now())
+		return storage.SaveBMC(node.BMC.ID, *node.BMC)
+	}
+
+	// Rename the stored BMC rather than trusting whatever copy the node carries
+	bmc, err := storage.GetBMC(node.BMC.ID)
+	if err != nil {
+		bmc = *node.BMC
+	}
+	bmc.XName = bmcXname
+	bmc.Touch(time.Now())
+	if err := storage.UpdateBMC(bmc.ID, bmc); err != nil {
+		return err
+	}
+	node.BMC = &bmc
+	return nil
+}
+
+// bmcServesOthers reports whether a node other than nodeID is linked to the BMC with bmcID,
+// such as another node on the same blade
+func bmcServesOthers(myStorage storage.NodeStorage, bmcID, nodeID uuid.UUID) (bool, error) {
+	linked, err := myStorage.SearchComputeNodes(storage.WithBMCID(bmcID))
+	if err != nil {
+		return false, err
+	}
+	for _, other := range linked {
+		if other.ID != nodeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func refreshNodeBMCXName(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		if node.XName.String() == "" {
+			response.Error(w, r, "node does not have an XName to derive the BMC XName from", http.StatusBadRequest)
+			return
+		}
+
+		if err := refreshBMCXName(storage, &node); err != nil {
+			log.Error().Err(err).Msg("Error refreshing BMC xname")
+			storageError(w, r, err, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		node.Touch(time.Now())
+		if err := storage.UpdateComputeNode(nodeID, node); err != nil {
+			log.Error().Err(err).Msg("Error saving node")
+			storageError(w, r, err, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		broker.Publish(nodeEvent(events.ActionUpdated, node))
+
+		render.JSON(w, r, node.Redacted())
+	}
+}
+
+// repairNodeBMC relinks a node whose BMC was deleted or renamed since the node was saved.  The
+// node's copy of its BMC is matched to a stored BMC as postNode would, or recreated, and the
+// resolved BMC is returned.
+func repairNodeBMC(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		if node.BMC == nil && node.XName.String() == "" {
+			response.Error(w, r, "node has neither a BMC nor an XName to infer one from", http.StatusBadRequest)
+			return
+		}
+
+		// A stale copy whose xname and MAC no longer match any BMC gives way to the BMC at the
+		// node's own position, if there is one, rather than being stored again as a new BMC
+		if node.BMC != nil && node.XName.String() != "" {
+			if _, found := lookupStoredBMC(storage, node.BMC.XName.String(), node.BMC.MACAddress); !found {
+				if inferred, err := storage.LookupBMCByXName(inferBMCXName(node.XName).String()); err == nil {
+					node.BMC = &inferred
+				}
+			}
+		}
+
+		now := time.Now()
+		if status, err := linkBMC(storage, &node, now); err != nil {
+			storageError(w, r, err, err.Error(), status)
+			return
+		}
+		node.Touch(now)
+		if err := storage.UpdateComputeNode(nodeID, node); err != nil {
+			log.Error().Err(err).Msg("Error saving node")
+			storageError(w, r, err, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		broker.Publish(nodeEvent(events.ActionUpdated, node))
+
+		render.JSON(w, r, node.BMC.Redacted())
+	}
+}
+
+// LifecycleRequest is the body of PATCH /ComputeNode/{nodeID}/lifecycle
+type LifecycleRequest struct {
+	LifecycleState nodes.LifecycleState `json:"lifecycle_state"`
+}
+
+// lifecycleErrorStatus is 409 for a transition the node's current state doesn't allow and 400
+// for a state that doesn't exist
+func lifecycleErrorStatus(err error) int {
+	if errors.Is(err, nodes.ErrInvalidLifecycleTransition) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// storageError renders message with status for err, an error returned by storage.  A backend
+// that doesn't implement the call answers 501 instead, whatever status would have been, and a
+// key that belongs to another record 409.
+func storageError(w http.ResponseWriter, r *http.Request, err error, message string, status int) {
+	switch {
+	case errors.Is(err, storage.ErrNotImplemented):
+		response.Error(w, r, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, storage.ErrConflict):
+		response.Error(w, r, err.Error(), http.StatusConflict)
+	default:
+		response.Error(w, r, message, status)
 	}
 }
 
-func deleteNode(storage storage.NodeStorage) http.HandlerFunc {
+// patchNodeLifecycle moves a node to another lifecycle state, e.g. to failed when its
+// provisioning doesn't complete
+func patchNodeLifecycle(storage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
 		if err != nil {
-			http.Error(w, "malformed node ID", http.StatusBadRequest)
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
 			return
 		}
-		err = storage.DeleteComputeNode(nodeID)
+		var req LifecycleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
 		if err != nil {
-			http.Error(w, "node not found", http.StatusNotFound)
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
 		}
+
+		previous := node.LifecycleState
+		if err := previous.Transition(req.LifecycleState); err != nil {
+			response.Error(w, r, err.Error(), lifecycleErrorStatus(err))
+			return
+		}
+		node.LifecycleState = req.LifecycleState
+		node.Touch(time.Now())
+		if err := storage.UpdateComputeNode(nodeID, node); err != nil {
+			log.Error().Err(err).Msg("Error saving node")
+			storageError(w, r, err, "error saving node", http.StatusInternalServerError)
+			return
+		}
+
+		log.Info().
+			Str("node_id", nodeID.String()).
+			Str("from", string(previous)).
+			Str("to", string(node.LifecycleState)).
+			Str("request_id", middleware.GetReqID(r.Context())).
+			Msg("Node lifecycle state changed")
+		broker.Publish(nodeEvent(events.ActionUpdated, node))
+
+		render.JSON(w, r, node.Redacted())
 	}
 }
 
-func NodeRoutes(myStorage storage.NodeStorage, authMiddlewares []func(http.Handler) http.Handler) chi.Router {
+// Outcomes of a status update in a bulk request
+const (
+	StatusUpdated  = "updated"
+	StatusNotFound = "not_found"
+	StatusFailed   = "failed"
+)
+
+// NodeStatusResult is the outcome of the status update of one node in a bulk request
+type NodeStatusResult struct {
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// patchNodeStatuses applies the statuses in the body, keyed by xname, to the nodes with those
+// xnames, e.g. the power states a monitoring agent found.  Only the status of each node is
+// replaced, each in its own transaction, so one unknown xname doesn't hold back the others.
+func patchNodeStatuses(myStorage storage.NodeStorage, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var statuses map[string]nodes.ComputeNodeStatus
+		if err := json.NewDecoder(r.Body).Decode(&statuses); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if len(statuses) == 0 {
+			response.Error(w, r, "at least one node status is required", http.StatusBadRequest)
+			return
+		}
+
+		results := make(map[string]NodeStatusResult, len(statuses))
+		for xname, status := range statuses {
+			node, err := myStorage.UpdateComputeNodeStatus(xname, status)
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				results[xname] = NodeStatusResult{Result: StatusNotFound, Error: err.Error()}
+			case err != nil:
+				log.Error().Err(err).Str("xname", xname).Msg("Error updating node status")
+				results[xname] = NodeStatusResult{Result: StatusFailed, Error: err.Error()}
+			default:
+				results[xname] = NodeStatusResult{Result: StatusUpdated}
+				broker.Publish(nodeEvent(events.ActionUpdated, node))
+			}
+		}
+		render.JSON(w, r, results)
+	}
+}
+
+// CollectionRef identifies a collection in error responses
+type CollectionRef struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name,omitempty"`
+}
+
+// NodeInCollectionsResponse is the 409 body returned when deleting a node that collections
+// still list
+type NodeInCollectionsResponse struct {
+	*response.ErrResponse
+	Collections []CollectionRef `json:"collections"`
+}
+
+// deleteNode refuses to delete a node that is still listed in a collection unless force=true
+// is given, in which case the node is removed from those collections first.
+func deleteNode(storage storage.NodeStorage, manager *nodes.CollectionManager, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		node, err := storage.GetComputeNode(nodeID)
+		if err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+
+		force := r.URL.Query().Get("force") == "true"
+		if node.XName.String() != "" && !force {
+			if collections := manager.FindCollectionsByNode(node.XName); len(collections) > 0 {
+				conflict := NodeInCollectionsResponse{
+					ErrResponse: response.ErrConflict(fmt.Errorf("node %s is still in collections, use force=true to remove it from them", node.XName)),
+				}
+				for _, collection := range collections {
+					conflict.Collections = append(conflict.Collections, CollectionRef{ID: collection.ID, Name: collection.Name})
+				}
+				render.Render(w, r, conflict)
+				return
+			}
+		}
+
+		if err := storage.DeleteComputeNode(nodeID); err != nil {
+			log.Error().Err(err).Msg("Error deleting node")
+			storageError(w, r, err, "error deleting node", http.StatusInternalServerError)
+			return
+		}
+		broker.Publish(nodeEvent(events.ActionDeleted, node))
+
+		// Only touch the collections once the node is gone, so a failed delete leaves them as they were
+		if node.XName.String() != "" && force {
+			for _, collection := range manager.RemoveNode(node.XName) {
+				log.Info().
+					Str("collection_id", collection.ID.String()).
+					Str("xname", node.XName.String()).
+					Msg("Removed deleted node from collection")
+				broker.Publish(collectionEvent(events.ActionUpdated, *collection))
+			}
+		}
+	}
+}
+
+func NodeRoutes(myStorage storage.NodeStorage, authMiddlewares []func(http.Handler) http.Handler, opts ...RouterOption) chi.Router {
+	config := newRouterConfig(opts)
+
 	// Create a new collection manager for node collections
 	manager := nodes.NewCollectionManager()
 	// Add a mutual exclusivity constraint to the manager that prevents a node from being in multipe partitions or multiple tenants.  Use the xname as the key.
-	manager.AddConstraint(nodes.DefaultType, &nodes.MutualExclusivityConstraint{ExistingNodes: make(map[xnames.NodeXname]uuid.UUID)})
-	manager.AddConstraint(nodes.PartitionType, &nodes.MutualExclusivityConstraint{ExistingNodes: make(map[xnames.NodeXname]uuid.UUID)})
-	manager.AddConstraint(nodes.TenantType, &nodes.MutualExclusivityConstraint{ExistingNodes: make(map[xnames.NodeXname]uuid.UUID)})
+	// Ad-hoc collections are free to overlap.
+	manager.AddConstraint(nodes.PartitionType, &nodes.MutualExclusivityConstraint{})
+	manager.AddConstraint(nodes.TenantType, &nodes.MutualExclusivityConstraint{})
+
+	nodeSchemaLoader = newNodeSchemaLoader()
 
 	// Create a router for both protected and unprotected routes
 	r := chi.NewRouter()
+	// Treat /ComputeNode/ and /ComputeNode as the same route
+	r.Use(middleware.StripSlashes)
 
 	// ComputeNode routes
-	r.With(authMiddlewares...).Put("/ComputeNode/{nodeID}", updateNode(myStorage))
-	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}", updateNode(myStorage))
-	r.With(authMiddlewares...).Post("/ComputeNode", postNode(myStorage))
-	r.With(authMiddlewares...).Delete("/ComputeNode/{nodeID}", deleteNode(myStorage))
+	r.With(authMiddlewares...).Put("/ComputeNode/{nodeID}", updateNode(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}", updateNode(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode", postNode(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/import", importNodes(myStorage, config.broker))
+	r.With(authMiddlewares...).Delete("/ComputeNode/{nodeID}", deleteNode(myStorage, manager, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/refresh-bmc-xname", refreshNodeBMCXName(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/repair-bmc", repairNodeBMC(myStorage, config.broker))
+	r.With(authMiddlewares...).Put("/ComputeNode/{nodeID}/boot/next", putNextBootData(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/boot/promote", changeBootData(myStorage, config.broker, (*nodes.ComputeNode).PromoteBootData))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/boot/rollback", changeBootData(myStorage, config.broker, (*nodes.ComputeNode).RollbackBootData))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/clone", cloneNode(myStorage, config.broker))
+	r.With(authMiddlewares...).Patch("/ComputeNode/{nodeID}/lifecycle", patchNodeLifecycle(myStorage, config.broker))
+	r.With(authMiddlewares...).Patch("/ComputeNode/status/bulk", patchNodeStatuses(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/notes", postNodeNote(myStorage))
+	r.With(authMiddlewares...).Post("/ComputeNode/{nodeID}/interfaces", postNodeInterface(myStorage, config.broker))
+	r.With(authMiddlewares...).Put("/ComputeNode/{nodeID}/interfaces/{mac}", putNodeInterface(myStorage, config.broker))
+	r.With(authMiddlewares...).Delete("/ComputeNode/{nodeID}/interfaces/{mac}", deleteNodeInterface(myStorage, config.broker))
 
 	// BMC routes
-	r.With(authMiddlewares...).Post("/bmc", postBMC(myStorage))
-	r.With(authMiddlewares...).Put("/bmc/{bmcID}", updateBMC(myStorage))
-	r.With(authMiddlewares...).Delete("/bmc/{bmcID}", deleteBMC(myStorage))
+	r.With(authMiddlewares...).Post("/bmc", postBMC(myStorage, config.broker))
+	r.With(authMiddlewares...).Post("/bmc/bulk", postBMCs(myStorage, config.broker))
+	r.With(authMiddlewares...).Put("/bmc/{bmcID}", updateBMC(myStorage, config.broker))
+	r.With(authMiddlewares...).Delete("/bmc/{bmcID}", deleteBMC(myStorage, config.broker))
 
 	// NodeCollection routes
-	r.With(authMiddlewares...).Post("/NodeCollection", createCollection(manager))
-	r.With(authMiddlewares...).Put("/NodeCollection/{identifier}", updateCollection(manager))
-	r.With(authMiddlewares...).Delete("/NodeCollection/{identifier}", deleteCollection(manager))
+	r.With(authMiddlewares...).Post("/NodeCollection", createCollection(manager, myStorage, config.lenientCollectionNodes, config.broker))
+	r.With(authMiddlewares...).Put("/NodeCollection/{identifier}", updateCollection(manager, myStorage, config.lenientCollectionNodes, config.broker))
+	r.With(authMiddlewares...).Delete("/NodeCollection/{identifier}", deleteCollection(manager, config.broker))
 
 	// Unprotected routes
 	r.Get("/ComputeNode/{nodeID}", getNode(myStorage))
-	r.Get("/ComputeNode", searchNodes(myStorage))
+	r.Get("/ComputeNode/{nodeID}/bmc", getNodeBMC(myStorage))
+	r.Get("/ComputeNode/{nodeID}/notes", getNodeNotes(myStorage))
+	r.Get("/ComputeNode", searchNodes(myStorage, manager))
+	r.Get("/ComputeNode/export", exportNodes(myStorage))
+	// A read that takes its MACs in the body, so it is a POST without authentication
+	r.Post("/ComputeNode/lookup/macs", lookupNodesByMAC(myStorage))
+	r.Get("/xname/{xname}", getXNameDetail(myStorage))
+	r.Post("/xnames/build", buildXName)
+	r.Get("/bootparameters", getBootParameters(myStorage))
+	r.Get("/bmc", searchBMCs(myStorage))
 	r.Get("/bmc/{bmcID}", getBMC(myStorage))
 	r.Get("/NodeCollection/{identifier}", getCollection(manager))
 
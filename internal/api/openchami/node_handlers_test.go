@@ -0,0 +1,1155 @@
+package openchami
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/storage/csm"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+	"github.com/rs/zerolog"
+)
+
+func TestInferBMCXName(t *testing.T) {
+	for _, xname := range []string{"x1000c0s7b1n0", "x1000c0s7b1n1"} {
+		if got := inferBMCXName(xnames.NewNodeXname(xname)).String(); got != "x1000c0s7b1" {
+			t.Errorf("inferBMCXName(%q) = %q, want %q", xname, got, "x1000c0s7b1")
+		}
+	}
+}
+
+// newTestRouter mounts the node routes without authentication on top of in-memory DuckDB storage
+func newTestRouter(t *testing.T) (chi.Router, *duckdb.DuckDBStorage) {
+	t.Helper()
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+	return r, store
+}
+
+func TestPostNodeReturnsInferredBMC(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	body := []byte(`{"hostname": "nid000001", "architecture": "x86_64", "xname": "x1000c0s1b0n0"}`)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response struct {
+		BMC *struct {
+			ID    string `json:"id"`
+			XName string `json:"xname"`
+		} `json:"bmc"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.BMC == nil {
+		t.Fatalf("expected the inferred BMC in the create response")
+	}
+	if response.BMC.XName != "x1000c0s1b0" {
+		t.Errorf("expected inferred BMC xname x1000c0s1b0, got %q", response.BMC.XName)
+	}
+
+	// The returned ID must reference the stored BMC
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc/"+response.BMC.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the inferred BMC %s to be retrievable, got %d", response.BMC.ID, rec.Code)
+	}
+	var stored nodes.BMC
+	if err := json.NewDecoder(rec.Body).Decode(&stored); err != nil {
+		t.Fatalf("failed to decode BMC: %v", err)
+	}
+	if stored.XName.String() != "x1000c0s1b0" {
+		t.Errorf("expected stored BMC xname x1000c0s1b0, got %q", stored.XName)
+	}
+}
+
+func TestPostNodeSharedBMC(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	var created []nodes.ComputeNode
+	for _, xname := range []string{"x1000c0s7b1n0", "x1000c0s7b1n1"} {
+		body, _ := json.Marshal(map[string]string{
+			"hostname":     "node-" + xname,
+			"architecture": "x86_64",
+			"xname":        xname,
+		})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", bytes.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 creating %s, got %d: %s", xname, rec.Code, rec.Body.String())
+		}
+		var node nodes.ComputeNode
+		if err := json.NewDecoder(rec.Body).Decode(&node); err != nil {
+			t.Fatalf("failed to decode node: %v", err)
+		}
+		created = append(created, node)
+	}
+
+	if created[0].XName.String() != "x1000c0s7b1n0" || created[1].XName.String() != "x1000c0s7b1n1" {
+		t.Errorf("expected node xnames to keep their node positions, got %s and %s", created[0].XName, created[1].XName)
+	}
+	if created[0].BMC == nil || created[1].BMC == nil {
+		t.Fatalf("expected a BMC to be inferred for both nodes")
+	}
+	if created[0].BMC.ID != created[1].BMC.ID {
+		t.Errorf("expected both nodes to share BMC %s, got %s", created[0].BMC.ID, created[1].BMC.ID)
+	}
+	if created[1].BMC.XName.String() != "x1000c0s7b1" {
+		t.Errorf("expected BMC xname x1000c0s7b1, got %s", created[1].BMC.XName)
+	}
+}
+
+func TestTrailingSlashes(t *testing.T) {
+	// None of these requests reach storage, so the routes can be exercised without one
+	r := NodeRoutes(nil, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/ComputeNode/not-a-uuid", "", http.StatusBadRequest},
+		{http.MethodPost, "/ComputeNode", "{", http.StatusBadRequest},
+		{http.MethodPut, "/ComputeNode/not-a-uuid", "", http.StatusBadRequest},
+		{http.MethodGet, "/bmc/not-a-uuid", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{tt.path, tt.path + "/"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, path, bytes.NewReader([]byte(tt.body))))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, path, tt.want, rec.Code)
+			}
+		}
+	}
+}
+
+// createNode posts a node with the given xname and returns the created node
+func createNode(t *testing.T, r chi.Router, xname string) nodes.ComputeNode {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"hostname": "node-" + xname, "architecture": "x86_64", "xname": xname})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 creating %s, got %d: %s", xname, rec.Code, rec.Body.String())
+	}
+	var node nodes.ComputeNode
+	if err := json.NewDecoder(rec.Body).Decode(&node); err != nil {
+		t.Fatalf("failed to decode node: %v", err)
+	}
+	return node
+}
+
+func TestRelocateNodeRefreshesBMCXName(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	occupant := createNode(t, r, "x1000c0s3b0n0")
+
+	relocate := func(xname string) nodes.ComputeNode {
+		t.Helper()
+		node.XName = xnames.NewNodeXname(xname)
+		node.BMC = nil
+		body, _ := json.Marshal(node)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 relocating to %s, got %d: %s", xname, rec.Code, rec.Body.String())
+		}
+		var updated nodes.ComputeNode
+		if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+			t.Fatalf("failed to decode node: %v", err)
+		}
+		return updated
+	}
+
+	// Moving to an empty slot renames the node's own BMC
+	bmcID := node.BMC.ID
+	updated := relocate("x1000c0s2b0n0")
+	if updated.BMC == nil || updated.BMC.ID != bmcID || updated.BMC.XName.String() != "x1000c0s2b0" {
+		t.Fatalf("expected BMC %s to be renamed to x1000c0s2b0, got %+v", bmcID, updated.BMC)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc/"+bmcID.String(), nil))
+	var stored nodes.BMC
+	json.NewDecoder(rec.Body).Decode(&stored)
+	if stored.XName.String() != "x1000c0s2b0" {
+		t.Errorf("expected the stored BMC to be renamed to x1000c0s2b0, got %q", stored.XName)
+	}
+
+	// Moving onto a blade whose BMC already exists links the node to that BMC
+	updated = relocate("x1000c0s3b0n1")
+	if updated.BMC == nil || updated.BMC.ID != occupant.BMC.ID {
+		t.Errorf("expected the node to share BMC %s with %s, got %+v", occupant.BMC.ID, occupant.XName, updated.BMC)
+	}
+}
+
+func TestRelocateNodeLeavesSharedBMC(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	neighbour := createNode(t, r, "x1000c0s1b0n1")
+	if node.BMC == nil || neighbour.BMC == nil || node.BMC.ID != neighbour.BMC.ID {
+		t.Fatalf("expected the nodes to share a BMC, got %+v and %+v", node.BMC, neighbour.BMC)
+	}
+
+	node.XName = xnames.NewNodeXname("x1000c0s2b0n0")
+	node.BMC = nil
+	body, _ := json.Marshal(node)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 relocating, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated nodes.ComputeNode
+	json.NewDecoder(rec.Body).Decode(&updated)
+	if updated.BMC == nil || updated.BMC.ID == neighbour.BMC.ID || updated.BMC.XName.String() != "x1000c0s2b0" {
+		t.Errorf("expected a new BMC at x1000c0s2b0, got %+v", updated.BMC)
+	}
+
+	// The neighbour's BMC stays where it is
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/bmc/"+neighbour.BMC.ID.String(), nil))
+	var shared nodes.BMC
+	json.NewDecoder(rec.Body).Decode(&shared)
+	if shared.XName.String() != "x1000c0s1b0" {
+		t.Errorf("expected the shared BMC to keep x1000c0s1b0, got %q", shared.XName)
+	}
+}
+
+func TestRefreshBMCXNameEndpoint(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s5b0n0")
+
+	// Rename the BMC out from under the node so that it no longer matches
+	stale := *node.BMC
+	stale.XName = xnames.NewBMCXname("x1000c0s9b0")
+	body, _ := json.Marshal(stale)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/bmc/"+stale.ID.String(), bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 updating BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+node.ID.String()+"/refresh-bmc-xname", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var refreshed nodes.ComputeNode
+	if err := json.NewDecoder(rec.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("failed to decode node: %v", err)
+	}
+	if refreshed.BMC == nil || refreshed.BMC.ID != stale.ID || refreshed.BMC.XName.String() != "x1000c0s5b0" {
+		t.Errorf("expected BMC %s to be renamed back to x1000c0s5b0, got %+v", stale.ID, refreshed.BMC)
+	}
+}
+
+func TestPostNodeBMCHierarchy(t *testing.T) {
+	r, _ := newTestRouter(t)
+	macs := 0
+	post := func(query, xname, bmcXName string) *httptest.ResponseRecorder {
+		macs++
+		bmc := map[string]string{"xname": bmcXName, "username": "root", "password": "secret", "mac_address": fmt.Sprintf("de:ad:be:ef:01:%02x", macs)}
+		body, _ := json.Marshal(map[string]interface{}{"hostname": "node-" + xname, "architecture": "x86_64", "xname": xname, "bmc": bmc})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode"+query, bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := post("", "x1000c0s1b0n0", "x9999c0s0b0")
+	var mismatch struct {
+		Mismatches []XNameMismatch `json:"mismatches"`
+	}
+	if rec.Code != http.StatusBadRequest || json.NewDecoder(rec.Body).Decode(&mismatch) != nil {
+		t.Fatalf("expected status 400 for a BMC in another cabinet, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := []XNameMismatch{{"cabinet", 1000, 9999}, {"slot", 1, 0}}
+	if len(mismatch.Mismatches) != len(want) || mismatch.Mismatches[0] != want[0] || mismatch.Mismatches[1] != want[1] {
+		t.Errorf("expected mismatches %+v, got %+v", want, mismatch.Mismatches)
+	}
+
+	if rec := post("", "x1000c0s2b0n0", "x1000c0s2b1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a BMC at another position, got %d", rec.Code)
+	}
+	if rec := post("", "x1000c0s3b0n1", "x01000c0s3b0"); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 for the node's own BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := post("?allow_bmc_mismatch=true", "x1000c0s1b0n0", "x9999c0s0b0"); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 with the override, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRepairBMCEndpoint(t *testing.T) {
+	r, store := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s5b0n0")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/inventory/bmc/"+node.BMC.ID.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 deleting BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+node.ID.String()+"/repair-bmc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var bmc nodes.BMC
+	if err := json.NewDecoder(rec.Body).Decode(&bmc); err != nil {
+		t.Fatalf("failed to decode BMC: %v", err)
+	}
+	if bmc.ID == node.BMC.ID || bmc.XName.String() != "x1000c0s5b0" {
+		t.Errorf("expected a new BMC at x1000c0s5b0 in place of the deleted %s, got %+v", node.BMC.ID, bmc)
+	}
+	if _, err := store.GetBMC(bmc.ID); err != nil {
+		t.Errorf("expected the resolved BMC to be stored: %v", err)
+	}
+	if repaired, err := store.GetComputeNode(node.ID); err != nil || repaired.BMC == nil || repaired.BMC.ID != bmc.ID {
+		t.Errorf("expected the node to be linked to BMC %s, got %+v (%v)", bmc.ID, repaired.BMC, err)
+	}
+
+	// Repairing a node that is already linked leaves it on the same BMC
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+node.ID.String()+"/repair-bmc", nil))
+	var again nodes.BMC
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&again) != nil || again.ID != bmc.ID {
+		t.Errorf("expected the node to keep BMC %s, got %d: %+v", bmc.ID, rec.Code, again)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+uuid.NewString()+"/repair-bmc", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown node, got %d", rec.Code)
+	}
+}
+
+func TestRepairBMCPrefersInferredBMC(t *testing.T) {
+	r, store := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s6b0n0")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/inventory/bmc/"+node.BMC.ID.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 deleting BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+	replacement := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x1000c0s6b0"), MACAddress: "de:ad:be:ef:06:01"}
+	if err := store.SaveBMC(replacement.ID, replacement); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	// The node's copy names neither the replacement's xname nor its MAC
+	stored, err := store.GetComputeNode(node.ID)
+	if err != nil {
+		t.Fatalf("failed to read node: %v", err)
+	}
+	stored.BMC.XName = xnames.NewBMCXname("x1000c0s7b0")
+	stored.BMC.MACAddress = "de:ad:be:ef:06:02"
+	if err := store.UpdateComputeNode(node.ID, stored); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+node.ID.String()+"/repair-bmc", nil))
+	var bmc nodes.BMC
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&bmc) != nil || bmc.ID != replacement.ID {
+		t.Errorf("expected the node to be linked to BMC %s, got %d: %+v", replacement.ID, rec.Code, bmc)
+	}
+	if _, err := store.LookupBMCByXName("x1000c0s7b0"); err == nil {
+		t.Errorf("expected the stale copy not to be stored")
+	}
+}
+
+func TestSearchNodesPage(t *testing.T) {
+	r, _ := newTestRouter(t)
+	var ids []string
+	for _, xname := range []string{"x1000c0s1b0n0", "x1000c0s2b0n0", "x1000c0s3b0n0"} {
+		ids = append(ids, createNode(t, r, xname).ID.String())
+	}
+	sort.Strings(ids)
+
+	search := func(query string) response.PagedResponse[nodes.ComputeNode] {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode?"+query, nil))
+		var page response.PagedResponse[nodes.ComputeNode]
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&page) != nil {
+			t.Fatalf("expected status 200 searching %s, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		return page
+	}
+
+	page := search("limit=2&offset=1")
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].ID.String() != ids[1] || page.Items[1].ID.String() != ids[2] {
+		t.Errorf("expected the second and third of 3 nodes, got %+v", page)
+	}
+	if page := search("offset=5"); page.Total != 3 || len(page.Items) != 0 {
+		t.Errorf("expected no nodes past the end out of 3, got %+v", page)
+	}
+	if page := search("xname=x1000c0s2b0n0&limit=1"); page.Total != 1 || len(page.Items) != 1 {
+		t.Errorf("expected one matching node, got %+v", page)
+	}
+}
+
+func TestPostNodeBodyTooLarge(t *testing.T) {
+	inventory, store := newTestRouter(t)
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.MaxBodySize(1024))
+	r.Mount("/", inventory)
+
+	body := `{"hostname": "node1", "architecture": "x86_64", "description": "` + strings.Repeat("x", 2048) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body))
+	// Without a Content-Length the limit is only hit while decoding
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if found, _ := store.SearchComputeNodes(); len(found) != 0 {
+		t.Errorf("expected no nodes to be saved, got %d", len(found))
+	}
+}
+
+func TestErrorResponsesAreJSON(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/inventory/ComputeNode/not-a-uuid", "", http.StatusBadRequest},
+		{http.MethodGet, "/inventory/ComputeNode/00000000-0000-0000-0000-000000000001", "", http.StatusNotFound},
+		{http.MethodPut, "/inventory/ComputeNode/00000000-0000-0000-0000-000000000001", "{", http.StatusBadRequest},
+		{http.MethodGet, "/inventory/bmc/00000000-0000-0000-0000-000000000001", "", http.StatusNotFound},
+		{http.MethodPost, "/inventory/bmc", "[]", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+			continue
+		}
+		var body struct {
+			Status string `json:"status"`
+			Code   int    `json:"code"`
+			Error  string `json:"error"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Errorf("%s %s: expected a JSON error body: %v", tt.method, tt.path, err)
+			continue
+		}
+		if body.Code != tt.status || body.Status == "" || body.Error == "" {
+			t.Errorf("%s %s: unexpected error body %+v", tt.method, tt.path, body)
+		}
+	}
+}
+
+func TestPostNodeArchitecture(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	post := func(arch string) *httptest.ResponseRecorder {
+		body := `{"hostname": "node-` + arch + `", "architecture": "` + arch + `"}`
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("AArch64")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var node nodes.ComputeNode
+	if err := json.NewDecoder(rec.Body).Decode(&node); err != nil {
+		t.Fatalf("failed to decode node: %v", err)
+	}
+	if node.Architecture != nodes.ArchARM64 {
+		t.Errorf("expected the architecture to be normalized to %s, got %s", nodes.ArchARM64, node.Architecture)
+	}
+
+	if rec := post("sparc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown architecture, got %d", rec.Code)
+	}
+
+	body := `{"hostname": "node-arm", "architecture": "mips"}`
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 updating to an unknown architecture, got %d", rec.Code)
+	}
+}
+
+func TestImportNodesCSV(t *testing.T) {
+	r, store := newTestRouter(t)
+	createNode(t, r, "x1000c0s2b0n0")
+
+	body := strings.Join([]string{
+		"hostname,xname,architecture,boot_mac,bmc_mac,bmc_ip",
+		"nid001,x1000c0s1b0n0,amd64,de:ad:be:ef:00:01,de:ad:be:ef:10:01,172.16.0.1",
+		"nid002,x1000c0s2b0n0,x86_64,de:ad:be:ef:00:02,,",
+		"nid003,x1000c0s3b0n0,sparc,de:ad:be:ef:00:03,,",
+		"nid004,x1000c0s4b0n0,arm64,de:ad:be:ef:00:04",
+		"nid005,,aarch64,de:ad:be:ef:00:05,,",
+		"",
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("expected status 207, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result NodeImportResponse
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode import response: %v", err)
+	}
+	if result.Created != 2 || result.Failed != 3 || len(result.Results) != 5 {
+		t.Fatalf("unexpected import summary: %+v", result)
+	}
+	for i, row := range result.Results {
+		if row.Line != i+2 {
+			t.Errorf("result %d has line %d, want %d", i, row.Line, i+2)
+		}
+		if created := row.Error == ""; created != (i == 0 || i == 4) {
+			t.Errorf("unexpected result for line %d: %+v", row.Line, row)
+		}
+	}
+
+	node, err := store.LookupComputeNodeByXName("x1000c0s1b0n0")
+	if err != nil {
+		t.Fatalf("imported node not found: %v", err)
+	}
+	if node.ID.String() != result.Results[0].ID || node.Architecture != nodes.ArchX86_64 {
+		t.Errorf("unexpected imported node: %+v", node)
+	}
+	if node.BMC == nil || node.BMC.MACAddress != "de:ad:be:ef:10:01" || node.BMC.IPv4Address != "172.16.0.1" {
+		t.Errorf("unexpected imported BMC: %+v", node.BMC)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/import", strings.NewReader("hostname,xname\nnid006,x1000c0s6b0n0\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an incomplete header, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/import", strings.NewReader(body)))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status 415 without a CSV content type, got %d", rec.Code)
+	}
+}
+
+// Import rows get the checks of a POST body, and keep the NIDs of an export
+func TestImportNodesValidatesRows(t *testing.T) {
+	r, store := newTestRouter(t)
+
+	body := strings.Join([]string{
+		"hostname,xname,architecture,boot_mac,bmc_mac,bmc_ip,nid",
+		"nid010,x1000c0s1b0n0,x86_64,DE-AD-BE-EF-00-10,,,77",
+		"nid011,x1000c0s2b0n0,x86_64,de:ad:be:ef:00,,,",
+		"nid012,x1000c0s3b0n0,x86_64,,de:ad:be:ef:10:12,not-an-ip,",
+		"nid013,bogus,x86_64,,,,",
+		"nid014,x1000c0s4b0n0,x86_64,,,,77",
+		"nid015,x1000c0s5b0n0,x86_64,,,,seven",
+		"",
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var result NodeImportResponse
+	if rec.Code != http.StatusMultiStatus || json.NewDecoder(rec.Body).Decode(&result) != nil {
+		t.Fatalf("expected status 207, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if result.Created != 1 || result.Failed != 5 {
+		t.Fatalf("unexpected import summary: %+v", result)
+	}
+	for i, want := range []string{"", "boot_mac", "bmc.ipv4_address", "xname", "77", "nid"} {
+		row := result.Results[i]
+		if (want == "") != (row.Error == "") || !strings.Contains(row.Error, want) {
+			t.Errorf("line %d: expected an error about %q, got %+v", row.Line, want, row)
+		}
+	}
+
+	node, err := store.LookupComputeNodeByXName("x1000c0s1b0n0")
+	if err != nil {
+		t.Fatalf("imported node not found: %v", err)
+	}
+	if node.NID != 77 || node.BootMac != "de:ad:be:ef:00:10" {
+		t.Errorf("expected NID 77 and a canonical boot MAC, got %d and %q", node.NID, node.BootMac)
+	}
+}
+
+func TestExportNodes(t *testing.T) {
+	r, _ := newTestRouter(t)
+	first := createNode(t, r, "x1000c0s1b0n0")
+	createNode(t, r, "x1000c0s2b0n0")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/export?format=csv", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("unexpected CSV export response %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	records, err := csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV export: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(nodeExportColumns, ",") {
+		t.Fatalf("unexpected CSV export: %v", records)
+	}
+
+	// The export carries every import column, so it can be imported into another inventory
+	other, _ := newTestRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/import", bytes.NewReader(rec.Body.Bytes()))
+	req.Header.Set("Content-Type", "text/csv")
+	imported := httptest.NewRecorder()
+	other.ServeHTTP(imported, req)
+	if imported.Code != http.StatusCreated {
+		t.Errorf("expected the CSV export to import cleanly, got %d: %s", imported.Code, imported.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/export?format=jsonl&xname=x1000c0s1b0n0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected JSONL export response %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one filtered node, got %d: %s", len(lines), rec.Body.String())
+	}
+	var node nodes.ComputeNode
+	if err := json.Unmarshal([]byte(lines[0]), &node); err != nil || node.ID != first.ID {
+		t.Errorf("unexpected JSONL node %s: %v", lines[0], err)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/export?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown format, got %d", rec.Code)
+	}
+}
+
+func TestNodeLabelValidation(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	body := `{"hostname": "nid001", "architecture": "x86_64", "labels": {"rack": "A3", "bad key": "x"}}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid label key, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"hostname": "nid001", "architecture": "x86_64", "labels": {"rack": "A3"}}`
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+	var node nodes.ComputeNode
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&node) != nil || node.Labels["rack"] != "A3" {
+		t.Fatalf("expected the labelled node to be created, got %d: %+v", rec.Code, node)
+	}
+
+	for _, query := range []string{"label=rack", "label=rack=A3&label=%3Dx"} {
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestSearchNodesRejectsInvalidParams(t *testing.T) {
+	r, _ := newTestRouter(t)
+	for _, query := range []string{"boot_ipv4=10.0.0", "boot_ipv4=fd00::10", "boot_ipv6=10.0.0.10", "boot_ipv6=nope", "nid=0", "nid=nid001", "created_after=yesterday", "updated_after=2024-01-01"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestDuplicateHostname(t *testing.T) {
+	r, _ := newTestRouter(t)
+	first := createNode(t, r, "x1000c0s1b0n0")
+	second := createNode(t, r, "x1000c0s2b0n0")
+
+	body := `{"hostname": "node-x1000c0s1b0n0", "architecture": "x86_64", "xname": "x1000c0s3b0n0"}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 creating a duplicate hostname, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	second.Hostname = first.Hostname
+	update, _ := json.Marshal(second)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+second.ID.String(), bytes.NewReader(update)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 renaming to a taken hostname, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Saving a node under its own hostname is not a conflict
+	update, _ = json.Marshal(first)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+first.ID.String(), bytes.NewReader(update)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 updating a node in place, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRelocateNodeOntoTakenXName(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	occupant := createNode(t, r, "x1000c0s2b0n0")
+
+	moved := node
+	moved.XName = occupant.XName
+	moved.BMC = nil
+	body, _ := json.Marshal(moved)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), bytes.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 moving onto a taken xname, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/"+node.ID.String(), nil))
+	var stored nodes.ComputeNode
+	json.NewDecoder(rec.Body).Decode(&stored)
+	if rec.Code != http.StatusOK || stored.XName.String() != "x1000c0s1b0n0" {
+		t.Errorf("expected the node to stay at x1000c0s1b0n0, got %d %q", rec.Code, stored.XName)
+	}
+}
+
+func TestNodeSchemaValidation(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+
+	for _, tt := range []struct {
+		method, path, body, field string
+	}{
+		{http.MethodPost, "/inventory/ComputeNode", `{"hostname": "nid001", "architecture": "x86_64", "bmc": {"xname": "x1000c0s2b0", "mac_address": 7}}`, "bmc.mac_address"},
+		{http.MethodPost, "/inventory/ComputeNode", `{"hostname": "nid001", "architecture": "x86_64", "boot_data": "vmlinuz"}`, "boot_data"},
+		{http.MethodPost, "/inventory/ComputeNode", `{"architecture": "x86_64"}`, "(root)"},
+		{http.MethodPut, "/inventory/ComputeNode/" + node.ID.String(), `{"hostname": "nid001", "architecture": "x86_64", "network_interfaces": {"eth0": {}}}`, "network_interfaces"},
+		{http.MethodPost, "/inventory/ComputeNode", `{"hostname": "nid001", "architecture": "x86_64", "boot_ipv4_address": "10.0.0.256"}`, "boot_ipv4_address"},
+		{http.MethodPut, "/inventory/ComputeNode/" + node.ID.String(), `{"hostname": "nid001", "architecture": "x86_64", "network_interfaces": [{"interface_name": "eth0", "mac_address": "00:11:22:33:44"}]}`, "network_interfaces.0.mac_address"},
+		{http.MethodPost, "/inventory/bmc", `{"xname": "x1000c0s3b0", "mac_address": "00:11:22:33:44:55", "ipv6_address": "10.0.0.1"}`, "ipv6_address"},
+		{http.MethodPost, "/inventory/bmc/bulk", `[{"mac_address": "00:11:22:33:44:55"}, {"mac_address": "00:11:22:33:44:56", "ipv4_address": "fd00::1"}]`, "1.ipv4_address"},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		var body struct {
+			Errors []NodeSchemaError `json:"errors"`
+		}
+		if rec.Code != http.StatusBadRequest || json.NewDecoder(rec.Body).Decode(&body) != nil || len(body.Errors) == 0 {
+			t.Errorf("%s: expected status 400 with schema errors, got %d %+v", tt.body, rec.Code, body)
+			continue
+		}
+		found := false
+		for _, e := range body.Errors {
+			found = found || e.Field == tt.field
+		}
+		if !found {
+			t.Errorf("%s: expected an error for %s, got %+v", tt.body, tt.field, body.Errors)
+		}
+	}
+}
+
+func TestNodeLifecycle(t *testing.T) {
+	r, _ := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	if node.LifecycleState != nodes.LifecycleDiscovered {
+		t.Errorf("expected a new node to be discovered, got %q", node.LifecycleState)
+	}
+
+	patch := func(state string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := `{"lifecycle_state": "` + state + `"}`
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/inventory/ComputeNode/"+node.ID.String()+"/lifecycle", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := patch("provisioned"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 skipping provisioning, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := patch("booting"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown state, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, state := range []string{"provisioning", "failed"} {
+		rec := patch(state)
+		var updated nodes.ComputeNode
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&updated) != nil || string(updated.LifecycleState) != state {
+			t.Fatalf("expected status 200 moving to %s, got %d: %s", state, rec.Code, rec.Body.String())
+		}
+	}
+
+	// A full update can't sidestep the transitions, and leaving the state out keeps it
+	node.LifecycleState = nodes.LifecycleProvisioned
+	update, _ := json.Marshal(node)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), bytes.NewReader(update)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 updating to an unreachable state, got %d: %s", rec.Code, rec.Body.String())
+	}
+	node.LifecycleState = ""
+	update, _ = json.Marshal(node)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), bytes.NewReader(update)))
+	var updated nodes.ComputeNode
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&updated) != nil || updated.LifecycleState != nodes.LifecycleFailed {
+		t.Errorf("expected the update to keep the failed state, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode?lifecycle_state=broken", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 searching for an unknown state, got %d", rec.Code)
+	}
+}
+
+func TestUpdateNodeKeepsNID(t *testing.T) {
+	r, store := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	if node.NID == 0 {
+		t.Fatalf("expected the new node to get a NID")
+	}
+
+	rec := httptest.NewRecorder()
+	body := `{"hostname": "renamed", "architecture": "x86_64", "xname": "x1000c0s1b0n0"}`
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+node.ID.String(), strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, err := store.GetComputeNode(node.ID); err != nil || stored.NID != node.NID || stored.Hostname != "renamed" {
+		t.Errorf("expected the update to keep NID %d, got %+v, %v", node.NID, stored, err)
+	}
+
+	// The NID is still reserved, so the next node doesn't get it
+	if other := createNode(t, r, "x1000c0s2b0n0"); other.NID == node.NID {
+		t.Errorf("expected a different NID than %d for the next node", node.NID)
+	}
+}
+
+func TestUnimplementedStorageAnswers501(t *testing.T) {
+	// Nothing listens on the CSM side, the stubs fail before sending anything
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(csm.NewNodeStorage("http://127.0.0.1:1/", "", time.Second), nil))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/inventory/ComputeNode/00000000-0000-0000-0000-000000000001", ""},
+		{http.MethodGet, "/inventory/ComputeNode?hostname=nid001", ""},
+		{http.MethodPost, "/inventory/ComputeNode", `{"hostname": "nid001", "architecture": "x86_64", "xname": "x1000c0s0b0n0"}`},
+		{http.MethodDelete, "/inventory/bmc/00000000-0000-0000-0000-000000000001", ""},
+		{http.MethodGet, "/inventory/xname/x1000c0s0b0n0", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("%s %s: expected status 501, got %d: %s", tt.method, tt.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestLookupNodesByMAC(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001", BootMac: "de:ad:be:ef:00:01", BMC: &nodes.BMC{Password: "hunter2"}}
+	store.SaveComputeNode(node.ID, node)
+	r := chi.NewRouter()
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/lookup/macs", strings.NewReader(`["DE:AD:BE:EF:00:01", "de-ad-be-ef-00-01", "dead.beef.0001", "de:ad:be:ef:00:02"]`)))
+	var found map[string]*nodes.ComputeNode
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&found) != nil {
+		t.Fatalf("expected status 200 with a map of nodes, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := found["DE:AD:BE:EF:00:01"]; n == nil || n.ID != node.ID || n.BMC.Password != nodes.RedactedPassword {
+		t.Errorf("expected the redacted node for the first MAC, got %+v", n)
+	}
+	// Hyphen and dot notation name the same MAC
+	for _, mac := range []string{"de-ad-be-ef-00-01", "dead.beef.0001"} {
+		if n := found[mac]; n == nil || n.ID != node.ID {
+			t.Errorf("expected the node for %s, got %+v", mac, n)
+		}
+	}
+	if n, ok := found["de:ad:be:ef:00:02"]; !ok || n != nil {
+		t.Errorf("expected null for an unknown MAC, got %+v", n)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/lookup/macs", strings.NewReader(`["not-a-mac"]`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid MAC, got %d", rec.Code)
+	}
+}
+
+func TestCloneNode(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	body := `{"hostname": "nid001", "architecture": "x86_64", "xname": "x1000c0s1b0n0", "boot_mac": "de:ad:be:ef:00:01",
+		"boot_ipv4_address": "10.0.0.1", "boot_data": {"kernel_url": "http://boot/vmlinuz"}, "labels": {"rack": "A3"}}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+	var source nodes.ComputeNode
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&source) != nil {
+		t.Fatalf("expected status 201 creating the source node, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	clone := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode/"+source.ID.String()+"/clone", strings.NewReader(body)))
+		return rec
+	}
+
+	rec = clone(`{"hostname": "nid002", "xname": "x1000c0s2b0n0", "boot_mac": "de:ad:be:ef:00:02"}`)
+	var cloned nodes.ComputeNode
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&cloned) != nil {
+		t.Fatalf("expected status 201 cloning, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if cloned.ID == source.ID || cloned.NID == source.NID || cloned.Hostname != "nid002" || cloned.BootMac != "de:ad:be:ef:00:02" {
+		t.Errorf("expected the clone to get its own identity, got %+v", cloned)
+	}
+	if cloned.BootData == nil || cloned.BootData.KernelURL != "http://boot/vmlinuz" || cloned.Labels["rack"] != "A3" || cloned.Architecture != "x86_64" {
+		t.Errorf("expected the clone to keep the boot data, labels and architecture, got %+v", cloned)
+	}
+	if cloned.BootIPv4Address != "" || cloned.BMC == nil || cloned.BMC.XName.String() != "x1000c0s2b0" {
+		t.Errorf("expected the clone to get its own address and BMC, got %+v", cloned)
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"hostname": "nid003", "xname": "x1000c0s2b0n0"}`, http.StatusConflict},
+		{`{"hostname": "nid003", "boot_mac": "de:ad:be:ef:00:01"}`, http.StatusConflict},
+		{`{"hostname": "nid003", "boot_mac": "nope"}`, http.StatusBadRequest},
+		{`{"xname": "x1000c0s3b0n0"}`, http.StatusBadRequest},
+	} {
+		if rec := clone(tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestIdempotentCreateNode(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	post := func(target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"hostname": "nid001", "architecture": "x86_64", "xname": "x1000c0s1b0n0", "boot_mac": "de:ad:be:ef:00:01"}`
+	rec := post("/inventory/ComputeNode", "create-nid001", body)
+	var created nodes.ComputeNode
+	if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&created) != nil {
+		t.Fatalf("expected status 201 creating the node, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, target := range []string{"/inventory/ComputeNode", "/inventory/ComputeNode?upsert=true"} {
+		key := "create-nid001"
+		if strings.Contains(target, "upsert") {
+			key = ""
+		}
+		rec = post(target, key, body)
+		var again nodes.ComputeNode
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&again) != nil {
+			t.Fatalf("expected status 200 re-posting to %s, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+		if again.ID != created.ID || again.NID != created.NID {
+			t.Errorf("expected the existing node %s back, got %+v", created.ID, again)
+		}
+	}
+
+	rec = post("/inventory/ComputeNode", "create-nid001", strings.Replace(body, "de:ad:be:ef:00:01", "de:ad:be:ef:00:02", 1))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 re-posting a different payload, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = post("/inventory/ComputeNode", "", body)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 re-posting without idempotency, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if all, _ := store.SearchComputeNodes(); len(all) != 1 {
+		t.Errorf("expected a single stored node, got %d", len(all))
+	}
+
+	// A BMC in the payload matches when it resolves to the node's BMC, however it is written,
+	// and a BMC that would be created doesn't
+	withBMC := func(bmc string) string {
+		return `{"hostname": "nid002", "architecture": "x86_64", "xname": "x1000c0s2b0n0", "bmc": ` + bmc + `}`
+	}
+	bmc := `{"xname": "x1000c0s2b0", "mac_address": "de:ad:be:ef:01:02", "username": "root", "password": "secret"}`
+	if rec := post("/inventory/ComputeNode", "create-nid002", withBMC(bmc)); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 creating a node with a BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, bmc := range []string{
+		bmc,
+		`{"xname": "x1000c0s2b0", "mac_address": "de:ad:be:ef:01:02", "username": "root", "password": "***"}`,
+		`{"mac_address": "DE-AD-BE-EF-01-02", "username": "root", "password": "secret"}`,
+	} {
+		if rec := post("/inventory/ComputeNode", "create-nid002", withBMC(bmc)); rec.Code != http.StatusOK {
+			t.Errorf("expected status 200 re-posting with BMC %s, got %d: %s", bmc, rec.Code, rec.Body.String())
+		}
+	}
+	other := `{"mac_address": "de:ad:be:ef:01:03", "username": "root", "password": "secret"}`
+	if rec := post("/inventory/ComputeNode", "create-nid002", withBMC(other)); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 re-posting with another BMC, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNodeTimestamps(t *testing.T) {
+	store := memory.NewInMemoryStorage()
+	r := chi.NewRouter()
+	r.Use(openchami_middleware.OpenCHAMILogger(zerolog.Nop()))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+
+	create := func(body string) nodes.ComputeNode {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/ComputeNode", strings.NewReader(body)))
+		var node nodes.ComputeNode
+		if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&node) != nil {
+			t.Fatalf("expected status 201 creating a node, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return node
+	}
+	search := func(query string) []nodes.ComputeNode {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode?"+query, nil))
+		var found response.PagedResponse[nodes.ComputeNode]
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&found) != nil {
+			t.Fatalf("expected status 200 searching %s, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		return found.Items
+	}
+
+	// Timestamps in the request are ignored
+	first := create(`{"hostname": "nid001", "architecture": "x86_64", "xname": "x1000c0s1b0n0", "created_at": "2000-01-01T00:00:00Z"}`)
+	if first.CreatedAt.IsZero() || !first.CreatedAt.Equal(first.UpdatedAt) || first.CreatedAt.Year() == 2000 {
+		t.Errorf("expected a new node to be created and updated now, got %v and %v", first.CreatedAt, first.UpdatedAt)
+	}
+	if first.BMC == nil || first.BMC.CreatedAt.IsZero() {
+		t.Errorf("expected the inferred BMC to have a creation time, got %+v", first.BMC)
+	}
+	time.Sleep(2 * time.Millisecond)
+	second := create(`{"hostname": "nid002", "architecture": "x86_64", "xname": "x1000c0s2b0n0"}`)
+	time.Sleep(2 * time.Millisecond)
+
+	first.Description = "moved to rack A3"
+	first.CreatedAt = time.Now()
+	body, _ := json.Marshal(first)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/inventory/ComputeNode/"+first.ID.String(), bytes.NewReader(body)))
+	var updated nodes.ComputeNode
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&updated) != nil {
+		t.Fatalf("expected status 200 updating the node, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/ComputeNode/"+first.ID.String(), nil))
+	var fetched nodes.ComputeNode
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&fetched) != nil {
+		t.Fatalf("expected status 200 getting the node, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !fetched.CreatedAt.Equal(updated.CreatedAt) || !fetched.UpdatedAt.Equal(updated.UpdatedAt) || !fetched.UpdatedAt.After(second.UpdatedAt) {
+		t.Errorf("expected the update to keep the creation time and move the update time, got %v and %v", fetched.CreatedAt, fetched.UpdatedAt)
+	}
+
+	after := second.UpdatedAt.Format(time.RFC3339Nano)
+	if found := search("updated_after=" + after); len(found) != 1 || found[0].ID != first.ID {
+		t.Errorf("expected only the updated node after %s, got %v", after, found)
+	}
+	after = fetched.CreatedAt.Format(time.RFC3339Nano)
+	if found := search("created_after=" + after); len(found) != 1 || found[0].ID != second.ID {
+		t.Errorf("expected only the second node created after %s, got %v", after, found)
+	}
+}
+
+func TestPatchNodeStatuses(t *testing.T) {
+	r, store := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s1b0n0")
+	node.Spec = nodes.ComputeNodeSpec{Hostname: "nid001", BMCPassword: "secret"}
+	if err := store.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	body := `{
+		"x1000c0s1b0n0": {"power_state": {"on": true}, "network_interfaces": [{"interface_name": "eth0", "ipv4_address": "10.0.0.1"}]},
+		"x1000c0s9b0n0": {"power_state": {"on": false}}
+	}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/inventory/ComputeNode/status/bulk", strings.NewReader(body)))
+	var results map[string]NodeStatusResult
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&results) != nil {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if results["x1000c0s1b0n0"].Result != StatusUpdated || results["x1000c0s9b0n0"].Result != StatusNotFound {
+		t.Errorf("expected one node updated and one not found, got %+v", results)
+	}
+
+	stored, err := store.GetComputeNode(node.ID)
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if !stored.Status.PowerState.On || len(stored.Status.NetworkInterfaces) != 1 {
+		t.Errorf("expected the status to be replaced, got %+v", stored.Status)
+	}
+	if stored.Spec.Hostname != "nid001" || stored.Spec.BMCPassword != "secret" || stored.Hostname != node.Hostname || stored.BMC == nil {
+		t.Errorf("expected the rest of the node to be left alone, got %+v", stored)
+	}
+	if stored.UpdatedAt.Before(node.UpdatedAt) {
+		t.Errorf("expected updated_at to move forward, got %v after %v", stored.UpdatedAt, node.UpdatedAt)
+	}
+
+	for _, body := range []string{`{}`, `[]`} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/inventory/ComputeNode/status/bulk", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, rec.Code)
+		}
+	}
+}
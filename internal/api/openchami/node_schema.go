@@ -0,0 +1,148 @@
+package openchami
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/invopop/jsonschema"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// nodeSchemaLoader holds the ComputeNode schema.  It is generated by NodeRoutes, after the
+// xname settings that its patterns depend on are in place.
+var nodeSchemaLoader gojsonschema.JSONLoader
+
+// newNodeSchemaLoader generates the ComputeNode JSON schema.  uuid.UUID is a byte array but
+// marshals as a string, so the schema has to say so.
+func newNodeSchemaLoader() gojsonschema.JSONLoader {
+	reflector := jsonschema.Reflector{
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			if t == reflect.TypeOf(uuid.UUID{}) {
+				return &jsonschema.Schema{Type: "string", Format: "uuid"}
+			}
+			return nil
+		},
+	}
+	schemaJSON, err := json.Marshal(reflector.Reflect(&nodes.ComputeNode{}))
+	if err != nil {
+		panic(err)
+	}
+	return gojsonschema.NewBytesLoader(schemaJSON)
+}
+
+// NodeSchemaError is one way in which a request body fails the ComputeNode schema
+type NodeSchemaError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NodeSchemaErrResponse is the 400 body for a node that fails the ComputeNode schema
+type NodeSchemaErrResponse struct {
+	*response.ErrResponse
+	Errors []NodeSchemaError `json:"errors"`
+}
+
+// decodeNode validates the request body against the ComputeNode schema and decodes it into
+// node.  The raw body is validated rather than the decoded node, so that omitted fields such
+// as an empty xname aren't checked against their patterns.  It renders the error response
+// and returns false if the body is unusable.
+func decodeNode(w http.ResponseWriter, r *http.Request, node *nodes.ComputeNode) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		render.Render(w, r, response.ErrInvalidRequest(err))
+		return false
+	}
+	if err := validateNode(body, node); err != nil {
+		var invalid *nodeValidationError
+		if errors.As(err, &invalid) {
+			render.Render(w, r, NodeSchemaErrResponse{
+				ErrResponse: response.ErrInvalidRequest(errors.New(invalid.reason)),
+				Errors:      invalid.errs,
+			})
+		} else {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+		}
+		return false
+	}
+	return true
+}
+
+// nodeValidationError is a node body that fails the schema or has malformed addresses
+type nodeValidationError struct {
+	reason string
+	errs   []NodeSchemaError
+}
+
+func (e *nodeValidationError) Error() string {
+	fields := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		fields = append(fields, err.Field+": "+err.Message)
+	}
+	return e.reason + ": " + strings.Join(fields, "; ")
+}
+
+// validateNode does the checks of decodeNode without a request, for bodies such as the rows of
+// an import.  A body that fails the schema or has malformed addresses is a
+// *nodeValidationError.
+func validateNode(body []byte, node *nodes.ComputeNode) error {
+	result, err := gojsonschema.Validate(nodeSchemaLoader, gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		errs := make([]NodeSchemaError, 0, len(result.Errors()))
+		for _, desc := range result.Errors() {
+			errs = append(errs, NodeSchemaError{Field: desc.Field(), Message: desc.Description()})
+		}
+		return &nodeValidationError{reason: "node does not match the ComputeNode schema", errs: errs}
+	}
+
+	if err := json.Unmarshal(body, node); err != nil {
+		return err
+	}
+	if errs := formatErrors(node); len(errs) > 0 {
+		return &nodeValidationError{reason: "node has malformed addresses", errs: errs}
+	}
+	return nil
+}
+
+// checkFormats renders a 400 listing every address field of v, a node, BMC or list of BMCs,
+// that isn't a valid IPv4, IPv6 or MAC address as its format tag requires.  The schema
+// can't catch these, as it doesn't know the mac-address format and rejects empty addresses.
+// Valid MAC addresses are rewritten in canonical form, which storage lookups compare against,
+// so v has to be a pointer or a slice.
+func checkFormats(w http.ResponseWriter, r *http.Request, what string, v interface{}) bool {
+	errs := formatErrors(v)
+	if len(errs) == 0 {
+		return true
+	}
+	render.Render(w, r, NodeSchemaErrResponse{
+		ErrResponse: response.ErrInvalidRequest(fmt.Errorf("%s has malformed addresses", what)),
+		Errors:      errs,
+	})
+	return false
+}
+
+// formatErrors lists the malformed addresses of v, or canonicalizes its MAC addresses if
+// there are none
+func formatErrors(v interface{}) []NodeSchemaError {
+	formatErrs := nodes.ValidateFormats(v)
+	if len(formatErrs) == 0 {
+		nodes.CanonicalizeMACs(v)
+		return nil
+	}
+	errs := make([]NodeSchemaError, 0, len(formatErrs))
+	for _, err := range formatErrs {
+		errs = append(errs, NodeSchemaError{Field: err.Field, Message: fmt.Sprintf("%q is not a valid %s", err.Value, err.Format)})
+	}
+	return errs
+}
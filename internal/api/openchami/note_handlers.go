@@ -0,0 +1,78 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog/log"
+)
+
+// postNodeNote appends a note to a node.  Only the text comes from the body: the author is the
+// subject of the request's token and the timestamp is when the note was received.
+func postNodeNote(storage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		var note nodes.Note
+		if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if strings.TrimSpace(note.Text) == "" {
+			response.Error(w, r, "note text is required", http.StatusBadRequest)
+			return
+		}
+		if _, err := storage.GetComputeNode(nodeID); err != nil {
+			storageError(w, r, err, "node not found", http.StatusNotFound)
+			return
+		}
+		subject, err := subjectClaim(r)
+		if err != nil {
+			render.Render(w, r, response.ErrUnauthorized(err))
+			return
+		}
+
+		note.Author = subject
+		note.Timestamp = nodes.Timestamp(time.Now())
+		if err := storage.AddNodeNote(nodeID, note); err != nil {
+			log.Error().Err(err).Str("node_id", nodeID.String()).Msg("Error saving node note")
+			storageError(w, r, err, "error saving note", http.StatusInternalServerError)
+			return
+		}
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, note)
+	}
+}
+
+// getNodeNotes lists the notes on a node, oldest first.  Notes outlive their node, so they
+// are listed whether or not the node still exists.
+func getNodeNotes(storage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID, err := uuid.Parse(chi.URLParam(r, "nodeID"))
+		if err != nil {
+			response.Error(w, r, "malformed node ID", http.StatusBadRequest)
+			return
+		}
+		notes, err := storage.GetNodeNotes(nodeID)
+		if err != nil {
+			log.Error().Err(err).Str("node_id", nodeID.String()).Msg("Error reading node notes")
+			storageError(w, r, err, "error reading notes", http.StatusInternalServerError)
+			return
+		}
+		if notes == nil {
+			notes = []nodes.Note{}
+		}
+		render.JSON(w, r, notes)
+	}
+}
@@ -0,0 +1,82 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestNodeNotes(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "admin@example.com"})
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001", XName: xnames.NewNodeXname("x1000c0s1b0n0"), Architecture: nodes.ArchX86_64}
+	if err := store.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Mount("/inventory", NodeRoutes(store, nil))
+	send := func(method, path, body string, authenticated bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	notesPath := "/inventory/ComputeNode/" + node.ID.String() + "/notes"
+
+	for _, text := range []string{"DIMM replaced", "BIOS updated"} {
+		rec := send(http.MethodPost, notesPath, `{"text": "`+text+`", "author": "someone-else"}`, true)
+		var note nodes.Note
+		if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&note) != nil {
+			t.Fatalf("expected status 201 adding a note, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if note.Author != "admin@example.com" || note.Text != text || note.Timestamp.IsZero() {
+			t.Errorf("expected the note to be authored by the token subject, got %+v", note)
+		}
+	}
+
+	for _, tt := range []struct {
+		path, body    string
+		authenticated bool
+		want          int
+	}{
+		{notesPath, `{"text": "  "}`, true, http.StatusBadRequest},
+		{"/inventory/ComputeNode/" + uuid.NewString() + "/notes", `{"text": "hello"}`, true, http.StatusNotFound},
+		{notesPath, `{"text": "hello"}`, false, http.StatusUnauthorized},
+	} {
+		if rec := send(http.MethodPost, tt.path, tt.body, tt.authenticated); rec.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.path, tt.body, tt.want, rec.Code)
+		}
+	}
+
+	// The notes stay behind when the node is deleted
+	if err := store.DeleteComputeNode(node.ID); err != nil {
+		t.Fatalf("failed to delete node: %v", err)
+	}
+	rec := send(http.MethodGet, notesPath, "", false)
+	var notes []nodes.Note
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&notes) != nil {
+		t.Fatalf("expected status 200 listing notes, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(notes) != 2 || notes[0].Text != "DIMM replaced" || notes[1].Text != "BIOS updated" {
+		t.Errorf("expected both notes oldest first, got %+v", notes)
+	}
+}
@@ -0,0 +1,65 @@
+package openchami
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// parseFields splits a comma separated fields parameter, dropping empty entries
+func parseFields(param string) []string {
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// projectFields returns only the requested JSON keys of v.  A field may name a nested key
+// with a dotted path such as status.power_state, which is returned under the same nesting.
+// Fields that don't exist in v are ignored.
+func projectFields(v interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	projection := make(map[string]interface{})
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		value, ok := lookupPath(document, path)
+		if !ok {
+			continue
+		}
+		target := projection
+		for _, key := range path[:len(path)-1] {
+			next, ok := target[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[key] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	return projection, nil
+}
+
+func lookupPath(document map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = document
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
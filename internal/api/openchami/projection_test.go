@@ -0,0 +1,36 @@
+package openchami
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestProjectFields(t *testing.T) {
+	node := nodes.ComputeNode{
+		ID:           uuid.MustParse("8a6e3f8c-3e5e-4f0a-9d5c-6d2f8f6f8f11"),
+		XName:        xnames.NewNodeXname("x3000c0s1b0n0"),
+		Hostname:     "nid001",
+		Architecture: "x86_64",
+		Status:       nodes.ComputeNodeStatus{PowerState: nodes.PowerState{On: true}},
+	}
+
+	got, err := projectFields(node, parseFields("id, xname,hostname,status.power_state.on,,bogus,status.bogus"))
+	if err != nil {
+		t.Fatalf("projection failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"id":       "8a6e3f8c-3e5e-4f0a-9d5c-6d2f8f6f8f11",
+		"xname":    "x3000c0s1b0n0",
+		"hostname": "nid001",
+		"status": map[string]interface{}{
+			"power_state": map[string]interface{}{"on": true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
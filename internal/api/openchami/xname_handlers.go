@@ -0,0 +1,119 @@
+package openchami
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+// componentLookup is implemented by storage backends that also hold SMD components
+type componentLookup interface {
+	GetComponentByXname(xname string) (smd.Component, error)
+}
+
+// XNameDetail combines everything known about an xname.  Sections that don't exist are null.
+type XNameDetail struct {
+	Node      *nodes.ComputeNode `json:"node"`
+	Component *smd.Component     `json:"component"`
+	BMC       *nodes.BMC         `json:"bmc"`
+}
+
+func getXNameDetail(myStorage storage.NodeStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		xname := chi.URLParam(r, "xname")
+		var detail XNameDetail
+
+		// Each section is a single indexed lookup: the node and BMC by their xname columns
+		// (or the BMC by ID) and the component by its primary key
+		node, err := myStorage.LookupComputeNodeByXName(xname)
+		switch {
+		case err == nil:
+			detail.Node = &node
+		case errors.Is(err, storage.ErrNotImplemented):
+			storageError(w, r, err, "", http.StatusInternalServerError)
+			return
+		}
+		if components, ok := myStorage.(componentLookup); ok {
+			if component, err := components.GetComponentByXname(xname); err == nil {
+				detail.Component = &component
+			}
+		}
+		switch {
+		case detail.Node != nil && detail.Node.BMC != nil:
+			if bmc, err := myStorage.GetBMC(detail.Node.BMC.ID); err == nil {
+				detail.BMC = &bmc
+			} else {
+				detail.BMC = detail.Node.BMC
+			}
+		case detail.Node != nil:
+			if bmc, err := myStorage.LookupBMCByXName(inferBMCXName(detail.Node.XName).String()); err == nil {
+				detail.BMC = &bmc
+			}
+		default:
+			// The xname may name a BMC rather than a node
+			if xnames.IsValidBMCXName(xname) {
+				if bmc, err := myStorage.LookupBMCByXName(xname); err == nil {
+					detail.BMC = &bmc
+				}
+			}
+		}
+
+		if detail.Node == nil && detail.Component == nil && detail.BMC == nil {
+			response.Error(w, r, "nothing found for xname "+xname, http.StatusNotFound)
+			return
+		}
+		if detail.Node != nil {
+			node := detail.Node.Redacted()
+			detail.Node = &node
+		}
+		if detail.BMC != nil {
+			bmc := detail.BMC.Redacted()
+			detail.BMC = &bmc
+		}
+		render.JSON(w, r, detail)
+	}
+}
+
+// XNameBuildResponse is the body of POST /xnames/build
+type XNameBuildResponse struct {
+	XName string `json:"xname"`
+}
+
+// buildXName formats the xnames.XNameComponents in the body as a canonical xname.  The type is
+// "n" for a node, the default, or "b" for a BMC; fields the type doesn't use are ignored.
+func buildXName(w http.ResponseWriter, r *http.Request) {
+	var components xnames.XNameComponents
+	if err := json.NewDecoder(r.Body).Decode(&components); err != nil {
+		render.Render(w, r, response.ErrInvalidRequest(err))
+		return
+	}
+	if components.Cabinet < 0 || components.Chassis < 0 || components.Slot < 0 || components.BMCPosition < 0 || components.NodePosition < 0 {
+		response.Error(w, r, "xname components must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	var xname string
+	switch components.Type {
+	case xnames.TypeNode, "":
+		xname = components.NodeXName()
+	case xnames.TypeBMC:
+		xname = components.BMCXName()
+	default:
+		response.Error(w, r, fmt.Sprintf("cannot build an xname of type %q", components.Type), http.StatusBadRequest)
+		return
+	}
+	if _, err := xnames.ParseXName(xname); err != nil {
+		response.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	render.JSON(w, r, XNameBuildResponse{XName: xname})
+}
@@ -0,0 +1,88 @@
+package openchami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+)
+
+func TestGetXNameDetail(t *testing.T) {
+	r, store := newTestRouter(t)
+	node := createNode(t, r, "x1000c0s4b0n0")
+	if err := store.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s4b0n0", Type: smd.TypeNode, NID: 4}}); err != nil {
+		t.Fatalf("failed to create component: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/xname/x1000c0s4b0n0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var detail XNameDetail
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if detail.Node == nil || detail.Node.ID != node.ID {
+		t.Errorf("expected node %s, got %+v", node.ID, detail.Node)
+	}
+	if detail.Component == nil || detail.Component.NID != 4 {
+		t.Errorf("expected the component with NID 4, got %+v", detail.Component)
+	}
+	if detail.BMC == nil || detail.BMC.ID != node.BMC.ID || detail.BMC.XName.String() != "x1000c0s4b0" {
+		t.Errorf("expected BMC %s at x1000c0s4b0, got %+v", node.BMC.ID, detail.BMC)
+	}
+
+	// Missing sections are null and an unknown xname is a 404
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/xname/x1000c0s4b0", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the BMC xname, got %d", rec.Code)
+	}
+	detail = XNameDetail{}
+	json.NewDecoder(rec.Body).Decode(&detail)
+	if detail.Node != nil || detail.Component != nil || detail.BMC == nil {
+		t.Errorf("expected only the BMC section for a BMC xname, got %+v", detail)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/xname/x1000c0s9b0n0", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown xname, got %d", rec.Code)
+	}
+}
+
+func TestBuildXName(t *testing.T) {
+	r := chi.NewRouter()
+	r.Mount("/inventory", NodeRoutes(memory.NewInMemoryStorage(), nil))
+
+	tests := []struct {
+		body  string
+		code  int
+		xname string
+	}{
+		{`{"cabinet": 1000, "chassis": 0, "slot": 7, "bmc_position": 1, "node_position": 1}`, http.StatusOK, "x1000c0s7b1n1"},
+		{`{"cabinet": 3, "chassis": 1, "slot": 2, "bmc_position": 0, "type": "b"}`, http.StatusOK, "x003c1s2b0"},
+		{`{"cabinet": 1000, "chassis": 256, "type": "b"}`, http.StatusBadRequest, ""},
+		{`{"cabinet": 1000, "slot": -1}`, http.StatusBadRequest, ""},
+		{`{"cabinet": 1000, "router": 1, "type": "r"}`, http.StatusBadRequest, ""},
+		{`{"cabinet": "x1000"}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/inventory/xnames/build", strings.NewReader(tt.body)))
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.code, rec.Code, rec.Body.String())
+			continue
+		}
+		var built XNameBuildResponse
+		if tt.code == http.StatusOK && (json.NewDecoder(rec.Body).Decode(&built) != nil || built.XName != tt.xname) {
+			t.Errorf("%s: expected %q, got %q", tt.body, tt.xname, built.XName)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package response renders the JSON error bodies and list envelopes shared by the API handlers.
+package response
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+)
+
+// ErrResponse renderer type for handling all sorts of errors.
+//
+// In the best case scenario, the excellent github.com/pkg/errors package
+// helps reveal information on the error, setting it on Err, and in the Render()
+// method, using it to set the application-specific error code in AppCode.
+type ErrResponse struct {
+	Err            error `json:"-"` // low-level runtime error
+	HTTPStatusCode int   `json:"-"` // http response status code
+
+	StatusText string `json:"status"`          // user-level status message
+	AppCode    int64  `json:"code"`            // application-specific error code, the HTTP status unless set otherwise
+	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+}
+
+func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, e.HTTPStatusCode)
+	return nil
+}
+
+func newErrResponse(status int, statusText string, err error) *ErrResponse {
+	e := &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: status,
+		StatusText:     statusText,
+		AppCode:        int64(status),
+	}
+	if err != nil {
+		e.ErrorText = err.Error()
+	}
+	return e
+}
+
+// Error is the JSON counterpart of http.Error: it renders message as an ErrResponse with status
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	render.Render(w, r, newErrResponse(status, http.StatusText(status)+".", errors.New(message)))
+}
+
+// ErrInvalidRequest renders a 400, or a 413 if err comes from reading past the body size limit
+func ErrInvalidRequest(err error) *ErrResponse {
+	if openchami_middleware.BodyErrorStatus(err) == http.StatusRequestEntityTooLarge {
+		return newErrResponse(http.StatusRequestEntityTooLarge, "Request body too large.", err)
+	}
+	return newErrResponse(http.StatusBadRequest, "Invalid request.", err)
+}
+
+func ErrUnauthorized(err error) *ErrResponse {
+	return newErrResponse(http.StatusUnauthorized, "Unauthorized.", err)
+}
+
+func ErrNotFound(err error) *ErrResponse {
+	return newErrResponse(http.StatusNotFound, "Resource not found.", err)
+}
+
+func ErrConflict(err error) *ErrResponse {
+	return newErrResponse(http.StatusConflict, "Conflict.", err)
+}
+
+func ErrInternalServer(err error) *ErrResponse {
+	return newErrResponse(http.StatusInternalServerError, "Internal server error.", err)
+}
@@ -0,0 +1,49 @@
+package response
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/render"
+)
+
+func TestErrorBodies(t *testing.T) {
+	tooLarge := httptest.NewRecorder()
+	body := http.MaxBytesReader(tooLarge, io.NopCloser(strings.NewReader(strings.Repeat("x", 10))), 1)
+	_, readErr := body.Read(make([]byte, 10))
+
+	tests := []struct {
+		name   string
+		write  func(w http.ResponseWriter, r *http.Request)
+		status int
+		error  string
+	}{
+		{"Error", func(w http.ResponseWriter, r *http.Request) { Error(w, r, "node not found", http.StatusNotFound) }, http.StatusNotFound, "node not found"},
+		{"ErrInvalidRequest", func(w http.ResponseWriter, r *http.Request) {
+			render.Render(w, r, ErrInvalidRequest(json.Unmarshal([]byte("{"), &struct{}{})))
+		}, http.StatusBadRequest, "unexpected end of JSON input"},
+		{"ErrInvalidRequest too large", func(w http.ResponseWriter, r *http.Request) { render.Render(w, r, ErrInvalidRequest(readErr)) }, http.StatusRequestEntityTooLarge, readErr.Error()},
+		{"ErrConflict", func(w http.ResponseWriter, r *http.Request) { render.Render(w, r, ErrConflict(http.ErrBodyNotAllowed)) }, http.StatusConflict, http.ErrBodyNotAllowed.Error()},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.write(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: expected a JSON body, got Content-Type %q", tt.name, ct)
+		}
+		var got map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: failed to decode body: %v", tt.name, err)
+		}
+		if got["code"] != float64(tt.status) || got["error"] != tt.error || got["status"] == "" {
+			t.Errorf("%s: unexpected body %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PagedResponse is the body of the list endpoints: the page of Items that starts at Offset,
+// at most Limit of them, out of the Total that matched.  A Limit of 0 is no limit.
+type PagedResponse[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Paginate cuts the page of items from offset, at most limit of them
+func Paginate[T any](items []T, limit, offset int) PagedResponse[T] {
+	page := PagedResponse[T]{Total: len(items), Limit: limit, Offset: offset}
+	items = items[min(offset, len(items)):]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	page.Items = items
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
+
+// PageParams reads the optional limit and offset query parameters, which default to 0
+func PageParams(query url.Values) (limit, offset int, err error) {
+	if limit, err = nonNegativeParam(query.Get("limit")); err != nil {
+		return 0, 0, fmt.Errorf("invalid limit: %w", err)
+	}
+	if offset, err = nonNegativeParam(query.Get("offset")); err != nil {
+		return 0, 0, fmt.Errorf("invalid offset: %w", err)
+	}
+	return limit, offset, nil
+}
+
+// nonNegativeParam parses an optional count from a query parameter, treating a missing one as zero
+func nonNegativeParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%d is negative", n)
+	}
+	return n, nil
+}
+
+// List writes the page of items as a PagedResponse.  Clients written before the envelope
+// existed get the page as a bare array with ?envelope=false.
+func List[T any](w http.ResponseWriter, r *http.Request, items []T, limit, offset int) {
+	writePage(w, r, Paginate(items, limit, offset))
+}
+
+// ListPage is List for items that storage has already cut to the page, out of total matches
+func ListPage[T any](w http.ResponseWriter, r *http.Request, items []T, total, limit, offset int) {
+	if items == nil {
+		items = []T{}
+	}
+	writePage(w, r, PagedResponse[T]{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+func writePage[T any](w http.ResponseWriter, r *http.Request, page PagedResponse[T]) {
+	if r.URL.Query().Get("envelope") == "false" {
+		json.NewEncoder(w).Encode(page.Items)
+		return
+	}
+	json.NewEncoder(w).Encode(page)
+}
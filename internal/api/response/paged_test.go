@@ -0,0 +1,52 @@
+package response
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{0, 0, []string{"a", "b", "c", "d"}},
+		{2, 0, []string{"a", "b"}},
+		{2, 3, []string{"d"}},
+		{0, 1, []string{"b", "c", "d"}},
+		{1, 10, []string{}},
+	}
+	for _, tt := range tests {
+		page := Paginate(items, tt.limit, tt.offset)
+		if page.Total != len(items) || page.Limit != tt.limit || page.Offset != tt.offset {
+			t.Errorf("limit %d offset %d: unexpected page %+v", tt.limit, tt.offset, page)
+		}
+		if page.Items == nil || len(page.Items) != len(tt.want) {
+			t.Errorf("limit %d offset %d: expected %v, got %v", tt.limit, tt.offset, tt.want, page.Items)
+			continue
+		}
+		for i := range tt.want {
+			if page.Items[i] != tt.want[i] {
+				t.Errorf("limit %d offset %d: expected %v, got %v", tt.limit, tt.offset, tt.want, page.Items)
+				break
+			}
+		}
+	}
+
+	if page := Paginate[string](nil, 0, 0); page.Items == nil || page.Total != 0 {
+		t.Errorf("expected an empty page rather than null items, got %+v", page)
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	limit, offset, err := PageParams(url.Values{"limit": {"5"}, "offset": {"10"}})
+	if err != nil || limit != 5 || offset != 10 {
+		t.Errorf("expected limit 5 and offset 10, got %d, %d, %v", limit, offset, err)
+	}
+	for _, query := range []url.Values{{"limit": {"-1"}}, {"offset": {"x"}}} {
+		if _, _, err := PageParams(query); err == nil {
+			t.Errorf("expected an error for %v", query)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package smd
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
 	"github.com/google/uuid"
 	"github.com/invopop/jsonschema"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
 )
 
 // Component represents a CSM Component
@@ -25,6 +30,66 @@ type Component struct {
 	Locked              bool             `json:"Locked,omitempty" db:"locked"`
 }
 
+// ComponentSummary counts the components by each of their state, role, architecture and
+// class.  Components without a value are counted under "".
+type ComponentSummary struct {
+	Total int            `json:"Total"`
+	State map[string]int `json:"State"`
+	Role  map[string]int `json:"Role"`
+	Arch  map[string]int `json:"Arch"`
+	Class map[string]int `json:"Class"`
+}
+
+// ErrUnknownComponentColumn is wrapped by errors for updates to a column that can't be set
+var ErrUnknownComponentColumn = errors.New("unknown component column")
+
+// ErrDuplicateNID is wrapped by errors for writes that would give a NID to a second component.
+// CSM assumes that a NID identifies one node, so NIDs other than 0 must be unique.
+var ErrDuplicateNID = errors.New("duplicate NID")
+
+// ComponentDataColumns are the component columns that UpdateComponentData may set.  uid and
+// id identify the component, so they are not among them.
+var ComponentDataColumns = []string{
+	"type", "subtype", "role", "sub_role", "net_type", "arch", "class", "state",
+	"flag", "enabled", "sw_status", "nid", "reservation_disabled", "locked",
+}
+
+// ValidateComponentData checks that every key of data is one of columns, or of
+// ComponentDataColumns if no columns are given.  Keys are checked in sorted order so that the
+// same column is reported each time when several are unknown.
+func ValidateComponentData(data map[string]interface{}, columns ...string) error {
+	if len(columns) == 0 {
+		columns = ComponentDataColumns
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		allowed := false
+		for _, column := range columns {
+			if key == column {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %q", ErrUnknownComponentColumn, key)
+		}
+	}
+	return nil
+}
+
+// ValidateComponentParams is ValidateComponentData for string valued filters
+func ValidateComponentParams(params map[string]string) error {
+	data := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		data[key] = value
+	}
+	return ValidateComponentData(data)
+}
+
 type ComponentType string
 
 const (
@@ -128,6 +193,15 @@ const (
 	StateReady   ComponentState = "Ready"   // Both On and Ready to provide its expected services, i.e. used for jobs.
 )
 
+// Valid reports whether s is one of the known component states
+func (s ComponentState) Valid() bool {
+	switch s {
+	case StateUnknown, StateEmpty, StatePopulated, StateOff, StateOn, StateStandby, StateHalt, StateReady:
+		return true
+	}
+	return false
+}
+
 func (ComponentState) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
@@ -156,6 +230,15 @@ const (
 	FlagLocked  ComponentFlag = "Locked"  // Another service has reserved this component.
 )
 
+// Valid reports whether f is one of the known component flags
+func (f ComponentFlag) Valid() bool {
+	switch f {
+	case FlagUnknown, FlagOK, FlagWarning, FlagAlert, FlagLocked:
+		return true
+	}
+	return false
+}
+
 func (ComponentFlag) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
@@ -182,6 +265,15 @@ const (
 	RoleManagement  ComponentRole = "Management"
 )
 
+// Valid reports whether r is one of the known component roles
+func (r ComponentRole) Valid() bool {
+	switch r {
+	case RoleCompute, RoleService, RoleSystem, RoleApplication, RoleStorage, RoleManagement:
+		return true
+	}
+	return false
+}
+
 func (ComponentRole) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
@@ -251,6 +343,30 @@ const (
 	ArchOther   ComponentArch = "Other"
 )
 
+// ComponentArchFor returns the SMD architecture of a node with the given
+// ComputeNode.Architecture
+func ComponentArchFor(architecture string) ComponentArch {
+	arch, err := nodes.NormalizeArchitecture(architecture)
+	switch {
+	case err != nil:
+		return ArchOther
+	case arch == nodes.ArchX86_64:
+		return ArchX86
+	case arch == nodes.ArchARM64:
+		return ArchARM
+	}
+	return ArchUnknown
+}
+
+// Valid reports whether a is one of the known component architectures
+func (a ComponentArch) Valid() bool {
+	switch a {
+	case ArchX86, ArchARM, ArchUnknown, ArchOther:
+		return true
+	}
+	return false
+}
+
 func (ComponentArch) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
@@ -273,6 +389,15 @@ const (
 	ClassOther    ComponentClass = "Other"
 )
 
+// Valid reports whether c is one of the known component classes
+func (c ComponentClass) Valid() bool {
+	switch c {
+	case ClassRiver, ClassMountain, ClassHill, ClassOther:
+		return true
+	}
+	return false
+}
+
 func (ComponentClass) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
@@ -0,0 +1,16 @@
+package smd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateComponentDataReportsFirstUnknownColumn(t *testing.T) {
+	data := map[string]interface{}{"state": "Ready", "zone": 1, "colour": "red", "owner": "x", "bogus": true}
+	for i := 0; i < 20; i++ {
+		err := ValidateComponentData(data)
+		if !errors.Is(err, ErrUnknownComponentColumn) || err.Error() != ErrUnknownComponentColumn.Error()+`: "bogus"` {
+			t.Fatalf("expected the first unknown column in key order to be reported, got %v", err)
+		}
+	}
+}
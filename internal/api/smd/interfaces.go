@@ -1,5 +1,15 @@
 package smd
 
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
 // CompEthInterface represents the SMD version of a network interface
 type CompEthInterface struct {
 	ID         string `json:"ID"`
@@ -17,3 +27,48 @@ type IPAddressMapping struct {
 	IPAddr  string `json:"IPAddress"`
 	Network string `json:"Network,omitempty"`
 }
+
+// EthernetInterfaceFilter narrows a listing of ethernet interfaces.  Empty fields match everything.
+type EthernetInterfaceFilter struct {
+	ComponentID string
+	MACAddress  string
+}
+
+// EthernetInterfaceID returns the ID SMD gives the interface with mac: the address in lower
+// case without separators
+func EthernetInterfaceID(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
+
+func getEthernetInterfaces(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := EthernetInterfaceFilter{
+			ComponentID: r.URL.Query().Get("ComponentID"),
+			MACAddress:  r.URL.Query().Get("MACAddress"),
+		}
+		interfaces, err := storage.GetEthernetInterfaces(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if interfaces == nil {
+			interfaces = []CompEthInterface{}
+		}
+		json.NewEncoder(w).Encode(interfaces)
+	}
+}
+
+func getEthernetInterfaceByID(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		intf, err := storage.GetEthernetInterfaceByID(chi.URLParam(r, "id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "ethernet interface not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(intf)
+	}
+}
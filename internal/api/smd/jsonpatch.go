@@ -0,0 +1,339 @@
+package smd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONPatchContentType is the media type of an RFC 6902 JSON Patch document
+const JSONPatchContentType = "application/json-patch+json"
+
+var (
+	// ErrInvalidPatch is wrapped by errors for patches that are malformed or cannot be applied
+	ErrInvalidPatch = errors.New("invalid JSON patch")
+	// ErrPatchTestFailed is wrapped by errors for patches whose test operation did not match
+	ErrPatchTestFailed = errors.New("JSON patch test failed")
+)
+
+// PatchOperation is a single RFC 6902 operation.  Value is kept raw so that a missing value
+// can be told apart from null.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is an RFC 6902 JSON Patch document
+type Patch []PatchOperation
+
+// Validate checks the operations and pointers without applying them
+func (p Patch) Validate() error {
+	for i, op := range p {
+		if _, err := parsePointer(op.Path); err != nil {
+			return fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return fmt.Errorf("%w: operation %d: %s requires a value", ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return fmt.Errorf("%w: operation %d: from: %v", ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return fmt.Errorf("%w: operation %d: unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+	}
+	return nil
+}
+
+// Apply applies the patch to doc, a value decoded from JSON into interface{}, and returns the
+// patched document.  doc may be modified in place.
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	for i, op := range p {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return doc, nil
+}
+
+func (op PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, _ := parsePointer(op.Path)
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, _, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "move":
+		from, _ := parsePointer(op.From)
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("cannot move %q into its own child %q", op.From, op.Path)
+		}
+		doc, value, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		// Round trip the value so the copy doesn't share maps or slices with the original
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		var copied interface{}
+		if err := json.Unmarshal(raw, &copied); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, copied)
+	case "test":
+		want, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := getValue(doc, path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			return nil, fmt.Errorf("%w: %s is not %s", ErrPatchTestFailed, op.Path, op.Value)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// value decodes the operation's value afresh, so every document it is applied to gets its own copy
+func (op PatchOperation) value() (interface{}, error) {
+	var value interface{}
+	err := json.Unmarshal(op.Value, &value)
+	return value, err
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token.  "-" is only accepted, as the end of the array,
+// when allowEnd is set.
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || strconv.Itoa(index) != token {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			doc = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", doc, token)
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds value at path and returns the updated document.  Arrays may be reallocated,
+// which is why every level hands its updated value back to its parent.
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		updated, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		updated, err := addValue(node[index], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot index %T with %q", doc, token)
+}
+
+// removeValue removes the value at path and returns the updated document and the removed value
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	token, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q not found", token)
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, child, nil
+		}
+		updated, removed, err := removeValue(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = updated
+		return node, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := node[index]
+			return append(node[:index], node[index+1:]...), removed, nil
+		}
+		updated, removed, err := removeValue(node[index], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[index] = updated
+		return node, removed, nil
+	}
+	return nil, nil, fmt.Errorf("cannot index %T with %q", doc, token)
+}
+
+// PatchComponent applies patch to the JSON representation of c.  Fields that are empty, and
+// so left out of the JSON, are filled in with their zero values first so that they can be
+// tested and replaced like any other field.  The patch may not change the component's ID or UID.
+func PatchComponent(c Component, patch Patch) (Component, error) {
+	doc, err := componentDocument(c)
+	if err != nil {
+		return Component{}, err
+	}
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		return Component{}, err
+	}
+	raw, err := json.Marshal(patched)
+	if err != nil {
+		return Component{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var result Component
+	if err := decoder.Decode(&result); err != nil {
+		return Component{}, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if result.ID != c.ID || result.UID != c.UID {
+		return Component{}, fmt.Errorf("%w: the ID and UID of a component cannot be patched", ErrInvalidPatch)
+	}
+	return result, nil
+}
+
+func componentDocument(c Component) (map[string]interface{}, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if _, ok := doc[name]; ok || name == "" || name == "-" {
+			continue
+		}
+		raw, err := json.Marshal(reflect.Zero(t.Field(i).Type).Interface())
+		if err != nil {
+			return nil, err
+		}
+		var zero interface{}
+		if err := json.Unmarshal(raw, &zero); err != nil {
+			return nil, err
+		}
+		doc[name] = zero
+	}
+	return doc, nil
+}
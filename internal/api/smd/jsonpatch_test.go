@@ -0,0 +1,91 @@
+package smd
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{"add member", `{"a": 1}`, `[{"op": "add", "path": "/b", "value": 2}]`, `{"a": 1, "b": 2}`, nil},
+		{"add to array", `{"a": [1, 3]}`, `[{"op": "add", "path": "/a/1", "value": 2}, {"op": "add", "path": "/a/-", "value": 4}]`, `{"a": [1, 2, 3, 4]}`, nil},
+		{"remove", `{"a": [1, 2], "b": 1}`, `[{"op": "remove", "path": "/a/0"}, {"op": "remove", "path": "/b"}]`, `{"a": [2]}`, nil},
+		{"replace", `{"a": {"b": "c"}}`, `[{"op": "replace", "path": "/a/b", "value": "d"}]`, `{"a": {"b": "d"}}`, nil},
+		{"move", `{"a": {"b": 1}, "c": {}}`, `[{"op": "move", "from": "/a/b", "path": "/c/d"}]`, `{"a": {}, "c": {"d": 1}}`, nil},
+		{"copy", `{"a": [1]}`, `[{"op": "copy", "from": "/a", "path": "/b"}, {"op": "add", "path": "/b/-", "value": 2}]`, `{"a": [1], "b": [1, 2]}`, nil},
+		{"escaped pointer", `{"a/b": 1, "c~d": 2}`, `[{"op": "test", "path": "/a~1b", "value": 1}, {"op": "remove", "path": "/c~0d"}]`, `{"a/b": 1}`, nil},
+		{"test passes", `{"a": {"b": [1, "x"]}}`, `[{"op": "test", "path": "/a", "value": {"b": [1, "x"]}}]`, `{"a": {"b": [1, "x"]}}`, nil},
+		{"test fails", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}]`, ``, ErrPatchTestFailed},
+		{"test missing member", `{}`, `[{"op": "test", "path": "/a", "value": 1}]`, ``, ErrPatchTestFailed},
+		{"replace missing member", `{}`, `[{"op": "replace", "path": "/a", "value": 1}]`, ``, ErrInvalidPatch},
+		{"remove out of range", `{"a": []}`, `[{"op": "remove", "path": "/a/0"}]`, ``, ErrInvalidPatch},
+		{"leading zero index", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/01"}]`, ``, ErrInvalidPatch},
+		{"move into child", `{"a": {}}`, `[{"op": "move", "from": "/a", "path": "/a/b"}]`, ``, ErrInvalidPatch},
+		{"unknown op", `{}`, `[{"op": "frobnicate", "path": "/a"}]`, ``, ErrInvalidPatch},
+		{"missing value", `{}`, `[{"op": "add", "path": "/a"}]`, ``, ErrInvalidPatch},
+		{"relative pointer", `{}`, `[{"op": "remove", "path": "a"}]`, ``, ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatalf("%s: bad document: %v", tt.name, err)
+		}
+		var patch Patch
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatalf("%s: bad patch: %v", tt.name, err)
+		}
+
+		got, err := patch.Apply(doc)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		var want interface{}
+		json.Unmarshal([]byte(tt.want), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, want, got)
+		}
+	}
+}
+
+func TestPatchComponent(t *testing.T) {
+	c := Component{ID: "x1000c0s0b0n0", Type: TypeNode, State: "On"}
+
+	// Empty fields are present in the document, so they can be replaced
+	patched, err := PatchComponent(c, Patch{
+		{Op: "test", Path: "/State", Value: json.RawMessage(`"On"`)},
+		{Op: "replace", Path: "/State", Value: json.RawMessage(`"Ready"`)},
+		{Op: "replace", Path: "/Flag", Value: json.RawMessage(`"OK"`)},
+	})
+	if err != nil {
+		t.Fatalf("PatchComponent failed: %v", err)
+	}
+	if patched.State != "Ready" || patched.Flag != "OK" || patched.Type != TypeNode {
+		t.Errorf("unexpected patched component: %+v", patched)
+	}
+
+	for name, patch := range map[string]Patch{
+		"ID":            {{Op: "replace", Path: "/ID", Value: json.RawMessage(`"x1000c0s0b0n1"`)}},
+		"unknown field": {{Op: "add", Path: "/Color", Value: json.RawMessage(`"red"`)}},
+		"wrong type":    {{Op: "replace", Path: "/NID", Value: json.RawMessage(`"one"`)}},
+	} {
+		if _, err := PatchComponent(c, patch); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected ErrInvalidPatch, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package smd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ComponentQuery is the request body accepted by the POST /State/Components/Query and
+// /State/Components/ByNID/Query routes.  NIDRanges entries are either a single NID ("7")
+// or an inclusive range ("1-16").
+type ComponentQuery struct {
+	ComponentIDs []string `json:"ComponentIDs,omitempty"`
+	NIDs         []int    `json:"NIDs,omitempty"`
+	NIDRanges    []string `json:"NIDRanges,omitempty"`
+}
+
+// NIDRange is an inclusive range of node IDs
+type NIDRange struct {
+	Start int
+	End   int
+}
+
+// ComponentFilter selects components by xname, NID and component columns.  A component
+// matches when its ID is in IDs (if any are given), its NID is in NIDs or any of NIDRanges (if
+// any are given) and it has each of the column values that are set.
+type ComponentFilter struct {
+	IDs       []string
+	NIDs      []int
+	NIDRanges []NIDRange
+	Role      ComponentRole
+	State     ComponentState
+	Arch      ComponentArch
+	Class     ComponentClass
+	Enabled   *bool
+}
+
+// ParseNIDRange parses "start-end" or a single NID into a NIDRange
+func ParseNIDRange(s string) (NIDRange, error) {
+	start, end, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	first, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil || first < 0 {
+		return NIDRange{}, fmt.Errorf("invalid NID range %q", s)
+	}
+	if !isRange {
+		return NIDRange{Start: first, End: first}, nil
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(end))
+	if err != nil || last < first {
+		return NIDRange{}, fmt.Errorf("invalid NID range %q", s)
+	}
+	return NIDRange{Start: first, End: last}, nil
+}
+
+// Filter converts the query into a ComponentFilter, parsing the NID ranges
+func (q ComponentQuery) Filter() (ComponentFilter, error) {
+	filter := ComponentFilter{IDs: q.ComponentIDs, NIDs: q.NIDs}
+	for _, s := range q.NIDRanges {
+		nidRange, err := ParseNIDRange(s)
+		if err != nil {
+			return ComponentFilter{}, err
+		}
+		filter.NIDRanges = append(filter.NIDRanges, nidRange)
+	}
+	return filter, nil
+}
+
+// HasColumns reports whether the filter restricts components by any column other than ID and NID
+func (f ComponentFilter) HasColumns() bool {
+	return f.Role != "" || f.State != "" || f.Arch != "" || f.Class != "" || f.Enabled != nil
+}
+
+// HasNIDs reports whether the filter restricts components by NID
+func (f ComponentFilter) HasNIDs() bool {
+	return len(f.NIDs) > 0 || len(f.NIDRanges) > 0
+}
+
+// ComponentFilterFromQuery reads the role, state, arch, class and enabled query parameters of
+// GET /State/Components.  Other parameters are ignored.
+func ComponentFilterFromQuery(query url.Values) (ComponentFilter, error) {
+	var filter ComponentFilter
+	if role := query.Get("role"); role != "" {
+		filter.Role = ComponentRole(role)
+		if !filter.Role.Valid() {
+			return filter, fmt.Errorf("invalid role %q", role)
+		}
+	}
+	if state := query.Get("state"); state != "" {
+		filter.State = ComponentState(state)
+		if !filter.State.Valid() {
+			return filter, fmt.Errorf("invalid state %q", state)
+		}
+	}
+	if arch := query.Get("arch"); arch != "" {
+		filter.Arch = ComponentArch(arch)
+		if !filter.Arch.Valid() {
+			return filter, fmt.Errorf("invalid arch %q", arch)
+		}
+	}
+	if class := query.Get("class"); class != "" {
+		filter.Class = ComponentClass(class)
+		if !filter.Class.Valid() {
+			return filter, fmt.Errorf("invalid class %q", class)
+		}
+	}
+	if enabled := query.Get("enabled"); enabled != "" {
+		value, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return filter, fmt.Errorf("invalid enabled %q", enabled)
+		}
+		filter.Enabled = &value
+	}
+	return filter, nil
+}
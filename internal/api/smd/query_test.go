@@ -0,0 +1,29 @@
+package smd
+
+import "testing"
+
+func TestParseNIDRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    NIDRange
+		wantErr bool
+	}{
+		{"7", NIDRange{Start: 7, End: 7}, false},
+		{"1-16", NIDRange{Start: 1, End: 16}, false},
+		{" 2 - 3 ", NIDRange{Start: 2, End: 3}, false},
+		{"16-1", NIDRange{}, true},
+		{"-4", NIDRange{}, true},
+		{"a-b", NIDRange{}, true},
+		{"", NIDRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseNIDRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNIDRange(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseNIDRange(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
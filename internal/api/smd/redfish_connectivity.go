@@ -0,0 +1,116 @@
+package smd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// redfishTestTimeout bounds a connectivity test, so that an unreachable BMC doesn't hold the request
+const redfishTestTimeout = 5 * time.Second
+
+// redfishClient and insecureRedfishClient are shared by the connectivity tests, so that their
+// idle connections are reused rather than left open by a client per test
+var (
+	redfishClient         = newRedfishClient(false)
+	insecureRedfishClient = newRedfishClient(true)
+)
+
+func newRedfishClient(insecure bool) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			IdleConnTimeout: 90 * time.Second,
+		},
+	}
+}
+
+// RedfishConnectivity is the outcome of testing an endpoint's service root with its stored credentials
+type RedfishConnectivity struct {
+	Reachable      bool   `json:"Reachable"`
+	StatusCode     int    `json:"StatusCode,omitempty"`
+	RedfishVersion string `json:"RedfishVersion,omitempty"`
+	Error          string `json:"Error,omitempty"`
+}
+
+// testRedfishEndpoint GETs /redfish/v1/ of a stored endpoint.  The result is only written to
+// the discovery log with record=true.  BMCs often have self-signed certificates, which are
+// accepted with insecure=true.
+func testRedfishEndpoint(storage RedfishEndpointStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		endpoint, err := storage.GetRedfishEndpointByID(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if endpoint.URI == "" {
+			http.Error(w, "endpoint "+endpoint.ID+" has no URI", http.StatusBadRequest)
+			return
+		}
+
+		attempted := time.Now()
+		result := checkRedfishRoot(r.Context(), endpoint, r.URL.Query().Get("insecure") == "true")
+
+		if r.URL.Query().Get("record") == "true" {
+			discovery := RedfishDiscovery{
+				UID:       uuid.New(),
+				URI:       endpoint.ID,
+				Attempted: attempted,
+				Completed: time.Now(),
+				Status:    "DiscoverOK",
+				Payload:   endpoint,
+			}
+			if !result.Reachable || result.StatusCode != http.StatusOK {
+				discovery.Status = "HTTPsGetFailed"
+			}
+			if err := storage.CreateorUpdateRedfishDiscoveryLog(discovery); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func checkRedfishRoot(ctx context.Context, endpoint RedfishEndpoint, insecure bool) RedfishConnectivity {
+	ctx, cancel := context.WithTimeout(ctx, redfishTestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint.URI, "/")+"/redfish/v1/", nil)
+	if err != nil {
+		return RedfishConnectivity{Error: err.Error()}
+	}
+	req.SetBasicAuth(endpoint.User, endpoint.Password)
+
+	client := redfishClient
+	if insecure {
+		client = insecureRedfishClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return RedfishConnectivity{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	result := RedfishConnectivity{Reachable: true, StatusCode: resp.StatusCode}
+	if resp.StatusCode != http.StatusOK {
+		result.Error = resp.Status
+		return result
+	}
+	var root struct {
+		RedfishVersion string `json:"RedfishVersion"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		result.Error = "malformed service root: " + err.Error()
+		return result
+	}
+	result.RedfishVersion = root.RedfishVersion
+	return result
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	_ "github.com/marcboeker/go-duckdb"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
 )
 
 type DiscoveryInfo struct {
@@ -48,8 +51,28 @@ type RedfishEndpoint struct {
 	DiscoveryInfo      DiscoveryInfo `json:"DiscoveryInfo,omitempty" jsonschema:"description=Contains info about the discovery status of the given endpoint,readOnly=true"`
 }
 
+// Redacted returns a copy of the endpoint that is safe to return to clients
+func (e RedfishEndpoint) Redacted() RedfishEndpoint {
+	if e.Password != "" {
+		e.Password = nodes.RedactedPassword
+	}
+	return e
+}
+
+// RedfishEndpointFilter narrows and pages a listing of Redfish endpoints.  Name and URI match
+// substrings, empty fields match everything and a Limit of zero returns every endpoint after
+// Offset.  Endpoints are ordered by ID.
+type RedfishEndpointFilter struct {
+	Name   string
+	URI    string
+	Limit  int
+	Offset int
+}
+
 type RedfishEndpointStorage interface {
-	GetRedfishEndpoints() ([]RedfishEndpoint, error)
+	GetRedfishEndpoints(filter RedfishEndpointFilter) ([]RedfishEndpoint, error)
+	// CountRedfishEndpoints counts the endpoints matching filter, ignoring its Limit and Offset
+	CountRedfishEndpoints(filter RedfishEndpointFilter) (int, error)
 	GetRedfishEndpointByID(id string) (RedfishEndpoint, error)
 	CreateorUpdateRedfishDiscoveryLog(log RedfishDiscovery) error
 	GetRedfishDiscoveryLogByEndpointID(id string) ([]RedfishDiscovery, error)
@@ -58,15 +81,37 @@ type RedfishEndpointStorage interface {
 	DeleteRedfishEndpointByID(id string) error
 }
 
-// Handler to retrieve all Redfish endpoints
+// Handler to retrieve the Redfish endpoints, filtered by the name and uri query parameters
+// and paged by limit and offset in a response.PagedResponse
 func getRedfishEndpoints(storage RedfishEndpointStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		endpoints, err := storage.GetRedfishEndpoints()
+		query := r.URL.Query()
+		limit, offset, err := response.PageParams(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter := RedfishEndpointFilter{
+			Name:   query.Get("name"),
+			URI:    query.Get("uri"),
+			Limit:  limit,
+			Offset: offset,
+		}
+		total, err := storage.CountRedfishEndpoints(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		endpoints, err := storage.GetRedfishEndpoints(filter)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(endpoints)
+		for i := range endpoints {
+			endpoints[i] = endpoints[i].Redacted()
+		}
+		response.ListPage(w, r, endpoints, total, limit, offset)
 	}
 }
 
@@ -79,7 +124,7 @@ func getRedfishEndpointByID(storage RedfishEndpointStorage) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		json.NewEncoder(w).Encode(endpoint)
+		json.NewEncoder(w).Encode(endpoint.Redacted())
 	}
 }
 
@@ -88,7 +133,7 @@ func createOrUpdateRedfishEndpoints(storage RedfishEndpointStorage) http.Handler
 	return func(w http.ResponseWriter, r *http.Request) {
 		var endpoints []RedfishEndpoint
 		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), openchami_middleware.BodyErrorStatus(err))
 			return
 		}
 
@@ -96,6 +141,12 @@ func createOrUpdateRedfishEndpoints(storage RedfishEndpointStorage) http.Handler
 			if endpoints[i].UID == uuid.Nil {
 				endpoints[i].UID = uuid.New()
 			}
+			// An endpoint read back from GET carries a redacted password, which must not be stored
+			if endpoints[i].Password == nodes.RedactedPassword {
+				if stored, err := storage.GetRedfishEndpointByID(endpoints[i].ID); err == nil {
+					endpoints[i].Password = stored.Password
+				}
+			}
 		}
 
 		if err := storage.CreateOrUpdateRedfishEndpoints(endpoints); err != nil {
@@ -129,8 +180,27 @@ func NewRedfishRouter(storage RedfishEndpointStorage) chi.Router {
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", getRedfishEndpointByID(storage))
 			r.Delete("/", deleteRedfishEndpointByID(storage))
+			r.Post("/test", testRedfishEndpoint(storage))
 		})
 	})
 
 	return r
 }
+
+// RedfishEndpointRoutes serves the Redfish endpoints relative to where it is mounted, e.g.
+// /smd/Inventory/RedfishEndpoints.  Changes require the auth middlewares.
+func RedfishEndpointRoutes(storage RedfishEndpointStorage, authMiddlewares []func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.StripSlashes)
+
+	// Unprotected Routes
+	r.Get("/", getRedfishEndpoints(storage))
+	r.Get("/{id}", getRedfishEndpointByID(storage))
+
+	// Protected Routes
+	r.With(authMiddlewares...).Post("/", createOrUpdateRedfishEndpoints(storage))
+	r.With(authMiddlewares...).Delete("/{id}", deleteRedfishEndpointByID(storage))
+	r.With(authMiddlewares...).Post("/{id}/test", testRedfishEndpoint(storage))
+
+	return r
+}
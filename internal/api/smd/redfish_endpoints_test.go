@@ -0,0 +1,195 @@
+package smd_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+)
+
+func TestRedfishEndpointRoutes(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// Mounted the way serveAPI mounts them, alongside the component routes
+	r := chi.NewRouter()
+	r.Mount("/smd", smd.SMDComponentRoutes(store, nil))
+	r.Mount("/smd/Inventory/RedfishEndpoints", smd.RedfishEndpointRoutes(store, nil))
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/smd/Inventory/RedfishEndpoints", `[{"ID": "x3000c0s1b0", "Name": "bmc", "URI": "https://x3000c0s1b0", "User": "root"}]`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 creating the endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(http.MethodGet, "/smd/Inventory/RedfishEndpoints/x3000c0s1b0", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 reading the endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var endpoint smd.RedfishEndpoint
+	if err := json.NewDecoder(rec.Body).Decode(&endpoint); err != nil {
+		t.Fatalf("failed to decode endpoint: %v", err)
+	}
+	if endpoint.Name != "bmc" || endpoint.URI != "https://x3000c0s1b0" || endpoint.User != "root" {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+
+	rec = serve(http.MethodGet, "/smd/Inventory/RedfishEndpoints/", "")
+	var endpoints response.PagedResponse[smd.RedfishEndpoint]
+	if err := json.NewDecoder(rec.Body).Decode(&endpoints); err != nil || len(endpoints.Items) != 1 || endpoints.Total != 1 {
+		t.Errorf("expected one endpoint in the listing, got %+v, %v", endpoints, err)
+	}
+
+	if rec := serve(http.MethodDelete, "/smd/Inventory/RedfishEndpoints/x3000c0s1b0", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 deleting the endpoint, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/smd/Inventory/RedfishEndpoints/x3000c0s1b0", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after deleting the endpoint, got %d", rec.Code)
+	}
+
+	// The component routes are still reachable next to the Redfish mount
+	if rec := serve(http.MethodGet, "/smd/State/Components", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the component routes to be unaffected, got %d", rec.Code)
+	}
+}
+
+func TestRedfishEndpointPaging(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	r := chi.NewRouter()
+	r.Mount("/smd/Inventory/RedfishEndpoints", smd.RedfishEndpointRoutes(store, nil))
+
+	var endpoints []smd.RedfishEndpoint
+	for _, id := range []string{"x3000c0s1b0", "x3000c0s2b0", "x3000c0s3b0", "x3001c0s1b0"} {
+		endpoints = append(endpoints, smd.RedfishEndpoint{ID: id, Name: "bmc-" + id, URI: "https://" + id + ".mgmt"})
+	}
+	if err := store.CreateOrUpdateRedfishEndpoints(endpoints); err != nil {
+		t.Fatalf("failed to create endpoints: %v", err)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+		total int
+	}{
+		{"", []string{"x3000c0s1b0", "x3000c0s2b0", "x3000c0s3b0", "x3001c0s1b0"}, 4},
+		{"?limit=2", []string{"x3000c0s1b0", "x3000c0s2b0"}, 4},
+		{"?limit=2&offset=2", []string{"x3000c0s3b0", "x3001c0s1b0"}, 4},
+		{"?offset=3", []string{"x3001c0s1b0"}, 4},
+		{"?name=x3000", []string{"x3000c0s1b0", "x3000c0s2b0", "x3000c0s3b0"}, 3},
+		{"?name=x3000&uri=s2b0&limit=5", []string{"x3000c0s2b0"}, 1},
+		{"?uri=nope", []string{}, 0},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/smd/Inventory/RedfishEndpoints"+tt.query, nil))
+		var got response.PagedResponse[smd.RedfishEndpoint]
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&got) != nil {
+			t.Errorf("%q: unexpected response %d: %s", tt.query, rec.Code, rec.Body.String())
+			continue
+		}
+		ids := []string{}
+		for _, e := range got.Items {
+			ids = append(ids, e.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: got %v, want %v", tt.query, ids, tt.want)
+		}
+		if got.Total != tt.total {
+			t.Errorf("%q: expected a total of %d, got %d", tt.query, tt.total, got.Total)
+		}
+	}
+
+	// Clients from before the envelope ask for the bare array
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/smd/Inventory/RedfishEndpoints?limit=2&offset=1&envelope=false", nil))
+	var raw []smd.RedfishEndpoint
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&raw) != nil {
+		t.Fatalf("expected a bare array with envelope=false, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(raw) != 2 || raw[0].ID != "x3000c0s2b0" || raw[1].ID != "x3000c0s3b0" {
+		t.Errorf("expected the second and third endpoints, got %+v", raw)
+	}
+
+	for _, query := range []string{"?limit=-1", "?offset=x"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/smd/Inventory/RedfishEndpoints"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestRedfishEndpointConnectivity(t *testing.T) {
+	bmc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if r.URL.Path != "/redfish/v1/" || user != "root" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"RedfishVersion": "1.9.0"}`))
+	}))
+	defer bmc.Close()
+
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	r := chi.NewRouter()
+	r.Mount("/smd/Inventory/RedfishEndpoints", smd.RedfishEndpointRoutes(store, nil))
+
+	if err := store.CreateOrUpdateRedfishEndpoints([]smd.RedfishEndpoint{
+		{ID: "x3000c0s1b0", URI: bmc.URL, User: "root", Password: "secret"},
+		{ID: "x3000c0s2b0", URI: bmc.URL, User: "root", Password: "wrong"},
+	}); err != nil {
+		t.Fatalf("failed to create endpoints: %v", err)
+	}
+
+	for _, tt := range []struct {
+		id   string
+		want smd.RedfishConnectivity
+	}{
+		{"x3000c0s1b0", smd.RedfishConnectivity{Reachable: true, StatusCode: http.StatusOK, RedfishVersion: "1.9.0"}},
+		{"x3000c0s2b0", smd.RedfishConnectivity{Reachable: true, StatusCode: http.StatusUnauthorized, Error: "401 Unauthorized"}},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/smd/Inventory/RedfishEndpoints/"+tt.id+"/test", nil))
+		var got smd.RedfishConnectivity
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&got) != nil || got != tt.want {
+			t.Errorf("%s: got %d %+v, want %+v", tt.id, rec.Code, got, tt.want)
+		}
+	}
+	if logs, _ := store.GetRedfishDiscoveryLogByEndpointID("x3000c0s1b0"); len(logs) != 0 {
+		t.Errorf("expected no discovery log without record=true, got %v", logs)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/smd/Inventory/RedfishEndpoints/x3000c0s1b0/test?record=true", nil))
+	if logs, _ := store.GetRedfishDiscoveryLogByEndpointID("x3000c0s1b0"); rec.Code != http.StatusOK || len(logs) != 1 || logs[0].Status != "DiscoverOK" {
+		t.Errorf("expected one DiscoverOK log entry, got %d %+v", rec.Code, logs)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/smd/Inventory/RedfishEndpoints/x9999c0s1b0/test", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown endpoint, got %d", rec.Code)
+	}
+}
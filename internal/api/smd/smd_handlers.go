@@ -1,13 +1,23 @@
 package smd
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
 	"github.com/google/uuid"
 	"github.com/invopop/jsonschema"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -20,10 +30,54 @@ type SMDStorage interface {
 	GetComponentByNID(nid int) (Component, error)
 	GetComponentByUID(uid uuid.UUID) (Component, error)
 	QueryComponents(xname string, params map[string]string) ([]Component, error)
+	FilterComponents(filter ComponentFilter) ([]Component, error)
+	SummarizeComponents() (ComponentSummary, error)
 	CreateOrUpdateComponents(components []Component) error
 	DeleteComponents() error
 	DeleteComponentByXname(xname string) error
+	// DeleteComponentsByXnames deletes the components in xnames and returns how many there were
+	DeleteComponentsByXnames(xnames []string) (int, error)
 	UpdateComponentData(xnames []string, data map[string]interface{}) error
+	// ModifyComponents replaces each of the components with ids by the result of modify in a
+	// single transaction.  If modify fails for any component, none of them are changed.
+	ModifyComponents(ids []string, modify func(Component) (Component, error)) error
+
+	// Ethernet interfaces are derived from the network interfaces of stored nodes, so they
+	// are read only
+	GetEthernetInterfaces(filter EthernetInterfaceFilter) ([]CompEthInterface, error)
+	GetEthernetInterfaceByID(id string) (CompEthInterface, error)
+}
+
+// RouterOption configures the SMD routers
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	strictComponentIDs bool
+}
+
+// WithStrictComponentIDs rejects components whose ID is not a node or BMC xname.  CSM accepts
+// any ID, so this is off by default.
+func WithStrictComponentIDs(strict bool) RouterOption {
+	return func(c *routerConfig) {
+		c.strictComponentIDs = strict
+	}
+}
+
+func newRouterConfig(opts []RouterOption) routerConfig {
+	var config routerConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// validComponentID reports whether id is a node or BMC xname
+func validComponentID(id string) bool {
+	if ok, _ := xnames.NewNodeXname(id).Valid(); ok {
+		return true
+	}
+	ok, _ := xnames.NewBMCXname(id).Valid()
+	return ok
 }
 
 // ValidationErrorResponse represents a detailed error response
@@ -46,14 +100,31 @@ func validateWithSchema(documentLoader gojsonschema.JSONLoader) []*ValidationErr
 	return errors
 }
 
+// getComponents lists the components, narrowed by the filters of ComponentFilterFromQuery,
+// e.g. ?role=Compute&state=Ready, and paged by limit and offset.  Filters combine with AND.
 func getComponents(storage SMDStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		components, err := storage.GetComponents()
+		filter, err := ComponentFilterFromQuery(r.URL.Query())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(components)
+		limit, offset, err := response.PageParams(r.URL.Query())
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var components []Component
+		if !filter.HasColumns() {
+			components, err = storage.GetComponents()
+		} else {
+			components, err = storage.FilterComponents(filter)
+		}
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.List(w, r, components, limit, offset)
 	}
 }
 
@@ -62,43 +133,178 @@ func getComponentByXname(storage SMDStorage) http.HandlerFunc {
 		xname := chi.URLParam(r, "xname")
 		component, err := storage.GetComponentByXname(xname)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			response.Error(w, r, err.Error(), http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(component)
 	}
 }
 
-func createUpdateComponents(storage SMDStorage) http.HandlerFunc {
+func getComponentByNID(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nid, err := strconv.Atoi(chi.URLParam(r, "nid"))
+		if err != nil {
+			response.Error(w, r, "malformed NID "+chi.URLParam(r, "nid"), http.StatusBadRequest)
+			return
+		}
+		component, err := storage.GetComponentByNID(nid)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, r, "component not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(component)
+	}
+}
+
+// getComponentsSummary serves GET /State/Components/summary, the component counts that a
+// dashboard shows, without sending every component over
+func getComponentsSummary(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, err := storage.SummarizeComponents()
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(summary)
+	}
+}
+
+// getComponentByUID serves GET /State/Components/ByUID/{uid}.  Unlike the xname, the UID of a
+// component never changes.
+func getComponentByUID(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, err := uuid.Parse(chi.URLParam(r, "uid"))
+		if err != nil {
+			response.Error(w, r, "malformed UID "+chi.URLParam(r, "uid"), http.StatusBadRequest)
+			return
+		}
+		component, err := storage.GetComponentByUID(uid)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.Error(w, r, "component not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(component)
+	}
+}
+
+func createUpdateComponents(storage SMDStorage, config routerConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var components []Component
 		if err := json.NewDecoder(r.Body).Decode(&components); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			render.Render(w, r, response.ErrInvalidRequest(err))
 			return
 		}
 
+		if config.strictComponentIDs {
+			if xname := chi.URLParam(r, "xname"); xname != "" && !validComponentID(xname) {
+				response.Error(w, r, "malformed component ID "+xname, http.StatusBadRequest)
+				return
+			}
+			for _, component := range components {
+				if !validComponentID(component.ID) {
+					response.Error(w, r, "malformed component ID "+component.ID, http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
 		// Validate each component
 		for _, component := range components {
 			documentLoader := gojsonschema.NewGoLoader(component)
 			if errs := validateWithSchema(documentLoader); len(errs) > 0 {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(errs)
+				messages := make([]string, len(errs))
+				for i, e := range errs {
+					messages[i] = e.Message
+				}
+				response.Error(w, r, "component "+component.ID+": "+strings.Join(messages, "; "), http.StatusBadRequest)
 				return
 			}
 		}
 
 		if err := storage.CreateOrUpdateComponents(components); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrDuplicateNID) {
+				status = http.StatusConflict
+			}
+			response.Error(w, r, err.Error(), status)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// queryComponents runs a ComponentQuery.  The ByNID variant requires a NID filter while the
+// plain variant requires component IDs; either may narrow the result further with the other.
+func queryComponents(storage SMDStorage, byNID bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var query ComponentQuery
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		filter, err := query.Filter()
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if byNID && !filter.HasNIDs() {
+			response.Error(w, r, "NIDs or NIDRanges is required", http.StatusBadRequest)
+			return
+		}
+		if !byNID && len(filter.IDs) == 0 {
+			response.Error(w, r, "ComponentIDs is required", http.StatusBadRequest)
+			return
+		}
+
+		components, err := storage.FilterComponents(filter)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if components == nil {
+			components = []Component{}
+		}
+		json.NewEncoder(w).Encode(components)
+	}
+}
+
+// queryComponentByXname serves GET /State/Components/Query/{xname}.  Query parameters narrow the
+// match by component column, e.g. ?state=Ready&role=Compute.
+func queryComponentByXname(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := make(map[string]string)
+		for key, values := range r.URL.Query() {
+			params[key] = values[0]
+		}
+		if err := ValidateComponentParams(params); err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		components, err := storage.QueryComponents(chi.URLParam(r, "xname"), params)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if components == nil {
+			components = []Component{}
+		}
+		json.NewEncoder(w).Encode(components)
+	}
+}
+
 func deleteComponents(storage SMDStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := storage.DeleteComponents(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -109,102 +315,323 @@ func deleteComponentByXname(storage SMDStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		xname := chi.URLParam(r, "xname")
 		if err := storage.DeleteComponentByXname(xname); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			response.Error(w, r, err.Error(), http.StatusNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func updateComponentData(storage SMDStorage) http.HandlerFunc {
+// deleteComponentsBulk serves DELETE /State/Components/Bulk, deleting the components whose
+// xnames are in the body.  Xnames without a component are skipped, so the count in the
+// response can be lower than the number of xnames.
+func deleteComponentsBulk(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Xnames []string `json:"xnames"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if len(request.Xnames) == 0 {
+			response.Error(w, r, "at least one xname is required", http.StatusBadRequest)
+			return
+		}
+		deleted, err := storage.DeleteComponentsByXnames(request.Xnames)
+		if err != nil {
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"deleted": deleted})
+	}
+}
+
+// updateComponentData sets column on every component in xnames.  The request's data may only
+// hold that one column, and its value has to pass validate.
+func updateComponentData(storage SMDStorage, column string, validate func(interface{}) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var request struct {
 			Xnames []string               `json:"xnames"`
 			Data   map[string]interface{} `json:"data"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			render.Render(w, r, response.ErrInvalidRequest(err))
 			return
 		}
-
-		// Validate the request
-		documentLoader := gojsonschema.NewGoLoader(request)
-		if errs := validateWithSchema(documentLoader); len(errs) > 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(errs)
+		if len(request.Xnames) == 0 || len(request.Data) == 0 {
+			response.Error(w, r, "xnames and data are required", http.StatusBadRequest)
+			return
+		}
+		if err := ValidateComponentData(request.Data, column); err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validate(request.Data[column]); err != nil {
+			response.Error(w, r, fmt.Sprintf("invalid %s: %v", column, err), http.StatusBadRequest)
 			return
 		}
 
 		if err := storage.UpdateComponentData(request.Xnames, request.Data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrDuplicateNID) {
+				status = http.StatusConflict
+			}
+			response.Error(w, r, err.Error(), status)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func NewRouter(storage SMDStorage) chi.Router {
+func bulkFlagOnly(storage SMDStorage) http.HandlerFunc {
+	return updateComponentData(storage, "flag", func(v interface{}) error {
+		if s, ok := v.(string); !ok || !ComponentFlag(s).Valid() {
+			return fmt.Errorf("%v is not a component flag", v)
+		}
+		return nil
+	})
+}
+
+func bulkEnabled(storage SMDStorage) http.HandlerFunc {
+	return updateComponentData(storage, "enabled", func(v interface{}) error {
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v is not a boolean", v)
+		}
+		return nil
+	})
+}
+
+func bulkSoftwareStatus(storage SMDStorage) http.HandlerFunc {
+	return updateComponentData(storage, "sw_status", func(v interface{}) error {
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%v is not a string", v)
+		}
+		return nil
+	})
+}
+
+func bulkRole(storage SMDStorage) http.HandlerFunc {
+	return updateComponentData(storage, "role", func(v interface{}) error {
+		if s, ok := v.(string); !ok || !ComponentRole(s).Valid() {
+			return fmt.Errorf("%v is not a component role", v)
+		}
+		return nil
+	})
+}
+
+// bulkNID serves PATCH /State/Components/BulkNID, which gives each component in the body its
+// own NID, e.g. {"Components": [{"ID": "x1000c0s0b0n0", "NID": 1}]}.  The NIDs are set in one
+// transaction, and a NID held by another component, or given to two of them, fails them all
+// with 409.
+func bulkNID(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Components []struct {
+				ID  string `json:"ID"`
+				NID int    `json:"NID"`
+			} `json:"Components"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if len(request.Components) == 0 {
+			response.Error(w, r, "Components are required", http.StatusBadRequest)
+			return
+		}
+		nids := make(map[string]int, len(request.Components))
+		ids := make([]string, 0, len(request.Components))
+		for _, c := range request.Components {
+			if c.ID == "" {
+				response.Error(w, r, "every component needs an ID", http.StatusBadRequest)
+				return
+			}
+			if c.NID < 0 {
+				response.Error(w, r, fmt.Sprintf("invalid nid: %d is not a NID", c.NID), http.StatusBadRequest)
+				return
+			}
+			if _, ok := nids[c.ID]; ok {
+				response.Error(w, r, "component "+c.ID+" is listed more than once", http.StatusBadRequest)
+				return
+			}
+			nids[c.ID] = c.NID
+			ids = append(ids, c.ID)
+		}
+
+		err := storage.ModifyComponents(ids, func(c Component) (Component, error) {
+			c.NID = nids[c.ID]
+			return c, nil
+		})
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, sql.ErrNoRows):
+			response.Error(w, r, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrDuplicateNID):
+			response.Error(w, r, err.Error(), http.StatusConflict)
+		default:
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// patchComponents applies the JSON Patch in the body to every component named by an id query
+// parameter.  The batch is applied atomically, so a failed test operation on any component
+// leaves all of them unchanged.
+func patchComponents(storage SMDStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isJSONPatch(r) {
+			response.Error(w, r, "Content-Type must be "+JSONPatchContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+		ids := r.URL.Query()["id"]
+		if len(ids) == 0 {
+			response.Error(w, r, "at least one id is required", http.StatusBadRequest)
+			return
+		}
+		var patch Patch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			render.Render(w, r, response.ErrInvalidRequest(err))
+			return
+		}
+		if err := patch.Validate(); err != nil {
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := storage.ModifyComponents(ids, func(c Component) (Component, error) {
+			patched, err := PatchComponent(c, patch)
+			if err != nil {
+				return Component{}, fmt.Errorf("component %s: %w", c.ID, err)
+			}
+			return patched, nil
+		})
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, sql.ErrNoRows):
+			response.Error(w, r, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrPatchTestFailed), errors.Is(err, ErrDuplicateNID):
+			response.Error(w, r, err.Error(), http.StatusConflict)
+		case errors.Is(err, ErrInvalidPatch):
+			response.Error(w, r, err.Error(), http.StatusBadRequest)
+		default:
+			response.Error(w, r, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func isJSONPatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == JSONPatchContentType
+}
+
+// bulkStateData serves PATCH /State/Components/BulkStateData, which takes either a JSON Patch
+// or the {xnames, data} body of updateComponentData depending on the Content-Type
+func bulkStateData(storage SMDStorage) http.HandlerFunc {
+	patch := patchComponents(storage)
+	update := updateComponentData(storage, "state", func(v interface{}) error {
+		if s, ok := v.(string); !ok || !ComponentState(s).Valid() {
+			return fmt.Errorf("%v is not a component state", v)
+		}
+		return nil
+	})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isJSONPatch(r) {
+			patch(w, r)
+			return
+		}
+		update(w, r)
+	}
+}
+
+func NewRouter(storage SMDStorage, opts ...RouterOption) chi.Router {
+	config := newRouterConfig(opts)
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
+	r.Use(middleware.StripSlashes)
 
 	r.Route("/State/Components", func(r chi.Router) {
 		r.Get("/", getComponents(storage))
-		r.Post("/", createUpdateComponents(storage))
+		r.Post("/", createUpdateComponents(storage, config))
 		r.Delete("/", deleteComponents(storage))
+		r.Delete("/Bulk", deleteComponentsBulk(storage))
 
 		r.Route("/{xname}", func(r chi.Router) {
 			r.Get("/", getComponentByXname(storage))
-			r.Put("/", createUpdateComponents(storage))
+			r.Put("/", createUpdateComponents(storage, config))
 			r.Delete("/", deleteComponentByXname(storage))
 		})
 
 		r.Route("/BulkStateData", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkStateData(storage))
 		})
 
 		r.Route("/BulkFlagOnly", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkFlagOnly(storage))
 		})
 
 		r.Route("/BulkEnabled", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkEnabled(storage))
 		})
 
 		r.Route("/BulkSoftwareStatus", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkSoftwareStatus(storage))
 		})
 
 		r.Route("/BulkRole", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkRole(storage))
 		})
 
 		r.Route("/BulkNID", func(r chi.Router) {
-			r.Patch("/", updateComponentData(storage))
+			r.Patch("/", bulkNID(storage))
 		})
 
 		r.Route("/ByNID/{nid}", func(r chi.Router) {
-			r.Get("/", getComponentByXname(storage))
+			r.Get("/", getComponentByNID(storage))
+		})
+
+		r.Get("/summary", getComponentsSummary(storage))
+
+		r.Route("/ByUID/{uid}", func(r chi.Router) {
+			r.Get("/", getComponentByUID(storage))
 		})
 
 		r.Route("/Query/{xname}", func(r chi.Router) {
-			r.Get("/", getComponentByXname(storage))
+			r.Get("/", queryComponentByXname(storage))
 		})
 
 		r.Route("/Query", func(r chi.Router) {
-			r.Post("/", createUpdateComponents(storage))
+			r.Post("/", queryComponents(storage, false))
 		})
 
 		r.Route("/ByNID/Query", func(r chi.Router) {
-			r.Post("/", createUpdateComponents(storage))
+			r.Post("/", queryComponents(storage, true))
 		})
 	})
 
+	r.Route("/Inventory/EthernetInterfaces", func(r chi.Router) {
+		r.Get("/", getEthernetInterfaces(storage))
+		r.Get("/{id}", getEthernetInterfaceByID(storage))
+	})
+
 	return r
 }
 
-func SMDComponentRoutes(storage SMDStorage, authMiddlewares []func(http.Handler) http.Handler) chi.Router {
-	// Generate JSON schema for Component struct
-	reflector := jsonschema.Reflector{}
+func SMDComponentRoutes(storage SMDStorage, authMiddlewares []func(http.Handler) http.Handler, opts ...RouterOption) chi.Router {
+	config := newRouterConfig(opts)
+
+	// Generate JSON schema for Component struct.  uuid.UUID is a byte array but marshals as a
+	// string, so the schema has to say so or every component fails validation.
+	reflector := jsonschema.Reflector{
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			if t == reflect.TypeOf(uuid.UUID{}) {
+				return &jsonschema.Schema{Type: "string", Format: "uuid"}
+			}
+			return nil
+		},
+	}
 	componentSchema := reflector.Reflect(&Component{})
 
 	// Convert schema to JSON
@@ -217,16 +644,33 @@ func SMDComponentRoutes(storage SMDStorage, authMiddlewares []func(http.Handler)
 	componentSchemaLoader = gojsonschema.NewBytesLoader(schemaJSON)
 
 	r := chi.NewRouter()
+	// Treat /State/Components/ and /State/Components as the same route
+	r.Use(middleware.StripSlashes)
 
 	// Unprotected Routes
-	r.Get("/State/Components/", getComponents(storage))
+	r.Get("/State/Components", getComponents(storage))
 	r.Get("/State/Components/{xname}", getComponentByXname(storage))
+	r.Get("/State/Components/ByNID/{nid}", getComponentByNID(storage))
+	r.Get("/State/Components/ByUID/{uid}", getComponentByUID(storage))
+	r.Get("/State/Components/summary", getComponentsSummary(storage))
+	r.Get("/State/Components/Query/{xname}", queryComponentByXname(storage))
+	r.Post("/State/Components/Query", queryComponents(storage, false))
+	r.Post("/State/Components/ByNID/Query", queryComponents(storage, true))
+	r.Get("/Inventory/EthernetInterfaces", getEthernetInterfaces(storage))
+	r.Get("/Inventory/EthernetInterfaces/{id}", getEthernetInterfaceByID(storage))
 
 	// Protected Routes
-	r.With(authMiddlewares...).Post("/State/Components/", createUpdateComponents(storage))
-	r.With(authMiddlewares...).Put("/State/Components/{xname}", createUpdateComponents(storage))
-	r.With(authMiddlewares...).Delete("/State/Components/", deleteComponents(storage))
+	r.With(authMiddlewares...).Post("/State/Components", createUpdateComponents(storage, config))
+	r.With(authMiddlewares...).Put("/State/Components/{xname}", createUpdateComponents(storage, config))
+	r.With(authMiddlewares...).Delete("/State/Components", deleteComponents(storage))
+	r.With(authMiddlewares...).Delete("/State/Components/Bulk", deleteComponentsBulk(storage))
 	r.With(authMiddlewares...).Delete("/State/Components/{xname}", deleteComponentByXname(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkStateData", bulkStateData(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkFlagOnly", bulkFlagOnly(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkEnabled", bulkEnabled(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkSoftwareStatus", bulkSoftwareStatus(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkRole", bulkRole(storage))
+	r.With(authMiddlewares...).Patch("/State/Components/BulkNID", bulkNID(storage))
 
 	return r
 }
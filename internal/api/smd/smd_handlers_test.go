@@ -0,0 +1,633 @@
+package smd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/response"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+)
+
+func TestQueryComponentsByNID(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	var components []smd.Component
+	for nid, xname := range []string{"x1000c0s0b0n0", "x1000c0s0b0n1", "x1000c0s1b0n0", "x1000c0s1b0n1", "x1000c0s2b0n0"} {
+		components = append(components, smd.Component{ID: xname, Type: smd.TypeNode, NID: nid + 1})
+	}
+	if err := store.CreateOrUpdateComponents(components); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	r := smd.SMDComponentRoutes(store, nil)
+
+	tests := []struct {
+		name  string
+		route string
+		query smd.ComponentQuery
+		want  []string
+	}{
+		{"NID set", "/State/Components/ByNID/Query", smd.ComponentQuery{NIDs: []int{1, 3}}, []string{"x1000c0s0b0n0", "x1000c0s1b0n0"}},
+		{"NID range", "/State/Components/ByNID/Query", smd.ComponentQuery{NIDRanges: []string{"2-4"}}, []string{"x1000c0s0b0n1", "x1000c0s1b0n0", "x1000c0s1b0n1"}},
+		{"NID set and range", "/State/Components/ByNID/Query", smd.ComponentQuery{NIDs: []int{5}, NIDRanges: []string{"1-2"}}, []string{"x1000c0s0b0n0", "x1000c0s0b0n1", "x1000c0s2b0n0"}},
+		{"IDs narrowed by NID range", "/State/Components/Query", smd.ComponentQuery{ComponentIDs: []string{"x1000c0s0b0n0", "x1000c0s2b0n0"}, NIDRanges: []string{"4-5"}}, []string{"x1000c0s2b0n0"}},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.query)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.route, bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d: %s", tt.name, rec.Code, rec.Body.String())
+			continue
+		}
+		var found []smd.Component
+		if err := json.NewDecoder(rec.Body).Decode(&found); err != nil {
+			t.Errorf("%s: failed to decode response: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, c := range found {
+			got = append(got, c.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+				break
+			}
+		}
+	}
+
+	// The query routes must not create components
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/State/Components/ByNID/Query", bytes.NewReader([]byte(`{}`))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a query without NIDs, got %d", rec.Code)
+	}
+	if all, _ := store.GetComponents(); len(all) != len(components) {
+		t.Errorf("expected %d components after querying, got %d", len(components), len(all))
+	}
+}
+
+func TestTrailingSlashes(t *testing.T) {
+	// None of these requests reach storage, so the routes can be exercised without one
+	r := smd.SMDComponentRoutes(nil, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/State/Components", "{", http.StatusBadRequest},
+		{http.MethodPost, "/State/Components/Query", "{}", http.StatusBadRequest},
+		{http.MethodPost, "/State/Components/ByNID/Query", "{}", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{tt.path, tt.path + "/"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, path, bytes.NewReader([]byte(tt.body))))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, path, tt.want, rec.Code)
+			}
+		}
+	}
+}
+
+func TestStrictComponentIDs(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		id     string
+		strict bool
+		want   int
+	}{
+		{"x1000c0s0b0n0", true, http.StatusNoContent},
+		{"x1000c0s0b0", true, http.StatusNoContent},
+		{"not-an-xname", true, http.StatusBadRequest},
+		{"x1000c0s0b0n0junk", true, http.StatusBadRequest},
+		{"x1000c0s0", true, http.StatusBadRequest},
+		{"not-an-xname", false, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		r := smd.SMDComponentRoutes(store, nil, smd.WithStrictComponentIDs(tt.strict))
+		body, _ := json.Marshal([]smd.Component{{ID: tt.id, Type: smd.TypeNode}})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/State/Components", bytes.NewReader(body)))
+		if rec.Code != tt.want {
+			t.Errorf("strict=%v: expected status %d for component ID %q, got %d: %s", tt.strict, tt.want, tt.id, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Rejected components must not have been stored
+	if _, err := store.GetComponentByXname("x1000c0s0b0n0junk"); err == nil {
+		t.Errorf("expected the malformed component not to be stored")
+	}
+}
+
+func TestDuplicateNIDConflict(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	r := smd.SMDComponentRoutes(store, nil)
+
+	post := func(components []smd.Component) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(components)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/State/Components", bytes.NewReader(body)))
+		return rec
+	}
+	if rec := post([]smd.Component{{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 7}}); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 creating the component, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := post([]smd.Component{{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 7}})
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "x1000c0s0b0n0") {
+		t.Errorf("expected status 409 naming the holder of NID 7, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteComponentsBulk(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode},
+		{ID: "x1000c0s0b0n2", Type: smd.TypeNode},
+	}); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	r := smd.SMDComponentRoutes(store, nil)
+	del := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/State/Components/Bulk", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := del(`{"xnames": ["x1000c0s0b0n0", "x1000c0s0b0n2", "x1000c0s0b0n9"]}`)
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&result) != nil || result.Deleted != 2 {
+		t.Fatalf("expected 2 components deleted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	remaining, err := store.GetComponents()
+	if err != nil {
+		t.Fatalf("failed to get components: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != "x1000c0s0b0n1" {
+		t.Errorf("expected only x1000c0s0b0n1 to remain, got %+v", remaining)
+	}
+
+	for _, body := range []string{`{"xnames": []}`, `{}`, `not json`} {
+		if rec := del(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestBulkStateDataJSONPatch(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: "On"},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode, State: "On"},
+		{ID: "x1000c0s0b0n2", Type: smd.TypeNode, State: "Off"},
+	}); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	r := smd.SMDComponentRoutes(store, nil)
+	patch := func(query, body, contentType string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/State/Components/BulkStateData?"+query, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	state := func(xname string) smd.ComponentState {
+		c, err := store.GetComponentByXname(xname)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", xname, err)
+		}
+		return c.State
+	}
+	setReady := `[{"op": "test", "path": "/State", "value": "On"}, {"op": "replace", "path": "/State", "value": "Ready"}]`
+
+	// One failed test rejects the whole batch
+	if code := patch("id=x1000c0s0b0n0&id=x1000c0s0b0n2", setReady, smd.JSONPatchContentType); code != http.StatusConflict {
+		t.Errorf("expected 409 when a test op fails, got %d", code)
+	}
+	if got := state("x1000c0s0b0n0"); got != "On" {
+		t.Errorf("expected x1000c0s0b0n0 to be left On after a failed batch, got %s", got)
+	}
+
+	if code := patch("id=x1000c0s0b0n0&id=x1000c0s0b0n1", setReady, smd.JSONPatchContentType+"; charset=utf-8"); code != http.StatusNoContent {
+		t.Fatalf("expected 204 for a passing patch, got %d", code)
+	}
+	for _, xname := range []string{"x1000c0s0b0n0", "x1000c0s0b0n1"} {
+		if got := state(xname); got != "Ready" {
+			t.Errorf("expected %s to be Ready, got %s", xname, got)
+		}
+	}
+	if got := state("x1000c0s0b0n2"); got != "Off" {
+		t.Errorf("expected x1000c0s0b0n2 to be untouched, got %s", got)
+	}
+
+	if code := patch("id=x1000c0s9b0n0", setReady, smd.JSONPatchContentType); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown component, got %d", code)
+	}
+	if code := patch("id=x1000c0s0b0n0", `[{"op": "nope", "path": "/State"}]`, smd.JSONPatchContentType); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid patch, got %d", code)
+	}
+	if code := patch("", setReady, smd.JSONPatchContentType); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without an id, got %d", code)
+	}
+	// A plain JSON body is read as the {xnames, data} form, which a patch isn't
+	if code := patch("id=x1000c0s0b0n0", setReady, "application/json"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a patch sent as plain JSON, got %d", code)
+	}
+}
+
+func TestBulkComponentDataColumns(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: "On"},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode, State: "On"},
+	}); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	r := smd.NewRouter(store)
+	update := func(route, body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/State/Components/"+route, bytes.NewReader([]byte(body))))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name  string
+		route string
+		body  string
+		want  int
+	}{
+		{"state", "BulkStateData", `{"xnames": ["x1000c0s0b0n0", "x1000c0s0b0n1"], "data": {"state": "Ready"}}`, http.StatusNoContent},
+		{"flag", "BulkFlagOnly", `{"xnames": ["x1000c0s0b0n0"], "data": {"flag": "Warning"}}`, http.StatusNoContent},
+		{"flag through state route", "BulkStateData", `{"xnames": ["x1000c0s0b0n0"], "data": {"flag": "Alert"}}`, http.StatusBadRequest},
+		{"uid", "BulkRole", `{"xnames": ["x1000c0s0b0n0"], "data": {"uid": "00000000-0000-0000-0000-000000000000"}}`, http.StatusBadRequest},
+		{"injected column", "BulkRole", `{"xnames": ["x1000c0s0b0n0"], "data": {"role = 'x', state": "Off"}}`, http.StatusBadRequest},
+		{"no xnames", "BulkRole", `{"data": {"role": "Compute"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := update(tt.route, tt.body); got != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	for _, xname := range []string{"x1000c0s0b0n0", "x1000c0s0b0n1"} {
+		c, err := store.GetComponentByXname(xname)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", xname, err)
+		}
+		if c.State != "Ready" {
+			t.Errorf("expected %s to be Ready, got %s", xname, c.State)
+		}
+	}
+	if c, _ := store.GetComponentByXname("x1000c0s0b0n0"); c.Flag != "Warning" {
+		t.Errorf("expected flag Warning, got %s", c.Flag)
+	}
+}
+
+func TestBulkComponentValues(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: "On"}}); err != nil {
+		t.Fatalf("failed to create component: %v", err)
+	}
+
+	tests := []struct {
+		route  string
+		column string
+		value  string
+		want   int
+	}{
+		{"BulkStateData", "state", `"Ready"`, http.StatusNoContent},
+		{"BulkStateData", "state", `"Asleep"`, http.StatusBadRequest},
+		{"BulkFlagOnly", "flag", `"Alert"`, http.StatusNoContent},
+		{"BulkFlagOnly", "flag", `"Red"`, http.StatusBadRequest},
+		{"BulkEnabled", "enabled", `true`, http.StatusNoContent},
+		{"BulkEnabled", "enabled", `"yes"`, http.StatusBadRequest},
+		{"BulkSoftwareStatus", "sw_status", `"AdminDown"`, http.StatusNoContent},
+		{"BulkSoftwareStatus", "sw_status", `7`, http.StatusBadRequest},
+		{"BulkRole", "role", `"Service"`, http.StatusNoContent},
+		{"BulkRole", "role", `"Chef"`, http.StatusBadRequest},
+	}
+	// The routes mounted by main.go serve the same Bulk* handlers as NewRouter
+	for name, r := range map[string]http.Handler{"NewRouter": smd.NewRouter(store), "SMDComponentRoutes": smd.SMDComponentRoutes(store, nil)} {
+		for _, tt := range tests {
+			body := `{"xnames": ["x1000c0s0b0n0"], "data": {"` + tt.column + `": ` + tt.value + `}}`
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/State/Components/"+tt.route, bytes.NewReader([]byte(body))))
+			if rec.Code != tt.want {
+				t.Errorf("%s: %s %s=%s: expected status %d, got %d: %s", name, tt.route, tt.column, tt.value, tt.want, rec.Code, rec.Body.String())
+			}
+		}
+	}
+
+	c, err := store.GetComponentByXname("x1000c0s0b0n0")
+	if err != nil {
+		t.Fatalf("failed to get component: %v", err)
+	}
+	want := smd.Component{ID: "x1000c0s0b0n0", UID: c.UID, Type: smd.TypeNode, State: smd.StateReady, Flag: smd.FlagAlert, Enabled: true, SwStatus: "AdminDown", Role: smd.RoleService}
+	if c != want {
+		t.Errorf("expected %+v, got %+v", want, c)
+	}
+}
+
+func TestBulkNID(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode},
+		{ID: "x1000c0s0b0n2", Type: smd.TypeNode, NID: 9},
+	}); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	r := smd.SMDComponentRoutes(store, nil)
+	patch := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/State/Components/BulkNID", strings.NewReader(body)))
+		return rec
+	}
+	nids := func() []int {
+		var got []int
+		for _, xname := range []string{"x1000c0s0b0n0", "x1000c0s0b0n1", "x1000c0s0b0n2"} {
+			c, err := store.GetComponentByXname(xname)
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", xname, err)
+			}
+			got = append(got, c.NID)
+		}
+		return got
+	}
+
+	if rec := patch(`{"Components": [{"ID": "x1000c0s0b0n0", "NID": 5}, {"ID": "x1000c0s0b0n1", "NID": 6}]}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := nids(); got[0] != 5 || got[1] != 6 || got[2] != 9 {
+		t.Errorf("expected NIDs [5 6 9], got %v", got)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"Components": [{"ID": "x1000c0s0b0n0", "NID": 7}, {"ID": "x1000c0s0b0n1", "NID": 7}]}`, http.StatusConflict},
+		{`{"Components": [{"ID": "x1000c0s0b0n0", "NID": 9}]}`, http.StatusConflict},
+		{`{"Components": [{"ID": "x1000c0s0b0n0", "NID": 4.2}]}`, http.StatusBadRequest},
+		{`{"Components": [{"ID": "x1000c0s0b0n0", "NID": -1}]}`, http.StatusBadRequest},
+		{`{"Components": [{"ID": "x1000c0s0b0n0", "NID": 1}, {"ID": "x1000c0s0b0n0", "NID": 2}]}`, http.StatusBadRequest},
+		{`{"Components": []}`, http.StatusBadRequest},
+		{`{"Components": [{"ID": "x1000c0s0b0n7", "NID": 1}]}`, http.StatusNotFound},
+	} {
+		if rec := patch(tt.body); rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.body, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+	if got := nids(); got[0] != 5 || got[1] != 6 || got[2] != 9 {
+		t.Errorf("expected the refused requests to leave NIDs [5 6 9], got %v", got)
+	}
+}
+
+func TestGetComponentByNID(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 7}}); err != nil {
+		t.Fatalf("failed to create component: %v", err)
+	}
+
+	for name, r := range map[string]http.Handler{"NewRouter": smd.NewRouter(store), "SMDComponentRoutes": smd.SMDComponentRoutes(store, nil)} {
+		get := func(nid string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/State/Components/ByNID/"+nid, nil))
+			return rec
+		}
+
+		rec := get("7")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", name, rec.Code)
+		}
+		var c smd.Component
+		if err := json.NewDecoder(rec.Body).Decode(&c); err != nil {
+			t.Fatalf("%s: failed to decode component: %v", name, err)
+		}
+		if c.ID != "x1000c0s0b0n0" {
+			t.Errorf("%s: expected x1000c0s0b0n0, got %s", name, c.ID)
+		}
+		if rec := get("8"); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 for an unused NID, got %d", name, rec.Code)
+		}
+		if rec := get("seven"); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400 for a non-numeric NID, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestQueryComponentByXname(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	component := smd.Component{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: smd.StateReady, Role: smd.RoleCompute, Enabled: true}
+	if err := store.CreateOrUpdateComponents([]smd.Component{component}); err != nil {
+		t.Fatalf("failed to create component: %v", err)
+	}
+
+	for name, r := range map[string]http.Handler{"NewRouter": smd.NewRouter(store), "SMDComponentRoutes": smd.SMDComponentRoutes(store, nil)} {
+		tests := []struct {
+			query string
+			code  int
+			found int
+		}{
+			{"", http.StatusOK, 1},
+			{"?state=Ready&role=Compute&enabled=true", http.StatusOK, 1},
+			{"?state=Off", http.StatusOK, 0},
+			{"?uid=1", http.StatusBadRequest, 0},
+		}
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/State/Components/Query/x1000c0s0b0n0"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Errorf("%s %q: expected status %d, got %d: %s", name, tt.query, tt.code, rec.Code, rec.Body.String())
+				continue
+			}
+			if tt.code != http.StatusOK {
+				continue
+			}
+			var found []smd.Component
+			if err := json.NewDecoder(rec.Body).Decode(&found); err != nil {
+				t.Fatalf("%s %q: failed to decode response: %v", name, tt.query, err)
+			}
+			if len(found) != tt.found {
+				t.Errorf("%s %q: expected %d components, got %d", name, tt.query, tt.found, len(found))
+			}
+		}
+	}
+
+	// Querying must leave the component as it was
+	all, err := store.GetComponents()
+	if err != nil {
+		t.Fatalf("failed to list components: %v", err)
+	}
+	if len(all) != 1 || all[0].State != smd.StateReady {
+		t.Errorf("expected the component to be unchanged by queries, got %+v", all)
+	}
+}
+
+func TestGetComponentsFilters(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	components := []smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 1, Role: smd.RoleCompute, State: smd.StateReady, Arch: smd.ArchX86, Class: smd.ClassMountain, Enabled: true},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode, NID: 2, Role: smd.RoleCompute, State: smd.StateOff, Arch: smd.ArchX86, Class: smd.ClassMountain, Enabled: true},
+		{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 3, Role: smd.RoleService, State: smd.StateReady, Arch: smd.ArchARM, Class: smd.ClassRiver},
+	}
+	if err := store.CreateOrUpdateComponents(components); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+	r := smd.SMDComponentRoutes(store, nil)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"x1000c0s0b0n0", "x1000c0s0b0n1", "x1000c0s1b0n0"}},
+		{"?role=Compute&state=Ready", []string{"x1000c0s0b0n0"}},
+		{"?state=Ready", []string{"x1000c0s0b0n0", "x1000c0s1b0n0"}},
+		{"?arch=ARM", []string{"x1000c0s1b0n0"}},
+		{"?class=Mountain&enabled=true", []string{"x1000c0s0b0n0", "x1000c0s0b0n1"}},
+		{"?enabled=false", []string{"x1000c0s1b0n0"}},
+		{"?role=Compute&class=River", []string{}},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/State/Components"+tt.query, nil))
+		var got response.PagedResponse[smd.Component]
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&got) != nil {
+			t.Errorf("%q: unexpected response %d: %s", tt.query, rec.Code, rec.Body.String())
+			continue
+		}
+		if got.Total != len(tt.want) {
+			t.Errorf("%q: expected a total of %d, got %d", tt.query, len(tt.want), got.Total)
+		}
+		ids := make(map[string]bool)
+		for _, c := range got.Items {
+			ids[c.ID] = true
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, ids, tt.want)
+		}
+		for _, id := range tt.want {
+			if !ids[id] {
+				t.Errorf("%q: expected %s in %v", tt.query, id, ids)
+			}
+		}
+	}
+
+	for _, query := range []string{"?role=Janitor", "?state=ready", "?arch=x86_64", "?class=Valley", "?enabled=maybe"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/State/Components"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestGetComponentByUID(t *testing.T) {
+	store, err := duckdb.NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 7}}); err != nil {
+		t.Fatalf("failed to create component: %v", err)
+	}
+	stored, err := store.GetComponentByXname("x1000c0s0b0n0")
+	if err != nil {
+		t.Fatalf("failed to read component: %v", err)
+	}
+	uid := stored.UID
+
+	for name, r := range map[string]http.Handler{"NewRouter": smd.NewRouter(store), "SMDComponentRoutes": smd.SMDComponentRoutes(store, nil)} {
+		get := func(uid string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/State/Components/ByUID/"+uid, nil))
+			return rec
+		}
+
+		rec := get(uid.String())
+		var c smd.Component
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&c) != nil || c.ID != "x1000c0s0b0n0" {
+			t.Fatalf("%s: unexpected response %d: %+v", name, rec.Code, c)
+		}
+		if rec := get(uuid.New().String()); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 for an unknown UID, got %d", name, rec.Code)
+		}
+		if rec := get("not-a-uuid"); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400 for a malformed UID, got %d", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package certs serves a TLS certificate that can be replaced on disk and reloaded without
+// restarting the server.
+package certs
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// Reloader holds the certificate loaded from a certificate and key file pair.  Its
+// GetCertificate method is meant for tls.Config so that new connections pick up a reloaded
+// certificate while existing ones carry on with the old one.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewReloader loads the certificate and key from certFile and keyFile
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate and key files again.  If either can't be loaded the current
+// certificate is kept and the error is returned.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,121 @@
+// Package events passes inventory changes from the API handlers to whoever is listening, such
+// as the clients of the /events stream.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The kinds of resource an event can be about
+const (
+	TypeNode       = "node"
+	TypeBMC        = "bmc"
+	TypeCollection = "collection"
+)
+
+// What happened to the resource
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it misses some
+const subscriberBuffer = 64
+
+// Event is a change to one resource.  Data is the resource as it is after the change, which
+// is left out for deletions.
+type Event struct {
+	Type   string      `json:"type"`
+	Action string      `json:"action"`
+	ID     string      `json:"id"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// Name is the event name in the stream, e.g. node.created
+func (e Event) Name() string {
+	return e.Type + "." + e.Action
+}
+
+type subscriber struct {
+	events chan Event
+	types  map[string]bool // empty for every type
+	closed bool            // guarded by the broker's mu
+}
+
+// Broker fans the published events out to its subscribers.  A nil Broker drops everything,
+// so handlers can publish whether or not events are enabled.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Publish sends e to every subscriber of its type.  It never blocks: a subscriber whose
+// buffer is full misses the event.
+func (b *Broker) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if len(sub.types) > 0 && !sub.types[e.Type] {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			log.Warn().Str("event", e.Name()).Str("id", e.ID).Msg("Dropping event for a slow subscriber")
+		}
+	}
+}
+
+// Subscribe returns a channel of the events of the given types, or of every type if none are
+// given.  cancel must be called once the subscriber is done, and closes the channel.
+func (b *Broker) Subscribe(types ...string) (events <-chan Event, cancel func()) {
+	sub := &subscriber{events: make(chan Event, subscriberBuffer), types: make(map[string]bool)}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.unsubscribe(sub)
+	}
+}
+
+// Close ends every subscription, e.g. so that open streams don't hold up a server shutdown
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		b.unsubscribe(sub)
+	}
+}
+
+// unsubscribe removes sub and closes its channel.  b.mu must be held.
+func (b *Broker) unsubscribe(sub *subscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
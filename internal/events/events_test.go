@@ -0,0 +1,52 @@
+package events
+
+import "testing"
+
+func TestBrokerFiltersByType(t *testing.T) {
+	b := NewBroker()
+	all, cancelAll := b.Subscribe()
+	defer cancelAll()
+	nodeEvents, cancelNodes := b.Subscribe(TypeNode)
+
+	b.Publish(Event{Type: TypeBMC, Action: ActionCreated, ID: "bmc"})
+	b.Publish(Event{Type: TypeNode, Action: ActionDeleted, ID: "node"})
+
+	if e := <-all; e.ID != "bmc" || e.Time.IsZero() {
+		t.Errorf("expected the BMC event with its time set, got %+v", e)
+	}
+	if e := <-all; e.ID != "node" {
+		t.Errorf("expected the node event, got %+v", e)
+	}
+	if e := <-nodeEvents; e.ID != "node" || e.Name() != "node.deleted" {
+		t.Errorf("expected only the node event, got %+v", e)
+	}
+
+	cancelNodes()
+	cancelNodes()
+	if _, open := <-nodeEvents; open {
+		t.Errorf("expected the channel to be closed once cancelled")
+	}
+
+	b.Close()
+	if _, open := <-all; open {
+		t.Errorf("expected closing the broker to end every subscription")
+	}
+	cancelAll()
+
+	// Publishing without subscribers or to a nil broker must not block or panic
+	b.Publish(Event{Type: TypeNode, Action: ActionCreated})
+	var nilBroker *Broker
+	nilBroker.Publish(Event{Type: TypeNode, Action: ActionCreated})
+}
+
+func TestBrokerDropsForSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	stream, cancel := b.Subscribe()
+	defer cancel()
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(Event{Type: TypeNode, Action: ActionUpdated})
+	}
+	if len(stream) != subscriberBuffer {
+		t.Errorf("expected a full buffer of %d events, got %d", subscriberBuffer, len(stream))
+	}
+}
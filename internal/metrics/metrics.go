@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// InventoryCounter is implemented by storage backends that can count their contents cheaply
+type InventoryCounter interface {
+	CountComputeNodes() (int, error)
+	CountBMCs() (int, error)
+	CountComponentsByState() (map[string]int, error)
+}
+
+// Metrics holds the prometheus registry behind the /metrics endpoint
+type Metrics struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics registry.  Inventory counts are read from counter at most once per
+// cacheTTL so that frequent scrapes don't turn into a stream of queries against storage.
+func New(counter InventoryCounter, cacheTTL time.Duration) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "orchestrator_http_request_duration_seconds",
+			Help:    "Duration of HTTP requests by method, route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+	}
+	m.registry.MustRegister(m.requestDuration)
+	m.registry.MustRegister(newInventoryCollector(counter, cacheTTL))
+	return m
+}
+
+// Handler serves the registry in the prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a completed request.  The chi route pattern is used instead of the
+// path so that IDs in the URL don't create a time series per node.
+func (m *Metrics) ObserveRequest(r *http.Request, status int, duration time.Duration) {
+	route := "unmatched"
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+	m.requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+var (
+	computeNodesDesc = prometheus.NewDesc("orchestrator_compute_nodes_total", "Number of compute nodes in the inventory.", nil, nil)
+	bmcsDesc         = prometheus.NewDesc("orchestrator_bmcs_total", "Number of BMCs in the inventory.", nil, nil)
+	componentsDesc   = prometheus.NewDesc("orchestrator_components_by_state", "Number of SMD components in each state.", []string{"state"}, nil)
+)
+
+// inventoryCollector reports the inventory counts, caching them for ttl
+type inventoryCollector struct {
+	counter InventoryCounter
+	ttl     time.Duration
+
+	mu           sync.Mutex
+	collected    time.Time
+	computeNodes int
+	bmcs         int
+	components   map[string]int
+}
+
+func newInventoryCollector(counter InventoryCounter, ttl time.Duration) *inventoryCollector {
+	return &inventoryCollector{counter: counter, ttl: ttl}
+}
+
+func (c *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- computeNodesDesc
+	ch <- bmcsDesc
+	ch <- componentsDesc
+}
+
+func (c *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.collected) >= c.ttl {
+		c.refresh()
+	}
+
+	ch <- prometheus.MustNewConstMetric(computeNodesDesc, prometheus.GaugeValue, float64(c.computeNodes))
+	ch <- prometheus.MustNewConstMetric(bmcsDesc, prometheus.GaugeValue, float64(c.bmcs))
+	for state, count := range c.components {
+		ch <- prometheus.MustNewConstMetric(componentsDesc, prometheus.GaugeValue, float64(count), state)
+	}
+}
+
+// refresh re-reads the counts.  A failed count keeps its previous value.
+func (c *inventoryCollector) refresh() {
+	if count, err := c.counter.CountComputeNodes(); err == nil {
+		c.computeNodes = count
+	} else {
+		log.Error().Err(err).Msg("Error counting compute nodes")
+	}
+	if count, err := c.counter.CountBMCs(); err == nil {
+		c.bmcs = count
+	} else {
+		log.Error().Err(err).Msg("Error counting BMCs")
+	}
+	if counts, err := c.counter.CountComponentsByState(); err == nil {
+		c.components = counts
+	} else {
+		log.Error().Err(err).Msg("Error counting components")
+	}
+	c.collected = time.Now()
+}
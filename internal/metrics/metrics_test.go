@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fakeCounter struct {
+	calls int
+}
+
+func (f *fakeCounter) CountComputeNodes() (int, error) {
+	f.calls++
+	return 3, nil
+}
+
+func (f *fakeCounter) CountBMCs() (int, error) {
+	return 2, nil
+}
+
+func (f *fakeCounter) CountComponentsByState() (map[string]int, error) {
+	return map[string]int{"Ready": 4, "Off": 1}, nil
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	return rr.Body.String()
+}
+
+func TestInventoryGauges(t *testing.T) {
+	counter := &fakeCounter{}
+	m := New(counter, time.Hour)
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		"orchestrator_compute_nodes_total 3",
+		"orchestrator_bmcs_total 2",
+		`orchestrator_components_by_state{state="Ready"} 4`,
+		`orchestrator_components_by_state{state="Off"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in metrics output", want)
+		}
+	}
+
+	scrape(t, m)
+	if counter.calls != 1 {
+		t.Errorf("expected counts to be cached, storage was queried %d times", counter.calls)
+	}
+}
+
+func TestObserveRequestUsesRoutePattern(t *testing.T) {
+	m := New(&fakeCounter{}, time.Hour)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+			m.ObserveRequest(req, http.StatusOK, 10*time.Millisecond)
+		})
+	})
+	r.Get("/ComputeNode/{nodeID}", func(w http.ResponseWriter, req *http.Request) {})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ComputeNode/abc", nil))
+
+	body := scrape(t, m)
+	want := `orchestrator_http_request_duration_seconds_count{code="200",method="GET",route="/ComputeNode/{nodeID}"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("expected %q in metrics output, got:\n%s", want, body)
+	}
+}
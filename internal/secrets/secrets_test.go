@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	sealed, err := c.Seal("hunter2")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if strings.Contains(sealed, "hunter2") || !IsSealed(sealed) {
+		t.Errorf("expected a sealed value, got %q", sealed)
+	}
+	if again, _ := c.Seal(sealed); again != sealed {
+		t.Errorf("expected sealing a sealed value to be a no-op")
+	}
+
+	opened, err := c.Open(sealed)
+	if err != nil || opened != "hunter2" {
+		t.Errorf("expected to open hunter2, got %q, %v", opened, err)
+	}
+	if opened, _ := c.Open("legacy"); opened != "legacy" {
+		t.Errorf("expected plaintext values to pass through, got %q", opened)
+	}
+
+	other, _ := NewCipher(bytes.Repeat([]byte("x"), 32))
+	if _, err := other.Open(sealed); err == nil {
+		t.Errorf("expected opening with the wrong key to fail")
+	}
+
+	if _, err := NewCipher([]byte("short")); err != ErrInvalidKey {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// BundleVersion is the version of the Bundle format written by this release
+const BundleVersion = 1
+
+var (
+	ErrBundleConflict           = errors.New("bundle conflicts with existing inventory")
+	ErrUnsupportedBundleVersion = errors.New("unsupported bundle version")
+)
+
+// Bundle is a portable JSON export of the whole inventory.  Unlike the parquet snapshots it
+// doesn't depend on the storage backend, so it can be used to move an inventory between
+// environments.
+type Bundle struct {
+	Version          int                    `json:"version"`
+	ExportedAt       time.Time              `json:"exported_at"`
+	ComputeNodes     []nodes.ComputeNode    `json:"compute_nodes"`
+	BMCs             []nodes.BMC            `json:"bmcs"`
+	Collections      []nodes.NodeCollection `json:"collections"`
+	Components       []smd.Component        `json:"components"`
+	RedfishEndpoints []smd.RedfishEndpoint  `json:"redfish_endpoints"`
+}
+
+// ConflictMode decides what an import does with an object whose ID is already stored
+type ConflictMode string
+
+const (
+	ConflictFail      ConflictMode = "fail"      // abort the import
+	ConflictSkip      ConflictMode = "skip"      // keep the stored object
+	ConflictOverwrite ConflictMode = "overwrite" // replace the stored object
+)
+
+// ParseConflictMode parses a ConflictMode, defaulting to ConflictFail when s is empty
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch ConflictMode(s) {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+		return ConflictMode(s), nil
+	}
+	return "", fmt.Errorf("unknown conflict mode %q", s)
+}
@@ -2,8 +2,14 @@ package csm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/openchami/node-orchestrator/internal/api/smd"
@@ -17,23 +23,46 @@ type CSMStorage struct {
 	Client  *http.Client
 }
 
-func NewCSMStorage(baseURI, jwt string) *CSMStorage {
+// DefaultTimeout bounds a CSM request when NewCSMStorage is given no timeout
+const DefaultTimeout = 30 * time.Second
+
+// ErrTimeout is wrapped by the errors of CSM requests that ran out of time, as opposed to
+// those that CSM refused or that couldn't be sent at all
+var ErrTimeout = errors.New("CSM request timed out")
+
+// NewCSMStorage talks to the CSM API at baseURI.  timeout bounds each request from dialing
+// to reading the response, 0 meaning DefaultTimeout, so that a hung SMD can't block a caller
+// forever.
+func NewCSMStorage(baseURI, jwt string, timeout time.Duration) *CSMStorage {
 	return &CSMStorage{
 		BaseURI: baseURI,
 		JWT:     jwt,
-		Client:  createHTTPClient(jwt),
+		Client:  createHTTPClient(jwt, timeout),
 	}
 }
 
-func createHTTPClient(jwt string) *http.Client {
-	// create a transport with default settings
+func createHTTPClient(jwt string, timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	// The transport gives up early on a CSM that can't be reached or never starts answering,
+	// within the overall timeout of the client
+	stepTimeout := min(10*time.Second, timeout)
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   stepTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   stepTimeout,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
 	}
 
 	// create a new http client with the transport
 	client := &http.Client{
 		Transport: transport,
+		Timeout:   timeout,
 	}
 
 	// set the default headers for authentication and encoding
@@ -67,103 +96,234 @@ func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.Transport.RoundTrip(req)
 }
 
+// SaveComputeNode pushes node to CSM: its component and its BMC's and its ethernet interfaces
+// to SMD, and its boot parameters to BSS.  It stops at the first request that fails.
 func (s *CSMStorage) SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode, nid int) error {
+	// SMD identifies components by xname, so a node without one can't be represented
+	if node.XName.String() == "" {
+		return errors.New("node has no xname")
+	}
+
 	// Call SMD to create the Components representing the Comptue Node and BMC
 	csmNodeComponent := smd.Component{
-		ID:    node.LocationString,
+		ID:    node.XName.String(),
 		Role:  "Compute",
-		Arch:  "X86",
+		Arch:  smd.ComponentArchFor(node.Architecture),
 		State: "Ready",
 		NID:   nid,
 	}
-	csmBMCComponent := smd.Component{
-		ID:   node.BMC.LocationString,
-		Role: "BMC",
+	if err := s.post("v2/State/Components/", csmNodeComponent); err != nil {
+		return err
+	}
+	if node.BMC != nil && node.BMC.XName.String() != "" {
+		csmBMCComponent := smd.Component{
+			ID:   node.BMC.XName.String(),
+			Role: "BMC",
+		}
+		if err := s.post("v2/State/Components/", csmBMCComponent); err != nil {
+			return err
+		}
 	}
-	csmNodeComponentJSON, _ := json.Marshal(csmNodeComponent)
-	s.Client.Post(s.BaseURI+"v2/State/Components/", "application/json", bytes.NewBuffer(csmNodeComponentJSON))
-	csmBMCComponentJSON, _ := json.Marshal(csmBMCComponent)
-	s.Client.Post(s.BaseURI+"v2/State/Components/", "application/json", bytes.NewBuffer(csmBMCComponentJSON))
 
 	// Call SMD to create the EthernetInterfaces representing the Compute Node's network interfaces
 	for _, intf := range node.NetworkInterfaces {
 		csmInterface := smd.CompEthInterface{
 			MACAddr: intf.MACAddress,
 			IPAddrs: []smd.IPAddressMapping{{IPAddr: intf.IPv4Address}},
-			CompID:  node.LocationString,
+			CompID:  node.XName.String(),
+		}
+		if err := s.post("v2/Inventory/EthernetInterfaces/", csmInterface); err != nil {
+			return err
 		}
-		csmInterfaceJSON, _ := json.Marshal(csmInterface)
-		s.Client.Post(s.BaseURI+"v2/Inventory/EthernetInterfaces/", "application/json", bytes.NewBuffer(csmInterfaceJSON))
 	}
 
 	// Call BSS to set the boot parameters
-	bootParams := smd.BootParams{
-		Macs:   []string{node.BootMac},
-		Kernel: node.BootData.KernelURL,
-		Initrd: node.BootData.ImageURL,
-		Params: node.BootData.KernelCommandLine,
-	}
-	bootParamsJSON, _ := json.Marshal(bootParams)
-	s.Client.Post(s.BaseURI+"/bootparameters", "application/json", bytes.NewBuffer(bootParamsJSON))
+	if node.BootData != nil && node.BootMac != "" {
+		bootParams := smd.BootParams{
+			Macs:   []string{node.BootMac},
+			Kernel: node.BootData.KernelURL,
+			Initrd: node.BootData.ImageURL,
+			Params: node.BootData.KernelCommandLine,
+		}
+		if err := s.post("/bootparameters", bootParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notImplemented is the error of the methods that CSM can't serve yet, instead of the empty
+// results that would pass for an empty inventory
+func notImplemented(method string) error {
+	return fmt.Errorf("CSM %s: %w", method, storage.ErrNotImplemented)
+}
+
+// timeoutError wraps err with ErrTimeout if it is a timeout
+func timeoutError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
+
+// post sends body as JSON to path under BaseURI and fails unless CSM answers with a 2xx status
+func (s *CSMStorage) post(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Post(s.BaseURI+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return timeoutError(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return timeoutError(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", path, resp.Status)
+	}
 	return nil
 }
 
 func (s *CSMStorage) GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, error) {
 	// TODO: Implement GetComputeNode method
-	return nodes.ComputeNode{}, nil
+	return nodes.ComputeNode{}, notImplemented("GetComputeNode")
 }
 
 func (s *CSMStorage) UpdateComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
 	// TODO: Implement UpdateComputeNode method
-	return nil
+	return notImplemented("UpdateComputeNode")
 }
 
 func (s *CSMStorage) DeleteComputeNode(nodeID uuid.UUID) error {
 	// TODO: Implement DeleteComputeNode method
-	return nil
+	return notImplemented("DeleteComputeNode")
 }
 
 func (s *CSMStorage) LookupComputeNodeByXName(xname string) (nodes.ComputeNode, error) {
 	// TODO: Implement LookupComputeNodeByXName method
-	return nodes.ComputeNode{}, nil
+	return nodes.ComputeNode{}, notImplemented("LookupComputeNodeByXName")
 }
 
 func (s *CSMStorage) LookupComputeNodeByMACAddress(mac string) (nodes.ComputeNode, error) {
 	// TODO: Implement LookupComputeNodeByMACAddress method
-	return nodes.ComputeNode{}, nil
+	return nodes.ComputeNode{}, notImplemented("LookupComputeNodeByMACAddress")
+}
+
+func (s *CSMStorage) LookupComputeNodesByMACAddresses(macs []string) (map[string]nodes.ComputeNode, error) {
+	// TODO: Implement LookupComputeNodesByMACAddresses method
+	return nil, notImplemented("LookupComputeNodesByMACAddresses")
+}
+
+func (s *CSMStorage) LookupComputeNodeByHostname(hostname string) (nodes.ComputeNode, error) {
+	// TODO: Implement LookupComputeNodeByHostname method
+	return nodes.ComputeNode{}, notImplemented("LookupComputeNodeByHostname")
+}
+
+func (s *CSMStorage) LookupComputeNodeByNID(nid int) (nodes.ComputeNode, error) {
+	// TODO: Implement LookupComputeNodeByNID method
+	return nodes.ComputeNode{}, notImplemented("LookupComputeNodeByNID")
 }
 
 func (s *CSMStorage) SearchComputeNodes(opts ...storage.NodeSearchOption) ([]nodes.ComputeNode, error) {
 	// TODO: Implement SearchComputeNodes method
-	return []nodes.ComputeNode{}, nil
+	return nil, notImplemented("SearchComputeNodes")
+}
+
+func (s *CSMStorage) CountMatchingComputeNodes(opts ...storage.NodeSearchOption) (int, error) {
+	// TODO: Implement CountMatchingComputeNodes method
+	return 0, notImplemented("CountMatchingComputeNodes")
+}
+
+func (s *CSMStorage) StreamComputeNodes(visit func(nodes.ComputeNode) error, opts ...storage.NodeSearchOption) error {
+	found, err := s.SearchComputeNodes(opts...)
+	if err != nil {
+		return err
+	}
+	for _, node := range found {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *CSMStorage) SaveBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
 	// TODO: Implement SaveBMC method
-	return nil
+	return notImplemented("SaveBMC")
+}
+
+func (s *CSMStorage) SaveBMCs(bmcs []nodes.BMC) error {
+	// TODO: Implement SaveBMCs method
+	return notImplemented("SaveBMCs")
 }
 
 func (s *CSMStorage) GetBMC(bmcID uuid.UUID) (nodes.BMC, error) {
 	// TODO: Implement GetBMC method
-	return nodes.BMC{}, nil
+	return nodes.BMC{}, notImplemented("GetBMC")
 }
 
 func (s *CSMStorage) UpdateBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
 	// TODO: Implement UpdateBMC method
-	return nil
+	return notImplemented("UpdateBMC")
 }
 
 func (s *CSMStorage) DeleteBMC(bmcID uuid.UUID) error {
 	// TODO: Implement DeleteBMC method
-	return nil
+	return notImplemented("DeleteBMC")
 }
 
 func (s *CSMStorage) LookupBMCByXName(xname string) (nodes.BMC, error) {
 	// TODO: Implement LookupBMCByXName method
-	return nodes.BMC{}, nil
+	return nodes.BMC{}, notImplemented("LookupBMCByXName")
 }
 
 func (s *CSMStorage) LookupBMCByMACAddress(mac string) (nodes.BMC, error) {
 	// TODO: Implement LookupBMCByMACAddress method
-	return nodes.BMC{}, nil
+	return nodes.BMC{}, notImplemented("LookupBMCByMACAddress")
+}
+
+func (s *CSMStorage) UpdateComputeNodeStatus(xname string, status nodes.ComputeNodeStatus) (nodes.ComputeNode, error) {
+	// TODO: Implement UpdateComputeNodeStatus method
+	return nodes.ComputeNode{}, notImplemented("UpdateComputeNodeStatus")
 }
+
+func (s *CSMStorage) AddNodeNote(nodeID uuid.UUID, note nodes.Note) error {
+	// TODO: Implement AddNodeNote method
+	return notImplemented("AddNodeNote")
+}
+
+func (s *CSMStorage) GetNodeNotes(nodeID uuid.UUID) ([]nodes.Note, error) {
+	// TODO: Implement GetNodeNotes method
+	return nil, notImplemented("GetNodeNotes")
+}
+
+func (s *CSMStorage) SearchBMCs(opts ...storage.BMCSearchOption) ([]nodes.BMC, error) {
+	// TODO: Implement SearchBMCs method
+	return nil, notImplemented("SearchBMCs")
+}
+
+func (s *CSMStorage) AllocateNID() (int, error) {
+	// TODO: Implement AllocateNID method
+	return 0, notImplemented("AllocateNID")
+}
+
+func (s *CSMStorage) CountComputeNodes() (int, error) {
+	// TODO: Implement CountComputeNodes method
+	return 0, notImplemented("CountComputeNodes")
+}
+
+func (s *CSMStorage) CountBMCs() (int, error) {
+	// TODO: Implement CountBMCs method
+	return 0, notImplemented("CountBMCs")
+}
+
+func (s *CSMStorage) CountComponentsByState() (map[string]int, error) {
+	// TODO: Implement CountComponentsByState method
+	return nil, notImplemented("CountComponentsByState")
+}
+
+// Shutdown has nothing to release, the HTTP client closes its idle connections on its own
+func (s *CSMStorage) Shutdown(ctx context.Context) {}
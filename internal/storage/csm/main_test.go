@@ -0,0 +1,56 @@
+package csm
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestSaveComputeNodeErrors(t *testing.T) {
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusBadRequest)
+	}))
+	defer refusing.Close()
+
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s0b0n0"), Architecture: "x86_64"}
+
+	start := time.Now()
+	err := NewCSMStorage(hung.URL+"/", "token", 50*time.Millisecond).SaveComputeNode(node.ID, node, 1)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a hung CSM to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the request to give up after its timeout, took %v", elapsed)
+	}
+
+	err = NewCSMStorage(refusing.URL+"/", "token", 0).SaveComputeNode(node.ID, node, 1)
+	if err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a refusal that is not a timeout, got %v", err)
+	}
+}
+
+func TestUnimplementedMethods(t *testing.T) {
+	s := NewNodeStorage("http://127.0.0.1:1/", "token", time.Second)
+	if _, err := s.GetComputeNode(uuid.New()); !errors.Is(err, storage.ErrNotImplemented) {
+		t.Errorf("expected GetComputeNode to be unimplemented, got %v", err)
+	}
+	if found, err := s.SearchComputeNodes(); !errors.Is(err, storage.ErrNotImplemented) || found != nil {
+		t.Errorf("expected SearchComputeNodes to be unimplemented rather than empty, got %v, %v", found, err)
+	}
+	if _, err := s.AllocateNID(); !errors.Is(err, storage.ErrNotImplemented) {
+		t.Errorf("expected AllocateNID to be unimplemented, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package csm
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// NodeStorage serves the inventory from CSM for -storage=csm.  It is a CSMStorage whose
+// SaveComputeNode takes the NID from the node, as storage.NodeStorage has it.  Most methods
+// are still stubs that fail with storage.ErrNotImplemented.
+type NodeStorage struct {
+	*CSMStorage
+}
+
+var _ storage.NodeStorage = NodeStorage{}
+
+// NewNodeStorage talks to the CSM API at baseURI like NewCSMStorage
+func NewNodeStorage(baseURI, jwt string, timeout time.Duration) NodeStorage {
+	return NodeStorage{CSMStorage: NewCSMStorage(baseURI, jwt, timeout)}
+}
+
+func (s NodeStorage) SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
+	return s.CSMStorage.SaveComputeNode(nodeID, node, node.NID)
+}
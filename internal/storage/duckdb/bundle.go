@@ -0,0 +1,320 @@
+package duckdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// ExportBundle reads the whole inventory into a storage.Bundle.  Passwords are exported as
+// stored, so a bundle from a storage with a secret key can only be read with the same key.
+func (d *DuckDBStorage) ExportBundle() (storage.Bundle, error) {
+	bundle := storage.Bundle{
+		Version:          storage.BundleVersion,
+		ExportedAt:       time.Now().UTC(),
+		ComputeNodes:     []nodes.ComputeNode{},
+		BMCs:             []nodes.BMC{},
+		Collections:      []nodes.NodeCollection{},
+		Components:       []smd.Component{},
+		RedfishEndpoints: []smd.RedfishEndpoint{},
+	}
+
+	if err := d.scanJSONRows(`SELECT data FROM compute_nodes ORDER BY id`, func(data []byte) error {
+		var node nodes.ComputeNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		bundle.ComputeNodes = append(bundle.ComputeNodes, node)
+		return nil
+	}); err != nil {
+		return bundle, err
+	}
+
+	if err := d.scanJSONRows(`SELECT data FROM bmcs ORDER BY id`, func(data []byte) error {
+		var bmc nodes.BMC
+		if err := json.Unmarshal(data, &bmc); err != nil {
+			return err
+		}
+		bundle.BMCs = append(bundle.BMCs, bmc)
+		return nil
+	}); err != nil {
+		return bundle, err
+	}
+
+	if err := d.scanJSONRows(`SELECT data FROM collections ORDER BY id`, func(data []byte) error {
+		var collection nodes.NodeCollection
+		if err := json.Unmarshal(data, &collection); err != nil {
+			return err
+		}
+		bundle.Collections = append(bundle.Collections, collection)
+		return nil
+	}); err != nil {
+		return bundle, err
+	}
+
+	components, err := d.GetComponents()
+	if err != nil {
+		return bundle, err
+	}
+	bundle.Components = append(bundle.Components, components...)
+
+	rows, err := d.db.Query(`SELECT id, name, uri, username, password FROM redfish_endpoints ORDER BY id`)
+	if err != nil {
+		return bundle, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e smd.RedfishEndpoint
+		if err := rows.Scan(&e.ID, &e.Name, &e.URI, &e.User, &e.Password); err != nil {
+			return bundle, err
+		}
+		bundle.RedfishEndpoints = append(bundle.RedfishEndpoints, e)
+	}
+	return bundle, rows.Err()
+}
+
+// scanJSONRows runs a query selecting a single JSON column and hands each value to fn
+func (d *DuckDBStorage) scanJSONRows(query string, fn func(data []byte) error) error {
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportBundle writes a bundle in a single transaction, so a conflict or error leaves the
+// inventory untouched.  IDs are preserved.  Objects whose ID is already stored are handled
+// according to mode; an xname or collection name that belongs to a different object is
+// always a conflict, as is overwriting an object with a different xname or name.
+func (d *DuckDBStorage) ImportBundle(bundle storage.Bundle, mode storage.ConflictMode) error {
+	if bundle.Version != storage.BundleVersion {
+		return fmt.Errorf("%w: %d", storage.ErrUnsupportedBundleVersion, bundle.Version)
+	}
+
+	var imported []nodes.NodeCollection
+	err := d.withTx(func(tx *sql.Tx) error {
+		for _, node := range bundle.ComputeNodes {
+			node.NormalizeXNames()
+			write, err := checkBundleConflict(tx, "compute_nodes", "xname", node.ID, node.XName.String(), mode)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			sealed, err := d.sealNode(node)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(sealed)
+			if err != nil {
+				return err
+			}
+			if err := claimKey(tx, nodeXNameKey, node.ID, node.XName.String()); err != nil {
+				return err
+			}
+			if err := claimKey(tx, nodeHostnameKey, node.ID, node.Hostname); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO compute_nodes (id, xname, hostname, data) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET hostname = excluded.hostname, data = excluded.data, updated_at = now()`,
+				node.ID, nullableXName(node.XName.String()), node.Hostname, string(data)); err != nil {
+				return err
+			}
+			if err := reserveNID(tx, node.ID, node.NID); err != nil {
+				return err
+			}
+			if err := replaceEthernetInterfaces(tx, node.ID, node); err != nil {
+				return err
+			}
+		}
+
+		for _, bmc := range bundle.BMCs {
+			bmc.XName = bmc.XName.Normalize()
+			write, err := checkBundleConflict(tx, "bmcs", "xname", bmc.ID, bmc.XName.String(), mode)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			sealed, err := d.sealBMC(bmc)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(sealed)
+			if err != nil {
+				return err
+			}
+			if err := claimKey(tx, bmcXNameKey, bmc.ID, bmc.XName.String()); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO bmcs (id, xname, data) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = now()`,
+				bmc.ID, nullableXName(bmc.XName.String()), string(data)); err != nil {
+				return err
+			}
+		}
+
+		for _, collection := range bundle.Collections {
+			write, err := checkBundleConflict(tx, "collections", "name", collection.ID, collection.Name, mode)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			data, err := json.Marshal(collection)
+			if err != nil {
+				return err
+			}
+			nodesData, err := json.Marshal(collection.Nodes)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO collections (id, name, data, nodes) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data, nodes = excluded.nodes`,
+				collection.ID, collection.Name, string(data), string(nodesData)); err != nil {
+				return err
+			}
+			imported = append(imported, collection)
+		}
+
+		for _, c := range bundle.Components {
+			write, err := checkIDConflict(tx, "components", c.ID, mode)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO components (uid, id, type, subtype, role, sub_role, net_type, arch, class, state, flag, enabled, sw_status, nid, reservation_disabled, locked)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(id) DO UPDATE SET
+				uid = excluded.uid,
+				type = excluded.type,
+				subtype = excluded.subtype,
+				role = excluded.role,
+				sub_role = excluded.sub_role,
+				net_type = excluded.net_type,
+				arch = excluded.arch,
+				class = excluded.class,
+				state = excluded.state,
+				flag = excluded.flag,
+				enabled = excluded.enabled,
+				sw_status = excluded.sw_status,
+				nid = excluded.nid,
+				reservation_disabled = excluded.reservation_disabled,
+				locked = excluded.locked`,
+				c.UID, c.ID, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range bundle.RedfishEndpoints {
+			write, err := checkIDConflict(tx, "redfish_endpoints", e.ID, mode)
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+			if e.Password, err = d.sealPassword(e.Password); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO redfish_endpoints (id, name, uri, username, password) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(id) DO UPDATE SET name = excluded.name, uri = excluded.uri, username = excluded.username, password = excluded.password`,
+				e.ID, e.Name, e.URI, e.User, e.Password); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The collection manager isn't transactional, so it only learns about the imported
+	// collections once they are committed
+	for i := range imported {
+		if err := d.collectionManager.UpdateCollection(&imported[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBundleConflict reports whether a bundle row with id and a unique key (an xname or a
+// name) should be written.  It returns an error wrapping storage.ErrBundleConflict when the
+// row can't be written under mode.
+func checkBundleConflict(tx *sql.Tx, table, keyColumn string, id uuid.UUID, key string, mode storage.ConflictMode) (bool, error) {
+	rows, err := tx.Query(`SELECT id::VARCHAR, `+keyColumn+` FROM `+table+` WHERE id = ? OR (`+keyColumn+` IS NOT NULL AND `+keyColumn+` = ?)`, id, key)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var sameID bool
+	var storedKey string
+	for rows.Next() {
+		var storedID string
+		var k sql.NullString
+		if err := rows.Scan(&storedID, &k); err != nil {
+			return false, err
+		}
+		if storedID != id.String() {
+			return false, fmt.Errorf("%w: %s %q already belongs to %s in %s", storage.ErrBundleConflict, keyColumn, key, storedID, table)
+		}
+		sameID = true
+		storedKey = k.String
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if !sameID {
+		return true, nil
+	}
+	switch mode {
+	case storage.ConflictSkip:
+		return false, nil
+	case storage.ConflictOverwrite:
+		if storedKey != key {
+			return false, fmt.Errorf("%w: %s in %s would change %s from %q to %q", storage.ErrBundleConflict, id, table, keyColumn, storedKey, key)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %s already exists in %s", storage.ErrBundleConflict, id, table)
+}
+
+// checkIDConflict is checkBundleConflict for the SMD tables, which are keyed by xname alone
+func checkIDConflict(tx *sql.Tx, table, id string, mode storage.ConflictMode) (bool, error) {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE id = ?`, id).Scan(&count); err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return true, nil
+	}
+	switch mode {
+	case storage.ConflictSkip:
+		return false, nil
+	case storage.ConflictOverwrite:
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %s already exists in %s", storage.ErrBundleConflict, id, table)
+}
@@ -0,0 +1,138 @@
+package duckdb
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func populateBundleSource(t *testing.T) *DuckDBStorage {
+	t.Helper()
+	source, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s1b0"), MACAddress: "00:00:00:00:00:01"}
+	if err := source.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x3000c0s1b0n0"), Hostname: "nid001", Architecture: "x86_64", BMC: &bmc}
+	if err := source.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	collection := &nodes.NodeCollection{Name: "compute", Type: nodes.DefaultType, Nodes: []xnames.NodeXname{node.XName}}
+	if err := source.SaveCollection(collection); err != nil {
+		t.Fatalf("failed to save collection: %v", err)
+	}
+	component := smd.Component{UID: uuid.New(), ID: "x3000c0s1b0n0", Type: "Node", State: "Ready", Enabled: true, NID: 1}
+	if err := source.CreateOrUpdateComponents([]smd.Component{component}); err != nil {
+		t.Fatalf("failed to save component: %v", err)
+	}
+	if _, err := source.db.Exec(`INSERT INTO redfish_endpoints (id, name, uri, username, password) VALUES ('x3000c0s1b0', 'bmc', 'https://x3000c0s1b0', 'root', 'secret')`); err != nil {
+		t.Fatalf("failed to save redfish endpoint: %v", err)
+	}
+	return source
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	source := populateBundleSource(t)
+	exported, err := source.ExportBundle()
+	if err != nil {
+		t.Fatalf("failed to export bundle: %v", err)
+	}
+
+	// Go through JSON the same way the admin endpoints do
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("failed to marshal bundle: %v", err)
+	}
+	var bundle storage.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatalf("failed to unmarshal bundle: %v", err)
+	}
+
+	target, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if err := target.ImportBundle(bundle, storage.ConflictFail); err != nil {
+		t.Fatalf("failed to import bundle: %v", err)
+	}
+
+	reexported, err := target.ExportBundle()
+	if err != nil {
+		t.Fatalf("failed to export imported bundle: %v", err)
+	}
+	reexported.ExportedAt = exported.ExportedAt
+	if !reflect.DeepEqual(exported, reexported) {
+		t.Errorf("round trip changed the bundle\nexported: %+v\nimported: %+v", exported, reexported)
+	}
+
+	node, err := target.LookupComputeNodeByXName("x3000c0s1b0n0")
+	if err != nil {
+		t.Fatalf("imported node not found by xname: %v", err)
+	}
+	if node.ID != exported.ComputeNodes[0].ID {
+		t.Errorf("expected the node ID to be preserved, got %s", node.ID)
+	}
+	if _, ok := target.collectionManager.GetCollection("compute"); !ok {
+		t.Errorf("expected the imported collection to be registered")
+	}
+}
+
+func TestImportBundleConflicts(t *testing.T) {
+	source := populateBundleSource(t)
+	bundle, err := source.ExportBundle()
+	if err != nil {
+		t.Fatalf("failed to export bundle: %v", err)
+	}
+
+	// A new BMC alongside the conflicting objects must not be written when the import fails
+	extra := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s2b0")}
+	bundle.BMCs = append(bundle.BMCs, extra)
+
+	if err := source.ImportBundle(bundle, storage.ConflictFail); !errors.Is(err, storage.ErrBundleConflict) {
+		t.Fatalf("expected ErrBundleConflict, got %v", err)
+	}
+	if _, err := source.GetBMC(extra.ID); err == nil {
+		t.Errorf("expected a failed import to write nothing")
+	}
+
+	if err := source.ImportBundle(bundle, storage.ConflictSkip); err != nil {
+		t.Fatalf("failed to import with skip: %v", err)
+	}
+	if _, err := source.GetBMC(extra.ID); err != nil {
+		t.Errorf("expected the new BMC to be imported: %v", err)
+	}
+
+	bundle.ComputeNodes[0].Hostname = "renamed"
+	if err := source.ImportBundle(bundle, storage.ConflictOverwrite); err != nil {
+		t.Fatalf("failed to import with overwrite: %v", err)
+	}
+	node, err := source.GetComputeNode(bundle.ComputeNodes[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if node.Hostname != "renamed" {
+		t.Errorf("expected overwrite to replace the node, got hostname %q", node.Hostname)
+	}
+
+	// Moving an xname to a different ID is a conflict whatever the mode
+	bundle.ComputeNodes[0].ID = uuid.New()
+	if err := source.ImportBundle(bundle, storage.ConflictOverwrite); !errors.Is(err, storage.ErrBundleConflict) {
+		t.Errorf("expected ErrBundleConflict for a reused xname, got %v", err)
+	}
+
+	bundle.Version = storage.BundleVersion + 1
+	if err := source.ImportBundle(bundle, storage.ConflictSkip); !errors.Is(err, storage.ErrUnsupportedBundleVersion) {
+		t.Errorf("expected ErrUnsupportedBundleVersion, got %v", err)
+	}
+}
@@ -46,8 +46,12 @@ func (d *DuckDBStorage) UpdateCollection(collection *nodes.NodeCollection) error
 	if err != nil {
 		return err
 	}
+	nodesData, err := json.Marshal(collection.Nodes)
+	if err != nil {
+		return err
+	}
 
-	_, err = d.db.Exec(`UPDATE collections SET data = ? WHERE id = ?`, string(data), collection.ID)
+	_, err = d.db.Exec(`UPDATE collections SET data = ?, nodes = ? WHERE id = ?`, string(data), string(nodesData), collection.ID)
 	return err
 }
 
@@ -0,0 +1,34 @@
+package duckdb
+
+import (
+	"testing"
+
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestCollectionMembershipChanges(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	first := xnames.NewNodeXname("x1000c0s0b0n0")
+	second := xnames.NewNodeXname("x1000c0s1b0n0")
+	collection := &nodes.NodeCollection{Name: "batch", Type: nodes.DefaultType, Nodes: []xnames.NodeXname{first}}
+	if err := d.SaveCollection(collection); err != nil {
+		t.Fatalf("failed to save collection: %v", err)
+	}
+
+	collection.Nodes = []xnames.NodeXname{second}
+	if err := d.UpdateCollection(collection); err != nil {
+		t.Fatalf("failed to update collection: %v", err)
+	}
+	if found, err := d.FindCollectionsByNode(first); err != nil || len(found) != 0 {
+		t.Errorf("expected no collections for the removed node, got %d, %v", len(found), err)
+	}
+	if found, err := d.FindCollectionsByNode(second); err != nil || len(found) != 1 || found[0].ID != collection.ID {
+		t.Errorf("expected the collection for the added node, got %v, %v", found, err)
+	}
+}
@@ -3,18 +3,74 @@ package duckdb
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
 func (d *DuckDBStorage) SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
-	data, err := json.Marshal(node)
+	node.NormalizeXNames()
+	if err := d.fillTimestamps("compute_nodes", nodeID, &node.CreatedAt, &node.UpdatedAt); err != nil {
+		return err
+	}
+	sealed, err := d.sealNode(node)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sealed)
 	if err != nil {
 		return err
 	}
-	_, err = d.db.Exec(`INSERT INTO compute_nodes (id, xname, data) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data`, nodeID, node.LocationString, string(data))
-	return err
+	return d.withTx(func(tx *sql.Tx) error {
+		if err := claimKey(tx, nodeXNameKey, nodeID, node.XName.String()); err != nil {
+			return err
+		}
+		if err := claimKey(tx, nodeHostnameKey, nodeID, node.Hostname); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO compute_nodes (id, added, xname, hostname, data) VALUES (?, ?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET xname = excluded.xname, hostname = excluded.hostname, data = excluded.data, updated_at = now()`,
+			nodeID, node.CreatedAt, nullableXName(node.XName.String()), node.Hostname, string(data)); err != nil {
+			return err
+		}
+		if err := reserveNID(tx, nodeID, node.NID); err != nil {
+			return err
+		}
+		return replaceEthernetInterfaces(tx, nodeID, node)
+	})
+}
+
+// fillTimestamps settles the timestamps of a save of the row with id in table.  A row that
+// is already stored keeps the time it was added as its CreatedAt, whatever the caller sent,
+// and a missing timestamp is now.
+func (d *DuckDBStorage) fillTimestamps(table string, id uuid.UUID, createdAt, updatedAt *time.Time) error {
+	now := nodes.Timestamp(time.Now())
+	var added time.Time
+	err := d.db.QueryRow(`SELECT added FROM `+table+` WHERE id = ?`, id).Scan(&added)
+	switch {
+	case err == nil:
+		*createdAt = nodes.Timestamp(added)
+	case err != sql.ErrNoRows:
+		return err
+	case createdAt.IsZero():
+		*createdAt = now
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = now
+	}
+	return nil
+}
+
+// nullableXName stores a missing xname as NULL
+func nullableXName(xname string) interface{} {
+	if xname == "" {
+		return nil
+	}
+	return xname
 }
 
 func (d *DuckDBStorage) GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, error) {
@@ -23,9 +79,7 @@ func (d *DuckDBStorage) GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, err
 	if err != nil {
 		return nodes.ComputeNode{}, err
 	}
-	var node nodes.ComputeNode
-	err = json.Unmarshal([]byte(data), &node)
-	return node, err
+	return d.decodeNode(data)
 }
 
 func (d *DuckDBStorage) UpdateComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
@@ -33,19 +87,77 @@ func (d *DuckDBStorage) UpdateComputeNode(nodeID uuid.UUID, node nodes.ComputeNo
 }
 
 func (d *DuckDBStorage) DeleteComputeNode(nodeID uuid.UUID) error {
-	_, err := d.db.Exec(`DELETE FROM compute_nodes WHERE id = ?`, nodeID)
-	return err
+	return d.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM ethernet_interfaces WHERE node_id = ?`, nodeID); err != nil {
+			return err
+		}
+		if err := releaseKeys(tx, nodeID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM compute_nodes WHERE id = ?`, nodeID)
+		return err
+	})
+}
+
+// UpdateComputeNodeStatus rewrites only the status and updated_at of the stored document.  The
+// document is changed as stored, so its sealed passwords are never opened.
+func (d *DuckDBStorage) UpdateComputeNodeStatus(xname string, status nodes.ComputeNodeStatus) (nodes.ComputeNode, error) {
+	xname = xnames.NewNodeXname(xname).Normalize().String()
+	var node nodes.ComputeNode
+	err := d.withTx(func(tx *sql.Tx) error {
+		var id uuid.UUID
+		var data string
+		err := tx.QueryRow(`SELECT id, data FROM compute_nodes WHERE xname = ?`, xname).Scan(&id, &data)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: compute node %s", storage.ErrNotFound, xname)
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(data), &node); err != nil {
+			return err
+		}
+		node.Status = status
+		node.UpdatedAt = nodes.Timestamp(time.Now())
+		updated, err := json.Marshal(node)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE compute_nodes SET data = ?, updated_at = now() WHERE id = ?`, string(updated), id)
+		return err
+	})
+	if err != nil {
+		return nodes.ComputeNode{}, err
+	}
+	return d.openNode(node)
 }
 
 func (d *DuckDBStorage) LookupComputeNodeByXName(xname string) (nodes.ComputeNode, error) {
 	var data string
-	err := d.db.QueryRow(`SELECT data FROM compute_nodes WHERE json_extract(data, '$.xname') = ?`, xname).Scan(&data)
+	err := d.db.QueryRow(`SELECT data FROM compute_nodes WHERE xname = ?`, xnames.NewNodeXname(xname).Normalize().String()).Scan(&data)
 	if err != nil {
 		return nodes.ComputeNode{}, err
 	}
-	var node nodes.ComputeNode
-	err = json.Unmarshal([]byte(data), &node)
-	return node, err
+	return d.decodeNode(data)
+}
+
+// LookupComputeNodeByHostname finds a node by the hostname column kept alongside its data
+func (d *DuckDBStorage) LookupComputeNodeByHostname(hostname string) (nodes.ComputeNode, error) {
+	var data string
+	err := d.db.QueryRow(`SELECT data FROM compute_nodes WHERE hostname = ?`, hostname).Scan(&data)
+	if err != nil {
+		return nodes.ComputeNode{}, err
+	}
+	return d.decodeNode(data)
+}
+
+func (d *DuckDBStorage) LookupComputeNodeByNID(nid int) (nodes.ComputeNode, error) {
+	var data string
+	err := d.db.QueryRow(`SELECT data FROM compute_nodes WHERE json_extract(data, '$.nid')::INTEGER = ?`, nid).Scan(&data)
+	if err != nil {
+		return nodes.ComputeNode{}, err
+	}
+	return d.decodeNode(data)
 }
 
 func (d *DuckDBStorage) LookupComputeNodeByMACAddress(mac string) (nodes.ComputeNode, error) {
@@ -54,19 +166,93 @@ func (d *DuckDBStorage) LookupComputeNodeByMACAddress(mac string) (nodes.Compute
 	if err != nil {
 		return nodes.ComputeNode{}, err
 	}
-	var node nodes.ComputeNode
-	err = json.Unmarshal([]byte(data), &node)
-	return node, err
+	return d.decodeNode(data)
+}
+
+func (d *DuckDBStorage) LookupComputeNodesByMACAddresses(macs []string) (map[string]nodes.ComputeNode, error) {
+	found := make(map[string]nodes.ComputeNode, len(macs))
+	if len(macs) == 0 {
+		return found, nil
+	}
+	placeholders := make([]string, len(macs))
+	args := make([]interface{}, len(macs))
+	for i, mac := range macs {
+		placeholders[i] = "?"
+		args[i] = strings.ToLower(mac)
+	}
+	rows, err := d.db.Query(`SELECT data FROM compute_nodes WHERE lower(json_extract_string(data, '$.boot_mac')) IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		node, err := d.decodeNode(data)
+		if err != nil {
+			return nil, err
+		}
+		found[strings.ToLower(node.BootMac)] = node
+	}
+	return found, rows.Err()
 }
 
 func (d *DuckDBStorage) SaveBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
-	data, err := json.Marshal(bmc)
+	bmc.XName = bmc.XName.Normalize()
+	if err := d.fillTimestamps("bmcs", bmcID, &bmc.CreatedAt, &bmc.UpdatedAt); err != nil {
+		return err
+	}
+	sealed, err := d.sealBMC(bmc)
 	if err != nil {
 		return err
 	}
-	_, err = d.db.Exec(`INSERT INTO bmcs (id, data) VALUES (?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data`,
-		bmcID, string(data))
-	return err
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	return d.withTx(func(tx *sql.Tx) error {
+		if err := claimKey(tx, bmcXNameKey, bmcID, bmc.XName.String()); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO bmcs (id, added, xname, data) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO UPDATE SET xname = excluded.xname, data = excluded.data, updated_at = now()`,
+			bmcID, bmc.CreatedAt, nullableXName(bmc.XName.String()), string(data))
+		return err
+	})
+}
+
+// SaveBMCs inserts new BMCs in a single transaction, so a duplicate ID or xname leaves none
+// of them stored
+func (d *DuckDBStorage) SaveBMCs(bmcs []nodes.BMC) error {
+	now := nodes.Timestamp(time.Now())
+	return d.withTx(func(tx *sql.Tx) error {
+		for _, bmc := range bmcs {
+			bmc.XName = bmc.XName.Normalize()
+			if bmc.CreatedAt.IsZero() {
+				bmc.CreatedAt = now
+			}
+			if bmc.UpdatedAt.IsZero() {
+				bmc.UpdatedAt = now
+			}
+			sealed, err := d.sealBMC(bmc)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(sealed)
+			if err != nil {
+				return err
+			}
+			if err := claimKey(tx, bmcXNameKey, bmc.ID, bmc.XName.String()); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO bmcs (id, added, xname, data) VALUES (?, ?, ?, ?)`,
+				bmc.ID, bmc.CreatedAt, nullableXName(bmc.XName.String()), string(data)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (d *DuckDBStorage) GetBMC(bmcID uuid.UUID) (nodes.BMC, error) {
@@ -75,9 +261,7 @@ func (d *DuckDBStorage) GetBMC(bmcID uuid.UUID) (nodes.BMC, error) {
 	if err != nil {
 		return nodes.BMC{}, err
 	}
-	var bmc nodes.BMC
-	err = json.Unmarshal([]byte(data), &bmc)
-	return bmc, err
+	return d.decodeBMC(data)
 }
 
 func (d *DuckDBStorage) UpdateBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
@@ -85,8 +269,16 @@ func (d *DuckDBStorage) UpdateBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
 }
 
 func (d *DuckDBStorage) DeleteBMC(bmcID uuid.UUID) error {
-	_, err := d.db.Exec(`DELETE FROM bmcs WHERE id = ?`, bmcID)
-	return err
+	return d.withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM bmcs WHERE id = ?`, bmcID)
+		if err != nil {
+			return err
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+			return fmt.Errorf("%w: BMC %s", storage.ErrNotFound, bmcID)
+		}
+		return releaseKeys(tx, bmcID)
+	})
 }
 
 func (d *DuckDBStorage) LookupBMCByMACAddress(mac string) (nodes.BMC, error) {
@@ -95,33 +287,174 @@ func (d *DuckDBStorage) LookupBMCByMACAddress(mac string) (nodes.BMC, error) {
 	if err != nil {
 		return nodes.BMC{}, err
 	}
-	var bmc nodes.BMC
-	err = json.Unmarshal([]byte(data), &bmc)
-	return bmc, err
+	return d.decodeBMC(data)
 }
 
 func (d *DuckDBStorage) LookupBMCByXName(xname string) (nodes.BMC, error) {
 	var data string
-	err := d.db.QueryRow(`SELECT data FROM bmcs WHERE json_extract(data, '$.xname') = ?`, xname).Scan(&data)
+	err := d.db.QueryRow(`SELECT data FROM bmcs WHERE xname = ?`, xnames.NewBMCXname(xname).Normalize().String()).Scan(&data)
 	if err != nil {
 		return nodes.BMC{}, err
 	}
+	return d.decodeBMC(data)
+}
+
+// decodeNode unmarshals a stored node document and opens its passwords
+func (d *DuckDBStorage) decodeNode(data string) (nodes.ComputeNode, error) {
+	var node nodes.ComputeNode
+	if err := json.Unmarshal([]byte(data), &node); err != nil {
+		return node, err
+	}
+	return d.openNode(node)
+}
+
+// decodeBMC unmarshals a stored BMC document and opens its password
+func (d *DuckDBStorage) decodeBMC(data string) (nodes.BMC, error) {
 	var bmc nodes.BMC
-	err = json.Unmarshal([]byte(data), &bmc)
-	return bmc, err
+	if err := json.Unmarshal([]byte(data), &bmc); err != nil {
+		return bmc, err
+	}
+	return d.openBMC(bmc)
 }
 
+// The xname columns are kept unique by unique_keys rather than by UNIQUE constraints
+const (
+	computeNodesTable = `CREATE TABLE IF NOT EXISTS compute_nodes (id UUID PRIMARY KEY, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, xname TEXT, boot_mac TEXT UNIQUE, data JSON, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, hostname TEXT)`
+	bmcsTable         = `CREATE TABLE IF NOT EXISTS bmcs (id UUID PRIMARY KEY, xname TEXT, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, data JSON, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
+)
+
 func initNodeTables(db *sql.DB) error {
 	queries := []string{
-		`CREATE TABLE IF NOT EXISTS compute_nodes (id UUID PRIMARY KEY, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, xname TEXT UNIQUE, boot_mac TEXT UNIQUE, data JSON)`,
-		`CREATE TABLE IF NOT EXISTS bmcs (id UUID PRIMARY KEY, xname TEXT UNIQUE, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, data JSON)`,
+		computeNodesTable,
+		bmcsTable,
+		// Databases created before incremental snapshots lack updated_at,
+		`ALTER TABLE compute_nodes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP`,
+		`ALTER TABLE bmcs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP`,
+		// and the hostname column that LookupComputeNodeByHostname searches
+		`ALTER TABLE compute_nodes ADD COLUMN IF NOT EXISTS hostname TEXT`,
 		`CREATE TABLE IF NOT EXISTS collections (id UUID PRIMARY KEY, name TEXT UNIQUE, data JSON, nodes JSON)`,
-		`CREATE INDEX IF NOT EXISTS idx_collections_nodes ON collections (nodes)`,
+		// DuckDB won't update an indexed column, and FindCollectionsByNode can't use the
+		// index on nodes that older databases have anyway
+		`DROP INDEX IF EXISTS idx_collections_nodes`,
+		ethernetInterfacesTable,
+		nidsTable,
+		nodeNotesTable,
+		uniqueKeysTable,
 	}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
 			return err
 		}
 	}
+	return backfillHostnames(db)
+}
+
+// backfillHostnames fills in the hostname column of nodes saved before it existed
+func backfillHostnames(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, data FROM compute_nodes WHERE hostname IS NULL`)
+	if err != nil {
+		return err
+	}
+	hostnames := map[uuid.UUID]string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var data string
+		var node nodes.ComputeNode
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := json.Unmarshal([]byte(data), &node); err != nil {
+			rows.Close()
+			return err
+		}
+		hostnames[id] = node.Hostname
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, hostname := range hostnames {
+		if _, err := db.Exec(`UPDATE compute_nodes SET hostname = ? WHERE id = ?`, hostname, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeXNames rewrites the xnames of nodes and BMCs stored before xnames were normalized,
+// or restored from a snapshot taken then, in both the xname column and the data
+func normalizeXNames(tx *sql.Tx) error {
+	err := normalizeRows(tx, "compute_nodes", func(data string) (string, string, bool, error) {
+		var node nodes.ComputeNode
+		if err := json.Unmarshal([]byte(data), &node); err != nil {
+			return "", "", false, err
+		}
+		before := node.XName
+		var bmcBefore xnames.BMCXname
+		if node.BMC != nil {
+			bmcBefore = node.BMC.XName
+		}
+		node.NormalizeXNames()
+		if node.XName == before && (node.BMC == nil || node.BMC.XName == bmcBefore) {
+			return "", "", false, nil
+		}
+		normalized, err := json.Marshal(node)
+		return node.XName.String(), string(normalized), true, err
+	})
+	if err != nil {
+		return err
+	}
+	return normalizeRows(tx, "bmcs", func(data string) (string, string, bool, error) {
+		var bmc nodes.BMC
+		if err := json.Unmarshal([]byte(data), &bmc); err != nil {
+			return "", "", false, err
+		}
+		normalized := bmc.XName.Normalize()
+		if normalized == bmc.XName {
+			return "", "", false, nil
+		}
+		bmc.XName = normalized
+		encoded, err := json.Marshal(bmc)
+		return bmc.XName.String(), string(encoded), true, err
+	})
+}
+
+// normalizeRows passes the data of every row of table to normalize and stores the xname and
+// data it returns for the rows it reports changed
+func normalizeRows(tx *sql.Tx, table string, normalize func(data string) (xname string, normalized string, changed bool, err error)) error {
+	type row struct {
+		xname, data string
+	}
+	rows, err := tx.Query(`SELECT id, data FROM ` + table)
+	if err != nil {
+		return err
+	}
+	updates := map[uuid.UUID]row{}
+	for rows.Next() {
+		var id uuid.UUID
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		xname, normalized, changed, err := normalize(data)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if changed {
+			updates[id] = row{xname, normalized}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, r := range updates {
+		if _, err := tx.Exec(`UPDATE `+table+` SET xname = ?, data = ?, updated_at = now() WHERE id = ?`, nullableXName(r.xname), r.data, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,337 @@
+package duckdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestLookupComputeNodeByXName(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{
+		ID:           uuid.New(),
+		Hostname:     "nid000001",
+		XName:        xnames.NewNodeXname("x1000c0s0b0n0"),
+		Architecture: "x86_64",
+	}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	// A node without an xname must not collide with other nodes lacking one
+	unlocated := nodes.ComputeNode{ID: uuid.New(), Hostname: "unlocated", Architecture: "x86_64"}
+	if err := d.SaveComputeNode(unlocated.ID, unlocated); err != nil {
+		t.Fatalf("failed to save node without an xname: %v", err)
+	}
+
+	found, err := d.LookupComputeNodeByXName("x1000c0s0b0n0")
+	if err != nil {
+		t.Fatalf("failed to look up node by xname: %v", err)
+	}
+	if found.ID != node.ID || found.XName.String() != node.XName.String() {
+		t.Errorf("expected node %s with xname %s, got %s with xname %s", node.ID, node.XName, found.ID, found.XName)
+	}
+
+	// Moving the node must free its old xname
+	node.XName = xnames.NewNodeXname("x1000c0s0b0n1")
+	if err := d.UpdateComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+	if _, err := d.LookupComputeNodeByXName("x1000c0s0b0n0"); err == nil {
+		t.Errorf("expected no node at the old xname after relocation")
+	}
+	if found, err := d.LookupComputeNodeByXName("x1000c0s0b0n1"); err != nil || found.ID != node.ID {
+		t.Errorf("expected node %s at the new xname, got %v (%v)", node.ID, found.ID, err)
+	}
+}
+
+func TestPaddedXNamesResolveToOneNode(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x0001c0s0b0")}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x0001c0s0b0n0"), Architecture: "x86_64", BMC: &bmc}
+	if err := d.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	for _, xname := range []string{"x1c0s0b0n0", "x0001c0s0b0n0", "x001c0s00b0n0"} {
+		found, err := d.LookupComputeNodeByXName(xname)
+		if err != nil || found.ID != node.ID {
+			t.Errorf("expected node %s at %s, got %v (%v)", node.ID, xname, found.ID, err)
+		}
+		if found.XName.String() != "x001c0s0b0n0" || found.BMC.XName.String() != "x001c0s0b0" {
+			t.Errorf("expected xnames stored normalized, got %s and %s", found.XName, found.BMC.XName)
+		}
+	}
+	if found, err := d.LookupBMCByXName("x1c0s0b0"); err != nil || found.ID != bmc.ID {
+		t.Errorf("expected BMC %s at x1c0s0b0, got %v (%v)", bmc.ID, found.ID, err)
+	}
+
+	duplicate := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1c0s0b0n0"), Architecture: "x86_64"}
+	if err := d.SaveComputeNode(duplicate.ID, duplicate); err == nil {
+		t.Errorf("expected the unpadded xname to collide with the padded one")
+	}
+}
+
+func TestNormalizeStoredXNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unnormalized.db")
+	d, err := NewDuckDBStorage(path)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	// Rows as written before xnames were normalized
+	nodeID, bmcID := uuid.New(), uuid.New()
+	for _, query := range []string{
+		fmt.Sprintf(`INSERT INTO bmcs (id, xname, data) VALUES ('%s', 'x1c0s0b0', '{"id": "%s", "xname": "x1c0s0b0"}')`, bmcID, bmcID),
+		fmt.Sprintf(`INSERT INTO compute_nodes (id, xname, data, hostname) VALUES ('%s', 'x0001c0s0b0n0', '{"id": "%s", "xname": "x0001c0s0b0n0", "bmc": {"id": "%s", "xname": "x1c0s0b0"}}', '')`, nodeID, nodeID, bmcID),
+	} {
+		if _, err := d.db.Exec(query); err != nil {
+			t.Fatalf("failed to insert old rows: %v", err)
+		}
+	}
+	d.Close()
+
+	d, err = NewDuckDBStorage(path)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer d.Close()
+
+	found, err := d.LookupComputeNodeByXName("x1c0s0b0n0")
+	if err != nil || found.ID != nodeID {
+		t.Fatalf("expected node %s at x1c0s0b0n0, got %v (%v)", nodeID, found.ID, err)
+	}
+	if found.XName.String() != "x001c0s0b0n0" || found.BMC == nil || found.BMC.XName.String() != "x001c0s0b0" {
+		t.Errorf("expected the stored xnames normalized, got %+v", found)
+	}
+	if bmc, err := d.LookupBMCByXName("x001c0s0b0"); err != nil || bmc.ID != bmcID || bmc.XName.String() != "x001c0s0b0" {
+		t.Errorf("expected BMC %s normalized, got %+v (%v)", bmcID, bmc, err)
+	}
+	duplicate := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x001c0s0b0n0")}
+	if err := d.SaveComputeNode(duplicate.ID, duplicate); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected the normalized xname to be taken, got %v", err)
+	}
+}
+
+func TestLookupComputeNodeByNID(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	for _, nid := range []int{7, 17} {
+		node := nodes.ComputeNode{ID: uuid.New(), Hostname: fmt.Sprintf("nid%06d", nid), NID: nid, Architecture: "x86_64"}
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	found, err := d.LookupComputeNodeByNID(7)
+	if err != nil {
+		t.Fatalf("failed to look up node by NID: %v", err)
+	}
+	if found.Hostname != "nid000007" {
+		t.Errorf("expected nid000007, got %s", found.Hostname)
+	}
+	if _, err := d.LookupComputeNodeByNID(8); err == nil {
+		t.Errorf("expected no node for an unused NID")
+	}
+
+	matches, err := d.SearchComputeNodes(storage.WithNID(17))
+	if err != nil {
+		t.Fatalf("failed to search by NID: %v", err)
+	}
+	if len(matches) != 1 || matches[0].NID != 17 {
+		t.Errorf("expected only the node with NID 17, got %v", matches)
+	}
+}
+
+func TestLookupComputeNodesByMACAddresses(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	for i, mac := range []string{"DE:AD:BE:EF:00:01", "de:ad:be:ef:00:02", "de:ad:be:ef:00:03"} {
+		node := nodes.ComputeNode{ID: uuid.New(), Hostname: fmt.Sprintf("nid%03d", i+1), BootMac: mac, Architecture: "x86_64"}
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	found, err := d.LookupComputeNodesByMACAddresses([]string{"de:ad:be:ef:00:01", "DE:AD:BE:EF:00:02", "de:ad:be:ef:00:09"})
+	if err != nil {
+		t.Fatalf("failed to look up nodes by MAC: %v", err)
+	}
+	if len(found) != 2 || found["de:ad:be:ef:00:01"].Hostname != "nid001" || found["de:ad:be:ef:00:02"].Hostname != "nid002" {
+		t.Errorf("expected nid001 and nid002 by their lower case MACs, got %v", found)
+	}
+}
+
+func TestComputeNodeTimestamps(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid000001", XName: xnames.NewNodeXname("x1000c0s0b0n0"), Architecture: "x86_64"}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	saved, err := d.GetComputeNode(node.ID)
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if saved.CreatedAt.IsZero() || saved.UpdatedAt.IsZero() {
+		t.Fatalf("expected the save to fill in the timestamps, got %v and %v", saved.CreatedAt, saved.UpdatedAt)
+	}
+
+	// Neither an update that claims another creation time nor a relocation, which re-inserts
+	// the row, may change when the node was created
+	created := saved.CreatedAt
+	saved.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	saved.UpdatedAt = time.Time{}
+	saved.XName = xnames.NewNodeXname("x1000c0s0b0n1")
+	time.Sleep(2 * time.Millisecond)
+	if err := d.UpdateComputeNode(node.ID, saved); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+	updated, err := d.GetComputeNode(node.ID)
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if !updated.CreatedAt.Equal(created) || !updated.UpdatedAt.After(created) {
+		t.Errorf("expected creation at %v and a later update, got %v and %v", created, updated.CreatedAt, updated.UpdatedAt)
+	}
+}
+
+func TestMoveComputeNodeXName(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s0b0n0"), NID: 1}
+	occupant := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s1b0n0"), NID: 2}
+	for _, n := range []nodes.ComputeNode{node, occupant} {
+		if err := d.SaveComputeNode(n.ID, n); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	// Moving onto a taken xname fails without losing the node
+	moved := node
+	moved.XName = occupant.XName
+	if err := d.SaveComputeNode(node.ID, moved); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("expected a conflict moving onto %s, got %v", occupant.XName, err)
+	}
+	if found, err := d.GetComputeNode(node.ID); err != nil || found.XName.String() != "x1000c0s0b0n0" {
+		t.Fatalf("expected the node to stay at x1000c0s0b0n0, got %+v, %v", found, err)
+	}
+
+	// Moving onto a free xname releases the old one
+	moved.XName = xnames.NewNodeXname("x1000c0s2b0n0")
+	if err := d.SaveComputeNode(node.ID, moved); err != nil {
+		t.Fatalf("failed to move node: %v", err)
+	}
+	if found, err := d.LookupComputeNodeByXName("x1000c0s2b0n0"); err != nil || found.ID != node.ID {
+		t.Errorf("expected to find the node at its new xname, got %+v, %v", found, err)
+	}
+	occupant.XName = node.XName
+	if err := d.SaveComputeNode(occupant.ID, occupant); err != nil {
+		t.Errorf("expected the released xname to be free, got %v", err)
+	}
+}
+
+func TestUniqueHostname(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001"}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	other := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid001"}
+	if err := d.SaveComputeNode(other.ID, other); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected a conflict for a taken hostname, got %v", err)
+	}
+
+	// Any number of nodes may lack a hostname
+	for i := 0; i < 2; i++ {
+		id := uuid.New()
+		if err := d.SaveComputeNode(id, nodes.ComputeNode{ID: id}); err != nil {
+			t.Errorf("failed to save a node without a hostname: %v", err)
+		}
+	}
+
+	// Imports are held to the same rule
+	bundle := storage.Bundle{Version: storage.BundleVersion, ComputeNodes: []nodes.ComputeNode{other}}
+	if err := d.ImportBundle(bundle, storage.ConflictOverwrite); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected an import with a taken hostname to conflict, got %v", err)
+	}
+}
+
+func TestXNameConstraintMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// The tables as created before unique_keys
+	id := uuid.New()
+	for _, query := range []string{
+		`CREATE TABLE compute_nodes (id UUID PRIMARY KEY, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, xname TEXT UNIQUE, boot_mac TEXT UNIQUE, data JSON, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, hostname TEXT)`,
+		`CREATE TABLE bmcs (id UUID PRIMARY KEY, xname TEXT UNIQUE, added TIMESTAMP DEFAULT CURRENT_TIMESTAMP, data JSON, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		fmt.Sprintf(`INSERT INTO compute_nodes (id, xname, data, hostname) VALUES ('%s', 'x1000c0s0b0n0', '{"id": "%s", "xname": "x1000c0s0b0n0"}', '')`, id, id),
+	} {
+		if _, err := old.Exec(query); err != nil {
+			t.Fatalf("failed to set up old tables: %v", err)
+		}
+	}
+	old.Close()
+
+	d, err := NewDuckDBStorage(path)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	other := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s0b0n0")}
+	if err := d.SaveComputeNode(other.ID, other); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected the migrated xname to be taken, got %v", err)
+	}
+	node, err := d.GetComputeNode(id)
+	if err != nil {
+		t.Fatalf("failed to get migrated node: %v", err)
+	}
+	node.XName = xnames.NewNodeXname("x1000c0s1b0n0")
+	if err := d.SaveComputeNode(id, node); err != nil {
+		t.Errorf("failed to move migrated node: %v", err)
+	}
+}
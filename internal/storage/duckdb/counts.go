@@ -0,0 +1,78 @@
+package duckdb
+
+import "github.com/openchami/node-orchestrator/internal/api/smd"
+
+func (d *DuckDBStorage) CountComputeNodes() (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM compute_nodes`).Scan(&count)
+	return count, err
+}
+
+func (d *DuckDBStorage) CountBMCs() (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM bmcs`).Scan(&count)
+	return count, err
+}
+
+// CountComponentsByState groups the SMD components by state.  Components without a state are
+// counted under the empty string.
+func (d *DuckDBStorage) CountComponentsByState() (map[string]int, error) {
+	rows, err := d.db.Query(`SELECT COALESCE(state, ''), COUNT(*) FROM components GROUP BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
+
+// SummarizeComponents counts the components by state, role, arch and class in one pass over
+// the table.  Each grouping set yields rows where the other columns are aggregated away, and
+// GROUPING tells which set a row belongs to: bit 3 is state, bit 2 role, bit 1 arch and
+// bit 0 class, set when the column is not grouped.
+func (d *DuckDBStorage) SummarizeComponents() (smd.ComponentSummary, error) {
+	summary := smd.ComponentSummary{
+		State: map[string]int{},
+		Role:  map[string]int{},
+		Arch:  map[string]int{},
+		Class: map[string]int{},
+	}
+	rows, err := d.db.Query(`SELECT GROUPING(state, role, arch, class),
+		COALESCE(state, ''), COALESCE(role, ''), COALESCE(arch, ''), COALESCE(class, ''), COUNT(*)
+		FROM components
+		GROUP BY GROUPING SETS ((state), (role), (arch), (class), ())`)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var grouping, count int
+		var state, role, arch, class string
+		if err := rows.Scan(&grouping, &state, &role, &arch, &class, &count); err != nil {
+			return summary, err
+		}
+		switch grouping {
+		case 0b0111:
+			summary.State[state] = count
+		case 0b1011:
+			summary.Role[role] = count
+		case 0b1101:
+			summary.Arch[arch] = count
+		case 0b1110:
+			summary.Class[class] = count
+		case 0b1111:
+			summary.Total = count
+		}
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,101 @@
+package duckdb
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// The ethernet_interfaces table is derived from the network interfaces in the node documents
+// so that they can be served SMD style.  It has no unique index because its rows are
+// replaced with a delete and insert inside one transaction, which DuckDB's indexes don't allow.
+const ethernetInterfacesTable = `CREATE TABLE IF NOT EXISTS ethernet_interfaces (
+	id TEXT,
+	node_id UUID,
+	component_id TEXT,
+	mac_address TEXT,
+	ipv4_address TEXT,
+	ipv6_address TEXT,
+	description TEXT,
+	last_update TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// replaceEthernetInterfaces rewrites the interfaces derived from the node with nodeID.
+// Interfaces without a MAC address have no SMD ID and are left out.
+func replaceEthernetInterfaces(tx *sql.Tx, nodeID uuid.UUID, node nodes.ComputeNode) error {
+	if _, err := tx.Exec(`DELETE FROM ethernet_interfaces WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+	for _, intf := range node.NetworkInterfaces {
+		if intf.MACAddress == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO ethernet_interfaces (id, node_id, component_id, mac_address, ipv4_address, ipv6_address, description) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			smd.EthernetInterfaceID(intf.MACAddress), nodeID, node.XName.String(), intf.MACAddress, intf.IPv4Address, intf.IPv6Address, intf.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DuckDBStorage) GetEthernetInterfaces(filter smd.EthernetInterfaceFilter) ([]smd.CompEthInterface, error) {
+	var where []string
+	var args []interface{}
+	if filter.ComponentID != "" {
+		where = append(where, "component_id = ?")
+		args = append(args, filter.ComponentID)
+	}
+	if filter.MACAddress != "" {
+		where = append(where, "id = ?")
+		args = append(args, smd.EthernetInterfaceID(filter.MACAddress))
+	}
+
+	query := `SELECT id, component_id, mac_address, ipv4_address, ipv6_address, description, last_update FROM ethernet_interfaces`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY component_id, id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interfaces []smd.CompEthInterface
+	for rows.Next() {
+		intf, err := scanEthernetInterface(rows)
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, intf)
+	}
+	return interfaces, rows.Err()
+}
+
+func (d *DuckDBStorage) GetEthernetInterfaceByID(id string) (smd.CompEthInterface, error) {
+	row := d.db.QueryRow(`SELECT id, component_id, mac_address, ipv4_address, ipv6_address, description, last_update FROM ethernet_interfaces WHERE id = ?`, smd.EthernetInterfaceID(id))
+	return scanEthernetInterface(row)
+}
+
+func scanEthernetInterface(row interface{ Scan(...interface{}) error }) (smd.CompEthInterface, error) {
+	var intf smd.CompEthInterface
+	var ipv4, ipv6 string
+	var lastUpdate time.Time
+	if err := row.Scan(&intf.ID, &intf.CompID, &intf.MACAddr, &ipv4, &ipv6, &intf.Desc, &lastUpdate); err != nil {
+		return intf, err
+	}
+	intf.Type = "Node"
+	intf.LastUpdate = lastUpdate.UTC().Format(time.RFC3339)
+	intf.IPAddrs = []smd.IPAddressMapping{}
+	for _, ip := range []string{ipv4, ipv6} {
+		if ip != "" {
+			intf.IPAddrs = append(intf.IPAddrs, smd.IPAddressMapping{IPAddr: ip})
+		}
+	}
+	return intf, nil
+}
@@ -0,0 +1,67 @@
+package duckdb
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestEthernetInterfacesFollowNodes(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	node := nodes.ComputeNode{
+		ID:    uuid.New(),
+		XName: xnames.NewNodeXname("x3000c0s1b0n0"),
+		NetworkInterfaces: []nodes.NetworkInterface{
+			{InterfaceName: "eth0", MACAddress: "AA:BB:CC:00:00:01", IPv4Address: "10.0.0.1", IPv6Address: "fd00::1"},
+			{InterfaceName: "eth1"},
+		},
+	}
+	if err := storage.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	interfaces, err := storage.GetEthernetInterfaces(smd.EthernetInterfaceFilter{ComponentID: "x3000c0s1b0n0"})
+	if err != nil {
+		t.Fatalf("failed to list interfaces: %v", err)
+	}
+	if len(interfaces) != 1 {
+		t.Fatalf("expected 1 interface, got %d", len(interfaces))
+	}
+	intf := interfaces[0]
+	if intf.ID != "aabbcc000001" || intf.CompID != "x3000c0s1b0n0" || len(intf.IPAddrs) != 2 {
+		t.Errorf("unexpected interface %+v", intf)
+	}
+
+	if _, err := storage.GetEthernetInterfaceByID("aa:bb:cc:00:00:01"); err != nil {
+		t.Errorf("expected lookup by MAC formatted ID to succeed: %v", err)
+	}
+
+	node.NetworkInterfaces = []nodes.NetworkInterface{{InterfaceName: "eth0", MACAddress: "aa:bb:cc:00:00:02"}}
+	if err := storage.UpdateComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+	if _, err := storage.GetEthernetInterfaceByID("aabbcc000001"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the removed interface to be gone, got %v", err)
+	}
+	interfaces, err = storage.GetEthernetInterfaces(smd.EthernetInterfaceFilter{MACAddress: "aa:bb:cc:00:00:02"})
+	if err != nil || len(interfaces) != 1 {
+		t.Errorf("expected the new interface to be listed, got %v, %v", interfaces, err)
+	}
+
+	if err := storage.DeleteComputeNode(node.ID); err != nil {
+		t.Fatalf("failed to delete node: %v", err)
+	}
+	interfaces, err = storage.GetEthernetInterfaces(smd.EthernetInterfaceFilter{})
+	if err != nil || len(interfaces) != 0 {
+		t.Errorf("expected no interfaces after deleting the node, got %v, %v", interfaces, err)
+	}
+}
@@ -0,0 +1,201 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Incremental snapshots are kept under the full snapshot they build on:
+//
+//	<snapshot path>/2024-06-01T12-00-00/                                      full EXPORT DATABASE
+//	<snapshot path>/2024-06-01T12-00-00/incremental/0001-2024-06-01T13-00-00/
+//	<snapshot path>/2024-06-01T12-00-00/incremental/0002-2024-06-01T14-00-00/
+//
+// Tables with an id and an updated_at column only have the rows changed since the previous
+// snapshot written to <table>.parquet, next to every id the table still holds in
+// <table>.ids.parquet so that a restore can drop deleted rows.  Other tables are written
+// out whole and replace the table on restore.
+const incrementalSnapshotDir = "incremental"
+
+// snapshotOverlap is how far before the last snapshot an incremental snapshot looks for
+// changed rows.  It has to outlast the longest write transaction.
+const snapshotOverlap = time.Minute
+
+// snapshotTable is a table as seen by incremental snapshots
+type snapshotTable struct {
+	name        string
+	incremental bool // has the id and updated_at columns needed to export only changed rows
+}
+
+// periodicSnapshot takes an incremental snapshot on top of the last full one, or a full
+// snapshot when there is none yet or fullSnapshotEvery says it is time for another.
+func (d *DuckDBStorage) periodicSnapshot(ctx context.Context) (string, error) {
+	if !d.snapshotMu.TryLock() {
+		return "", ErrSnapshotInProgress
+	}
+	defer d.snapshotMu.Unlock()
+
+	if d.fullSnapshotDir == "" || d.incrementalsSinceFull+1 >= d.fullSnapshotEvery {
+		return d.exportDatabase(ctx, d.snapshotPath)
+	}
+	return d.exportIncremental(ctx)
+}
+
+// exportIncremental writes the changes since the last snapshot.  The caller holds snapshotMu.
+func (d *DuckDBStorage) exportIncremental(ctx context.Context) (string, error) {
+	started, err := d.snapshotClock(ctx)
+	if err != nil {
+		return "", err
+	}
+	tables, err := d.snapshotTables(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%04d-%s", d.incrementalsSinceFull+1, time.Now().Format(snapshotDirFormat))
+	dir := filepath.Join(d.fullSnapshotDir, incrementalSnapshotDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// updated_at is the start of the transaction that wrote a row, so a write that started
+	// before the last snapshot and committed after it is only caught by looking further back.
+	// Rows exported twice are replaced by the same rows on restore.
+	since := d.lastSnapshotAt.Add(-snapshotOverlap).Format("2006-01-02 15:04:05.999999")
+	for _, table := range tables {
+		file := sqlString(filepath.Join(dir, table.name+".parquet"))
+		query := fmt.Sprintf(`COPY %s TO %s (%s)`, sqlIdentifier(table.name), file, d.parquetFormat())
+		if table.incremental {
+			query = fmt.Sprintf(`COPY (SELECT * FROM %s WHERE updated_at >= TIMESTAMP %s) TO %s (%s);
+			COPY (SELECT id FROM %s) TO %s (%s)`,
+				sqlIdentifier(table.name), sqlString(since), file, d.parquetFormat(),
+				sqlIdentifier(table.name), sqlString(filepath.Join(dir, table.name+".ids.parquet")), d.parquetFormat())
+		}
+		if _, err := d.db.ExecContext(ctx, query); err != nil {
+			d.logger.Error().Err(err).Str("table", table.name).Msg("Error writing incremental snapshot")
+			return "", err
+		}
+	}
+	d.logger.Info().
+		Str("path", dir).
+		Str("since", since).
+		Msg("Incremental snapshot")
+
+	d.lastSnapshotAt = started
+	d.incrementalsSinceFull++
+	return dir, nil
+}
+
+// snapshotClock reads the database clock in the form updated_at is stored in, so that
+// comparisons against it don't depend on the time zone of the process
+func (d *DuckDBStorage) snapshotClock(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	err := d.db.QueryRowContext(ctx, `SELECT CURRENT_TIMESTAMP::TIMESTAMP`).Scan(&now)
+	return now, err
+}
+
+func (d *DuckDBStorage) snapshotTables(ctx context.Context) ([]snapshotTable, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT table_name, bool_or(column_name = 'id') AND bool_or(column_name = 'updated_at')
+		FROM information_schema.columns
+		WHERE table_schema = 'main'
+		GROUP BY table_name
+		ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []snapshotTable
+	for rows.Next() {
+		var table snapshotTable
+		if err := rows.Scan(&table.name, &table.incremental); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// applyIncrementalSnapshots replays the incremental snapshots under the full snapshot in
+// path, oldest first
+func (d *DuckDBStorage) applyIncrementalSnapshots(path string) error {
+	entries, err := os.ReadDir(filepath.Join(path, incrementalSnapshotDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// ReadDir sorts by name, and the names start with the sequence number
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(path, incrementalSnapshotDir, entry.Name())
+		if err := d.applyIncrementalSnapshot(dir); err != nil {
+			return fmt.Errorf("error applying incremental snapshot %s: %w", dir, err)
+		}
+		d.logger.Info().Str("path", dir).Msg("Applied incremental snapshot")
+	}
+	return nil
+}
+
+// applyIncrementalSnapshot replays one incremental snapshot in a single transaction, so that a
+// failure leaves the tables as the previous snapshot left them.  Each table is rebuilt rather
+// than updated in place, since DuckDB cannot re-insert a unique key deleted earlier in the
+// same transaction.
+func (d *DuckDBStorage) applyIncrementalSnapshot(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.parquet"))
+	if err != nil {
+		return err
+	}
+	return d.withTx(func(tx *sql.Tx) error {
+		for _, file := range files {
+			if strings.HasSuffix(file, ".ids.parquet") {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(file), ".parquet")
+			table, old := sqlIdentifier(name), sqlIdentifier(name+"_old")
+			ids := strings.TrimSuffix(file, ".parquet") + ".ids.parquet"
+
+			var create string
+			if err := tx.QueryRow(`SELECT sql FROM duckdb_tables() WHERE schema_name = 'main' AND table_name = ?`, name).Scan(&create); err != nil {
+				return fmt.Errorf("error reading the schema of %s: %w", name, err)
+			}
+
+			changed := fmt.Sprintf(`INSERT INTO %s BY NAME SELECT * FROM read_parquet(%s)`, table, sqlString(file))
+			if _, err := os.Stat(ids); err != nil {
+				// Written out whole
+				if err := rebuildTable(tx, name, create, changed); err != nil {
+					return err
+				}
+				continue
+			}
+			// Rows still present that didn't change are kept, and the changed ones replace theirs
+			kept := fmt.Sprintf(`INSERT INTO %s BY NAME SELECT * FROM %s
+				WHERE id IN (SELECT id FROM read_parquet(%s)) AND id NOT IN (SELECT id FROM read_parquet(%s))`,
+				table, old, sqlString(ids), sqlString(file))
+			if err := rebuildTable(tx, name, create, kept, changed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sqlString quotes s as a SQL string literal
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlIdentifier quotes s as a SQL identifier
+func sqlIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
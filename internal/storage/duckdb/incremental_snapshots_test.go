@@ -0,0 +1,150 @@
+package duckdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestIncrementalSnapshotRestore(t *testing.T) {
+	path := t.TempDir()
+	d, err := NewDuckDBStorage("", WithSnapshotPath(path), WithFullSnapshotEvery(3))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	ctx := context.Background()
+
+	save := func(id uuid.UUID, xname, hostname string) {
+		t.Helper()
+		node := nodes.ComputeNode{ID: id, Hostname: hostname, Architecture: nodes.ArchX86_64, XName: xnames.NewNodeXname(xname)}
+		if err := d.SaveComputeNode(id, node); err != nil {
+			t.Fatalf("failed to save %s: %v", xname, err)
+		}
+	}
+	kept, updated, deleted, added := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	save(kept, "x1000c0s0b0n0", "kept")
+	save(updated, "x1000c0s1b0n0", "before")
+	save(deleted, "x1000c0s2b0n0", "deleted")
+
+	full, err := d.periodicSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take full snapshot: %v", err)
+	}
+	if strings.Contains(full, incrementalSnapshotDir) {
+		t.Fatalf("expected the first snapshot to be a full one, got %s", full)
+	}
+
+	save(updated, "x1000c0s1b0n0", "after")
+	if err := d.DeleteComputeNode(deleted); err != nil {
+		t.Fatalf("failed to delete node: %v", err)
+	}
+	first, err := d.periodicSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take incremental snapshot: %v", err)
+	}
+	save(added, "x1000c0s3b0n0", "added")
+	second, err := d.periodicSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take incremental snapshot: %v", err)
+	}
+	for i, dir := range []string{first, second} {
+		if filepath.Dir(filepath.Dir(dir)) != full || !strings.HasPrefix(filepath.Base(dir), []string{"0001-", "0002-"}[i]) {
+			t.Errorf("unexpected incremental snapshot directory %s", dir)
+		}
+	}
+
+	restored, err := NewDuckDBStorage("", WithRestore(path))
+	if err != nil {
+		t.Fatalf("failed to restore storage: %v", err)
+	}
+	defer restored.Close()
+
+	for id, hostname := range map[uuid.UUID]string{kept: "kept", updated: "after", added: "added"} {
+		node, err := restored.GetComputeNode(id)
+		if err != nil {
+			t.Errorf("expected node %s to be restored: %v", hostname, err)
+		} else if node.Hostname != hostname {
+			t.Errorf("restored node has hostname %q, want %q", node.Hostname, hostname)
+		}
+	}
+	if _, err := restored.GetComputeNode(deleted); err == nil {
+		t.Error("expected the deleted node to stay deleted after restore")
+	}
+}
+
+func TestFullSnapshotEvery(t *testing.T) {
+	d, err := NewDuckDBStorage("", WithSnapshotPath(t.TempDir()), WithFullSnapshotEvery(2))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	var kinds []bool
+	for i := 0; i < 3; i++ {
+		dir, err := d.periodicSnapshot(context.Background())
+		if err != nil {
+			t.Fatalf("snapshot %d failed: %v", i, err)
+		}
+		kinds = append(kinds, strings.Contains(dir, incrementalSnapshotDir))
+	}
+	if kinds[0] || !kinds[1] || kinds[2] {
+		t.Errorf("expected full, incremental, full snapshots, got incremental=%v", kinds)
+	}
+}
+
+// An incremental snapshot that fails part way through leaves none of its tables applied
+func TestIncrementalSnapshotAppliesAtomically(t *testing.T) {
+	path := t.TempDir()
+	d, err := NewDuckDBStorage("", WithSnapshotPath(path), WithFullSnapshotEvery(3))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	ctx := context.Background()
+
+	id := uuid.New()
+	save := func(hostname string) {
+		t.Helper()
+		node := nodes.ComputeNode{ID: id, Hostname: hostname, Architecture: nodes.ArchX86_64, XName: xnames.NewNodeXname("x1000c0s0b0n0")}
+		if err := d.SaveComputeNode(id, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+	save("before")
+	if _, err := d.periodicSnapshot(ctx); err != nil {
+		t.Fatalf("failed to take full snapshot: %v", err)
+	}
+	save("after")
+	incremental, err := d.periodicSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take incremental snapshot: %v", err)
+	}
+	// unique_keys is applied after compute_nodes
+	if err := os.WriteFile(filepath.Join(incremental, "unique_keys.parquet"), []byte("not parquet"), 0644); err != nil {
+		t.Fatalf("failed to corrupt snapshot: %v", err)
+	}
+
+	// Restore the full snapshot alone and apply the incremental on top of it
+	moved := filepath.Join(t.TempDir(), "incremental")
+	if err := os.Rename(incremental, moved); err != nil {
+		t.Fatalf("failed to move incremental snapshot: %v", err)
+	}
+	restored, err := NewDuckDBStorage("", WithRestore(path))
+	if err != nil {
+		t.Fatalf("failed to restore storage: %v", err)
+	}
+	defer restored.Close()
+	if err := restored.applyIncrementalSnapshot(moved); err == nil {
+		t.Fatal("expected the corrupt incremental snapshot to fail")
+	}
+	if node, err := restored.GetComputeNode(id); err != nil || node.Hostname != "before" {
+		t.Errorf("expected the node of the full snapshot, got %q, %v", node.Hostname, err)
+	}
+}
@@ -3,11 +3,17 @@ package duckdb
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
-	_ "github.com/marcboeker/go-duckdb"
+	goduckdb "github.com/marcboeker/go-duckdb"
+	"github.com/openchami/node-orchestrator/internal/secrets"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,30 +24,76 @@ type DuckDBStorage struct {
 	restoreFirst      bool
 	wg                sync.WaitGroup
 	cancelSnapshot    context.CancelFunc
+	snapshotRunning   bool       // set once snapshotRoutine has been started
+	snapshotMu        sync.Mutex // held while a snapshot is being written
+	fullSnapshotEvery int        // every fullSnapshotEvery-th periodic snapshot is a full one
+	snapshotRetention int        // full snapshots kept under a snapshot path, zero keeps all
 	collectionManager *nodes.CollectionManager
+	cipher            *secrets.Cipher // nil stores passwords in plaintext
+	nidMu             sync.Mutex      // serializes AllocateNID
+	logger            zerolog.Logger
+	// Statements taking longer are logged, zero logs none.  Set before the database is used.
+	slowQueryThreshold time.Duration
+	// Parquet codec snapshot files are written with, empty for DuckDB's default
+	snapshotCompression string
+
+	// The last snapshot, guarded by snapshotMu
+	fullSnapshotDir       string    // base of the incremental snapshots, empty until a full snapshot is taken
+	lastSnapshotAt        time.Time // database clock when the last snapshot started
+	incrementalsSinceFull int
 }
 
+// MemoryPath keeps the database in memory only, as does an empty path
+const MemoryPath = ":memory:"
+
 func NewDuckDBStorage(path string, options ...DuckDBStorageOption) (*DuckDBStorage, error) {
-	db, err := sql.Open("duckdb", path)
+	if path == MemoryPath {
+		path = ""
+	}
+	// DuckDB creates the database file but not the directory holding it
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("error creating the directory of database %s: %w", path, err)
+		}
+	}
+	d := &DuckDBStorage{
+		collectionManager:  nodes.NewCollectionManager(),
+		cancelSnapshot:     func() {},
+		logger:             log.Logger,
+		slowQueryThreshold: DefaultSlowQueryThreshold,
+	}
+	connector, err := goduckdb.NewConnector(path, nil)
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(timedConnector{Connector: connector, d: d})
+	db.SetMaxOpenConns(DefaultMaxOpenConns)
+	db.SetMaxIdleConns(DefaultMaxIdleConns)
+	db.SetConnMaxLifetime(DefaultConnMaxLifetime)
+	d.db = db
 
-	d := &DuckDBStorage{
-		db:                db,
-		collectionManager: nodes.NewCollectionManager(),
-		cancelSnapshot:    func() {},
+	// The logger goes first so that the other options already log through it
+	for _, option := range options {
+		if logger, ok := option.(loggerOption); ok {
+			logger.apply(d)
+		}
 	}
-
 	for _, option := range options {
 		err := option.apply(d)
+		if errors.Is(err, ErrInvalidOption) {
+			db.Close()
+			return nil, err
+		}
 		if err != nil {
-			log.Warn().Err(err).Msg("Error applying DuckDBStorage option")
+			d.logger.Warn().Err(err).Msg("Error applying DuckDBStorage option")
 		}
 	}
 
 	d.loadExtensions()
-	d.initTables()
+	if err := d.initTables(); err != nil {
+		d.logger.Error().Err(err).Msg("Error initializing tables")
+	}
+	d.startSnapshotRoutine()
 
 	return d, nil
 }
@@ -56,7 +108,23 @@ func (d *DuckDBStorage) initTables() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return d.initUniqueKeys()
+}
+
+// withTx runs fn inside a transaction.  The transaction is committed if fn returns nil and
+// rolled back otherwise, in which case fn's error is returned.
+func (d *DuckDBStorage) withTx(fn func(*sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			d.logger.Error().Err(rollbackErr).Msg("Error rolling back transaction")
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
 func (d *DuckDBStorage) Close() error {
@@ -73,19 +141,21 @@ func (d *DuckDBStorage) initializeDatabase() error {
 func (d *DuckDBStorage) loadExtensions() error {
 	_, err := d.db.Exec("SET autoinstall_known_extensions=1;INSTALL json;LOAD json;INSTALL parquet;LOAD parquet")
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to load DuckDB extensions")
+		d.logger.Error().Err(err).Msg("Failed to load DuckDB extensions")
 	}
 	return err
 }
 
 // Shutdown initiates the shutdown process
 func (d *DuckDBStorage) Shutdown(ctx context.Context) {
-	log.Info().Msg("Taking final snapshot before shutdown")
-	if err := d.SnapshotParquet(ctx, d.snapshotPath); err != nil {
-		log.Error().Err(err).Msg("Error taking final snapshot")
+	if d.snapshotPath != "" {
+		d.logger.Info().Msg("Taking final snapshot before shutdown")
+		if _, err := d.periodicSnapshot(ctx); err != nil {
+			d.logger.Error().Err(err).Msg("Error taking final snapshot")
+		}
 	}
 
-	log.Info().Msg("Stopping snapshot routine")
+	d.logger.Info().Msg("Stopping snapshot routine")
 	d.cancelSnapshot()
 
 	done := make(chan struct{})
@@ -96,15 +166,15 @@ func (d *DuckDBStorage) Shutdown(ctx context.Context) {
 
 	select {
 	case <-done:
-		log.Info().Msg("All goroutines finished cleanly")
+		d.logger.Info().Msg("All goroutines finished cleanly")
 	case <-ctx.Done():
-		log.Warn().Msg("Timeout waiting for goroutines to finish")
+		d.logger.Warn().Msg("Timeout waiting for goroutines to finish")
 	}
 
-	log.Info().Msg("Closing database connection")
+	d.logger.Info().Msg("Closing database connection")
 	if err := d.Close(); err != nil {
-		log.Error().Err(err).Msg("Error closing database connection")
+		d.logger.Error().Err(err).Msg("Error closing database connection")
 	}
 
-	log.Info().Msg("DuckDB Shutdown complete")
+	d.logger.Info().Msg("DuckDB Shutdown complete")
 }
@@ -0,0 +1,46 @@
+package duckdb
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// The nids table records every NID handed out by AllocateNID or saved with a node, so that a
+// NID allocated for a node that hasn't been saved yet is not handed out twice.  NIDs are not
+// released when their node is deleted.
+const nidsTable = `CREATE TABLE IF NOT EXISTS nids (nid INTEGER PRIMARY KEY)`
+
+// AllocateNID reserves and returns the next unused NID, one more than the highest NID seen so far.
+func (d *DuckDBStorage) AllocateNID() (int, error) {
+	d.nidMu.Lock()
+	defer d.nidMu.Unlock()
+
+	var nid int
+	err := d.withTx(func(tx *sql.Tx) error {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(nid), 0) + 1 FROM nids`).Scan(&nid); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO nids (nid) VALUES (?)`, nid)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return nid, nil
+}
+
+// reserveNID makes nid the NID of the node with id and records it, since it may have been
+// supplied with the node rather than allocated.  A NID held by another node is a conflict.
+// A NID that was allocated but not saved with any node yet is free to take.
+func reserveNID(tx *sql.Tx, id uuid.UUID, nid int) error {
+	if nid <= 0 {
+		return claimKey(tx, nodeNIDKey, id, "")
+	}
+	if err := claimKey(tx, nodeNIDKey, id, strconv.Itoa(nid)); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO nids (nid) VALUES (?) ON CONFLICT DO NOTHING`, nid)
+	return err
+}
@@ -0,0 +1,106 @@
+package duckdb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+func TestAllocateNIDConcurrent(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("Failed to create DuckDBStorage: %v", err)
+	}
+	defer d.Close()
+
+	const allocations = 50
+	var wg sync.WaitGroup
+	results := make(chan int, allocations)
+	for i := 0; i < allocations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nid, err := d.AllocateNID()
+			if err != nil {
+				t.Errorf("AllocateNID failed: %v", err)
+				return
+			}
+			results <- nid
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[int]bool)
+	for nid := range results {
+		if seen[nid] {
+			t.Errorf("NID %d was allocated twice", nid)
+		}
+		seen[nid] = true
+	}
+	for nid := 1; nid <= allocations; nid++ {
+		if !seen[nid] {
+			t.Errorf("expected NID %d to be allocated", nid)
+		}
+	}
+}
+
+func TestAllocateNIDSkipsSavedNIDs(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("Failed to create DuckDBStorage: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.SaveComputeNode(uuid.New(), nodes.ComputeNode{Hostname: "node10", NID: 10}); err != nil {
+		t.Fatalf("SaveComputeNode failed: %v", err)
+	}
+	nid, err := d.AllocateNID()
+	if err != nil {
+		t.Fatalf("AllocateNID failed: %v", err)
+	}
+	if nid != 11 {
+		t.Errorf("expected NID 11 after a node saved with NID 10, got %d", nid)
+	}
+}
+
+func TestSaveDuplicateNID(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("Failed to create DuckDBStorage: %v", err)
+	}
+	defer d.Close()
+
+	first := uuid.New()
+	if err := d.SaveComputeNode(first, nodes.ComputeNode{Hostname: "node10", NID: 10}); err != nil {
+		t.Fatalf("SaveComputeNode failed: %v", err)
+	}
+	// Saving a node again keeps its own NID
+	if err := d.SaveComputeNode(first, nodes.ComputeNode{Hostname: "node10", NID: 10}); err != nil {
+		t.Errorf("expected a node to be saved again with its NID, got %v", err)
+	}
+	if err := d.SaveComputeNode(uuid.New(), nodes.ComputeNode{Hostname: "other", NID: 10}); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected a conflict for a NID held by another node, got %v", err)
+	}
+
+	// An allocated NID nobody has saved yet is free to take
+	nid, err := d.AllocateNID()
+	if err != nil {
+		t.Fatalf("AllocateNID failed: %v", err)
+	}
+	if err := d.SaveComputeNode(uuid.New(), nodes.ComputeNode{Hostname: "allocated", NID: nid}); err != nil {
+		t.Errorf("expected an allocated NID to be saved, got %v", err)
+	}
+
+	// A node that changes NID gives up the old one
+	if err := d.SaveComputeNode(first, nodes.ComputeNode{Hostname: "node10", NID: 20}); err != nil {
+		t.Fatalf("SaveComputeNode failed: %v", err)
+	}
+	if err := d.SaveComputeNode(uuid.New(), nodes.ComputeNode{Hostname: "reuse", NID: 10}); err != nil {
+		t.Errorf("expected a released NID to be free, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package duckdb
+
+import (
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// The node_notes table holds the notes left on nodes.  It isn't tied to compute_nodes, so the
+// notes on a node outlive its document.  The id and updated_at columns are only there for
+// incremental snapshots; notes are never changed once added.
+const nodeNotesTable = `CREATE TABLE IF NOT EXISTS node_notes (
+	id UUID PRIMARY KEY,
+	node_id UUID,
+	author TEXT,
+	text TEXT,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// AddNodeNote appends a note to those of the node with nodeID
+func (d *DuckDBStorage) AddNodeNote(nodeID uuid.UUID, note nodes.Note) error {
+	_, err := d.db.Exec(`INSERT INTO node_notes (id, node_id, author, text, created_at) VALUES (?, ?, ?, ?, ?)`,
+		uuid.New(), nodeID, note.Author, note.Text, note.Timestamp)
+	return err
+}
+
+// GetNodeNotes returns the notes of the node with nodeID, oldest first
+func (d *DuckDBStorage) GetNodeNotes(nodeID uuid.UUID) ([]nodes.Note, error) {
+	rows, err := d.db.Query(`SELECT author, text, created_at FROM node_notes WHERE node_id = ? ORDER BY created_at, rowid`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []nodes.Note
+	for rows.Next() {
+		var note nodes.Note
+		if err := rows.Scan(&note.Author, &note.Text, &note.Timestamp); err != nil {
+			return nil, err
+		}
+		note.Timestamp = nodes.Timestamp(note.Timestamp)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
@@ -1,10 +1,28 @@
 package duckdb
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/openchami/node-orchestrator/internal/secrets"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/rs/zerolog"
 )
 
+// MinSnapshotFrequency is the shortest snapshot interval accepted without forcing it.
+// Snapshots are full EXPORT DATABASEs unless WithFullSnapshotEvery makes the ones in between
+// incremental.  Those write only the changed rows, but still every id of each table and the
+// tables without an updated_at in full, so anything shorter mostly generates I/O.
+const MinSnapshotFrequency = time.Minute
+
+// ErrInvalidOption is wrapped by option errors that should stop the storage from starting
+// rather than just being logged.
+var ErrInvalidOption = errors.New("invalid DuckDBStorage option")
+
 type DuckDBStorageOption interface {
 	apply(*DuckDBStorage) error
 }
@@ -12,38 +30,123 @@ type DuckDBStorageOption interface {
 // snapshotFrequencyOption is an option to set the frequency of snapshots.
 // when enabled, the storage will take a snapshot of the database every
 // snapshotFrequency duration.  The snapshot logic is handled in a separate
-// goroutine.
-type snapshotFrequencyOption time.Duration
+// goroutine.  A frequency of zero disables snapshots, negative frequencies are
+// rejected and so are frequencies below MinSnapshotFrequency unless forced.
+type snapshotFrequencyOption struct {
+	frequency time.Duration
+	force     bool
+}
 
 func (s snapshotFrequencyOption) apply(d *DuckDBStorage) error {
-	d.snapshotFrequency = time.Duration(s)
+	if s.frequency < 0 {
+		return fmt.Errorf("%w: snapshot frequency %s is negative", ErrInvalidOption, s.frequency)
+	}
+	if s.frequency > 0 && s.frequency < MinSnapshotFrequency && !s.force {
+		return fmt.Errorf("%w: snapshot frequency %s is below the minimum of %s", ErrInvalidOption, s.frequency, MinSnapshotFrequency)
+	}
+	d.snapshotFrequency = s.frequency
 	return nil
 }
 
 func WithSnapshotFrequency(frequency time.Duration) DuckDBStorageOption {
-	return snapshotFrequencyOption(frequency)
+	return snapshotFrequencyOption{frequency: frequency}
+}
+
+// WithForcedSnapshotFrequency is like WithSnapshotFrequency but skips the
+// MinSnapshotFrequency check.
+func WithForcedSnapshotFrequency(frequency time.Duration) DuckDBStorageOption {
+	return snapshotFrequencyOption{frequency: frequency, force: true}
+}
+
+// fullSnapshotEveryOption makes every n-th periodic snapshot a full EXPORT DATABASE and the
+// ones in between incremental snapshots holding only what changed since the snapshot before.
+// Zero and one take only full snapshots.
+type fullSnapshotEveryOption int
+
+func (f fullSnapshotEveryOption) apply(d *DuckDBStorage) error {
+	if f < 0 {
+		return fmt.Errorf("%w: full snapshot interval %d is negative", ErrInvalidOption, int(f))
+	}
+	d.fullSnapshotEvery = int(f)
+	return nil
+}
+
+func WithFullSnapshotEvery(n int) DuckDBStorageOption {
+	return fullSnapshotEveryOption(n)
+}
+
+// snapshotRetentionOption keeps only the n most recent snapshot directories, each a full
+// snapshot with its incrementals, pruning the older ones after every full snapshot.  Zero
+// keeps them all.
+type snapshotRetentionOption int
+
+func (s snapshotRetentionOption) apply(d *DuckDBStorage) error {
+	if s < 0 {
+		return fmt.Errorf("%w: snapshot retention %d is negative", ErrInvalidOption, int(s))
+	}
+	d.snapshotRetention = int(s)
+	return nil
+}
+
+func WithSnapshotRetention(n int) DuckDBStorageOption {
+	return snapshotRetentionOption(n)
+}
+
+// ParquetCodecs are the compression codecs WithSnapshotCompression accepts
+var ParquetCodecs = []string{"snappy", "zstd", "gzip", "uncompressed"}
+
+// snapshotCompressionOption sets the codec Parquet snapshot files are compressed with.  An
+// empty codec leaves DuckDB's default, snappy.
+type snapshotCompressionOption string
+
+func (s snapshotCompressionOption) apply(d *DuckDBStorage) error {
+	codec := strings.ToLower(string(s))
+	if codec != "" && !slices.Contains(ParquetCodecs, codec) {
+		return fmt.Errorf("%w: unknown Parquet codec %q, expected one of %s", ErrInvalidOption, string(s), strings.Join(ParquetCodecs, ", "))
+	}
+	d.snapshotCompression = codec
+	return nil
+}
+
+func WithSnapshotCompression(codec string) DuckDBStorageOption {
+	return snapshotCompressionOption(codec)
 }
 
 // snapshotPathOption is an option to set the path to store snapshots.
-// when enabled, the storage will store snapshots in the specified path.
+// when enabled, the storage will store snapshots in the specified path.  A directory that
+// already exists must be writable; one that doesn't is checked once WithCreateSnapshotDir
+// creates it.
 type snapshotPathOption string
 
 func (s snapshotPathOption) apply(d *DuckDBStorage) error {
 	d.snapshotPath = string(s)
-	return nil
+	if _, err := os.Stat(d.snapshotPath); os.IsNotExist(err) {
+		return nil
+	}
+	return checkWritable(d.snapshotPath)
 }
 
 func WithSnapshotPath(path string) DuckDBStorageOption {
 	return snapshotPathOption(path)
 }
 
-// restoreOption is an option to restore the database from a snapshot on startup.
+// restoreOption is an option to restore the database from a snapshot on startup.  A snapshot
+// with an unsupported schema version stops the storage from starting.
 type restoreOption string
 
 func (r restoreOption) apply(d *DuckDBStorage) error {
 	d.restoreFirst = true
 	d.snapshotPath = string(r)
-	return d.restore(d.snapshotPath)
+	err := d.restore(d.snapshotPath)
+	if errors.Is(err, storage.ErrNoSnapshot) {
+		d.logger.Warn().Str("snapshot_path", d.snapshotPath).Msg("No snapshot found, starting with empty database")
+		return nil
+	}
+	if errors.Is(err, ErrSnapshotVersion) {
+		// Starting empty would hide the snapshot behind a fresh one at the next tick
+		return fmt.Errorf("%w: %w", ErrInvalidOption, err)
+	}
+	return err
 }
 
 func WithRestore(path string) DuckDBStorageOption {
@@ -54,10 +157,25 @@ func WithRestore(path string) DuckDBStorageOption {
 type createSnapshotDirOption bool
 
 func (c createSnapshotDirOption) apply(d *DuckDBStorage) error {
-	if bool(c) {
-		return os.MkdirAll(d.snapshotPath, 0755)
+	if !bool(c) {
+		return nil
 	}
-	return nil
+	if err := os.MkdirAll(d.snapshotPath, 0755); err != nil {
+		return fmt.Errorf("%w: error creating snapshot directory: %v", ErrInvalidOption, err)
+	}
+	return checkWritable(d.snapshotPath)
+}
+
+// checkWritable writes and removes a temporary file in the snapshot directory dir, so that a
+// directory snapshots can't be written to stops the storage from starting rather than failing
+// the first snapshot hours later
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return fmt.Errorf("%w: snapshot directory %s is not writable: %v", ErrInvalidOption, dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
 }
 
 func WithCreateSnapshotDir(create bool) DuckDBStorageOption {
@@ -77,3 +195,115 @@ func (i initTablesOption) apply(d *DuckDBStorage) error {
 func WithInitTables(init bool) DuckDBStorageOption {
 	return initTablesOption(init)
 }
+
+// secretKeyOption encrypts BMC and Redfish endpoint passwords at rest with an AES key.
+// Passwords stored before the key was configured are still read as plaintext and are
+// encrypted the next time they are saved.
+type secretKeyOption []byte
+
+func (s secretKeyOption) apply(d *DuckDBStorage) error {
+	c, err := secrets.NewCipher(s)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOption, err)
+	}
+	d.cipher = c
+	return nil
+}
+
+func WithSecretKey(key []byte) DuckDBStorageOption {
+	return secretKeyOption(key)
+}
+
+// Connection pool defaults.  DuckDB runs in-process, so a connection is cheap to keep and
+// never goes stale the way a network connection does, hence no lifetime by default.
+//
+// DuckDB allows any number of connections to read concurrently but has a single writer per
+// database: writes from several connections go through optimistic concurrency control and a
+// transaction that touches rows another one changed since it started fails with a conflict
+// instead of waiting.  More open connections therefore speed up concurrent reads and
+// snapshots, not writes.  Writes that must not conflict, like AllocateNID, are serialized in
+// DuckDBStorage itself.
+const (
+	DefaultMaxOpenConns    = 16
+	DefaultMaxIdleConns    = 4
+	DefaultConnMaxLifetime = time.Duration(0)
+)
+
+// maxOpenConnsOption caps the connections open to the database at once.  Zero lifts the cap.
+type maxOpenConnsOption int
+
+func (m maxOpenConnsOption) apply(d *DuckDBStorage) error {
+	if m < 0 {
+		return fmt.Errorf("%w: maximum of %d open connections is negative", ErrInvalidOption, int(m))
+	}
+	d.db.SetMaxOpenConns(int(m))
+	return nil
+}
+
+func WithMaxOpenConns(n int) DuckDBStorageOption {
+	return maxOpenConnsOption(n)
+}
+
+// maxIdleConnsOption sets how many idle connections are kept for reuse.  Zero keeps none, and
+// database/sql never keeps more than the maximum of open connections.
+type maxIdleConnsOption int
+
+func (m maxIdleConnsOption) apply(d *DuckDBStorage) error {
+	if m < 0 {
+		return fmt.Errorf("%w: maximum of %d idle connections is negative", ErrInvalidOption, int(m))
+	}
+	d.db.SetMaxIdleConns(int(m))
+	return nil
+}
+
+func WithMaxIdleConns(n int) DuckDBStorageOption {
+	return maxIdleConnsOption(n)
+}
+
+// connMaxLifetimeOption closes connections once they have been open for the given duration.
+// Zero keeps them open for as long as the pool wants them.
+type connMaxLifetimeOption time.Duration
+
+func (c connMaxLifetimeOption) apply(d *DuckDBStorage) error {
+	if c < 0 {
+		return fmt.Errorf("%w: connection lifetime %s is negative", ErrInvalidOption, time.Duration(c))
+	}
+	d.db.SetConnMaxLifetime(time.Duration(c))
+	return nil
+}
+
+func WithConnMaxLifetime(lifetime time.Duration) DuckDBStorageOption {
+	return connMaxLifetimeOption(lifetime)
+}
+
+// loggerOption logs through the given logger instead of the global one, e.g. to tag storage
+// logs with the component or to silence them with zerolog.Nop().  NewDuckDBStorage applies it
+// before any other option.
+type loggerOption zerolog.Logger
+
+func (l loggerOption) apply(d *DuckDBStorage) error {
+	d.logger = zerolog.Logger(l)
+	return nil
+}
+
+func WithLogger(logger zerolog.Logger) DuckDBStorageOption {
+	return loggerOption(logger)
+}
+
+// slowQueryThresholdOption sets how long a statement may take before it is logged at WARN with
+// its SQL, duration and the Go types of its arguments as arg_types.  The argument values are
+// never logged, so the node documents and passwords they carry stay out of the log.
+type slowQueryThresholdOption time.Duration
+
+func (s slowQueryThresholdOption) apply(d *DuckDBStorage) error {
+	if s < 0 {
+		return fmt.Errorf("%w: slow query threshold %s is negative", ErrInvalidOption, time.Duration(s))
+	}
+	d.slowQueryThreshold = time.Duration(s)
+	return nil
+}
+
+// WithSlowQueryThreshold replaces DefaultSlowQueryThreshold.  Zero turns slow query logging off.
+func WithSlowQueryThreshold(threshold time.Duration) DuckDBStorageOption {
+	return slowQueryThresholdOption(threshold)
+}
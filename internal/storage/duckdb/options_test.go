@@ -0,0 +1,232 @@
+package duckdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSnapshotFrequencyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  DuckDBStorageOption
+		wantErr bool
+	}{
+		{"disabled", WithSnapshotFrequency(0), false},
+		{"at minimum", WithSnapshotFrequency(MinSnapshotFrequency), false},
+		{"below minimum", WithSnapshotFrequency(5 * time.Second), true},
+		{"below minimum forced", WithForcedSnapshotFrequency(5 * time.Second), false},
+		{"negative", WithSnapshotFrequency(-time.Minute), true},
+		{"negative forced", WithForcedSnapshotFrequency(-time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		d, err := NewDuckDBStorage("", tt.option)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("%s: expected ErrInvalidOption, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		d.Close()
+	}
+}
+
+func TestSnapshotParquetRejectsOverlap(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	// Simulate a snapshot that is still being written
+	d.snapshotMu.Lock()
+	err = d.SnapshotParquet(context.Background(), t.TempDir())
+	d.snapshotMu.Unlock()
+	if !errors.Is(err, ErrSnapshotInProgress) {
+		t.Errorf("expected ErrSnapshotInProgress while a snapshot is running, got %v", err)
+	}
+}
+
+func TestZeroSnapshotFrequencyDisablesSnapshots(t *testing.T) {
+	d, err := NewDuckDBStorage("", WithSnapshotPath(t.TempDir()), WithSnapshotFrequency(0))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if d.snapshotRunning {
+		t.Error("expected no snapshot routine with a zero snapshot frequency")
+	}
+
+	// With no routine to wait for, shutdown must not need the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d.Shutdown(ctx)
+	if ctx.Err() != nil {
+		t.Error("shutdown waited for a snapshot routine that should not exist")
+	}
+}
+
+func TestSnapshotDirMustBeWritable(t *testing.T) {
+	// A regular file stands in the way of the directory, which stops root as well
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	type dirTest struct {
+		name    string
+		options []DuckDBStorageOption
+	}
+	tests := []dirTest{
+		{"path is a file", []DuckDBStorageOption{WithSnapshotPath(blocker)}},
+		{"created under a file", []DuckDBStorageOption{WithSnapshotPath(filepath.Join(blocker, "snapshots")), WithCreateSnapshotDir(true)}},
+	}
+	if os.Geteuid() != 0 {
+		readOnly := filepath.Join(t.TempDir(), "read-only")
+		if err := os.Mkdir(readOnly, 0555); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		tests = append(tests, dirTest{"read-only directory", []DuckDBStorageOption{WithSnapshotPath(readOnly), WithCreateSnapshotDir(true)}})
+	}
+
+	for _, tt := range tests {
+		d, err := NewDuckDBStorage("", tt.options...)
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: expected ErrInvalidOption, got %v", tt.name, err)
+		}
+		if err == nil {
+			d.Close()
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	d, err := NewDuckDBStorage("", WithSnapshotPath(dir), WithCreateSnapshotDir(true))
+	if err != nil {
+		t.Fatalf("failed to create storage with a writable snapshot directory: %v", err)
+	}
+	d.Close()
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("expected an empty snapshot directory after the check, got %v (%v)", entries, err)
+	}
+}
+
+func TestSnapshotFrequencyStartsSnapshots(t *testing.T) {
+	d, err := NewDuckDBStorage("", WithSnapshotPath(t.TempDir()), WithSnapshotFrequency(MinSnapshotFrequency))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if !d.snapshotRunning {
+		t.Error("expected a snapshot routine with a snapshot frequency and path")
+	}
+	d.Shutdown(context.Background())
+}
+
+func TestDatabasePath(t *testing.T) {
+	memory, err := NewDuckDBStorage(MemoryPath)
+	if err != nil {
+		t.Fatalf("failed to open an in-memory database: %v", err)
+	}
+	memory.Close()
+	if _, err := os.Stat(MemoryPath); !os.IsNotExist(err) {
+		t.Errorf("expected no %s file to be created", MemoryPath)
+	}
+
+	path := filepath.Join(t.TempDir(), "volume", "orchestrator", "data.db")
+	d, err := NewDuckDBStorage(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	d.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the database file to be created: %v", err)
+	}
+}
+
+func TestConnectionPoolOptions(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if got := d.db.Stats().MaxOpenConnections; got != DefaultMaxOpenConns {
+		t.Errorf("expected %d open connections by default, got %d", DefaultMaxOpenConns, got)
+	}
+	d.Close()
+
+	d, err = NewDuckDBStorage("", WithMaxOpenConns(2), WithMaxIdleConns(1), WithConnMaxLifetime(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if got := d.db.Stats().MaxOpenConnections; got != 2 {
+		t.Errorf("expected at most 2 open connections, got %d", got)
+	}
+	d.Close()
+
+	for _, option := range []DuckDBStorageOption{WithMaxOpenConns(-1), WithMaxIdleConns(-1), WithConnMaxLifetime(-time.Second)} {
+		if _, err := NewDuckDBStorage("", option); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("expected ErrInvalidOption for %#v, got %v", option, err)
+		}
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	d, err := NewDuckDBStorage("", WithSnapshotFrequency(0), WithLogger(zerolog.New(&buf)))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	d.Shutdown(context.Background())
+
+	if !strings.Contains(buf.String(), "Periodic snapshots are disabled") || !strings.Contains(buf.String(), "DuckDB Shutdown complete") {
+		t.Errorf("expected the storage to log through the given logger, got %q", buf.String())
+	}
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	if _, err := NewDuckDBStorage("", WithSlowQueryThreshold(-time.Second)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for a negative threshold, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	d, err := NewDuckDBStorage("", WithLogger(zerolog.New(&buf)), WithSlowQueryThreshold(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	// Statements run inside transactions are timed too
+	if err := d.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`SELECT ? + 1, ?`, 41, "hunter2")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"message":"Slow DuckDB query"`) || !strings.Contains(buf.String(), `"sql":"SELECT ? + 1, ?","arg_types":["int64","string"]`) {
+		t.Errorf("expected the query to be logged as slow, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("expected the argument values to be left out of the log, got %q", buf.String())
+	}
+
+	buf.Reset()
+	off, err := NewDuckDBStorage("", WithLogger(zerolog.New(&buf)), WithSlowQueryThreshold(0))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer off.Close()
+	if _, err := off.db.Exec(`SELECT 1`); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Slow DuckDB query") {
+		t.Errorf("expected no slow queries logged with slow query logging off, got %q", buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package duckdb
+
+import (
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// Passwords are sealed just before an object is written and opened right after it is read,
+// so everything outside the storage only sees plaintext.
+
+func (d *DuckDBStorage) sealPassword(password string) (string, error) {
+	if d.cipher == nil {
+		return password, nil
+	}
+	return d.cipher.Seal(password)
+}
+
+func (d *DuckDBStorage) openPassword(password string) (string, error) {
+	if d.cipher == nil {
+		return password, nil
+	}
+	return d.cipher.Open(password)
+}
+
+func (d *DuckDBStorage) sealBMC(bmc nodes.BMC) (nodes.BMC, error) {
+	var err error
+	bmc.Password, err = d.sealPassword(bmc.Password)
+	return bmc, err
+}
+
+func (d *DuckDBStorage) openBMC(bmc nodes.BMC) (nodes.BMC, error) {
+	var err error
+	bmc.Password, err = d.openPassword(bmc.Password)
+	return bmc, err
+}
+
+// sealNode seals the passwords of the node's embedded BMC and spec.  The BMC is copied so
+// that the caller's node is left as it was.
+func (d *DuckDBStorage) sealNode(node nodes.ComputeNode) (nodes.ComputeNode, error) {
+	var err error
+	if node.BMC != nil {
+		bmc, err := d.sealBMC(*node.BMC)
+		if err != nil {
+			return node, err
+		}
+		node.BMC = &bmc
+	}
+	node.Spec.BMCPassword, err = d.sealPassword(node.Spec.BMCPassword)
+	return node, err
+}
+
+func (d *DuckDBStorage) openNode(node nodes.ComputeNode) (nodes.ComputeNode, error) {
+	var err error
+	if node.BMC != nil {
+		bmc, err := d.openBMC(*node.BMC)
+		if err != nil {
+			return node, err
+		}
+		node.BMC = &bmc
+	}
+	node.Spec.BMCPassword, err = d.openPassword(node.Spec.BMCPassword)
+	return node, err
+}
@@ -0,0 +1,76 @@
+package duckdb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestPasswordsEncryptedAtRest(t *testing.T) {
+	storage, err := NewDuckDBStorage("", WithSecretKey(bytes.Repeat([]byte("k"), 32)))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x3000c0s1b0"), Username: "root", Password: "bmc-secret"}
+	if err := storage.SaveBMC(bmc.ID, bmc); err != nil {
+		t.Fatalf("failed to save BMC: %v", err)
+	}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x3000c0s1b0n0"), BMC: &bmc}
+	node.Spec.BMCPassword = "spec-secret"
+	if err := storage.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+	if node.BMC.Password != "bmc-secret" {
+		t.Errorf("saving must not modify the caller's node")
+	}
+	if err := storage.CreateOrUpdateRedfishEndpoints([]smd.RedfishEndpoint{{ID: "x3000c0s1b0", User: "root", Password: "redfish-secret"}}); err != nil {
+		t.Fatalf("failed to save redfish endpoint: %v", err)
+	}
+
+	for _, query := range []string{
+		`SELECT data::VARCHAR FROM bmcs`,
+		`SELECT data::VARCHAR FROM compute_nodes`,
+		`SELECT password FROM redfish_endpoints`,
+	} {
+		var stored string
+		if err := storage.db.QueryRow(query).Scan(&stored); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if strings.Contains(stored, "secret") {
+			t.Errorf("%s: found a plaintext password in %s", query, stored)
+		}
+	}
+
+	gotBMC, err := storage.GetBMC(bmc.ID)
+	if err != nil || gotBMC.Password != "bmc-secret" {
+		t.Errorf("expected the BMC password to be decrypted on read, got %q, %v", gotBMC.Password, err)
+	}
+	gotNode, err := storage.GetComputeNode(node.ID)
+	if err != nil || gotNode.BMC.Password != "bmc-secret" || gotNode.Spec.BMCPassword != "spec-secret" {
+		t.Errorf("expected the node passwords to be decrypted on read, got %+v, %v", gotNode, err)
+	}
+	endpoint, err := storage.GetRedfishEndpointByID("x3000c0s1b0")
+	if err != nil || endpoint.Password != "redfish-secret" {
+		t.Errorf("expected the redfish password to be decrypted on read, got %q, %v", endpoint.Password, err)
+	}
+
+	// Re-saving what was read must not encrypt twice
+	if err := storage.SaveBMC(gotBMC.ID, gotBMC); err != nil {
+		t.Fatalf("failed to re-save BMC: %v", err)
+	}
+	if again, _ := storage.GetBMC(bmc.ID); again.Password != "bmc-secret" {
+		t.Errorf("expected the password to survive a re-save, got %q", again.Password)
+	}
+}
+
+func TestInvalidSecretKey(t *testing.T) {
+	if _, err := NewDuckDBStorage("", WithSecretKey([]byte("short"))); err == nil {
+		t.Errorf("expected an invalid key to stop the storage from starting")
+	}
+}
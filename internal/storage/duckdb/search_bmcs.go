@@ -0,0 +1,68 @@
+package duckdb
+
+import (
+	"strings"
+
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+// SearchBMCs returns the BMCs matching opts in the order of their IDs
+func (d *DuckDBStorage) SearchBMCs(opts ...storage.BMCSearchOption) ([]nodes.BMC, error) {
+	options := &storage.BMCSearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var queryStrings []string
+	var queryArgs []interface{}
+
+	if options.XName != "" {
+		queryStrings = append(queryStrings, "xname = ?")
+		queryArgs = append(queryArgs, options.XName)
+	}
+	if options.XNamePrefix != "" {
+		queryStrings = append(queryStrings, "starts_with(xname, ?)")
+		queryArgs = append(queryArgs, options.XNamePrefix)
+	}
+	if options.MACAddress != "" {
+		queryStrings = append(queryStrings, "lower(json_extract_string(data, '$.mac_address')) = ?")
+		queryArgs = append(queryArgs, strings.ToLower(options.MACAddress))
+	}
+	if options.IPAddress != "" {
+		queryStrings = append(queryStrings, "(json_extract_string(data, '$.ipv4_address') = ? OR json_extract_string(data, '$.ipv6_address') = ?)")
+		queryArgs = append(queryArgs, options.IPAddress, options.IPAddress)
+	}
+
+	query := "SELECT data FROM bmcs WHERE 1=1"
+	for _, condition := range queryStrings {
+		query += " AND " + condition
+	}
+	query += " ORDER BY id"
+
+	rows, err := d.db.Query(query, queryArgs...)
+	if err != nil {
+		d.logger.Error().Err(err).Msg("Error querying DuckDB for BMCs")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bmcs []nodes.BMC
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		bmc, err := d.decodeBMC(data)
+		if err != nil {
+			return nil, err
+		}
+		bmcs = append(bmcs, bmc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	d.logger.Debug().Str("query", query).Interface("args", queryArgs).Int("count", len(bmcs)).Msg("DuckDB BMC search complete")
+	return bmcs, nil
+}
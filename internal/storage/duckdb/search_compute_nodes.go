@@ -1,32 +1,121 @@
 package duckdb
 
 import (
-	"encoding/json"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/openchami/node-orchestrator/internal/storage"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
-	"github.com/rs/zerolog/log"
 )
 
 func (d *DuckDBStorage) SearchComputeNodes(opts ...storage.NodeSearchOption) ([]nodes.ComputeNode, error) {
+	var foundNodes []nodes.ComputeNode
+	err := d.StreamComputeNodes(func(node nodes.ComputeNode) error {
+		foundNodes = append(foundNodes, node)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return foundNodes, nil
+}
+
+// StreamComputeNodes calls visit with each node matching opts as it is read from the cursor,
+// stopping at the first error visit returns
+func (d *DuckDBStorage) StreamComputeNodes(visit func(nodes.ComputeNode) error, opts ...storage.NodeSearchOption) error {
 	options := &storage.NodeSearchOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
+	queryStrings, queryArgs := nodeSearchConditions(options)
+
+	// Ordered like the in-memory search so that pages of the results don't overlap
+	query := buildQuery("data", "AND", queryStrings...) + " ORDER BY id"
+	if options.Limit > 0 {
+		query += " LIMIT ?"
+		queryArgs = append(queryArgs, options.Limit)
+	}
+	if options.Offset > 0 {
+		query += " OFFSET ?"
+		queryArgs = append(queryArgs, options.Offset)
+	}
+
+	rows, err := d.db.Query(query, queryArgs...)
+	if err != nil {
+		d.logger.Error().Err(err).Msg("Error querying DuckDB for ComputeNodes")
+		return err
+	}
+	defer rows.Close()
 
+	count := 0
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		node, err := d.decodeNode(data)
+		if err != nil {
+			return err
+		}
+		if err := visit(node); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	d.logger.Debug().Str("query", query).Interface("args", queryArgs).Int("count", count).Msg("DuckDB ComputeNode search complete")
+	return nil
+}
+
+func (d *DuckDBStorage) CountMatchingComputeNodes(opts ...storage.NodeSearchOption) (int, error) {
+	options := &storage.NodeSearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	queryStrings, queryArgs := nodeSearchConditions(options)
+
+	var count int
+	err := d.db.QueryRow(buildQuery("COUNT(*)", "AND", queryStrings...), queryArgs...).Scan(&count)
+	return count, err
+}
+
+// nodeSearchConditions turns the filters of options into SQL conditions on compute_nodes and
+// their arguments
+func nodeSearchConditions(options *storage.NodeSearchOptions) ([]string, []interface{}) {
 	var queryStrings []string
 	var queryArgs []interface{}
 
 	if options.XName != "" {
-		queryStrings = append(queryStrings, "json_extract(data, '$.xname')::text = ?")
-		queryArgs = append(queryArgs, `"`+options.XName+`"`)
+		queryStrings = append(queryStrings, "xname = ?")
+		queryArgs = append(queryArgs, options.XName)
+	}
+	if options.XNames != nil {
+		if len(options.XNames) == 0 {
+			queryStrings = append(queryStrings, "FALSE")
+		} else {
+			placeholders := make([]string, len(options.XNames))
+			for i, xname := range options.XNames {
+				placeholders[i] = "?"
+				queryArgs = append(queryArgs, xname)
+			}
+			queryStrings = append(queryStrings, "xname IN ("+strings.Join(placeholders, ", ")+")")
+		}
 	}
 	if options.Hostname != "" {
 		queryStrings = append(queryStrings, "json_extract(data, '$.hostname')::text = ?")
 		queryArgs = append(queryArgs, `"`+options.Hostname+`"`)
 	}
+	if options.NID > 0 {
+		queryStrings = append(queryStrings, "json_extract(data, '$.nid')::INTEGER = ?")
+		queryArgs = append(queryArgs, options.NID)
+	}
 	if options.Arch != "" {
-		queryStrings = append(queryStrings, "json_extract(data, '$.arch')::text = ?")
+		queryStrings = append(queryStrings, "json_extract(data, '$.architecture')::text = ?")
 		queryArgs = append(queryArgs, `"`+options.Arch+`"`)
 	}
 	if options.BootMAC != "" {
@@ -37,58 +126,89 @@ func (d *DuckDBStorage) SearchComputeNodes(opts ...storage.NodeSearchOption) ([]
 		queryStrings = append(queryStrings, "json_extract(data, '$.bmc.mac_address')::text = ?")
 		queryArgs = append(queryArgs, `"`+options.BMCMAC+`"`)
 	}
+	if options.BMCID != uuid.Nil {
+		queryStrings = append(queryStrings, "json_extract_string(data, '$.bmc.id') = ?")
+		queryArgs = append(queryArgs, options.BMCID.String())
+	}
+	if options.BootIPv4 != "" {
+		queryStrings = append(queryStrings, "json_extract(data, '$.boot_ipv4_address')::text = ?")
+		queryArgs = append(queryArgs, `"`+options.BootIPv4+`"`)
+	}
+	if options.BootIPv6 != "" {
+		queryStrings = append(queryStrings, "json_extract(data, '$.boot_ipv6_address')::text = ?")
+		queryArgs = append(queryArgs, `"`+options.BootIPv6+`"`)
+	}
+	if options.LifecycleState != "" {
+		queryStrings = append(queryStrings, "json_extract_string(data, '$.lifecycle_state') = ?")
+		queryArgs = append(queryArgs, string(options.LifecycleState))
+	}
+	// The [*] wildcard makes json_extract_string return a VARCHAR[] holding the field of every
+	// interface, without a value for interfaces that lack it, which list_contains then
+	// searches.  Nodes without interfaces get an empty list or NULL and never match.
+	if options.NICFirmware != "" {
+		queryStrings = append(queryStrings, "list_contains(json_extract_string(data, '$.network_interfaces[*].firmware_version'), ?)")
+		queryArgs = append(queryArgs, options.NICFirmware)
+	}
+	if options.NICModel != "" {
+		queryStrings = append(queryStrings, "list_contains(json_extract_string(data, '$.network_interfaces[*].model'), ?)")
+		queryArgs = append(queryArgs, options.NICModel)
+	}
+	if options.NICMAC != "" {
+		queryStrings = append(queryStrings, "list_contains(list_transform(json_extract_string(data, '$.network_interfaces[*].mac_address'), mac -> lower(mac)), ?)")
+		queryArgs = append(queryArgs, options.NICMAC)
+	}
+	// The timestamps are compared as instants, whatever offset they were written with
+	if !options.CreatedAfter.IsZero() {
+		queryStrings = append(queryStrings, "CAST(json_extract_string(data, '$.created_at') AS TIMESTAMPTZ) > CAST(? AS TIMESTAMPTZ)")
+		queryArgs = append(queryArgs, options.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !options.UpdatedAfter.IsZero() {
+		queryStrings = append(queryStrings, "CAST(json_extract_string(data, '$.updated_at') AS TIMESTAMPTZ) > CAST(? AS TIMESTAMPTZ)")
+		queryArgs = append(queryArgs, options.UpdatedAfter.Format(time.RFC3339Nano))
+	}
 
+	// hostname and architecture are written even when empty, other fields are left out, so
+	// both a missing and an empty value count as missing
 	if options.MissingXName {
-		queryStrings = append(queryStrings, "json_extract(data, '$.xname') IS NULL")
+		queryStrings = append(queryStrings, "xname IS NULL")
 	}
 	if options.MissingHostname {
-		queryStrings = append(queryStrings, "json_extract(data, '$.hostname') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.hostname'), '') = ''")
 	}
 	if options.MissingArch {
-		queryStrings = append(queryStrings, "json_extract(data, '$.arch') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.architecture'), '') = ''")
 	}
 	if options.MissingBootMAC {
-		queryStrings = append(queryStrings, "json_extract(data, '$.boot_mac') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.boot_mac'), '') = ''")
 	}
 	if options.MissingBMCMAC {
-		queryStrings = append(queryStrings, "json_extract(data, '$.bmc.mac_address') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.bmc.mac_address'), '') = ''")
 	}
 	if options.MissingIPV4 {
-		queryStrings = append(queryStrings, "json_extract(data, '$.boot_ipv4_address') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.boot_ipv4_address'), '') = ''")
 	}
 	if options.MissingIPV6 {
-		queryStrings = append(queryStrings, "json_extract(data, '$.boot_ipv4_address') IS NULL")
+		queryStrings = append(queryStrings, "COALESCE(json_extract_string(data, '$.boot_ipv6_address'), '') = ''")
 	}
 
-	query := buildQuery("AND", queryStrings...)
-
-	rows, err := d.db.Query(query, queryArgs...)
-	if err != nil {
-		log.Error().Err(err).Msg("Error querying DuckDB for ComputeNodes")
-		return nil, err
+	// Label keys are validated by nodes.ValidateLabels, so quoting them is enough to keep
+	// dots and slashes from being read as JSON path syntax
+	labelKeys := make([]string, 0, len(options.Labels))
+	for key := range options.Labels {
+		labelKeys = append(labelKeys, key)
 	}
-	defer rows.Close()
-
-	var foundNodes []nodes.ComputeNode
-	for rows.Next() {
-		var data string
-		if err := rows.Scan(&data); err != nil {
-			return nil, err
-		}
-		var node nodes.ComputeNode
-		if err := json.Unmarshal([]byte(data), &node); err != nil {
-			return nil, err
-		}
-		foundNodes = append(foundNodes, node)
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		queryStrings = append(queryStrings, "json_extract_string(data, ?) = ?")
+		queryArgs = append(queryArgs, `$.labels."`+key+`"`, options.Labels[key])
 	}
 
-	log.Debug().Str("query", query).Interface("args", queryArgs).Int("count", len(foundNodes)).Msg("DuckDB ComputeNode search complete")
-	return foundNodes, nil
+	return queryStrings, queryArgs
 }
 
-// buildQuery builds a SQL query for searching compute nodes
-func buildQuery(condition string, fields ...string) string {
-	query := "SELECT data FROM compute_nodes WHERE 1=1"
+// buildQuery builds a SQL query selecting columns from the compute nodes matching fields
+func buildQuery(columns string, condition string, fields ...string) string {
+	query := "SELECT " + columns + " FROM compute_nodes WHERE 1=1"
 	for _, field := range fields {
 		query += " " + condition + " " + field
 	}
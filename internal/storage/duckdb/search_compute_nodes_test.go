@@ -0,0 +1,263 @@
+package duckdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+// hostname and architecture are stored even when empty, and still count as missing
+func TestSearchComputeNodesMissingHostnameAndArch(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	bare := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s0b0n0")}
+	named := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x1000c0s1b0n0"), Hostname: "nid001", Architecture: "x86_64"}
+	for _, n := range []nodes.ComputeNode{bare, named} {
+		if err := d.SaveComputeNode(n.ID, n); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	for name, opt := range map[string]storage.NodeSearchOption{
+		"hostname":     storage.WithMissingHostname(),
+		"architecture": storage.WithMissingArch(),
+	} {
+		found, err := d.SearchComputeNodes(opt)
+		if err != nil {
+			t.Fatalf("failed to search for nodes missing %s: %v", name, err)
+		}
+		if len(found) != 1 || found[0].ID != bare.ID {
+			t.Errorf("expected only the bare node to be missing %s, got %v", name, found)
+		}
+	}
+}
+
+func TestSearchComputeNodesMissingIPV6(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{
+		ID:              uuid.New(),
+		Hostname:        "ipv4-only",
+		Architecture:    "x86_64",
+		BootIPv4Address: "10.0.0.10",
+	}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithMissingIPV6())
+	if err != nil {
+		t.Fatalf("failed to search for nodes missing IPv6: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != node.ID {
+		t.Errorf("expected the IPv4-only node to be missing IPv6, got %v", found)
+	}
+
+	found, err = d.SearchComputeNodes(storage.WithMissingIPV4())
+	if err != nil {
+		t.Fatalf("failed to search for nodes missing IPv4: %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected no nodes missing IPv4, got %v", found)
+	}
+}
+
+func TestSearchComputeNodesByLabel(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	labelled := map[string]map[string]string{
+		"a3-team-x":  {"rack": "A3", "example.com/owner": "team-x"},
+		"a3-team-y":  {"rack": "A3", "example.com/owner": "team-y"},
+		"unlabelled": nil,
+	}
+	ids := map[string]uuid.UUID{}
+	for hostname, labels := range labelled {
+		node := nodes.ComputeNode{ID: uuid.New(), Hostname: hostname, Architecture: "x86_64", Labels: labels}
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+		ids[hostname] = node.ID
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithLabel("rack", "A3"))
+	if err != nil {
+		t.Fatalf("failed to search by label: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected both rack A3 nodes, got %v", found)
+	}
+
+	found, err = d.SearchComputeNodes(storage.WithLabel("rack", "A3"), storage.WithLabel("example.com/owner", "team-x"))
+	if err != nil {
+		t.Fatalf("failed to search by labels: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != ids["a3-team-x"] || found[0].Labels["rack"] != "A3" {
+		t.Errorf("expected labels to AND together, got %v", found)
+	}
+}
+
+func TestSearchComputeNodesByBootIP(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	node := nodes.ComputeNode{
+		ID:              uuid.New(),
+		Hostname:        "dual-stack",
+		Architecture:    "x86_64",
+		BootIPv4Address: "10.0.0.10",
+		BootIPv6Address: "fd00::10",
+	}
+	if err := d.SaveComputeNode(node.ID, node); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	for _, opt := range []storage.NodeSearchOption{storage.WithBootIPv4("10.0.0.10"), storage.WithBootIPv6("fd00::10")} {
+		found, err := d.SearchComputeNodes(opt)
+		if err != nil {
+			t.Fatalf("failed to search by boot IP: %v", err)
+		}
+		if len(found) != 1 || found[0].ID != node.ID {
+			t.Errorf("expected the dual-stack node, got %v", found)
+		}
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithBootIPv4("10.0.0.11"))
+	if err != nil {
+		t.Fatalf("failed to search by boot IP: %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected no node for an unused address, got %v", found)
+	}
+}
+
+func TestSearchComputeNodesByLifecycleState(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	failed := nodes.ComputeNode{ID: uuid.New(), Hostname: "failed", Architecture: "x86_64", LifecycleState: nodes.LifecycleFailed}
+	ready := nodes.ComputeNode{ID: uuid.New(), Hostname: "ready", Architecture: "x86_64", LifecycleState: nodes.LifecycleProvisioned}
+	for _, node := range []nodes.ComputeNode{failed, ready} {
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithLifecycleState(nodes.LifecycleFailed))
+	if err != nil {
+		t.Fatalf("failed to search by lifecycle state: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != failed.ID {
+		t.Errorf("expected only the failed node, got %v", found)
+	}
+}
+
+func TestSearchComputeNodesByXNames(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	var saved []nodes.ComputeNode
+	for _, xname := range []string{"x1000c0s1b0n0", "x1000c0s1b0n1", "x1000c0s2b0n0"} {
+		node := nodes.ComputeNode{ID: uuid.New(), Architecture: "x86_64", XName: xnames.NewNodeXname(xname)}
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+		saved = append(saved, node)
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithXNames([]string{"x1000c0s2b0n0", "x1000c0s1b0n0", "x9c0s0b0n0"}))
+	if err != nil {
+		t.Fatalf("failed to search by xnames: %v", err)
+	}
+	if len(found) != 2 || found[0].ID == found[1].ID {
+		t.Fatalf("expected the two saved nodes in the list, got %v", found)
+	}
+	for _, node := range found {
+		if node.ID != saved[0].ID && node.ID != saved[2].ID {
+			t.Errorf("expected only nodes in the list, got %s", node.XName)
+		}
+	}
+	found, err = d.SearchComputeNodes(storage.WithXNames(nil))
+	if err != nil {
+		t.Fatalf("failed to search by no xnames: %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected an empty list to match no nodes, got %v", found)
+	}
+}
+
+func TestSearchComputeNodesByNIC(t *testing.T) {
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	outdated := nodes.ComputeNode{ID: uuid.New(), Hostname: "outdated", Architecture: "x86_64", NetworkInterfaces: []nodes.NetworkInterface{
+		{InterfaceName: "eth0", MACAddress: "02:00:00:00:00:01"},
+		{InterfaceName: "eth1", MACAddress: "02:00:00:00:00:02", Model: "ConnectX-6", FirmwareVersion: "20.31.1014"},
+	}}
+	current := nodes.ComputeNode{ID: uuid.New(), Hostname: "current", Architecture: "x86_64", NetworkInterfaces: []nodes.NetworkInterface{
+		{InterfaceName: "eth0", MACAddress: "02:00:00:00:00:03", Model: "ConnectX-6", FirmwareVersion: "22.36.1010"},
+	}}
+	bare := nodes.ComputeNode{ID: uuid.New(), Hostname: "bare", Architecture: "x86_64"}
+	for _, node := range []nodes.ComputeNode{outdated, current, bare} {
+		if err := d.SaveComputeNode(node.ID, node); err != nil {
+			t.Fatalf("failed to save node: %v", err)
+		}
+	}
+
+	found, err := d.SearchComputeNodes(storage.WithNICFirmware("20.31.1014"))
+	if err != nil {
+		t.Fatalf("failed to search by NIC firmware: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != outdated.ID {
+		t.Errorf("expected only the node with the outdated NIC, got %v", found)
+	}
+
+	found, err = d.SearchComputeNodes(storage.WithNICModel("ConnectX-6"))
+	if err != nil {
+		t.Fatalf("failed to search by NIC model: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected both nodes with a ConnectX-6, got %v", found)
+	}
+
+	found, err = d.SearchComputeNodes(storage.WithNICMAC("02:00:00:00:00:0A"))
+	if err != nil {
+		t.Fatalf("failed to search by NIC MAC: %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected no node with an unknown NIC MAC, got %v", found)
+	}
+	found, err = d.SearchComputeNodes(storage.WithNICMAC("02:00:00:00:00:02"))
+	if err != nil {
+		t.Fatalf("failed to search by NIC MAC: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != outdated.ID {
+		t.Errorf("expected the node with the NIC on the MAC, got %v", found)
+	}
+}
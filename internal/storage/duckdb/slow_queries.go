@@ -0,0 +1,80 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	goduckdb "github.com/marcboeker/go-duckdb"
+)
+
+// DefaultSlowQueryThreshold is how long a statement may take before it is logged as slow
+const DefaultSlowQueryThreshold = time.Second
+
+// duckDBConn is what database/sql uses of a go-duckdb connection
+type duckDBConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.NamedValueChecker
+}
+
+// timedConnector hands out connections that time every statement run on them, inside
+// transactions or not, and report the slow ones to the storage.
+type timedConnector struct {
+	*goduckdb.Connector
+	d *DuckDBStorage
+}
+
+func (c timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	duckConn, ok := conn.(duckDBConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected DuckDB connection type %T", conn)
+	}
+	return timedConn{duckDBConn: duckConn, d: c.d}, nil
+}
+
+type timedConn struct {
+	duckDBConn
+	d *DuckDBStorage
+}
+
+func (c timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.duckDBConn.ExecContext(ctx, query, args)
+	c.d.logSlowQuery(query, args, time.Since(start))
+	return result, err
+}
+
+// QueryContext is timed until the result is ready, which DuckDB has computed by the time the
+// first row can be read.
+func (c timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.duckDBConn.QueryContext(ctx, query, args)
+	c.d.logSlowQuery(query, args, time.Since(start))
+	return rows, err
+}
+
+// logSlowQuery logs query at WARN if it took longer than the slow query threshold.  Only the
+// types of the arguments are logged, since the values include passwords.
+func (d *DuckDBStorage) logSlowQuery(query string, args []driver.NamedValue, elapsed time.Duration) {
+	if d.slowQueryThreshold <= 0 || elapsed < d.slowQueryThreshold {
+		return
+	}
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = fmt.Sprintf("%T", arg.Value)
+	}
+	d.logger.Warn().
+		Str("sql", query).
+		Strs("arg_types", types).
+		Dur("duration", elapsed).
+		Msg("Slow DuckDB query")
+}
@@ -2,7 +2,9 @@ package duckdb
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/google/uuid"
@@ -36,6 +38,12 @@ func initComponentTables(db *sql.DB) error {
 		uri TEXT,
 		username TEXT,
 		password TEXT
+	)`,
+		`CREATE TABLE IF NOT EXISTS redfish_discovery (
+		uid UUID,
+		endpoint_id TEXT,
+		uri TEXT,
+		data JSON
 	)`}
 	for _, query := range queries {
 		if _, err := db.Exec(query); err != nil {
@@ -46,7 +54,7 @@ func initComponentTables(db *sql.DB) error {
 }
 
 func (s *DuckDBStorage) GetComponents() ([]smd.Component, error) {
-	query := "SELECT * FROM components"
+	query := "SELECT * FROM components ORDER BY nid, id"
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -92,15 +100,17 @@ func (s *DuckDBStorage) GetComponentByUID(uid uuid.UUID) (smd.Component, error)
 
 	var c smd.Component
 	if err := row.Scan(&c.UID, &c.ID, &c.Type, &c.Subtype, &c.Role, &c.SubRole, &c.NetType, &c.Arch, &c.Class, &c.State, &c.Flag, &c.Enabled, &c.SwStatus, &c.NID, &c.ReservationDisabled, &c.Locked); err != nil {
-		if err == sql.ErrNoRows {
-			return c, fmt.Errorf("component not found")
-		}
 		return c, err
 	}
 	return c, nil
 }
 
+// QueryComponents returns the component with xname if it also matches params, a map of column
+// to value.  Like UpdateComponentData it refuses columns outside smd.ComponentDataColumns.
 func (s *DuckDBStorage) QueryComponents(xname string, params map[string]string) ([]smd.Component, error) {
+	if err := smd.ValidateComponentParams(params); err != nil {
+		return nil, err
+	}
 	query := "SELECT * FROM components WHERE id = ?"
 	args := []interface{}{xname}
 
@@ -126,34 +136,267 @@ func (s *DuckDBStorage) QueryComponents(xname string, params map[string]string)
 	return components, nil
 }
 
+func (s *DuckDBStorage) FilterComponents(filter smd.ComponentFilter) ([]smd.Component, error) {
+	var where []string
+	var args []interface{}
+
+	if len(filter.IDs) > 0 {
+		where = append(where, "id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(filter.IDs)), ", ")+")")
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+
+	var nidClauses []string
+	if len(filter.NIDs) > 0 {
+		nidClauses = append(nidClauses, "nid IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(filter.NIDs)), ", ")+")")
+		for _, nid := range filter.NIDs {
+			args = append(args, nid)
+		}
+	}
+	for _, nidRange := range filter.NIDRanges {
+		nidClauses = append(nidClauses, "nid BETWEEN ? AND ?")
+		args = append(args, nidRange.Start, nidRange.End)
+	}
+	if len(nidClauses) > 0 {
+		where = append(where, "("+strings.Join(nidClauses, " OR ")+")")
+	}
+
+	for _, column := range []struct {
+		name  string
+		value string
+	}{
+		{"role", string(filter.Role)},
+		{"state", string(filter.State)},
+		{"arch", string(filter.Arch)},
+		{"class", string(filter.Class)},
+	} {
+		if column.value != "" {
+			where = append(where, column.name+" = ?")
+			args = append(args, column.value)
+		}
+	}
+	if filter.Enabled != nil {
+		where = append(where, "enabled = ?")
+		args = append(args, *filter.Enabled)
+	}
+
+	query := "SELECT * FROM components"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY nid, id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var components []smd.Component
+	for rows.Next() {
+		var c smd.Component
+		if err := rows.Scan(&c.UID, &c.ID, &c.Type, &c.Subtype, &c.Role, &c.SubRole, &c.NetType, &c.Arch, &c.Class, &c.State, &c.Flag, &c.Enabled, &c.SwStatus, &c.NID, &c.ReservationDisabled, &c.Locked); err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
+
+// checkNIDFree fails with smd.ErrDuplicateNID if a component other than id holds nid.  The
+// components table has no unique index on nid, since DuckDB can't update indexed columns,
+// so writes that set a NID check it first, inside their transaction.
+func checkNIDFree(tx *sql.Tx, nid int, id string) error {
+	if nid == 0 {
+		return nil
+	}
+	var holder string
+	err := tx.QueryRow("SELECT id FROM components WHERE nid = ? AND id <> ? LIMIT 1", nid, id).Scan(&holder)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil
+	case err != nil:
+		return err
+	}
+	return fmt.Errorf("%w: NID %d of %s is already held by %s", smd.ErrDuplicateNID, nid, id, holder)
+}
+
+// CreateOrUpdateComponents saves the batch in a single transaction, so either every
+// component is written or none are.  A NID already held by another component, stored or
+// earlier in the batch, fails the whole batch with smd.ErrDuplicateNID.
 func (s *DuckDBStorage) CreateOrUpdateComponents(components []smd.Component) error {
-	for _, c := range components {
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, c := range components {
+			if err := checkNIDFree(tx, c.NID, c.ID); err != nil {
+				return err
+			}
 
-		var existingComponent smd.Component
-		var err error
+			var existingUID uuid.UUID
+			var err error
 
-		// Check if component already exists by xname
-		if c.ID != "" {
-			existingComponent, err = s.GetComponentByXname(c.ID)
+			// Check if component already exists by xname, then by uuid.  Lookups go through
+			// the transaction so that earlier components in the same batch are visible.
+			if c.ID != "" {
+				err = tx.QueryRow("SELECT uid FROM components WHERE id = ?", c.ID).Scan(&existingUID)
+			} else if c.UID != uuid.Nil {
+				err = tx.QueryRow("SELECT uid FROM components WHERE uid = ?", c.UID).Scan(&existingUID)
+			}
 			if err != nil && err != sql.ErrNoRows {
 				return err
 			}
-			// Check if it exists by uuid
-		} else if c.UID != uuid.Nil {
-			existingComponent, err = s.GetComponentByUID(c.UID)
-			if err != nil && err != sql.ErrNoRows {
+
+			// If component exists, update it
+			if existingUID != uuid.Nil {
+				query := `
+				UPDATE components SET
+				uid = ?,
+				type = ?,
+				subtype = ?,
+				role = ?,
+				sub_role = ?,
+				net_type = ?,
+				arch = ?,
+				class = ?,
+				state = ?,
+				flag = ?,
+				enabled = ?,
+				sw_status = ?,
+				nid = ?,
+				reservation_disabled = ?,
+				locked = ?
+				WHERE id = ?`
+
+				_, err := tx.Exec(query, c.UID, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked, c.ID)
+				if err != nil {
+					return err
+				}
+			} else {
+				// If component does not exist, create it
+				c.UID = uuid.New()
+				query := `
+				INSERT INTO components (uid, id, type, subtype, role, sub_role, net_type, arch, class, state, flag, enabled, sw_status, nid, reservation_disabled, locked)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+				_, err := tx.Exec(query, c.UID, c.ID, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *DuckDBStorage) DeleteComponents() error {
+	query := "DELETE FROM components"
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *DuckDBStorage) DeleteComponentByXname(xname string) error {
+	query := "DELETE FROM components WHERE id = ?"
+	_, err := s.db.Exec(query, xname)
+	return err
+}
+
+// DeleteComponentsByXnames deletes the components in xnames with a single statement
+func (s *DuckDBStorage) DeleteComponentsByXnames(xnames []string) (int, error) {
+	if len(xnames) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(xnames))
+	for i, xname := range xnames {
+		args[i] = xname
+	}
+	result, err := s.db.Exec("DELETE FROM components WHERE id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(xnames)), ", ")+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// UpdateComponentData sets the columns in data on the components in xnames.  The keys of data
+// end up in the query, so anything that isn't one of smd.ComponentDataColumns is refused.  A
+// nid already held by another component, including another of xnames, fails the update with
+// smd.ErrDuplicateNID.
+func (s *DuckDBStorage) UpdateComponentData(xnames []string, data map[string]interface{}) error {
+	if err := smd.ValidateComponentData(data); err != nil {
+		return err
+	}
+	if len(xnames) == 0 || len(data) == 0 {
+		return nil
+	}
+	nid, setsNID := data["nid"]
+
+	columns := make([]string, 0, len(data))
+	for k := range data {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+
+	setClauses := []string{}
+	args := []interface{}{}
+	for _, k := range columns {
+		setClauses = append(setClauses, fmt.Sprintf("%s = ?", k))
+		args = append(args, data[k])
+	}
+	placeholders := make([]string, len(xnames))
+	for i, xname := range xnames {
+		placeholders[i] = "?"
+		args = append(args, xname)
+	}
+
+	query := fmt.Sprintf("UPDATE components SET %s WHERE id IN (%s)", strings.Join(setClauses, ", "), strings.Join(placeholders, ", "))
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+		if !setsNID {
+			return nil
+		}
+		// Checked after the update, so that xnames given the same NID see each other
+		var stored int
+		if err := tx.QueryRow("SELECT CAST(? AS INTEGER)", nid).Scan(&stored); err != nil {
+			return err
+		}
+		for _, xname := range xnames {
+			if err := checkNIDFree(tx, stored, xname); err != nil {
 				return err
 			}
-			// if it doesn't exist, create
-		} else {
-			existingComponent = smd.Component{}
 		}
+		return nil
+	})
+}
+
+func (s *DuckDBStorage) ModifyComponents(ids []string, modify func(smd.Component) (smd.Component, error)) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			var c smd.Component
+			err := tx.QueryRow("SELECT * FROM components WHERE id = ?", id).Scan(&c.UID, &c.ID, &c.Type, &c.Subtype, &c.Role, &c.SubRole, &c.NetType, &c.Arch, &c.Class, &c.State, &c.Flag, &c.Enabled, &c.SwStatus, &c.NID, &c.ReservationDisabled, &c.Locked)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("component %s: %w", id, err)
+			}
+			if err != nil {
+				return err
+			}
+			c, err = modify(c)
+			if err != nil {
+				return err
+			}
+			if err := checkNIDFree(tx, c.NID, id); err != nil {
+				return err
+			}
 
-		// If component exists, update it
-		if existingComponent.UID != uuid.Nil {
 			query := `
 			UPDATE components SET
-			uid = ?,
 			type = ?,
 			subtype = ?,
 			role = ?,
@@ -169,57 +412,53 @@ func (s *DuckDBStorage) CreateOrUpdateComponents(components []smd.Component) err
 			reservation_disabled = ?,
 			locked = ?
 			WHERE id = ?`
-
-			_, err := s.db.Exec(query, c.UID, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked, c.ID)
-			if err != nil {
-				return err
-			}
-		} else {
-			// If component does not exist, create it
-			c.UID = uuid.New()
-			query := `
-			INSERT INTO components (uid, id, type, subtype, role, sub_role, net_type, arch, class, state, flag, enabled, sw_status, nid, reservation_disabled, locked)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-			_, err := s.db.Exec(query, c.UID, c.ID, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked)
-			if err != nil {
+			if _, err := tx.Exec(query, c.Type, c.Subtype, c.Role, c.SubRole, c.NetType, c.Arch, c.Class, c.State, c.Flag, c.Enabled, c.SwStatus, c.NID, c.ReservationDisabled, c.Locked, id); err != nil {
 				return err
 			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
-func (s *DuckDBStorage) DeleteComponents() error {
-	query := "DELETE FROM components"
-	_, err := s.db.Exec(query)
-	return err
+// redfishEndpointConditions is the WHERE clause matching the name and URI of filter, with its
+// arguments
+func redfishEndpointConditions(filter smd.RedfishEndpointFilter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if filter.Name != "" {
+		where = append(where, "contains(name, ?)")
+		args = append(args, filter.Name)
+	}
+	if filter.URI != "" {
+		where = append(where, "contains(uri, ?)")
+		args = append(args, filter.URI)
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
 }
 
-func (s *DuckDBStorage) DeleteComponentByXname(xname string) error {
-	query := "DELETE FROM components WHERE id = ?"
-	_, err := s.db.Exec(query, xname)
-	return err
+func (s *DuckDBStorage) CountRedfishEndpoints(filter smd.RedfishEndpointFilter) (int, error) {
+	where, args := redfishEndpointConditions(filter)
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM redfish_endpoints"+where, args...).Scan(&count)
+	return count, err
 }
 
-func (s *DuckDBStorage) UpdateComponentData(xnames []string, data map[string]interface{}) error {
-	setClauses := []string{}
-	args := []interface{}{}
-
-	for k, v := range data {
-		setClauses = append(setClauses, fmt.Sprintf("%s = ?", k))
-		args = append(args, v)
+func (s *DuckDBStorage) GetRedfishEndpoints(filter smd.RedfishEndpointFilter) ([]smd.RedfishEndpoint, error) {
+	where, args := redfishEndpointConditions(filter)
+	query := "SELECT id, name, uri, username, password FROM redfish_endpoints" + where + " ORDER BY id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, filter.Offset)
 	}
-	args = append(args, strings.Join(xnames, ","))
-
-	query := fmt.Sprintf("UPDATE components SET %s WHERE id IN (?)", strings.Join(setClauses, ", "))
-	_, err := s.db.Exec(query, args...)
-	return err
-}
 
-func (s *DuckDBStorage) GetRedfishEndpoints() ([]smd.RedfishEndpoint, error) {
-	query := "SELECT * FROM redfish_endpoints"
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -230,6 +469,10 @@ func (s *DuckDBStorage) GetRedfishEndpoints() ([]smd.RedfishEndpoint, error) {
 		if err := rows.Scan(&e.ID, &e.Name, &e.URI, &e.User, &e.Password); err != nil {
 			return nil, err
 		}
+		var err error
+		if e.Password, err = s.openPassword(e.Password); err != nil {
+			return nil, err
+		}
 		endpoints = append(endpoints, e)
 	}
 	return endpoints, nil
@@ -242,13 +485,18 @@ func (s *DuckDBStorage) GetRedfishEndpointByID(id string) (smd.RedfishEndpoint,
 	if err := row.Scan(&e.ID, &e.Name, &e.URI, &e.User, &e.Password); err != nil {
 		return e, err
 	}
-	return e, nil
+	var err error
+	e.Password, err = s.openPassword(e.Password)
+	return e, err
 }
 
 func (s *DuckDBStorage) CreateOrUpdateRedfishEndpoints(endpoints []smd.RedfishEndpoint) error {
 	for _, e := range endpoints {
 		var existingEndpoint smd.RedfishEndpoint
 		var err error
+		if e.Password, err = s.sealPassword(e.Password); err != nil {
+			return err
+		}
 		// Check if endpoint already exists by ID
 		if e.ID != "" {
 			existingEndpoint, err = s.GetRedfishEndpointByID(e.ID)
@@ -263,7 +511,7 @@ func (s *DuckDBStorage) CreateOrUpdateRedfishEndpoints(endpoints []smd.RedfishEn
 			query := `
 			UPDATE redfish_endpoints SET
 			name = ?,
-			url = ?,
+			uri = ?,
 			username = ?,
 			password = ?
 			WHERE id = ?`
@@ -274,7 +522,7 @@ func (s *DuckDBStorage) CreateOrUpdateRedfishEndpoints(endpoints []smd.RedfishEn
 		} else {
 			// If endpoint does not exist, create it
 			query := `
-			INSERT INTO redfish_endpoints (id, name, url, username, password)
+			INSERT INTO redfish_endpoints (id, name, uri, username, password)
 			VALUES (?, ?, ?, ?, ?)`
 			_, err := s.db.Exec(query, e.ID, e.Name, e.URI, e.User, e.Password)
 			if err != nil {
@@ -290,3 +538,58 @@ func (s *DuckDBStorage) DeleteRedfishEndpointByID(id string) error {
 	_, err := s.db.Exec(query, id)
 	return err
 }
+
+// CreateorUpdateRedfishDiscoveryLog stores a discovery attempt, replacing any earlier record
+// with the same UID.  The table has no unique index so that the replacement can happen in a
+// single transaction.
+func (s *DuckDBStorage) CreateorUpdateRedfishDiscoveryLog(discovery smd.RedfishDiscovery) error {
+	var err error
+	if discovery.Payload.Password, err = s.sealPassword(discovery.Payload.Password); err != nil {
+		return err
+	}
+	data, err := json.Marshal(discovery)
+	if err != nil {
+		return err
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM redfish_discovery WHERE uid = ?", discovery.UID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO redfish_discovery (uid, endpoint_id, uri, data) VALUES (?, ?, ?, ?)",
+			discovery.UID, discovery.URI, discovery.Payload.URI, string(data))
+		return err
+	})
+}
+
+func (s *DuckDBStorage) GetRedfishDiscoveryLogByEndpointID(id string) ([]smd.RedfishDiscovery, error) {
+	return s.queryRedfishDiscovery("SELECT data FROM redfish_discovery WHERE endpoint_id = ?", id)
+}
+
+func (s *DuckDBStorage) GetRedfishDiscoveryLogByURI(uri string) ([]smd.RedfishDiscovery, error) {
+	return s.queryRedfishDiscovery("SELECT data FROM redfish_discovery WHERE uri = ?", uri)
+}
+
+func (s *DuckDBStorage) queryRedfishDiscovery(query string, args ...interface{}) ([]smd.RedfishDiscovery, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discoveries []smd.RedfishDiscovery
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var discovery smd.RedfishDiscovery
+		if err := json.Unmarshal([]byte(data), &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.Payload.Password, err = s.openPassword(discovery.Payload.Password); err != nil {
+			return nil, err
+		}
+		discoveries = append(discoveries, discovery)
+	}
+	return discoveries, rows.Err()
+}
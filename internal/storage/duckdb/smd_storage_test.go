@@ -1,6 +1,8 @@
 package duckdb
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	_ "github.com/marcboeker/go-duckdb"
@@ -92,3 +94,173 @@ func TestCreateOrUpdateComponents(t *testing.T) {
 		t.Errorf("failed to create components: %v", err)
 	}
 }
+
+func TestCreateOrUpdateComponentsIsAtomic(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	components := []smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 1},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode, NID: 2},
+		// The nid column is a 32 bit INTEGER, so this NID can't be stored
+		{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 1 << 40},
+		{ID: "x1000c0s1b0n1", Type: smd.TypeNode, NID: 4},
+	}
+	if err := storage.CreateOrUpdateComponents(components); err == nil {
+		t.Fatalf("expected the batch to fail on the third component")
+	}
+
+	persisted, err := storage.GetComponents()
+	if err != nil {
+		t.Fatalf("failed to get components: %v", err)
+	}
+	if len(persisted) != 0 {
+		t.Errorf("expected no components to be persisted after a failed batch, got %d", len(persisted))
+	}
+}
+
+func TestCreateOrUpdateComponentsRejectsDuplicateNID(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	existing := []smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 1},
+		{ID: "x1000c0s0b0", Type: smd.TypeNodeBMC},
+		{ID: "x1000c0s1b0", Type: smd.TypeNodeBMC},
+	}
+	if err := storage.CreateOrUpdateComponents(existing); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+	// Updating a component keeps its own NID, and NID 0 is no NID at all
+	existing[0].State = smd.StateReady
+	if err := storage.CreateOrUpdateComponents(existing[:1]); err != nil {
+		t.Errorf("expected a component to keep its NID, got %v", err)
+	}
+
+	for _, batch := range [][]smd.Component{
+		{{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 1}},
+		{{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 2}, {ID: "x1000c0s1b0n1", Type: smd.TypeNode, NID: 2}},
+	} {
+		err := storage.CreateOrUpdateComponents(batch)
+		if !errors.Is(err, smd.ErrDuplicateNID) {
+			t.Errorf("expected %v for %+v, got %v", smd.ErrDuplicateNID, batch, err)
+		}
+	}
+	if err := storage.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s1b0n0", Type: smd.TypeNode, NID: 1}}); err == nil || !strings.Contains(err.Error(), "x1000c0s0b0n0") {
+		t.Errorf("expected the error to name the xname holding the NID, got %v", err)
+	}
+
+	persisted, err := storage.GetComponents()
+	if err != nil {
+		t.Fatalf("failed to get components: %v", err)
+	}
+	if len(persisted) != 3 {
+		t.Errorf("expected the refused batches to leave 3 components, got %d", len(persisted))
+	}
+}
+
+func TestUpdateComponentDataRejectsUnknownColumns(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("Failed to create DuckDBStorage: %v", err)
+	}
+	defer storage.Close()
+
+	if err := storage.CreateOrUpdateComponents([]smd.Component{{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: "On"}}); err != nil {
+		t.Fatalf("Failed to create component: %v", err)
+	}
+	for _, column := range []string{"uid", "id", "state = 'Off' --"} {
+		err := storage.UpdateComponentData([]string{"x1000c0s0b0n0"}, map[string]interface{}{column: "Off"})
+		if !errors.Is(err, smd.ErrUnknownComponentColumn) {
+			t.Errorf("expected ErrUnknownComponentColumn for %q, got %v", column, err)
+		}
+	}
+	if c, _ := storage.GetComponentByXname("x1000c0s0b0n0"); c.State != "On" {
+		t.Errorf("expected state to be unchanged, got %s", c.State)
+	}
+}
+
+func TestUpdateComponentDataRejectsDuplicateNID(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	if err := storage.CreateOrUpdateComponents([]smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, NID: 1},
+		{ID: "x1000c0s0b0n1", Type: smd.TypeNode},
+		{ID: "x1000c0s0b0n2", Type: smd.TypeNode},
+	}); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	for _, xnames := range [][]string{{"x1000c0s0b0n1"}, {"x1000c0s0b0n1", "x1000c0s0b0n2"}} {
+		nid := 1
+		if len(xnames) > 1 {
+			nid = 5
+		}
+		// JSON numbers arrive as float64
+		err := storage.UpdateComponentData(xnames, map[string]interface{}{"nid": float64(nid)})
+		if !errors.Is(err, smd.ErrDuplicateNID) {
+			t.Errorf("expected %v giving %v NID %d, got %v", smd.ErrDuplicateNID, xnames, nid, err)
+		}
+	}
+	for _, xname := range []string{"x1000c0s0b0n1", "x1000c0s0b0n2"} {
+		if c, _ := storage.GetComponentByXname(xname); c.NID != 0 {
+			t.Errorf("expected the refused updates to leave %s without a NID, got %d", xname, c.NID)
+		}
+	}
+
+	if err := storage.UpdateComponentData([]string{"x1000c0s0b0n1"}, map[string]interface{}{"nid": float64(2), "state": "Ready"}); err != nil {
+		t.Errorf("expected a free NID to be set, got %v", err)
+	}
+	if err := storage.UpdateComponentData([]string{"x1000c0s0b0n1", "x1000c0s0b0n2"}, map[string]interface{}{"nid": 0}); err != nil {
+		t.Errorf("expected NID 0 to be shared, got %v", err)
+	}
+}
+
+func TestSummarizeComponents(t *testing.T) {
+	storage, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	components := []smd.Component{
+		{ID: "x1000c0s0b0n0", Type: smd.TypeNode, State: smd.StateReady, Role: "Compute", Arch: "X86", Class: "River"},
+		{ID: "x1000c0s1b0n0", Type: smd.TypeNode, State: smd.StateReady, Role: "Compute", Arch: "ARM", Class: "River"},
+		{ID: "x1000c0s2b0n0", Type: smd.TypeNode, State: "Off", Role: "Management", Arch: "X86"},
+	}
+	if err := storage.CreateOrUpdateComponents(components); err != nil {
+		t.Fatalf("failed to create components: %v", err)
+	}
+
+	summary, err := storage.SummarizeComponents()
+	if err != nil {
+		t.Fatalf("failed to summarize components: %v", err)
+	}
+	if summary.Total != 3 {
+		t.Errorf("expected 3 components in total, got %d", summary.Total)
+	}
+	for name, got := range map[string][2]int{
+		"state Ready":     {summary.State[string(smd.StateReady)], 2},
+		"state Off":       {summary.State["Off"], 1},
+		"role Compute":    {summary.Role["Compute"], 2},
+		"role Management": {summary.Role["Management"], 1},
+		"arch X86":        {summary.Arch["X86"], 2},
+		"arch ARM":        {summary.Arch["ARM"], 1},
+		"class River":     {summary.Class["River"], 2},
+		"no class":        {summary.Class[""], 1},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("expected %d components with %s, got %d", got[1], name, got[0])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package duckdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SnapshotSchemaVersion is written to the VERSION file of every full snapshot.  Bump it
+// whenever a change to the tables would stop an older snapshot's schema.sql and load.sql
+// from restoring cleanly, and list the old version in migratableSnapshotVersions if
+// initTables brings such a restore up to date.
+//
+//	1: snapshots taken before VERSION files were written
+//	2: compute_nodes and bmcs gained updated_at
+//	3: compute_nodes gained hostname
+const SnapshotSchemaVersion = 3
+
+// snapshotVersionFile is the file in a snapshot directory that records its schema version
+const snapshotVersionFile = "VERSION"
+
+// migratableSnapshotVersions are older versions that restore correctly and are migrated
+// by initTables afterwards
+var migratableSnapshotVersions = map[int]bool{
+	1: true, // initTables adds the missing updated_at and hostname columns
+	2: true, // initTables adds and fills in the hostname column
+}
+
+// ErrSnapshotVersion is returned when restoring a snapshot whose schema version this build
+// cannot load
+var ErrSnapshotVersion = errors.New("unsupported snapshot schema version")
+
+func writeSnapshotVersion(dir string) error {
+	return os.WriteFile(filepath.Join(dir, snapshotVersionFile), []byte(strconv.Itoa(SnapshotSchemaVersion)+"\n"), 0644)
+}
+
+// checkSnapshotVersion reads the schema version of the snapshot in dir and fails unless it
+// can be restored.  Snapshots without a VERSION file predate it and are version 1.
+func checkSnapshotVersion(dir string) error {
+	version := 1
+	data, err := os.ReadFile(filepath.Join(dir, snapshotVersionFile))
+	switch {
+	case err == nil:
+		version, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("%w: cannot parse %s in %s: %v", ErrSnapshotVersion, snapshotVersionFile, dir, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if version == SnapshotSchemaVersion || migratableSnapshotVersions[version] {
+		return nil
+	}
+	return fmt.Errorf("%w: snapshot %s has schema version %d, this build restores version %d", ErrSnapshotVersion, dir, version, SnapshotSchemaVersion)
+}
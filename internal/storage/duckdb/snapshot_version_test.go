@@ -0,0 +1,73 @@
+package duckdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+func TestSnapshotWritesVersion(t *testing.T) {
+	path := t.TempDir()
+	d, err := NewDuckDBStorage("", WithSnapshotPath(path))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	nodeID := uuid.New()
+	if err := d.SaveComputeNode(nodeID, nodes.ComputeNode{ID: nodeID, Hostname: "nid001", Architecture: nodes.ArchX86_64}); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	dir, err := d.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, snapshotVersionFile))
+	if err != nil {
+		t.Fatalf("failed to read VERSION: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(SnapshotSchemaVersion) {
+		t.Errorf("VERSION = %q, want %d", got, SnapshotSchemaVersion)
+	}
+
+	restored, err := NewDuckDBStorage("", WithRestore(path))
+	if err != nil {
+		t.Fatalf("failed to restore storage: %v", err)
+	}
+	defer restored.Close()
+	if _, err := restored.GetComputeNode(nodeID); err != nil {
+		t.Errorf("expected a snapshot of the current version to restore, got %v", err)
+	}
+}
+
+func TestRestoreRejectsMismatchedVersion(t *testing.T) {
+	path := t.TempDir()
+	dir := filepath.Join(path, "2024-06-01T12-00-00")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	version := strconv.Itoa(SnapshotSchemaVersion + 1)
+	if err := os.WriteFile(filepath.Join(dir, snapshotVersionFile), []byte(version+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDuckDBStorage("")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	if err := d.RestoreParquet(dir); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("expected ErrSnapshotVersion restoring version %s, got %v", version, err)
+	}
+
+	if _, err := NewDuckDBStorage("", WithRestore(path)); !errors.Is(err, ErrInvalidOption) || !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("expected the storage to refuse to start from version %s, got %v", version, err)
+	}
+}
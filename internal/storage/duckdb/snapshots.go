@@ -3,6 +3,7 @@ package duckdb
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -11,9 +12,26 @@ import (
 	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/openchami/node-orchestrator/internal/storage"
 )
 
+// startSnapshotRoutine starts snapshotRoutine when both a snapshot frequency and a snapshot
+// path are configured.  A frequency of zero disables periodic snapshots.
+func (d *DuckDBStorage) startSnapshotRoutine() {
+	if d.snapshotFrequency <= 0 || d.snapshotPath == "" {
+		d.logger.Info().
+			Dur("frequency", d.snapshotFrequency).
+			Str("path", d.snapshotPath).
+			Msg("Periodic snapshots are disabled")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancelSnapshot = cancel
+	d.snapshotRunning = true
+	d.wg.Add(1)
+	go d.snapshotRoutine(ctx)
+}
+
 func (d *DuckDBStorage) snapshotRoutine(ctx context.Context) {
 	defer d.wg.Done()
 	ticker := time.NewTicker(d.snapshotFrequency)
@@ -22,54 +40,124 @@ func (d *DuckDBStorage) snapshotRoutine(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Info().Msg("Snapshot routine stopped")
+			d.logger.Info().Msg("Snapshot routine stopped")
 			return
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-			defer cancel()
-			if err := d.SnapshotParquet(ctx, d.snapshotPath); err != nil {
-				log.Error().Err(err).Msg("Error taking snapshot")
+			snapshotCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			_, err := d.periodicSnapshot(snapshotCtx)
+			cancel()
+			if errors.Is(err, ErrSnapshotInProgress) {
+				d.logger.Warn().Msg("Previous snapshot still running, skipping this one")
+			} else if err != nil {
+				d.logger.Error().Err(err).Msg("Error taking snapshot")
 			}
 		}
 	}
 }
 
+// snapshotDirFormat names the directory of each full snapshot after the time it was taken
+const snapshotDirFormat = "2006-01-02T15-04-05"
+
+// ErrSnapshotInProgress is returned by SnapshotParquet when another snapshot is still being written.
+var ErrSnapshotInProgress = storage.ErrSnapshotInProgress
+
 func (d *DuckDBStorage) SnapshotParquet(ctx context.Context, path string) error {
-	// Ensure the path is escaped properly
-	escapedPath := strings.ReplaceAll(path, "'", "''")
-	// Add a trailing slash if it is missing
-	if !strings.HasSuffix(escapedPath, "/") {
-		escapedPath += "/"
+	_, err := d.snapshotParquet(ctx, path)
+	return err
+}
+
+// Snapshot takes a snapshot under the configured snapshot path and returns its directory
+func (d *DuckDBStorage) Snapshot(ctx context.Context) (string, error) {
+	if d.snapshotPath == "" {
+		return "", storage.ErrNoSnapshotPath
 	}
-	// Add a date and time to the path
-	escapedPath += time.Now().Format("2006-01-02T15-04-05")
-	if !strings.HasSuffix(escapedPath, "/") {
-		escapedPath += "/"
+	return d.snapshotParquet(ctx, d.snapshotPath)
+}
+
+// LatestSnapshot returns the directory of the most recent snapshot under the configured snapshot path
+func (d *DuckDBStorage) LatestSnapshot() (string, error) {
+	if d.snapshotPath == "" {
+		return "", storage.ErrNoSnapshotPath
+	}
+	dir, err := findMostRecentSnapshotDir(d.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", storage.ErrNoSnapshot
+	}
+	return dir, err
+}
+
+// snapshotParquet exports the database to a new timestamped directory under path and returns it
+func (d *DuckDBStorage) snapshotParquet(ctx context.Context, path string) (string, error) {
+	// Snapshots that take longer than the snapshot frequency must not pile up
+	if !d.snapshotMu.TryLock() {
+		return "", ErrSnapshotInProgress
 	}
-	// Ensure the directory exists
-	os.MkdirAll(escapedPath, 0755)
+	defer d.snapshotMu.Unlock()
+	return d.exportDatabase(ctx, path)
+}
+
+// exportDatabase writes a full snapshot and makes it the base of the following incremental
+// snapshots.  The caller holds snapshotMu.
+func (d *DuckDBStorage) exportDatabase(ctx context.Context, path string) (string, error) {
+	started, err := d.snapshotClock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Add a date and time to the path
+	dir := filepath.Join(path, time.Now().Format(snapshotDirFormat))
+	// Ensure the directory exists.  A snapshot taken within the same second replaces the
+	// previous one, whose incrementals no longer apply.
+	os.RemoveAll(filepath.Join(dir, incrementalSnapshotDir))
+	os.MkdirAll(dir, 0755)
+	// Ensure the path is escaped properly
+	escapedPath := strings.ReplaceAll(dir, "'", "''") + "/"
 
-	// Construct the SQL statement
-	sql := fmt.Sprintf(`INSTALL parquet;
-	LOAD parquet;
-	EXPORT DATABASE '%s' (FORMAT PARQUET);`, escapedPath)
+	// Construct the SQL statement.  Parquet is installed by loadExtensions, installing it
+	// again here would need network access on every snapshot.
+	sql := fmt.Sprintf(`LOAD parquet;
+	EXPORT DATABASE '%s' (%s);`, escapedPath, d.parquetFormat())
 
 	// Execute the SQL statement with context
-	_, err := d.db.ExecContext(ctx, sql)
-	if err != nil {
-		log.Error().Err(err).Msg("Error exporting DuckDB database to Parquet format")
-		return err
+	if _, err := d.db.ExecContext(ctx, sql); err != nil {
+		d.logger.Error().Err(err).Msg("Error exporting DuckDB database to Parquet format")
+		return "", err
 	}
-	log.Info().
-		Str("path", escapedPath).
+	if err := writeSnapshotVersion(dir); err != nil {
+		return "", err
+	}
+	d.logger.Info().
+		Str("path", dir).
 		Msg("SnapshotParquet")
 
-	return nil
+	d.fullSnapshotDir = dir
+	d.lastSnapshotAt = started
+	d.incrementalsSinceFull = 0
+
+	if d.snapshotRetention > 0 {
+		// The snapshot is taken, so failing to prune is only worth a log
+		if err := d.pruneSnapshots(path, d.snapshotRetention); err != nil {
+			d.logger.Error().Err(err).Str("path", path).Msg("Error pruning old snapshots")
+		}
+	}
+	return dir, nil
+}
+
+// parquetFormat is the option list of the statements that write snapshot files
+func (d *DuckDBStorage) parquetFormat() string {
+	if d.snapshotCompression == "" {
+		return "FORMAT PARQUET"
+	}
+	return "FORMAT PARQUET, COMPRESSION " + d.snapshotCompression
 }
 
 func (d *DuckDBStorage) RestoreParquet(path string) error {
+	// Refuse snapshots whose schema would fail part way through the load
+	if err := checkSnapshotVersion(path); err != nil {
+		return err
+	}
 	// Load the appropriate extensions for our restore to work correctly
-	_, err := d.db.Exec(``)
+	_, err := d.db.Exec(`LOAD parquet`)
 	if err != nil {
 		return err
 	}
@@ -78,16 +166,20 @@ func (d *DuckDBStorage) RestoreParquet(path string) error {
 	if err := d.executeSQLFile(schemaFile); err != nil {
 		return fmt.Errorf("error executing schema.sql: %w", err)
 	}
-	log.Info().Str("file", schemaFile).Msg("Executed schema.sql")
+	d.logger.Info().Str("file", schemaFile).Msg("Executed schema.sql")
 
 	// Read and execute load.sql to load Parquet files
 	loadFile := filepath.Join(path, "load.sql")
 	if err := d.executeSQLFile(loadFile); err != nil {
 		return fmt.Errorf("error executing load.sql: %w", err)
 	}
-	log.Info().Str("file", loadFile).Msg("Executed load.sql")
+	d.logger.Info().Str("file", loadFile).Msg("Executed load.sql")
 
-	return nil
+	if err := d.applyIncrementalSnapshots(path); err != nil {
+		return err
+	}
+	// Snapshots taken before xnames were normalized hold them as they were sent
+	return d.withTx(normalizeXNames)
 }
 
 func (d *DuckDBStorage) executeSQLFile(filePath string) error {
@@ -119,7 +211,7 @@ func (d *DuckDBStorage) executeSQLFile(filePath string) error {
 }
 
 func (d *DuckDBStorage) restore(path string) error {
-	log.Info().Msg("Restoring snapshot")
+	d.logger.Info().Msg("Restoring snapshot")
 
 	// Find the most recent snapshot directory
 	snapshotDir, err := findMostRecentSnapshotDir(path)
@@ -136,32 +228,53 @@ func (d *DuckDBStorage) restore(path string) error {
 
 // findMostRecentSnapshotDir finds the most recent directory under the given path
 func findMostRecentSnapshotDir(path string) (string, error) {
-	entries, err := os.ReadDir(path)
+	dirs, err := snapshotDirs(path)
 	if err != nil {
 		return "", err
 	}
+	if len(dirs) == 0 {
+		return "", storage.ErrNoSnapshot
+	}
+	return dirs[0], nil
+}
 
-	var dirs []fs.FileInfo
-	for _, entry := range entries {
-		if entry.IsDir() {
-			info, err := entry.Info()
-			if err != nil {
-				return "", err
-			}
-			dirs = append(dirs, info)
-		}
+// snapshotDirs lists the snapshot directories under path, most recent first.  Directories
+// not named like a snapshot are left out, so that nothing else kept there is restored from
+// or pruned.
+func snapshotDirs(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(dirs) == 0 {
-		return "", fmt.Errorf("no snapshot directories found")
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(snapshotDirFormat, entry.Name()); err != nil {
+			continue
+		}
+		dirs = append(dirs, filepath.Join(path, entry.Name()))
 	}
 
-	// Sort directories by name (assuming they are named by date)
-	sort.Slice(dirs, func(i, j int) bool {
-		return dirs[i].Name() > dirs[j].Name() // descending order
-	})
+	// The names sort in the order the snapshots were taken
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	return dirs, nil
+}
 
-	// Return the most recent directory
-	mostRecentDir := filepath.Join(path, dirs[0].Name())
-	return mostRecentDir, nil
+// pruneSnapshots removes all but the keep most recent snapshot directories under path, along
+// with the incremental snapshots inside them
+func (d *DuckDBStorage) pruneSnapshots(path string, keep int) error {
+	dirs, err := snapshotDirs(path)
+	if err != nil || len(dirs) <= keep {
+		return err
+	}
+	for _, dir := range dirs[keep:] {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		d.logger.Info().Str("path", dir).Msg("Pruned old snapshot")
+	}
+	return nil
 }
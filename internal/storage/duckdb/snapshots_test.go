@@ -0,0 +1,91 @@
+package duckdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+)
+
+func TestSnapshotRetention(t *testing.T) {
+	const keep = 2
+	path := t.TempDir()
+	d, err := NewDuckDBStorage("", WithSnapshotPath(path), WithSnapshotRetention(keep))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+
+	// Snapshots are named by the second they are taken in, so the older ones are made up
+	for i := 0; i < keep+1; i++ {
+		if err := os.MkdirAll(filepath.Join(path, fmt.Sprintf("2024-01-01T00-00-0%d", i), incrementalSnapshotDir), 0755); err != nil {
+			t.Fatalf("failed to create old snapshot: %v", err)
+		}
+	}
+	// Directories that aren't snapshots are neither counted nor removed
+	for _, other := range []string{"lost+found", "backups"} {
+		if err := os.Mkdir(filepath.Join(path, other), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", other, err)
+		}
+	}
+	latest, err := d.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %v", err)
+	}
+
+	dirs, err := snapshotDirs(path)
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %v", err)
+	}
+	want := []string{latest, filepath.Join(path, "2024-01-01T00-00-02")}
+	if len(dirs) != keep || dirs[0] != want[0] || dirs[1] != want[1] {
+		t.Errorf("expected snapshots %v to be kept, got %v", want, dirs)
+	}
+	for _, other := range []string{"lost+found", "backups"} {
+		if _, err := os.Stat(filepath.Join(path, other)); err != nil {
+			t.Errorf("expected %s to be left alone: %v", other, err)
+		}
+	}
+
+	if _, err := NewDuckDBStorage("", WithSnapshotRetention(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for a negative retention, got %v", err)
+	}
+}
+
+func TestSnapshotCompression(t *testing.T) {
+	path := t.TempDir()
+	d, err := NewDuckDBStorage("", WithSnapshotPath(path), WithSnapshotCompression("ZSTD"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer d.Close()
+	nodeID := uuid.New()
+	if err := d.SaveComputeNode(nodeID, nodes.ComputeNode{ID: nodeID, Hostname: "nid001", Architecture: nodes.ArchX86_64}); err != nil {
+		t.Fatalf("failed to save node: %v", err)
+	}
+
+	dir, err := d.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*compute_nodes*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("expected one compute_nodes file, got %v", files)
+	}
+	var codec string
+	if err := d.db.QueryRow(`SELECT DISTINCT compression FROM parquet_metadata(?)`, files[0]).Scan(&codec); err != nil {
+		t.Fatalf("failed to read Parquet metadata: %v", err)
+	}
+	if codec != "ZSTD" {
+		t.Errorf("expected the snapshot compressed with ZSTD, got %s", codec)
+	}
+
+	if _, err := NewDuckDBStorage("", WithSnapshotCompression("lz5")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for an unknown codec, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package duckdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+)
+
+// The unique_keys table stands in for UNIQUE constraints on the xname columns of compute_nodes
+// and bmcs, and on the hostnames and NIDs of compute nodes.  DuckDB can neither update a uniquely indexed column nor re-insert a key deleted
+// earlier in the same transaction, so a node moving to another xname could only be saved with
+// a delete and an insert committed separately.  A key in a table of its own is released and
+// claimed inside the transaction that saves its row.
+const uniqueKeysTable = `CREATE TABLE IF NOT EXISTS unique_keys (kind TEXT, key TEXT, id UUID NOT NULL, PRIMARY KEY (kind, key))`
+
+// The kinds of key in unique_keys, named after the column they keep unique
+const (
+	nodeXNameKey    = "compute_nodes.xname"
+	nodeHostnameKey = "compute_nodes.hostname"
+	nodeNIDKey      = "compute_nodes.nid"
+	bmcXNameKey     = "bmcs.xname"
+)
+
+// uniqueKeyColumns are the columns unique_keys is filled from when it is rebuilt.  A column
+// may be an expression over the row.
+var uniqueKeyColumns = []struct{ kind, table, column string }{
+	{nodeXNameKey, "compute_nodes", "xname"},
+	{nodeHostnameKey, "compute_nodes", "hostname"},
+	{nodeNIDKey, "compute_nodes", "json_extract_string(data, '$.nid')"},
+	{bmcXNameKey, "bmcs", "xname"},
+}
+
+// claimKey makes key the key of kind held by id, releasing the one id held before.  An empty
+// key only releases.  A key held by another id is a conflict.
+func claimKey(tx *sql.Tx, kind string, id uuid.UUID, key string) error {
+	if key != "" {
+		var owner uuid.UUID
+		err := tx.QueryRow(`SELECT id FROM unique_keys WHERE kind = ? AND key = ?`, kind, key).Scan(&owner)
+		switch {
+		case err == nil && owner == id:
+			return nil
+		case err == nil:
+			return fmt.Errorf("%w: %s %q already belongs to %s", storage.ErrConflict, kind, key, owner)
+		case err != sql.ErrNoRows:
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM unique_keys WHERE kind = ? AND id = ?`, kind, id); err != nil {
+		return err
+	}
+	if key == "" {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO unique_keys (kind, key, id) VALUES (?, ?, ?)`, kind, key, id)
+	return err
+}
+
+// releaseKeys drops every key held by id, for a row that is being deleted
+func releaseKeys(tx *sql.Tx, id uuid.UUID) error {
+	_, err := tx.Exec(`DELETE FROM unique_keys WHERE id = ?`, id)
+	return err
+}
+
+// rebuildTable replaces table with a new one made by create and filled by inserts, inside tx.
+// The old table is renamed to <table>_old for the inserts to read from and dropped afterwards.
+// A fresh table has no deleted keys for DuckDB's indexes to trip over, so rows can be replaced
+// with the same keys in one transaction.
+func rebuildTable(tx *sql.Tx, table, create string, inserts ...string) error {
+	statements := []string{
+		`ALTER TABLE ` + sqlIdentifier(table) + ` RENAME TO ` + sqlIdentifier(table+"_old"),
+		create,
+	}
+	statements = append(statements, inserts...)
+	statements = append(statements, `DROP TABLE `+sqlIdentifier(table+"_old"))
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropXNameConstraint rebuilds table without the UNIQUE constraint its xname column had before
+// unique_keys took over
+func dropXNameConstraint(tx *sql.Tx, table, create string) error {
+	var constrained int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM duckdb_constraints()
+		WHERE table_name = ? AND constraint_type = 'UNIQUE' AND list_contains(constraint_column_names, 'xname')`, table).Scan(&constrained)
+	if err != nil || constrained == 0 {
+		return err
+	}
+	return rebuildTable(tx, table, create, `INSERT INTO `+table+` BY NAME SELECT * FROM `+table+`_old`)
+}
+
+// syncUniqueKeys rebuilds unique_keys from the rows of compute_nodes and bmcs, whose keys may
+// have been written by a restore or by a build that predates the table.  Where two rows share
+// a key only one of them gets it, and the clash is logged.
+func (d *DuckDBStorage) syncUniqueKeys(tx *sql.Tx) error {
+	selects := make([]string, len(uniqueKeyColumns))
+	for i, c := range uniqueKeyColumns {
+		selects[i] = fmt.Sprintf(`SELECT %s AS kind, %s AS key, id FROM %s WHERE %s IS NOT NULL AND %s <> ''`,
+			sqlString(c.kind), c.column, c.table, c.column, c.column)
+	}
+	keys := strings.Join(selects, " UNION ALL ")
+
+	rows, err := tx.Query(`SELECT kind, key, COUNT(*) FROM (` + keys + `) GROUP BY kind, key HAVING COUNT(*) > 1`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var kind, key string
+		var count int
+		if err := rows.Scan(&kind, &key, &count); err != nil {
+			rows.Close()
+			return err
+		}
+		d.logger.Warn().Str("kind", kind).Str("key", key).Int("rows", count).Msg("Rows share a key that should be unique")
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return rebuildTable(tx, "unique_keys", uniqueKeysTable,
+		`INSERT INTO unique_keys (kind, key, id) SELECT kind, key, min(id) FROM (`+keys+`) GROUP BY kind, key`)
+}
+
+// initUniqueKeys moves the tables of older databases and snapshots over to unique_keys and
+// brings unique_keys in line with the rows it guards
+func (d *DuckDBStorage) initUniqueKeys() error {
+	return d.withTx(func(tx *sql.Tx) error {
+		if err := dropXNameConstraint(tx, "compute_nodes", computeNodesTable); err != nil {
+			return err
+		}
+		if err := dropXNameConstraint(tx, "bmcs", bmcsTable); err != nil {
+			return err
+		}
+		if err := normalizeXNames(tx); err != nil {
+			return err
+		}
+		return d.syncUniqueKeys(tx)
+	})
+}
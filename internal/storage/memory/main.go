@@ -1,31 +1,69 @@
 package memory
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
 	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
+// InMemoryStorage keeps nodes and BMCs in maps, guarded by mu since the HTTP handlers use
+// it concurrently
 type InMemoryStorage struct {
+	mu         sync.RWMutex
 	nodes      map[uuid.UUID]nodes.ComputeNode
 	bmcEntries map[uuid.UUID]nodes.BMC
+	notes      map[uuid.UUID][]nodes.Note
+	lastNID    int // the highest NID handed out by AllocateNID
 }
 
+var _ storage.NodeStorage = (*InMemoryStorage)(nil)
+
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		nodes:      make(map[uuid.UUID]nodes.ComputeNode),
 		bmcEntries: make(map[uuid.UUID]nodes.BMC),
+		notes:      make(map[uuid.UUID][]nodes.Note),
 	}
 }
 
 func (s *InMemoryStorage) SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkNIDFree(nodeID, node.NID); err != nil {
+		return err
+	}
+	node.NormalizeXNames()
+	stamp(&node.CreatedAt, &node.UpdatedAt, s.nodes[nodeID].CreatedAt)
 	s.nodes[nodeID] = node
 	return nil
 }
 
+// stamp settles the timestamps of a save.  A replaced record keeps its creation time,
+// previous, and a missing timestamp is now.
+func stamp(createdAt, updatedAt *time.Time, previous time.Time) {
+	now := nodes.Timestamp(time.Now())
+	if !previous.IsZero() {
+		*createdAt = previous
+	} else if createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = now
+	}
+}
+
 func (s *InMemoryStorage) GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	node, ok := s.nodes[nodeID]
 	if !ok {
 		return nodes.ComputeNode{}, fmt.Errorf("ComputeNode not found")
@@ -34,15 +72,24 @@ func (s *InMemoryStorage) GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, e
 }
 
 func (s *InMemoryStorage) UpdateComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error {
-	_, ok := s.nodes[nodeID]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.nodes[nodeID]
 	if !ok {
 		return fmt.Errorf("ComputeNode not found")
 	}
+	if err := s.checkNIDFree(nodeID, node.NID); err != nil {
+		return err
+	}
+	node.NormalizeXNames()
+	stamp(&node.CreatedAt, &node.UpdatedAt, existing.CreatedAt)
 	s.nodes[nodeID] = node
 	return nil
 }
 
 func (s *InMemoryStorage) DeleteComputeNode(nodeID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	_, ok := s.nodes[nodeID]
 	if !ok {
 		return fmt.Errorf("ComputeNode not found")
@@ -51,12 +98,58 @@ func (s *InMemoryStorage) DeleteComputeNode(nodeID uuid.UUID) error {
 	return nil
 }
 
+// checkNIDFree fails with storage.ErrConflict when a node other than nodeID has nid.  The
+// caller holds mu.
+func (s *InMemoryStorage) checkNIDFree(nodeID uuid.UUID, nid int) error {
+	if nid <= 0 {
+		return nil
+	}
+	for id, node := range s.nodes {
+		if node.NID == nid && id != nodeID {
+			return fmt.Errorf("%w: NID %d already belongs to %s", storage.ErrConflict, nid, id)
+		}
+	}
+	return nil
+}
+
+// AllocateNID returns the next unused NID.  It remembers the NIDs it hands out, so that
+// concurrent creates get different NIDs before either node is saved.
+func (s *InMemoryStorage) AllocateNID() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nid := s.lastNID
+	for _, node := range s.nodes {
+		if node.NID > nid {
+			nid = node.NID
+		}
+	}
+	s.lastNID = nid + 1
+	return s.lastNID, nil
+}
+
 func (s *InMemoryStorage) SaveBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bmc.XName = bmc.XName.Normalize()
+	stamp(&bmc.CreatedAt, &bmc.UpdatedAt, s.bmcEntries[bmcID].CreatedAt)
 	s.bmcEntries[bmcID] = bmc
 	return nil
 }
 
+func (s *InMemoryStorage) SaveBMCs(bmcs []nodes.BMC) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, bmc := range bmcs {
+		bmc.XName = bmc.XName.Normalize()
+		stamp(&bmc.CreatedAt, &bmc.UpdatedAt, s.bmcEntries[bmc.ID].CreatedAt)
+		s.bmcEntries[bmc.ID] = bmc
+	}
+	return nil
+}
+
 func (s *InMemoryStorage) GetBMC(bmcID uuid.UUID) (nodes.BMC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	bmc, ok := s.bmcEntries[bmcID]
 	if !ok {
 		return nodes.BMC{}, fmt.Errorf("BMC not found")
@@ -65,49 +158,245 @@ func (s *InMemoryStorage) GetBMC(bmcID uuid.UUID) (nodes.BMC, error) {
 }
 
 func (s *InMemoryStorage) UpdateBMC(bmcID uuid.UUID, bmc nodes.BMC) error {
-	_, ok := s.bmcEntries[bmcID]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.bmcEntries[bmcID]
 	if !ok {
 		return fmt.Errorf("BMC not found")
 	}
+	bmc.XName = bmc.XName.Normalize()
+	stamp(&bmc.CreatedAt, &bmc.UpdatedAt, existing.CreatedAt)
 	s.bmcEntries[bmcID] = bmc
 	return nil
 }
 
 func (s *InMemoryStorage) DeleteBMC(bmcID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	_, ok := s.bmcEntries[bmcID]
 	if !ok {
-		return fmt.Errorf("BMC not found")
+		return fmt.Errorf("%w: BMC %s", storage.ErrNotFound, bmcID)
 	}
 	delete(s.bmcEntries, bmcID)
 	return nil
 }
 
 func (s *InMemoryStorage) LookupComputeNodeByXName(xname string) (nodes.ComputeNode, error) {
-	//Convert string to xname and compare
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Nodes are stored normalized, so padded and unpadded xnames match
+	xname = xnames.NewNodeXname(xname).Normalize().String()
 	for _, node := range s.nodes {
-		if (node.LocationString == xnames.NodeXname{Value: xname}.String()) {
+		if node.XName.String() == xname {
 			return node, nil
 		}
 	}
 	return nodes.ComputeNode{}, fmt.Errorf("ComputeNode not found")
 }
 
-func (s *InMemoryStorage) SearchComputeNodes(xname, hostname, arch, bootMAC, bmcMAC string) ([]nodes.ComputeNode, error) {
-	var nodes []nodes.ComputeNode
+func (s *InMemoryStorage) LookupComputeNodeByHostname(hostname string) (nodes.ComputeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, node := range s.nodes {
-		if (xname == "" || node.LocationString == xname) &&
-			(hostname == "" || node.Hostname == hostname) &&
-			(arch == "" || node.Architecture == arch) &&
-			(bootMAC == "" || node.BootMac == bootMAC) {
-			nodes = append(nodes, node)
+		if node.Hostname == hostname {
+			return node, nil
+		}
+	}
+	return nodes.ComputeNode{}, fmt.Errorf("ComputeNode not found")
+}
+
+func (s *InMemoryStorage) LookupComputeNodeByNID(nid int) (nodes.ComputeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, node := range s.nodes {
+		if node.NID == nid {
+			return node, nil
+		}
+	}
+	return nodes.ComputeNode{}, fmt.Errorf("ComputeNode not found")
+}
+
+func (s *InMemoryStorage) UpdateComputeNodeStatus(xname string, status nodes.ComputeNodeStatus) (nodes.ComputeNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	xname = xnames.NewNodeXname(xname).Normalize().String()
+	for id, node := range s.nodes {
+		if node.XName.String() == xname {
+			node.Status = status
+			node.UpdatedAt = nodes.Timestamp(time.Now())
+			s.nodes[id] = node
+			return node, nil
+		}
+	}
+	return nodes.ComputeNode{}, fmt.Errorf("%w: compute node %s", storage.ErrNotFound, xname)
+}
+
+func (s *InMemoryStorage) SearchComputeNodes(opts ...storage.NodeSearchOption) ([]nodes.ComputeNode, error) {
+	var found []nodes.ComputeNode
+	err := s.StreamComputeNodes(func(node nodes.ComputeNode) error {
+		found = append(found, node)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// StreamComputeNodes calls visit with each node matching opts in the order of their IDs.  The
+// matches are collected first, so visit may use the storage.
+func (s *InMemoryStorage) StreamComputeNodes(visit func(nodes.ComputeNode) error, opts ...storage.NodeSearchOption) error {
+	options := &storage.NodeSearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	s.mu.RLock()
+	var matches []nodes.ComputeNode
+	for _, node := range s.nodes {
+		if matchesSearch(node, options) {
+			matches = append(matches, node)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+	matches = matches[min(options.Offset, len(matches)):]
+	if options.Limit > 0 && options.Limit < len(matches) {
+		matches = matches[:options.Limit]
+	}
+	for _, node := range matches {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) CountMatchingComputeNodes(opts ...storage.NodeSearchOption) (int, error) {
+	options := &storage.NodeSearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, node := range s.nodes {
+		if matchesSearch(node, options) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// matchesSearch applies the same filters to node as the DuckDB search does in SQL
+func matchesSearch(node nodes.ComputeNode, options *storage.NodeSearchOptions) bool {
+	var bmcMAC string
+	var bmcID uuid.UUID
+	if node.BMC != nil {
+		bmcMAC = node.BMC.MACAddress
+		bmcID = node.BMC.ID
+	}
+
+	if options.XName != "" && node.XName.String() != options.XName {
+		return false
+	}
+	if options.XNames != nil && !slices.Contains(options.XNames, node.XName.String()) {
+		return false
+	}
+	if options.Hostname != "" && node.Hostname != options.Hostname {
+		return false
+	}
+	if options.NID > 0 && node.NID != options.NID {
+		return false
+	}
+	if options.Arch != "" && node.Architecture != options.Arch {
+		return false
+	}
+	if options.BootMAC != "" && node.BootMac != options.BootMAC {
+		return false
+	}
+	if options.BMCMAC != "" && bmcMAC != options.BMCMAC {
+		return false
+	}
+	if options.BMCID != uuid.Nil && bmcID != options.BMCID {
+		return false
+	}
+	if options.BootIPv4 != "" && node.BootIPv4Address != options.BootIPv4 {
+		return false
+	}
+	if options.BootIPv6 != "" && node.BootIPv6Address != options.BootIPv6 {
+		return false
+	}
+	if options.LifecycleState != "" && node.LifecycleState != options.LifecycleState {
+		return false
+	}
+	if options.NICFirmware != "" && !hasInterface(node, func(nic nodes.NetworkInterface) bool { return nic.FirmwareVersion == options.NICFirmware }) {
+		return false
+	}
+	if options.NICModel != "" && !hasInterface(node, func(nic nodes.NetworkInterface) bool { return nic.Model == options.NICModel }) {
+		return false
+	}
+	if options.NICMAC != "" && !hasInterface(node, func(nic nodes.NetworkInterface) bool { return strings.EqualFold(nic.MACAddress, options.NICMAC) }) {
+		return false
+	}
+	if !options.CreatedAfter.IsZero() && !node.CreatedAt.After(options.CreatedAfter) {
+		return false
+	}
+	if !options.UpdatedAfter.IsZero() && !node.UpdatedAt.After(options.UpdatedAfter) {
+		return false
+	}
+
+	// An empty field is missing, as it is to the DuckDB search whether or not it was written out
+	if options.MissingXName && node.XName.String() != "" {
+		return false
+	}
+	if options.MissingHostname && node.Hostname != "" {
+		return false
+	}
+	if options.MissingArch && node.Architecture != "" {
+		return false
+	}
+	if options.MissingBootMAC && node.BootMac != "" {
+		return false
+	}
+	if options.MissingBMCMAC && bmcMAC != "" {
+		return false
+	}
+	if options.MissingIPV4 && node.BootIPv4Address != "" {
+		return false
+	}
+	if options.MissingIPV6 && node.BootIPv6Address != "" {
+		return false
+	}
+
+	for key, value := range options.Labels {
+		if labelValue, ok := node.Labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// hasInterface reports whether any network interface of node matches
+func hasInterface(node nodes.ComputeNode, match func(nodes.NetworkInterface) bool) bool {
+	for _, nic := range node.NetworkInterfaces {
+		if match(nic) {
+			return true
 		}
 	}
-	return nodes, nil
+	return false
 }
 
 func (s *InMemoryStorage) LookupBMCByXName(xname string) (nodes.BMC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	xname = xnames.NewBMCXname(xname).Normalize().String()
 	for _, bmc := range s.bmcEntries {
-		if bmc.LocationString == xname {
+		if bmc.XName.String() == xname {
 			return bmc, nil
 		}
 	}
@@ -115,7 +404,13 @@ func (s *InMemoryStorage) LookupBMCByXName(xname string) (nodes.BMC, error) {
 }
 
 func (s *InMemoryStorage) LookupComputeNodeByMACAddress(mac string) (nodes.ComputeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, node := range s.nodes {
+		// DuckDB looks nodes up by their boot MAC
+		if node.BootMac == mac {
+			return node, nil
+		}
 		for _, iface := range node.NetworkInterfaces {
 			if iface.MACAddress == mac {
 				return node, nil
@@ -125,7 +420,25 @@ func (s *InMemoryStorage) LookupComputeNodeByMACAddress(mac string) (nodes.Compu
 	return nodes.ComputeNode{}, fmt.Errorf("ComputeNode not found")
 }
 
+func (s *InMemoryStorage) LookupComputeNodesByMACAddresses(macs []string) (map[string]nodes.ComputeNode, error) {
+	wanted := make(map[string]bool, len(macs))
+	for _, mac := range macs {
+		wanted[strings.ToLower(mac)] = true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := make(map[string]nodes.ComputeNode, len(macs))
+	for _, node := range s.nodes {
+		if mac := strings.ToLower(node.BootMac); wanted[mac] {
+			found[mac] = node
+		}
+	}
+	return found, nil
+}
+
 func (s *InMemoryStorage) LookupBMCByMACAddress(mac string) (nodes.BMC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, bmc := range s.bmcEntries {
 		if bmc.MACAddress == mac {
 			return bmc, nil
@@ -133,3 +446,77 @@ func (s *InMemoryStorage) LookupBMCByMACAddress(mac string) (nodes.BMC, error) {
 	}
 	return nodes.BMC{}, fmt.Errorf("BMC not found")
 }
+
+// AddNodeNote appends a note to those of the node with nodeID, which outlive the node
+func (s *InMemoryStorage) AddNodeNote(nodeID uuid.UUID, note nodes.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notes[nodeID] = append(s.notes[nodeID], note)
+	return nil
+}
+
+// GetNodeNotes returns a copy of the notes of the node with nodeID, oldest first
+func (s *InMemoryStorage) GetNodeNotes(nodeID uuid.UUID) ([]nodes.Note, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]nodes.Note(nil), s.notes[nodeID]...), nil
+}
+
+// SearchBMCs returns the BMCs matching opts in the order of their IDs
+func (s *InMemoryStorage) SearchBMCs(opts ...storage.BMCSearchOption) ([]nodes.BMC, error) {
+	options := &storage.BMCSearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	s.mu.RLock()
+	var matches []nodes.BMC
+	for _, bmc := range s.bmcEntries {
+		if matchesBMCSearch(bmc, options) {
+			matches = append(matches, bmc)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+	return matches, nil
+}
+
+// matchesBMCSearch applies the same filters to bmc as the DuckDB BMC search does in SQL
+func matchesBMCSearch(bmc nodes.BMC, options *storage.BMCSearchOptions) bool {
+	if options.XName != "" && bmc.XName.String() != options.XName {
+		return false
+	}
+	if options.XNamePrefix != "" && !strings.HasPrefix(bmc.XName.String(), options.XNamePrefix) {
+		return false
+	}
+	if options.MACAddress != "" && !strings.EqualFold(bmc.MACAddress, options.MACAddress) {
+		return false
+	}
+	if options.IPAddress != "" && bmc.IPv4Address != options.IPAddress && bmc.IPv6Address != options.IPAddress {
+		return false
+	}
+	return true
+}
+
+func (s *InMemoryStorage) CountComputeNodes() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.nodes), nil
+}
+
+func (s *InMemoryStorage) CountBMCs() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.bmcEntries), nil
+}
+
+// CountComponentsByState is always empty since the in-memory storage holds no SMD components
+func (s *InMemoryStorage) CountComponentsByState() (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+// Shutdown has nothing to flush, whatever is stored is lost
+func (s *InMemoryStorage) Shutdown(ctx context.Context) {}
@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+// TestConcurrentAccess is meant for go test -race, which reports the map accesses that
+// aren't guarded
+func TestConcurrentAccess(t *testing.T) {
+	s := NewInMemoryStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			xname := fmt.Sprintf("x1000c0s%db0n0", i)
+			node := nodes.ComputeNode{ID: uuid.New(), Hostname: fmt.Sprintf("nid%03d", i), XName: xnames.NewNodeXname(xname)}
+			bmc := nodes.BMC{ID: uuid.New(), MACAddress: fmt.Sprintf("de:ad:be:ef:00:%02x", i)}
+
+			s.SaveComputeNode(node.ID, node)
+			s.SaveBMC(bmc.ID, bmc)
+			s.GetComputeNode(node.ID)
+			s.LookupComputeNodeByXName(xname)
+			s.LookupComputeNodeByHostname(node.Hostname)
+			s.LookupBMCByMACAddress(bmc.MACAddress)
+			s.AllocateNID()
+			s.UpdateComputeNode(node.ID, node)
+			s.UpdateBMC(bmc.ID, bmc)
+			if i%2 == 0 {
+				s.DeleteComputeNode(node.ID)
+				s.DeleteBMC(bmc.ID)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(s.nodes) != 25 || len(s.bmcEntries) != 25 {
+		t.Errorf("expected 25 nodes and BMCs to remain, got %d and %d", len(s.nodes), len(s.bmcEntries))
+	}
+}
+
+func TestSearchComputeNodes(t *testing.T) {
+	s := NewInMemoryStorage()
+	full := nodes.ComputeNode{
+		ID:              uuid.New(),
+		Hostname:        "nid001",
+		NID:             1,
+		XName:           xnames.NewNodeXname("x1000c0s1b0n0"),
+		Architecture:    nodes.ArchX86_64,
+		BootMac:         "de:ad:be:ef:00:01",
+		BootIPv4Address: "10.0.0.1",
+		BMC:             &nodes.BMC{ID: uuid.New(), MACAddress: "de:ad:be:ef:10:01"},
+		Labels:          map[string]string{"rack": "A3"},
+		LifecycleState:  nodes.LifecycleFailed,
+		NetworkInterfaces: []nodes.NetworkInterface{
+			{InterfaceName: "eth0", Model: "ConnectX-6", FirmwareVersion: "20.31.1014"},
+			{InterfaceName: "eth1", Model: "E810", FirmwareVersion: "4.20", MACAddress: "de:ad:be:ef:00:11"},
+		},
+	}
+	bare := nodes.ComputeNode{ID: uuid.New(), Hostname: "nid002", BootIPv6Address: "fd00::2"}
+	s.SaveComputeNode(full.ID, full)
+	s.SaveComputeNode(bare.ID, bare)
+
+	tests := []struct {
+		name string
+		opts []storage.NodeSearchOption
+		want []uuid.UUID
+	}{
+		{"no filters", nil, []uuid.UUID{full.ID, bare.ID}},
+		{"xname", []storage.NodeSearchOption{storage.WithXName("x1000c0s1b0n0")}, []uuid.UUID{full.ID}},
+		{"hostname", []storage.NodeSearchOption{storage.WithHostname("nid002")}, []uuid.UUID{bare.ID}},
+		{"NID", []storage.NodeSearchOption{storage.WithNID(1)}, []uuid.UUID{full.ID}},
+		{"arch", []storage.NodeSearchOption{storage.WithArch(nodes.ArchX86_64)}, []uuid.UUID{full.ID}},
+		{"boot MAC", []storage.NodeSearchOption{storage.WithBootMAC("de:ad:be:ef:00:01")}, []uuid.UUID{full.ID}},
+		{"BMC MAC", []storage.NodeSearchOption{storage.WithBMCMAC("de:ad:be:ef:10:01")}, []uuid.UUID{full.ID}},
+		{"BMC ID", []storage.NodeSearchOption{storage.WithBMCID(full.BMC.ID)}, []uuid.UUID{full.ID}},
+		{"boot IPv6", []storage.NodeSearchOption{storage.WithBootIPv6("fd00::2")}, []uuid.UUID{bare.ID}},
+		{"lifecycle state", []storage.NodeSearchOption{storage.WithLifecycleState(nodes.LifecycleFailed)}, []uuid.UUID{full.ID}},
+		{"label", []storage.NodeSearchOption{storage.WithLabel("rack", "A3")}, []uuid.UUID{full.ID}},
+		{"wrong label", []storage.NodeSearchOption{storage.WithLabel("rack", "A4")}, nil},
+		{"NIC firmware", []storage.NodeSearchOption{storage.WithNICFirmware("4.20")}, []uuid.UUID{full.ID}},
+		{"NIC model and firmware", []storage.NodeSearchOption{storage.WithNICModel("ConnectX-6"), storage.WithNICFirmware("20.31.1014")}, []uuid.UUID{full.ID}},
+		{"other NIC firmware", []storage.NodeSearchOption{storage.WithNICFirmware("20.31.1015")}, nil},
+		{"NIC MAC", []storage.NodeSearchOption{storage.WithNICMAC("DE:AD:BE:EF:00:11")}, []uuid.UUID{full.ID}},
+		{"missing", []storage.NodeSearchOption{storage.WithMissingXName(), storage.WithMissingArch(), storage.WithMissingBootMAC(), storage.WithMissingBMCMAC(), storage.WithMissingIPV4()}, []uuid.UUID{bare.ID}},
+		{"missing IPv6", []storage.NodeSearchOption{storage.WithMissingIPV6()}, []uuid.UUID{full.ID}},
+		{"missing hostname", []storage.NodeSearchOption{storage.WithMissingHostname()}, nil},
+	}
+	if found, err := s.LookupComputeNodeByNID(1); err != nil || found.ID != full.ID {
+		t.Errorf("expected node %s for NID 1, got %v (%v)", full.ID, found.ID, err)
+	}
+	for _, tt := range tests {
+		found, err := s.SearchComputeNodes(tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: failed to search: %v", tt.name, err)
+		}
+		got := map[uuid.UUID]bool{}
+		for _, node := range found {
+			got[node.ID] = true
+		}
+		if len(found) != len(tt.want) {
+			t.Errorf("%s: expected %d nodes, got %d", tt.name, len(tt.want), len(found))
+			continue
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("%s: expected node %s in %v", tt.name, id, found)
+			}
+		}
+	}
+}
+
+func TestSearchComputeNodesPage(t *testing.T) {
+	s := NewInMemoryStorage()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		node := nodes.ComputeNode{ID: uuid.New(), Hostname: fmt.Sprintf("nid%03d", i)}
+		s.SaveComputeNode(node.ID, node)
+		ids = append(ids, node.ID.String())
+	}
+	sort.Strings(ids)
+
+	page, err := s.SearchComputeNodes(storage.WithPage(2, 3))
+	if err != nil || len(page) != 2 || page[0].ID.String() != ids[3] || page[1].ID.String() != ids[4] {
+		t.Errorf("expected the fourth and fifth nodes, got %v (%v)", page, err)
+	}
+	if page, err := s.SearchComputeNodes(storage.WithPage(0, 7)); err != nil || len(page) != 0 {
+		t.Errorf("expected no nodes past the end, got %v (%v)", page, err)
+	}
+	if count, err := s.CountMatchingComputeNodes(storage.WithHostname("nid001"), storage.WithPage(1, 1)); err != nil || count != 1 {
+		t.Errorf("expected the count to ignore the page, got %d (%v)", count, err)
+	}
+}
+
+func TestPaddedXNamesResolveToOneNode(t *testing.T) {
+	store := NewInMemoryStorage()
+	bmc := nodes.BMC{ID: uuid.New(), XName: xnames.NewBMCXname("x0001c0s0b0")}
+	node := nodes.ComputeNode{ID: uuid.New(), XName: xnames.NewNodeXname("x0001c0s0b0n0")}
+	store.SaveBMC(bmc.ID, bmc)
+	store.SaveComputeNode(node.ID, node)
+
+	for _, xname := range []string{"x1c0s0b0n0", "x0001c0s0b0n0"} {
+		if found, err := store.LookupComputeNodeByXName(xname); err != nil || found.ID != node.ID {
+			t.Errorf("expected node %s at %s, got %v (%v)", node.ID, xname, found.ID, err)
+		}
+		if found, err := store.SearchComputeNodes(storage.WithXName(xname)); err != nil || len(found) != 1 {
+			t.Errorf("expected a search for %s to find the node, got %v (%v)", xname, found, err)
+		}
+	}
+	if found, err := store.LookupBMCByXName("x1c0s0b0"); err != nil || found.ID != bmc.ID {
+		t.Errorf("expected BMC %s at x1c0s0b0, got %v (%v)", bmc.ID, found.ID, err)
+	}
+}
+
+func TestAllocateNIDConcurrent(t *testing.T) {
+	s := NewInMemoryStorage()
+	if err := s.SaveComputeNode(uuid.New(), nodes.ComputeNode{NID: 10}); err != nil {
+		t.Fatalf("SaveComputeNode failed: %v", err)
+	}
+
+	const allocations = 50
+	var wg sync.WaitGroup
+	results := make(chan int, allocations)
+	for i := 0; i < allocations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nid, err := s.AllocateNID()
+			if err != nil {
+				t.Errorf("AllocateNID failed: %v", err)
+				return
+			}
+			results <- nid
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[int]bool)
+	for nid := range results {
+		if seen[nid] || nid <= 10 {
+			t.Errorf("NID %d was allocated twice or below the saved NID 10", nid)
+		}
+		seen[nid] = true
+	}
+
+	if err := s.SaveComputeNode(uuid.New(), nodes.ComputeNode{NID: 10}); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("expected a conflict for a NID held by another node, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "errors"
+
+var (
+	// ErrSnapshotInProgress is returned when a snapshot is requested while another is still being written
+	ErrSnapshotInProgress = errors.New("snapshot already in progress")
+	// ErrNoSnapshotPath is returned by snapshot operations when no snapshot path is configured
+	ErrNoSnapshotPath = errors.New("no snapshot path configured")
+	// ErrNoSnapshot is returned when the snapshot path holds no snapshots
+	ErrNoSnapshot = errors.New("no snapshot found")
+)
@@ -1,35 +1,78 @@
 package storage
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/openchami/node-orchestrator/pkg/nodes"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
+// ErrNotFound is wrapped by the errors of storage methods that don't find what they are asked
+// to change
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is wrapped by the errors of storage methods refusing to give a record a key, such
+// as an xname, that already belongs to another record
+var ErrConflict = errors.New("conflict")
+
+// ErrNotImplemented is wrapped by the errors of backends that can't do what a method asks yet,
+// so that callers can tell a missing feature from a missing record
+var ErrNotImplemented = errors.New("not implemented by this storage backend")
+
 type NodeStorage interface {
 	SaveComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error
 	GetComputeNode(nodeID uuid.UUID) (nodes.ComputeNode, error)
 	UpdateComputeNode(nodeID uuid.UUID, node nodes.ComputeNode) error
 	DeleteComputeNode(nodeID uuid.UUID) error
+	// UpdateComputeNodeStatus replaces the status of the node with xname in one transaction,
+	// leaving the rest of the node as it is stored, and returns the updated node
+	UpdateComputeNodeStatus(xname string, status nodes.ComputeNodeStatus) (nodes.ComputeNode, error)
 
 	LookupComputeNodeByXName(xname string) (nodes.ComputeNode, error)
 	LookupComputeNodeByMACAddress(mac string) (nodes.ComputeNode, error)
+	// LookupComputeNodesByMACAddresses finds the nodes booting from any of macs in one go,
+	// keyed by their lower case boot MAC.  MACs without a node are left out.
+	LookupComputeNodesByMACAddresses(macs []string) (map[string]nodes.ComputeNode, error)
+	LookupComputeNodeByHostname(hostname string) (nodes.ComputeNode, error)
+	LookupComputeNodeByNID(nid int) (nodes.ComputeNode, error)
 	SearchComputeNodes(opts ...NodeSearchOption) ([]nodes.ComputeNode, error)
+	// CountMatchingComputeNodes counts the nodes SearchComputeNodes finds with opts, across
+	// every page
+	CountMatchingComputeNodes(opts ...NodeSearchOption) (int, error)
+	// StreamComputeNodes is SearchComputeNodes without holding every node in memory
+	StreamComputeNodes(visit func(nodes.ComputeNode) error, opts ...NodeSearchOption) error
+	AllocateNID() (int, error)
+	// AddNodeNote and GetNodeNotes keep the notes on a node apart from the node itself, so
+	// that they survive its replacement
+	AddNodeNote(nodeID uuid.UUID, note nodes.Note) error
+	GetNodeNotes(nodeID uuid.UUID) ([]nodes.Note, error)
 
 	SaveBMC(bmcID uuid.UUID, bmc nodes.BMC) error
+	// SaveBMCs stores new BMCs under their IDs, all of them or none
+	SaveBMCs(bmcs []nodes.BMC) error
 	GetBMC(bmcID uuid.UUID) (nodes.BMC, error)
 	UpdateBMC(bmcID uuid.UUID, bmc nodes.BMC) error
 	DeleteBMC(bmcID uuid.UUID) error
 
 	LookupBMCByXName(xname string) (nodes.BMC, error)
 	LookupBMCByMACAddress(mac string) (nodes.BMC, error)
+	SearchBMCs(opts ...BMCSearchOption) ([]nodes.BMC, error)
 }
 
 type NodeSearchOptions struct {
 	XName           string
+	XNames          []string
 	Hostname        string
+	NID             int
 	Arch            string
 	BootMAC         string
 	BMCMAC          string
+	BMCID           uuid.UUID
+	BootIPv4        string
+	BootIPv6        string
 	MissingXName    bool
 	MissingHostname bool
 	MissingArch     bool
@@ -37,13 +80,34 @@ type NodeSearchOptions struct {
 	MissingBMCMAC   bool
 	MissingIPV4     bool
 	MissingIPV6     bool
+	Labels          map[string]string
+	LifecycleState  nodes.LifecycleState
+	NICFirmware     string
+	NICModel        string
+	NICMAC          string
+	CreatedAfter    time.Time
+	UpdatedAfter    time.Time
+	// Limit and Offset page the matches, which are ordered by ID.  A Limit of 0 is no limit.
+	Limit  int
+	Offset int
 }
 
 type NodeSearchOption func(*NodeSearchOptions)
 
+// WithXName matches the node with the xname, padded or not
 func WithXName(xname string) NodeSearchOption {
 	return func(opts *NodeSearchOptions) {
-		opts.XName = xname
+		opts.XName = xnames.NewNodeXname(xname).Normalize().String()
+	}
+}
+
+// WithXNames matches the nodes with any of the xnames.  An empty list matches no nodes.
+func WithXNames(names []string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.XNames = make([]string, len(names))
+		for i, xname := range names {
+			opts.XNames[i] = xnames.NewNodeXname(xname).Normalize().String()
+		}
 	}
 }
 
@@ -53,6 +117,13 @@ func WithHostname(hostname string) NodeSearchOption {
 	}
 }
 
+// WithNID matches the node with the NID, which must be positive
+func WithNID(nid int) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.NID = nid
+	}
+}
+
 func WithArch(arch string) NodeSearchOption {
 	return func(opts *NodeSearchOptions) {
 		opts.Arch = arch
@@ -71,6 +142,25 @@ func WithBMCMAC(bmcMAC string) NodeSearchOption {
 	}
 }
 
+// WithBMCID matches the nodes linked to the BMC with the ID
+func WithBMCID(id uuid.UUID) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.BMCID = id
+	}
+}
+
+func WithBootIPv4(ip string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.BootIPv4 = ip
+	}
+}
+
+func WithBootIPv6(ip string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.BootIPv6 = ip
+	}
+}
+
 func WithMissingXName() NodeSearchOption {
 	return func(opts *NodeSearchOptions) {
 		opts.MissingXName = true
@@ -112,3 +202,103 @@ func WithMissingIPV6() NodeSearchOption {
 		opts.MissingIPV6 = true
 	}
 }
+
+// WithLifecycleState matches nodes in the lifecycle state
+func WithLifecycleState(state nodes.LifecycleState) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.LifecycleState = state
+	}
+}
+
+// WithNICFirmware matches nodes with any network interface on the firmware version, e.g. to
+// find the nodes whose NICs still need an update
+func WithNICFirmware(version string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.NICFirmware = version
+	}
+}
+
+// WithNICModel matches nodes with any network interface of the model
+func WithNICModel(model string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.NICModel = model
+	}
+}
+
+// WithNICMAC matches the nodes with a network interface on the MAC address, in any case
+func WithNICMAC(mac string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.NICMAC = strings.ToLower(mac)
+	}
+}
+
+// WithCreatedAfter matches nodes created after t
+func WithCreatedAfter(t time.Time) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.CreatedAfter = t
+	}
+}
+
+// WithUpdatedAfter matches nodes last saved after t, for clients syncing incrementally
+func WithUpdatedAfter(t time.Time) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.UpdatedAfter = t
+	}
+}
+
+// WithLabel matches nodes whose label key is value.  Several labels must all match.
+func WithLabel(key, value string) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[key] = value
+	}
+}
+
+// WithPage returns at most limit of the matches, skipping the first offset of them.  A limit
+// of 0 returns every match after offset.
+func WithPage(limit, offset int) NodeSearchOption {
+	return func(opts *NodeSearchOptions) {
+		opts.Limit = limit
+		opts.Offset = offset
+	}
+}
+
+// BMCSearchOptions filter a BMC search.  Every filter that is set must match.
+type BMCSearchOptions struct {
+	XName       string
+	XNamePrefix string
+	MACAddress  string
+	IPAddress   string
+}
+
+type BMCSearchOption func(*BMCSearchOptions)
+
+func WithBMCXName(xname string) BMCSearchOption {
+	return func(opts *BMCSearchOptions) {
+		opts.XName = xnames.NewBMCXname(xname).Normalize().String()
+	}
+}
+
+// WithBMCXNamePrefix matches BMCs whose xname starts with prefix, e.g. x1000c0 for the BMCs
+// of a chassis.  The cabinet is padded like stored xnames, so x1c0 finds x001c0s0b0.
+func WithBMCXNamePrefix(prefix string) BMCSearchOption {
+	return func(opts *BMCSearchOptions) {
+		opts.XNamePrefix = xnames.NormalizePrefix(prefix)
+	}
+}
+
+// WithBMCMACAddress matches the BMC with the MAC address, in any case
+func WithBMCMACAddress(mac string) BMCSearchOption {
+	return func(opts *BMCSearchOptions) {
+		opts.MACAddress = mac
+	}
+}
+
+// WithBMCIPAddress matches BMCs with the address as either their IPv4 or IPv6 address
+func WithBMCIPAddress(ip string) BMCSearchOption {
+	return func(opts *BMCSearchOptions) {
+		opts.IPAddress = ip
+	}
+}
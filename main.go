@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,11 +18,19 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/openchami/node-orchestrator/internal/api/admin"
 	"github.com/openchami/node-orchestrator/internal/api/openchami"
 	"github.com/openchami/node-orchestrator/internal/api/smd"
+	"github.com/openchami/node-orchestrator/internal/certs"
+	"github.com/openchami/node-orchestrator/internal/events"
+	"github.com/openchami/node-orchestrator/internal/metrics"
+	"github.com/openchami/node-orchestrator/internal/secrets"
 	"github.com/openchami/node-orchestrator/internal/storage"
+	"github.com/openchami/node-orchestrator/internal/storage/csm"
 	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
 	openchami_middleware "github.com/openchami/node-orchestrator/pkg/middleware"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -31,9 +42,36 @@ var (
 	snapshotPath      = serveCmd.String("dir", "snapshots/", "directory to store snapshots")
 	schemaPath        = schemaCmd.String("dir", "schemas/", "directory to store JSON schemas")
 	snapshotFreq      = serveCmd.Duration("snapshot-freq", 60*time.Minute, "frequency to take snapshots. 0 disables snapshots")
+	snapshotFullEvery = serveCmd.Int("snapshot-full-every", 0, "take a full snapshot every n snapshots and incremental ones in between. 0 or 1 takes only full snapshots")
+	snapshotRetention = serveCmd.Int("snapshot-retention", 0, "keep only the n most recent full snapshots, with their incrementals. 0 keeps them all")
+	snapshotCodec     = serveCmd.String("snapshot-compression", "", "Parquet codec to compress snapshots with, one of "+strings.Join(duckdb.ParquetCodecs, ", ")+". Empty uses DuckDB's default")
+	snapshotFreqForce = serveCmd.Bool("snapshot-freq-force", false, "allow snapshot frequencies below the minimum of "+duckdb.MinSnapshotFrequency.String())
 	snapshotDirCreate = serveCmd.Bool("snapshot-dir", true, "create snapshot directory if it doesn't exist")
 	initTables        = serveCmd.Bool("init-tables", false, "initialize tables in the database")
 	restoreSnapshot   = serveCmd.Bool("restore", true, "restore from snapshot on startup")
+	relaxedXnames     = serveCmd.Bool("relaxed-xnames", false, "accept xnames with 1 or 2 digit cabinet numbers")
+	schemaRelaxed     = schemaCmd.Bool("relaxed-xnames", false, "generate xname patterns that accept 1 or 2 digit cabinet numbers")
+	lenientMembers    = serveCmd.Bool("lenient-collection-nodes", false, "accept collections listing xnames that no node has, with a warning, instead of refusing them")
+	strictComponents  = serveCmd.Bool("strict-component-ids", false, "reject SMD components whose ID is not a node or BMC xname")
+	redirectSlashes   = serveCmd.Bool("redirect-slashes", false, "redirect requests with a trailing slash instead of serving them as if it were absent")
+	rateLimitRPS      = serveCmd.Int("rate-limit-rps", 0, "requests per second allowed to each client on protected routes. 0 disables rate limiting")
+	rateLimitBurst    = serveCmd.Int("rate-limit-burst", 20, "burst size for the rate limit on protected routes")
+	secretKeyFile     = serveCmd.String("secret-key-file", "", "file holding a base64 encoded AES key used to encrypt BMC and Redfish passwords at rest")
+	tlsCert           = serveCmd.String("tls-cert", "", "PEM certificate to serve HTTPS with. Requires -tls-key and is reloaded on SIGHUP")
+	tlsKey            = serveCmd.String("tls-key", "", "PEM private key for -tls-cert")
+	maxBodySize       = serveCmd.Int64("max-body-size", openchami_middleware.DefaultMaxBodyBytes, "largest request body accepted, in bytes")
+	listenAddr        = serveCmd.String("listen", ":8080", "address to serve the API on")
+	jwtSecret         = serveCmd.String("jwt-secret", "secret", "HS256 secret that JWTs are verified with")
+	corsOrigins       = serveCmd.String("cors-origins", "", "comma separated origins allowed to call the API from a browser, or * for any. Empty disables CORS")
+	corsMethods       = serveCmd.String("cors-methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS", "comma separated methods allowed for cross-origin requests")
+	corsHeaders       = serveCmd.String("cors-headers", "Authorization,Content-Type", "comma separated request headers allowed for cross-origin requests")
+	corsCredentials   = serveCmd.Bool("cors-credentials", false, "allow cross-origin requests with credentials, answering with the requesting origin. Cannot be combined with -cors-origins *")
+	csmURL            = serveCmd.String("csm-url", "", "base URI of the CSM API that -storage csm serves the inventory from and POST /admin/sync/csm pushes the nodes to. Empty disables the sync")
+	csmJWT            = serveCmd.String("csm-jwt", "", "JWT to authenticate to CSM with")
+	csmTimeout        = serveCmd.Duration("csm-timeout", csm.DefaultTimeout, "time allowed for each request to CSM, including reading the response")
+	slowQuery         = serveCmd.Duration("slow-query-threshold", duckdb.DefaultSlowQueryThreshold, "log DuckDB statements that take longer than this at WARN. 0 disables slow query logging")
+	storageBackend    = serveCmd.String("storage", "duckdb", "storage backend, duckdb, memory or csm. memory keeps nothing across restarts, serves no SMD or bundle routes and ignores the DuckDB and snapshot flags. csm is experimental")
+	dbPath            = serveCmd.String("db", "data.db", "DuckDB database file, created along with its directory if missing. "+duckdb.MemoryPath+" keeps the database in memory")
 )
 
 type Config struct {
@@ -62,9 +100,16 @@ func main() {
 	switch os.Args[1] {
 	case "serve":
 		serveCmd.Parse(os.Args[2:])
+		sources, err := resolveEnv(serveCmd, os.LookupEnv)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error reading configuration from the environment")
+		}
+		logConfig(serveCmd, sources)
+		xnames.SetRelaxedCabinetDigits(*relaxedXnames)
 		serveAPI(logger)
 	case "schemas":
 		schemaCmd.Parse(os.Args[2:])
+		xnames.SetRelaxedCabinetDigits(*schemaRelaxed)
 		generateAndWriteSchemas(*schemaPath)
 	default:
 		fmt.Println("expected 'serve' or 'schemas' subcommands")
@@ -74,58 +119,117 @@ func main() {
 
 func serveAPI(logger zerolog.Logger) {
 	// Create a new token authenticator
-	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil, jwt.WithAcceptableSkew(30*time.Second))
+	tokenAuth := jwtauth.New("HS256", []byte(*jwtSecret), nil, jwt.WithAcceptableSkew(30*time.Second))
+	var authMiddleware = []func(http.Handler) http.Handler{
+		jwtauth.Verifier(tokenAuth),
+		openchami_middleware.AuthenticatorWithRequiredClaims(tokenAuth, []string{"sub", "iss", "aud"}),
+		// Every change goes through the auth middlewares, so this is where it gets audited
+		openchami_middleware.Audit(logger),
+	}
+
+	myStorage, err := newStorage(logger)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error creating storage")
+	}
+
+	// Inventory counts are cached so that scrapes don't query DuckDB every time
+	orchestratorMetrics := metrics.New(myStorage, 5*time.Second)
+
 	// Create a new chi router
 	r := chi.NewRouter()
 	// Add middleware to the router
 	r.Use(middleware.RequestID)
-	r.Use(openchami_middleware.OpenCHAMILogger(logger))
+	if *redirectSlashes {
+		r.Use(middleware.RedirectSlashes)
+	}
+	r.Use(openchami_middleware.OpenCHAMILogger(logger, orchestratorMetrics.ObserveRequest))
 	r.Use(middleware.Recoverer)
+	// CORS answers preflights before any route or authentication sees them
+	if origins := splitList(*corsOrigins); len(origins) > 0 {
+		corsOptions := openchami_middleware.CORSOptions{
+			AllowedOrigins:   origins,
+			AllowedMethods:   splitList(*corsMethods),
+			AllowedHeaders:   splitList(*corsHeaders),
+			AllowCredentials: *corsCredentials,
+			MaxAge:           10 * time.Minute,
+		}
+		if err := corsOptions.Validate(); err != nil {
+			log.Fatal().Err(err).Msg("Invalid CORS configuration")
+		}
+		r.Use(openchami_middleware.CORS(corsOptions))
+	}
+	r.Use(openchami_middleware.MaxBodySize(*maxBodySize))
 
-	var authMiddleware = []func(http.Handler) http.Handler{
-		jwtauth.Verifier(tokenAuth),
-		openchami_middleware.AuthenticatorWithRequiredClaims(tokenAuth, []string{"sub", "iss", "aud"}),
+	// Clients are rate limited by IP before authentication, so that floods of requests with bad
+	// tokens are limited too, and by JWT subject after it
+	protectedMiddleware := authMiddleware
+	if *rateLimitRPS > 0 {
+		protectedMiddleware = append([]func(http.Handler) http.Handler{openchami_middleware.RateLimitByIP(*rateLimitRPS, *rateLimitBurst)}, authMiddleware...)
+		protectedMiddleware = append(protectedMiddleware, openchami_middleware.RateLimit(*rateLimitRPS, *rateLimitBurst))
 	}
+	// Changes made through the inventory routes are streamed to the clients of /events
+	broker := events.NewBroker()
+	r.Mount("/inventory", openchami.NodeRoutes(myStorage, protectedMiddleware,
+		openchami.WithEvents(broker), openchami.WithLenientCollectionNodes(*lenientMembers)))
+	r.Mount("/events", openchami.EventRoutes(broker))
 
-	// Initialize the storage backend options
-	var options []duckdb.DuckDBStorageOption
-	if serveCmd.Parsed() {
-		if *initTables {
-			options = append(options, duckdb.WithInitTables(*initTables))
-		}
-		if *snapshotPath != "" {
-			log.Info().Msg("Adding the storage option to specify a snapshot path")
-			options = append(options, duckdb.WithSnapshotPath(*snapshotPath))
-			if *snapshotDirCreate {
-				log.Info().Msg("Adding the storage option to create the snapshot directory if it doesn't exist")
-				options = append(options, duckdb.WithCreateSnapshotDir(*snapshotDirCreate))
-			}
-			if *snapshotFreq > time.Duration(0) {
-				log.Info().Msg("Adding the storage option to snapshot regularly")
-				options = append(options, duckdb.WithSnapshotFrequency(*snapshotFreq))
-			}
-			if *restoreSnapshot {
-				log.Info().Msg("Adding the storage option to restore from snapshot on startup")
-				options = append(options, duckdb.WithRestore(*snapshotPath))
-			}
-		}
+	// CSM Routes
+	if smdStorage, ok := myStorage.(smdBackend); ok {
+		r.Mount("/smd", smd.SMDComponentRoutes(smdStorage, protectedMiddleware, smd.WithStrictComponentIDs(*strictComponents)))
+		r.Mount("/smd/Inventory/RedfishEndpoints", smd.RedfishEndpointRoutes(smdStorage, protectedMiddleware))
+	} else {
+		log.Warn().Str("storage", *storageBackend).Msg("Storage backend holds no SMD components, not serving /smd")
 	}
 
-	myStorage, err := duckdb.NewDuckDBStorage("data.db", options...)
+	// Admin Routes
+	var adminOptions []admin.RouterOption
+	if *csmURL != "" {
+		adminOptions = append(adminOptions, admin.WithCSMSync(myStorage, csm.NewCSMStorage(*csmURL, *csmJWT, *csmTimeout)))
+	}
+	bundles, _ := myStorage.(admin.BundleStorage)
+	r.Mount("/admin", admin.AdminRoutes(r, bundles, protectedMiddleware, adminOptions...))
+
+	// JSON schemas of the models, generated once
+	schemas, err := generateSchemas()
 	if err != nil {
-		if err.Error() == "no snapshot found" {
-			log.Warn().Msg("No snapshot found, starting with empty database")
-		} else {
-			log.Fatal().Err(err).Msg("Error creating storage")
-		}
+		log.Fatal().Err(err).Msg("Error generating JSON schemas")
 	}
+	r.Mount("/schemas", schemaRoutes(schemas))
 
-	r.Mount("/inventory", openchami.NodeRoutes(myStorage, authMiddleware))
+	// Build information for tracking which version is deployed where
+	r.Get("/version", versionHandler(buildVersionInfo()))
 
-	// CSM Routes
-	r.Mount("/smd", smd.SMDComponentRoutes(myStorage, authMiddleware))
+	// Prometheus metrics
+	r.Method(http.MethodGet, "/metrics", orchestratorMetrics.Handler())
+
+	server := &http.Server{Addr: *listenAddr, Handler: r}
+	// Event streams never finish on their own, so end them when the server shuts down
+	server.RegisterOnShutdown(broker.Close)
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal().Msg("-tls-cert and -tls-key must be given together")
+	}
+	if *tlsCert != "" {
+		reloader, err := certs.NewReloader(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error loading TLS certificate")
+		}
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
 
-	log.Info().Msg("Starting server on :8080")
+		// Rotate the certificate without a restart
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					log.Error().Err(err).Msg("Error reloading TLS certificate, keeping the current one")
+					continue
+				}
+				log.Info().Str("cert", *tlsCert).Msg("Reloaded TLS certificate")
+			}
+		}()
+	}
+
+	log.Info().Bool("tls", server.TLSConfig != nil).Msg("Starting server on " + server.Addr)
 	chi.Walk(r, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
 		fmt.Printf("[%s]: '%s' has %d middlewares\n", method, route, len(middlewares))
 		return nil
@@ -137,7 +241,14 @@ func serveAPI(logger zerolog.Logger) {
 
 	// Start the HTTP server
 	go func() {
-		if err := http.ListenAndServe(":8080", r); err != nil {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal().Err(err).Msg("HTTP server failed")
 		}
 	}()
@@ -150,6 +261,101 @@ func serveAPI(logger zerolog.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Drain in-flight requests before the storage goes away underneath them.  The storage
+	// layer leaves signal handling to us so that this ordering holds.
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Error draining HTTP server")
+	}
+
 	// Call the storage shutdown method
 	myStorage.Shutdown(ctx)
 }
+
+// inventoryStorage is what serveAPI needs of a storage backend.  Backends that also implement
+// smdBackend or admin.BundleStorage serve the SMD and bundle routes as well.
+type inventoryStorage interface {
+	storage.NodeStorage
+	metrics.InventoryCounter
+	Shutdown(ctx context.Context)
+}
+
+type smdBackend interface {
+	smd.SMDStorage
+	smd.RedfishEndpointStorage
+}
+
+// newStorage creates the storage backend selected with -storage.  The in-memory backend
+// starts empty, keeps nothing across restarts and ignores the DuckDB flags.  The CSM backend
+// reads and writes the CSM API, as far as it is implemented.
+func newStorage(logger zerolog.Logger) (inventoryStorage, error) {
+	switch *storageBackend {
+	case "duckdb":
+		myStorage, err := duckdb.NewDuckDBStorage(*dbPath, duckDBOptions(logger)...)
+		if err != nil {
+			return nil, err
+		}
+		return myStorage, nil
+	case "memory":
+		log.Warn().Msg("Using in-memory storage, the inventory will be lost when the server stops")
+		return memory.NewInMemoryStorage(), nil
+	case "csm":
+		if *csmURL == "" {
+			return nil, errors.New("-storage csm requires -csm-url")
+		}
+		log.Warn().Str("csm_url", *csmURL).Msg("CSM storage is experimental, most inventory routes answer 501 Not Implemented")
+		return csm.NewNodeStorage(*csmURL, *csmJWT, *csmTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected duckdb, memory or csm", *storageBackend)
+	}
+}
+
+// duckDBOptions turns the serve flags into DuckDB storage options
+func duckDBOptions(logger zerolog.Logger) []duckdb.DuckDBStorageOption {
+	options := []duckdb.DuckDBStorageOption{duckdb.WithLogger(logger)}
+	if serveCmd.Parsed() {
+		if *initTables {
+			options = append(options, duckdb.WithInitTables(*initTables))
+		}
+		options = append(options, duckdb.WithSlowQueryThreshold(*slowQuery))
+		if *secretKeyFile != "" {
+			key, err := secrets.LoadKeyFile(*secretKeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Error loading secret key")
+			}
+			options = append(options, duckdb.WithSecretKey(key))
+		} else {
+			log.Warn().Msg("No secret key file configured, passwords will be stored in plaintext")
+		}
+		if *snapshotPath != "" {
+			log.Info().Msg("Adding the storage option to specify a snapshot path")
+			options = append(options, duckdb.WithSnapshotPath(*snapshotPath))
+			if *snapshotDirCreate {
+				log.Info().Msg("Adding the storage option to create the snapshot directory if it doesn't exist")
+				options = append(options, duckdb.WithCreateSnapshotDir(*snapshotDirCreate))
+			}
+			if *snapshotFreq != time.Duration(0) {
+				log.Info().Msg("Adding the storage option to snapshot regularly")
+				if *snapshotFreqForce {
+					options = append(options, duckdb.WithForcedSnapshotFrequency(*snapshotFreq))
+				} else {
+					options = append(options, duckdb.WithSnapshotFrequency(*snapshotFreq))
+				}
+				if *snapshotFullEvery > 1 {
+					log.Info().Int("full_every", *snapshotFullEvery).Msg("Adding the storage option to take incremental snapshots between full ones")
+					options = append(options, duckdb.WithFullSnapshotEvery(*snapshotFullEvery))
+				}
+			}
+			if *snapshotRetention > 0 {
+				options = append(options, duckdb.WithSnapshotRetention(*snapshotRetention))
+			}
+			if *snapshotCodec != "" {
+				options = append(options, duckdb.WithSnapshotCompression(*snapshotCodec))
+			}
+			if *restoreSnapshot {
+				log.Info().Msg("Adding the storage option to restore from snapshot on startup")
+				options = append(options, duckdb.WithRestore(*snapshotPath))
+			}
+		}
+	}
+	return options
+}
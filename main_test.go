@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/openchami/node-orchestrator/internal/api/admin"
+	"github.com/openchami/node-orchestrator/internal/storage/duckdb"
+	"github.com/openchami/node-orchestrator/internal/storage/memory"
+	"github.com/rs/zerolog"
+)
+
+func TestNewStorage(t *testing.T) {
+	backend, path := *storageBackend, *dbPath
+	defer func() { *storageBackend, *dbPath = backend, path }()
+	*dbPath = duckdb.MemoryPath
+
+	*storageBackend = "memory"
+	myStorage, err := newStorage(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create in-memory storage: %v", err)
+	}
+	if _, ok := myStorage.(*memory.InMemoryStorage); !ok {
+		t.Errorf("expected in-memory storage, got %T", myStorage)
+	}
+	if _, ok := myStorage.(smdBackend); ok {
+		t.Error("expected in-memory storage to serve no SMD routes")
+	}
+
+	*storageBackend = "duckdb"
+	myStorage, err = newStorage(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create DuckDB storage: %v", err)
+	}
+	defer myStorage.(*duckdb.DuckDBStorage).Close()
+	if _, ok := myStorage.(smdBackend); !ok {
+		t.Error("expected DuckDB storage to serve the SMD routes")
+	}
+	if _, ok := myStorage.(admin.BundleStorage); !ok {
+		t.Error("expected DuckDB storage to serve the bundle routes")
+	}
+
+	*storageBackend = "csm"
+	if _, err := newStorage(zerolog.Nop()); err == nil {
+		t.Error("expected an error for CSM storage without a base URI")
+	}
+	baseURI := *csmURL
+	defer func() { *csmURL = baseURI }()
+	*csmURL = "https://api.example.com/"
+	myStorage, err = newStorage(zerolog.Nop())
+	if err != nil {
+		t.Fatalf("failed to create CSM storage: %v", err)
+	}
+	if _, ok := myStorage.(smdBackend); ok {
+		t.Error("expected CSM storage to serve no SMD routes")
+	}
+
+	*storageBackend = "postgres"
+	if _, err := newStorage(zerolog.Nop()); err == nil {
+		t.Error("expected an error for an unknown storage backend")
+	}
+}
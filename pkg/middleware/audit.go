@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/rs/zerolog"
+)
+
+// auditedMethods are the methods that change the inventory.  Reads are not audited.
+var auditedMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Audit writes an audit record to logger for every successful mutating request: the subject
+// of the request's JWT, the method, the matched route with its URL parameters, which identify
+// the resource, and the response status.  It reads the token that jwtauth.Verifier put in the
+// context, so it belongs in the auth middlewares after the verifier.
+func Audit(logger zerolog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auditedMethods[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				// Nothing was written, which net/http answers with a 200
+				status = http.StatusOK
+			}
+			if status < 200 || status > 299 {
+				return
+			}
+
+			var subject string
+			if token, _, err := jwtauth.FromContext(r.Context()); err == nil && token != nil {
+				subject = token.Subject()
+			}
+			resource := zerolog.Dict()
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					route = pattern
+				}
+				for i, key := range rctx.URLParams.Keys {
+					if key != "*" {
+						resource.Str(key, rctx.URLParams.Values[i])
+					}
+				}
+			}
+
+			logger.Info().
+				Str("event_type", "audit").
+				Str("subject", subject).
+				Str("method", r.Method).
+				Str("route", route).
+				Str("path", r.URL.Path).
+				Dict("resource", resource).
+				Int("status_code", status).
+				Str("request_id", middleware.GetReqID(r.Context())).
+				Msg("Audit")
+		})
+	}
+}
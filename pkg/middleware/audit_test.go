@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/rs/zerolog"
+)
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth), Audit(zerolog.New(&buf)))
+	r.Put("/ComputeNode/{nodeID}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/ComputeNode/{nodeID}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Delete("/ComputeNode/{nodeID}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": "alice"})
+	request := func(method string) {
+		req := httptest.NewRequest(method, "/ComputeNode/1234", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request(http.MethodPut)
+	var record struct {
+		EventType string            `json:"event_type"`
+		Subject   string            `json:"subject"`
+		Method    string            `json:"method"`
+		Route     string            `json:"route"`
+		Resource  map[string]string `json:"resource"`
+		Status    int               `json:"status_code"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one audit record, got %q: %v", buf.String(), err)
+	}
+	if record.EventType != "audit" || record.Subject != "alice" || record.Method != http.MethodPut ||
+		record.Route != "/ComputeNode/{nodeID}" || record.Resource["nodeID"] != "1234" || record.Status != http.StatusOK {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+
+	buf.Reset()
+	request(http.MethodGet)
+	if buf.Len() != 0 {
+		t.Errorf("expected reads not to be audited, got %q", buf.String())
+	}
+
+	request(http.MethodDelete)
+	if buf.Len() != 0 {
+		t.Errorf("expected failed requests not to be audited, got %q", buf.String())
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORS
+type CORSOptions struct {
+	// AllowedOrigins are the origins, e.g. https://dashboard.example.com, that browsers may
+	// call the API from.  "*" allows any origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization headers cross-origin
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.  0 leaves it to them.
+	MaxAge time.Duration
+}
+
+// ErrCORSAnyOriginWithCredentials is returned by CORSOptions.Validate for options that would
+// let every site make credentialed requests on behalf of the browser's user
+var ErrCORSAnyOriginWithCredentials = errors.New(`CORS cannot allow credentials from any origin ("*"), list the origins instead`)
+
+// Validate checks that the options are safe to serve
+func (o CORSOptions) Validate() error {
+	if o.AllowCredentials && slices.Contains(o.AllowedOrigins, "*") {
+		return ErrCORSAnyOriginWithCredentials
+	}
+	return nil
+}
+
+// CORS sets the CORS headers for requests from the allowed origins and answers their
+// preflight OPTIONS requests itself.  The origin is echoed back, or answered with "*" when any
+// origin is allowed, which Validate only accepts without credentials.  Requests from other origins are passed on without CORS headers, so browsers block them,
+// and their preflights are refused with a 403.  It panics if options fail Validate.
+func CORS(options CORSOptions) func(next http.Handler) http.Handler {
+	if err := options.Validate(); err != nil {
+		panic(err)
+	}
+	anyOrigin := false
+	allowed := make(map[string]bool, len(options.AllowedOrigins))
+	for _, origin := range options.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(options.AllowedMethods, ", ")
+	headers := strings.Join(options.AllowedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// The answer depends on the origin, so caches must keep them apart
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !anyOrigin && !allowed[origin] {
+				if preflight {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if options.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if options.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(options.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
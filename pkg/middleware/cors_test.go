@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	options := CORSOptions{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         time.Minute,
+	}
+
+	request := func(handler http.Handler, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/inventory/ComputeNode", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	handler := CORS(options)(next)
+	rec := request(handler, http.MethodOptions, "https://dashboard.example.com", true)
+	if rec.Code != http.StatusNoContent ||
+		rec.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		rec.Header().Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+		rec.Header().Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("unexpected preflight response %d %v", rec.Code, rec.Header())
+	}
+
+	rec = request(handler, http.MethodGet, "https://dashboard.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("expected only the origin on a simple request, got %v", rec.Header())
+	}
+
+	if rec := request(handler, http.MethodOptions, "https://evil.example.com", true); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a preflight from another origin, got %d", rec.Code)
+	}
+	if rec := request(handler, http.MethodGet, "https://evil.example.com", false); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for another origin, got %v", rec.Header())
+	}
+	if rec := request(handler, http.MethodGet, "", false); rec.Header().Get("Vary") != "" {
+		t.Errorf("expected a request without an origin to be left alone, got %v", rec.Header())
+	}
+
+	options.AllowedOrigins = []string{"*"}
+	if rec := request(CORS(options)(next), http.MethodGet, "https://any.example.com", false); rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("expected * without credentials, got %v", rec.Header())
+	}
+
+	// Browsers refuse * for credentialed requests, so a listed origin is echoed back
+	options.AllowedOrigins = []string{"https://dashboard.example.com"}
+	options.AllowCredentials = true
+	rec = request(CORS(options)(next), http.MethodGet, "https://dashboard.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("expected the origin echoed with credentials, got %v", rec.Header())
+	}
+}
+
+func TestCORSRefusesAnyOriginWithCredentials(t *testing.T) {
+	options := CORSOptions{AllowedOrigins: []string{"https://dashboard.example.com", "*"}, AllowCredentials: true}
+	if err := options.Validate(); !errors.Is(err, ErrCORSAnyOriginWithCredentials) {
+		t.Errorf("expected ErrCORSAnyOriginWithCredentials, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected CORS to panic on options that fail Validate")
+		}
+	}()
+	CORS(options)
+}
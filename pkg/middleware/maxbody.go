@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the default limit for MaxBodySize, 10 MiB
+const DefaultMaxBodyBytes int64 = 10 << 20
+
+// MaxBodySize limits request bodies to limit bytes.  Requests that announce a larger body are
+// refused with a 413 straight away, otherwise reading past the limit fails with an
+// *http.MaxBytesError that handlers can turn into a 413 with BodyErrorStatus.
+func MaxBodySize(limit int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodyErrorStatus returns the status for a failure to decode a request body: 413 if the body
+// was cut off by MaxBodySize and 400 otherwise.
+func BodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
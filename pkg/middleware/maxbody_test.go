@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	handler := MaxBodySize(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), BodyErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	large := `["` + strings.Repeat("x", 100) + `"]`
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		want          int
+	}{
+		{"small body", `["a", "b"]`, -1, http.StatusNoContent},
+		{"malformed body", `["a"`, -1, http.StatusBadRequest},
+		{"large body without a length", large, -1, http.StatusRequestEntityTooLarge},
+		{"large announced length", large, int64(len(large)), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		req.ContentLength = tt.contentLength
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+}
@@ -45,8 +45,12 @@ func AuthenticatorWithRequiredClaims(ja *jwtauth.JWTAuth, requiredClaims []strin
 
 const LoggerKey = "logger"
 
-// OpenCHAMILogger is a chi middleware that adds a sublogger to the context.
-func OpenCHAMILogger(logger zerolog.Logger) func(next http.Handler) http.Handler {
+// RequestObserver is notified of every request completed by OpenCHAMILogger
+type RequestObserver func(r *http.Request, status int, duration time.Duration)
+
+// OpenCHAMILogger is a chi middleware that adds a sublogger to the context.  The timing it logs
+// is also handed to any observers, e.g. to feed request latency metrics.
+func OpenCHAMILogger(logger zerolog.Logger, observers ...RequestObserver) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -74,6 +78,9 @@ func OpenCHAMILogger(logger zerolog.Logger) func(next http.Handler) http.Handler
 					Int("bytes_out", ww.BytesWritten()).
 					Dur("duration", duration).
 					Msg("Request")
+				for _, observe := range observers {
+					observe(r, ww.Status(), duration)
+				}
 			}()
 
 			next.ServeHTTP(ww, r)
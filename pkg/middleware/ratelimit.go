@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// RateLimit is a token bucket rate limiter allowing each client rps requests per second with
+// bursts of up to burst requests.  Clients are identified by the subject of their JWT, so it
+// has to run after jwtauth.Verifier to see it, and by remote IP when there is no token.
+// Requests over the limit get a 429 with a Retry-After header.  rps must be positive.
+func RateLimit(rps int, burst int) func(next http.Handler) http.Handler {
+	return rateLimit(rps, burst, rateLimitKey)
+}
+
+// RateLimitByIP is RateLimit keyed by remote IP alone.  It goes before authentication, so
+// that requests rejected for a missing or bad token are limited as well.
+func RateLimitByIP(rps int, burst int) func(next http.Handler) http.Handler {
+	return rateLimit(rps, burst, remoteIPKey)
+}
+
+func rateLimit(rps int, burst int, key func(*http.Request) string) func(next http.Handler) http.Handler {
+	limiter := newRateLimiter(float64(rps), float64(burst), time.Now)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wait, ok := limiter.allow(key(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if token, _, err := jwtauth.FromContext(r.Context()); err == nil && token != nil && token.Subject() != "" {
+		return "sub:" + token.Subject()
+	}
+	return remoteIPKey(r)
+}
+
+func remoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate, burst float64, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     math.Max(burst, 1),
+		now:       now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+	}
+}
+
+// allow takes a token from key's bucket.  When the bucket is empty it returns how long
+// until the next token is available.
+func (l *rateLimiter) allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return max(wait, time.Second), false
+}
+
+// sweep forgets buckets that have refilled completely, since a new bucket is identical.
+// It runs at most once a minute so that the cost is spread over many requests.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
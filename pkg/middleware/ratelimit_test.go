@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+)
+
+func TestRateLimiterRefills(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, 3, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if _, ok := limiter.allow("a"); !ok {
+			t.Fatalf("request %d within the burst was limited", i)
+		}
+	}
+	wait, ok := limiter.allow("a")
+	if ok {
+		t.Fatalf("expected the request after the burst to be limited")
+	}
+	if wait != time.Second {
+		t.Errorf("expected to wait 1s, got %s", wait)
+	}
+	if _, ok := limiter.allow("b"); !ok {
+		t.Errorf("expected a different key to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := limiter.allow("a"); !ok {
+		t.Errorf("expected a token to be available after refilling")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.allow("a")
+	if _, exists := limiter.buckets["b"]; exists {
+		t.Errorf("expected the idle bucket to be swept")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(RateLimit(1, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ComputeNode", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if subject != "" {
+			_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": subject})
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("alice"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", rec.Code)
+	}
+	rec := request("alice")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After of 1, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	// Same address, but a different subject and no subject are limited separately
+	if rec := request("bob"); rec.Code != http.StatusOK {
+		t.Errorf("expected another subject to pass, got %d", rec.Code)
+	}
+	if rec := request(""); rec.Code != http.StatusOK {
+		t.Errorf("expected an anonymous request to be keyed by IP, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := RateLimitByIP(1, 1)(jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(tokenAuth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	request := func(addr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/ComputeNode", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Unauthenticated requests count against their address before they are turned away
+	if code := request("10.0.0.1:1234"); code != http.StatusUnauthorized {
+		t.Fatalf("expected the first request to reach authentication, got %d", code)
+	}
+	if code := request("10.0.0.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for the second request from the address, got %d", code)
+	}
+	if code := request("10.0.0.2:1234"); code != http.StatusUnauthorized {
+		t.Errorf("expected another address to be limited separately, got %d", code)
+	}
+}
@@ -0,0 +1,40 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Canonical values of ComputeNode.Architecture
+const (
+	ArchX86_64 = "x86_64"
+	ArchARM64  = "arm64"
+)
+
+// ErrUnknownArchitecture is wrapped by NormalizeArchitecture errors
+var ErrUnknownArchitecture = errors.New("unknown architecture")
+
+// architectureAliases maps the lower case spellings accepted for an architecture, including
+// the SMD X86 and ARM values, to the canonical name
+var architectureAliases = map[string]string{
+	"x86_64":  ArchX86_64,
+	"amd64":   ArchX86_64,
+	"x86":     ArchX86_64,
+	"arm64":   ArchARM64,
+	"aarch64": ArchARM64,
+	"arm":     ArchARM64,
+}
+
+// NormalizeArchitecture returns the canonical name for arch, which is matched regardless of
+// case.  An empty architecture stays empty since it is simply not known yet.
+func NormalizeArchitecture(arch string) (string, error) {
+	if arch == "" {
+		return "", nil
+	}
+	canonical, ok := architectureAliases[strings.ToLower(strings.TrimSpace(arch))]
+	if !ok {
+		return "", fmt.Errorf("%w %q, expected %s or %s", ErrUnknownArchitecture, arch, ArchX86_64, ArchARM64)
+	}
+	return canonical, nil
+}
@@ -0,0 +1,33 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeArchitecture(t *testing.T) {
+	tests := []struct {
+		arch string
+		want string
+		err  error
+	}{
+		{"x86_64", ArchX86_64, nil},
+		{"X86", ArchX86_64, nil},
+		{"AMD64", ArchX86_64, nil},
+		{"arm64", ArchARM64, nil},
+		{" aarch64 ", ArchARM64, nil},
+		{"ARM", ArchARM64, nil},
+		{"", "", nil},
+		{"sparc", "", ErrUnknownArchitecture},
+		{"x86-64", "", ErrUnknownArchitecture},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeArchitecture(tt.arch)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("NormalizeArchitecture(%q): expected error %v, got %v", tt.arch, tt.err, err)
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeArchitecture(%q): expected %q, got %q", tt.arch, tt.want, got)
+		}
+	}
+}
@@ -1,17 +1,50 @@
 package nodes
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
 type BMC struct {
-	ID uuid.UUID `json:"id,omitempty" format:"uuid"`
-	// XName       xnames.BMCXname `json:"xname,omitempty"`
-	Username       string `json:"username" jsonschema:"required"`
-	Password       string `json:"password" jsonschema:"required"`
-	IPv4Address    string `json:"ipv4_address,omitempty" format:"ipv4"`
-	IPv6Address    string `json:"ipv6_address,omitempty" format:"ipv6"`
-	MACAddress     string `json:"mac_address" format:"mac-address" binding:"required"`
-	Description    string `json:"description,omitempty"`
-	LocationString string `json:"location_string,omitempty"`
+	ID             uuid.UUID       `json:"id,omitempty" format:"uuid"`
+	XName          xnames.BMCXname `json:"xname,omitempty"`
+	Username       string          `json:"username" jsonschema:"required"`
+	Password       string          `json:"password" jsonschema:"required"`
+	IPv4Address    string          `json:"ipv4_address,omitempty" format:"ipv4"`
+	IPv6Address    string          `json:"ipv6_address,omitempty" format:"ipv6"`
+	MACAddress     string          `json:"mac_address" format:"mac-address" binding:"required"`
+	Description    string          `json:"description,omitempty"`
+	LocationString string          `json:"location_string,omitempty"`
+	CreatedAt      time.Time       `json:"created_at,omitempty" jsonschema:"readOnly=true"`
+	UpdatedAt      time.Time       `json:"updated_at,omitempty" jsonschema:"readOnly=true"`
+}
+
+// Touch records that the BMC is being saved at now, and created then if it never was before
+func (b *BMC) Touch(now time.Time) {
+	now = Timestamp(now)
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = now
+	}
+	b.UpdatedAt = now
+}
+
+// RedactedPassword replaces passwords in API responses
+const RedactedPassword = "***"
+
+// Redacted returns a copy of the BMC that is safe to return to clients
+func (b BMC) Redacted() BMC {
+	if b.Password != "" {
+		b.Password = RedactedPassword
+	}
+	return b
+}
+
+// KeepPassword puts back the password of stored when b was read from the API with its
+// password redacted, so that a BMC sent back unchanged doesn't lose its password
+func (b *BMC) KeepPassword(stored BMC) {
+	if b.Password == RedactedPassword {
+		b.Password = stored.Password
+	}
 }
@@ -0,0 +1,43 @@
+package nodes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestBMCXNameRoundTrip(t *testing.T) {
+	bmc := BMC{XName: xnames.NewBMCXname("x1000c0s1b0"), MACAddress: "00:11:22:33:44:55"}
+	data, err := json.Marshal(bmc)
+	if err != nil {
+		t.Fatalf("failed to marshal BMC: %v", err)
+	}
+	if !strings.Contains(string(data), `"xname":"x1000c0s1b0"`) {
+		t.Errorf("expected the xname to marshal as a plain string, got %s", data)
+	}
+
+	var decoded BMC
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal BMC: %v", err)
+	}
+	if decoded.XName.String() != "x1000c0s1b0" {
+		t.Errorf("expected xname x1000c0s1b0, got %q", decoded.XName)
+	}
+	if ok, err := decoded.XName.Valid(); !ok {
+		t.Errorf("expected a valid BMC xname: %v", err)
+	}
+}
+
+func TestBMCSchemaUsesBMCXName(t *testing.T) {
+	schema := jsonschema.Reflect(&BMC{})
+	def, ok := schema.Definitions["BMCXname"]
+	if !ok {
+		t.Fatalf("expected a BMCXname definition in the BMC schema")
+	}
+	if def.Pattern != (xnames.BMCXname{}).JSONSchema().Pattern {
+		t.Errorf("expected the BMC xname pattern, got %q", def.Pattern)
+	}
+}
@@ -0,0 +1,37 @@
+package nodes
+
+import "errors"
+
+// A node boots from BootData.  A new configuration is staged in NextBootData, tried by asking
+// for the pending boot parameters, and promoted once it is known to work.  Promotion keeps the
+// configuration it replaces in LastGoodBootData, so that it can be rolled back to.
+
+var (
+	// ErrNoNextBootData is returned by PromoteBootData when nothing has been staged
+	ErrNoNextBootData = errors.New("node has no next boot configuration to promote")
+	// ErrNoLastGoodBootData is returned by RollbackBootData when nothing has been promoted
+	ErrNoLastGoodBootData = errors.New("node has no last known good boot configuration to roll back to")
+)
+
+// PromoteBootData makes the staged boot configuration current and keeps the one it replaces as
+// the last known good
+func (n *ComputeNode) PromoteBootData() error {
+	if n.NextBootData == nil {
+		return ErrNoNextBootData
+	}
+	if n.BootData != nil {
+		n.LastGoodBootData = n.BootData
+	}
+	n.BootData, n.NextBootData = n.NextBootData, nil
+	return nil
+}
+
+// RollbackBootData restores the last known good boot configuration.  The configuration rolled
+// back from is dropped rather than restaged, and a configuration staged since is left alone.
+func (n *ComputeNode) RollbackBootData() error {
+	if n.LastGoodBootData == nil {
+		return ErrNoLastGoodBootData
+	}
+	n.BootData, n.LastGoodBootData = n.LastGoodBootData, nil
+	return nil
+}
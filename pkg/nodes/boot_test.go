@@ -0,0 +1,40 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPromoteAndRollbackBootData(t *testing.T) {
+	a := &BootData{KernelURL: "http://boot/vmlinuz-a"}
+	b := &BootData{KernelURL: "http://boot/vmlinuz-b"}
+	node := ComputeNode{BootData: a}
+
+	if err := node.PromoteBootData(); !errors.Is(err, ErrNoNextBootData) {
+		t.Errorf("expected ErrNoNextBootData with nothing staged, got %v", err)
+	}
+	if err := node.RollbackBootData(); !errors.Is(err, ErrNoLastGoodBootData) {
+		t.Errorf("expected ErrNoLastGoodBootData before any promotion, got %v", err)
+	}
+
+	node.NextBootData = b
+	if err := node.PromoteBootData(); err != nil {
+		t.Fatal(err)
+	}
+	if node.BootData != b || node.NextBootData != nil || node.LastGoodBootData != a {
+		t.Fatalf("expected b current and a kept as last known good, got %+v", node)
+	}
+
+	if err := node.RollbackBootData(); err != nil {
+		t.Fatal(err)
+	}
+	if node.BootData != a || node.LastGoodBootData != nil {
+		t.Errorf("expected a current again with nothing left to roll back to, got %+v", node)
+	}
+
+	// The first configuration promoted onto a node without one leaves nothing to roll back to
+	fresh := ComputeNode{NextBootData: b}
+	if err := fresh.PromoteBootData(); err != nil || fresh.BootData != b || fresh.LastGoodBootData != nil {
+		t.Errorf("expected b current with no last known good, got %+v (%v)", fresh, err)
+	}
+}
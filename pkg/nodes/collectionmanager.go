@@ -1,27 +1,32 @@
 package nodes
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
 // CollectionManager manages collections with constraints.
 type CollectionManager struct {
-	CollectionsByID   map[uuid.UUID]*NodeCollection
-	CollectionsByName map[string]*NodeCollection
-	Constraints       map[NodeCollectionType][]CollectionConstraint
+	CollectionsByID    map[uuid.UUID]*NodeCollection
+	CollectionsByName  map[string]*NodeCollection
+	CollectionsByAlias map[string]*NodeCollection
+	Constraints        map[NodeCollectionType][]CollectionConstraint
 }
 
 func NewCollectionManager() *CollectionManager {
 	manager := &CollectionManager{
-		CollectionsByID:   make(map[uuid.UUID]*NodeCollection),
-		CollectionsByName: make(map[string]*NodeCollection),
-		Constraints:       make(map[NodeCollectionType][]CollectionConstraint),
+		CollectionsByID:    make(map[uuid.UUID]*NodeCollection),
+		CollectionsByName:  make(map[string]*NodeCollection),
+		CollectionsByAlias: make(map[string]*NodeCollection),
+		Constraints:        make(map[NodeCollectionType][]CollectionConstraint),
 	}
 	// Add constraints for each type if needed
-	// manager.AddConstraint(PartitionType, &MutualExclusivityConstraint{ExistingNodes: make(map[xnames.NodeXname]uuid.UUID)})
-	// manager.AddConstraint(TenantType, &MutualExclusivityConstraint{ExistingNodes: make(map[xnames.NodeXname]uuid.UUID)})
+	// manager.AddConstraint(PartitionType, &MutualExclusivityConstraint{})
+	// manager.AddConstraint(TenantType, &MutualExclusivityConstraint{})
 	// Add other constraints as necessary
 	return manager
 }
@@ -30,40 +35,96 @@ func (m *CollectionManager) AddConstraint(collectionType NodeCollectionType, con
 	m.Constraints[collectionType] = append(m.Constraints[collectionType], constraint) // Append the constraint to the list of constraints for this type
 }
 
+// ErrNameInUse is wrapped by the errors refusing a collection a name or alias that another
+// collection already goes by
+var ErrNameInUse = errors.New("already in use")
+
 func (m *CollectionManager) CreateCollection(collection *NodeCollection) error {
 	collection.ID = uuid.New() // Generate a new UUID for the collection
 
 	if collection.Name != "" {
 		if _, exists := m.CollectionsByName[collection.Name]; exists {
-			return fmt.Errorf("name %s is already in use", collection.Name)
+			return fmt.Errorf("name %s is %w", collection.Name, ErrNameInUse)
+		}
+		if other, exists := m.CollectionsByAlias[collection.Name]; exists {
+			return fmt.Errorf("name %s is %w as the alias of collection %s", collection.Name, ErrNameInUse, other.ID)
 		}
+	}
+	if err := m.checkAlias(collection); err != nil {
+		return err
+	}
+
+	if err := m.validate(collection); err != nil {
+		return err
+	}
+
+	if collection.Name != "" {
 		m.CollectionsByName[collection.Name] = collection
 	}
+	if collection.Alias != "" {
+		m.CollectionsByAlias[collection.Alias] = collection
+	}
+	m.CollectionsByID[collection.ID] = collection
+	return nil
+}
 
-	if constraints, exists := m.Constraints[NodeCollectionType(collection.Type)]; exists {
-		for _, constraint := range constraints {
-			if err := constraint.Validate(collection.Nodes); err != nil {
-				return err
+// validate checks collection against the constraints of its type.  A MutualExclusivityConstraint
+// is first given the nodes of the other collections of the type, so that a collection being
+// updated doesn't conflict with itself.
+func (m *CollectionManager) validate(collection *NodeCollection) error {
+	for _, constraint := range m.Constraints[collection.Type] {
+		if exclusive, ok := constraint.(*MutualExclusivityConstraint); ok {
+			exclusive.ExistingNodes = make(map[string]uuid.UUID)
+			for id, other := range m.CollectionsByID {
+				if id == collection.ID || other.Type != collection.Type {
+					continue
+				}
+				for _, member := range other.Nodes {
+					exclusive.ExistingNodes[member.Key()] = id
+				}
 			}
 		}
+		if err := constraint.Validate(collection.Nodes); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// checkAlias refuses an alias that another collection already goes by, as its alias or its
+// name, since GetCollection could only ever find one of them
+func (m *CollectionManager) checkAlias(collection *NodeCollection) error {
+	if collection.Alias == "" {
+		return nil
+	}
+	if other, exists := m.CollectionsByAlias[collection.Alias]; exists && other.ID != collection.ID {
+		return fmt.Errorf("alias %s is %w", collection.Alias, ErrNameInUse)
+	}
+	if other, exists := m.CollectionsByName[collection.Alias]; exists && other.ID != collection.ID {
+		return fmt.Errorf("alias %s is %w as the name of collection %s", collection.Alias, ErrNameInUse, other.ID)
+	}
 	return nil
 }
 
 func (m *CollectionManager) UpdateCollection(collection *NodeCollection) error {
+	if err := m.checkAlias(collection); err != nil {
+		return err
+	}
 
-	if constraints, exists := m.Constraints[NodeCollectionType(collection.Type)]; exists {
-		for _, constraint := range constraints {
-			if err := constraint.Validate(collection.Nodes); err != nil {
-				return err
-			}
-		}
+	if err := m.validate(collection); err != nil {
+		return err
 	}
 
 	if collection.Name != "" {
 		m.CollectionsByName[collection.Name] = collection
 	}
+	// The alias may have changed, so drop the old one before indexing the new one
+	if previous, exists := m.CollectionsByID[collection.ID]; exists && previous.Alias != "" {
+		delete(m.CollectionsByAlias, previous.Alias)
+	}
+	if collection.Alias != "" {
+		m.CollectionsByAlias[collection.Alias] = collection
+	}
 
 	m.CollectionsByID[collection.ID] = collection
 	return nil
@@ -78,6 +139,9 @@ func (m *CollectionManager) DeleteCollection(collectionID uuid.UUID) error {
 	if collection.Name != "" {
 		delete(m.CollectionsByName, collection.Name)
 	}
+	if collection.Alias != "" {
+		delete(m.CollectionsByAlias, collection.Alias)
+	}
 	delete(m.CollectionsByID, collectionID)
 	return nil
 }
@@ -90,5 +154,43 @@ func (m *CollectionManager) GetCollection(identifier string) (*NodeCollection, b
 	if collection, exists := m.CollectionsByName[identifier]; exists {
 		return collection, true
 	}
+	if collection, exists := m.CollectionsByAlias[identifier]; exists {
+		return collection, true
+	}
 	return nil, false
 }
+
+// FindCollectionsByNode returns the collections that list xname, ordered by name
+func (m *CollectionManager) FindCollectionsByNode(xname xnames.NodeXname) []*NodeCollection {
+	var found []*NodeCollection
+	for _, collection := range m.CollectionsByID {
+		for _, member := range collection.Nodes {
+			if member.Key() == xname.Key() {
+				found = append(found, collection)
+				break
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Name != found[j].Name {
+			return found[i].Name < found[j].Name
+		}
+		return found[i].ID.String() < found[j].ID.String()
+	})
+	return found
+}
+
+// RemoveNode removes xname from every collection that lists it and returns those collections
+func (m *CollectionManager) RemoveNode(xname xnames.NodeXname) []*NodeCollection {
+	found := m.FindCollectionsByNode(xname)
+	for _, collection := range found {
+		remaining := collection.Nodes[:0]
+		for _, member := range collection.Nodes {
+			if member.Key() != xname.Key() {
+				remaining = append(remaining, member)
+			}
+		}
+		collection.Nodes = remaining
+	}
+	return found
+}
@@ -1,6 +1,7 @@
 package nodes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -27,6 +28,15 @@ func (n NodeCollectionType) String() string {
 	return string(n)
 }
 
+// Valid reports whether n is one of the defined collection types.
+func (n NodeCollectionType) Valid() bool {
+	switch n {
+	case DefaultType, TenantType, JobType, PartitionType:
+		return true
+	}
+	return false
+}
+
 // JSONSchema for NodeCollectionType to enforce enum and description.
 func (NodeCollectionType) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
@@ -43,6 +53,7 @@ type NodeCollection struct {
 	CreatorSubject string             `json:"creator_subject,omitempty" format:"email"` // JWT subject of the creator of the collection
 	Description    string             `json:"description,omitempty"`
 	Name           string             `json:"name"`
+	Alias          string             `json:"alias,omitempty"` // Another name to look the collection up by, e.g. a scheduler's partition name
 	Type           NodeCollectionType `json:"type"`
 	Nodes          []xnames.NodeXname `json:"nodes"`                     // List of ComputeNode IDs
 	CloudInitData  map[string]string  `json:"cloud_init_data,omitempty"` // Optional cloud-init data for the collection.  It will be available in the payload as `group_{Name}`
@@ -61,15 +72,20 @@ type CollectionConstraint interface {
 	Validate(nodes []xnames.NodeXname) error
 }
 
-// MutualExclusivityConstraint ensures nodes are only in one collection of this type.
+// ErrNodeInOtherCollection is wrapped by the errors refusing a node that a collection of an
+// exclusive type already has
+var ErrNodeInOtherCollection = errors.New("already assigned to another collection")
+
+// MutualExclusivityConstraint ensures nodes are only in one collection of this type.  The
+// CollectionManager fills ExistingNodes from its collections before each validation.
 type MutualExclusivityConstraint struct {
-	ExistingNodes map[xnames.NodeXname]uuid.UUID // Map of nodeID to collectionID
+	ExistingNodes map[string]uuid.UUID // Map of NodeXname.Key() to collectionID
 }
 
 func (c *MutualExclusivityConstraint) Validate(nodes []xnames.NodeXname) error {
 	for _, nodeID := range nodes {
-		if _, exists := c.ExistingNodes[nodeID]; exists {
-			return fmt.Errorf("node %s is already assigned to another collection", nodeID)
+		if collectionID, exists := c.ExistingNodes[nodeID.Key()]; exists {
+			return fmt.Errorf("node %s is %w %s", nodeID, ErrNodeInOtherCollection, collectionID)
 		}
 	}
 	return nil
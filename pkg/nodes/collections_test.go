@@ -0,0 +1,80 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+func TestMutualExclusivityConstraintNormalizesXnames(t *testing.T) {
+	padded := xnames.NewNodeXname("x0001c0s0b0n0")
+	unpadded := xnames.NewNodeXname("x1c0s0b0n0")
+
+	if padded.Key() != unpadded.Key() {
+		t.Fatalf("expected %q and %q to share a key, got %q and %q", padded, unpadded, padded.Key(), unpadded.Key())
+	}
+
+	constraint := &MutualExclusivityConstraint{ExistingNodes: map[string]uuid.UUID{
+		padded.Key(): uuid.New(),
+	}}
+	if err := constraint.Validate([]xnames.NodeXname{unpadded}); err == nil {
+		t.Errorf("expected %q to conflict with existing node %q", unpadded, padded)
+	}
+	if err := constraint.Validate([]xnames.NodeXname{xnames.NewNodeXname("x1c0s0b0n1")}); err != nil {
+		t.Errorf("unexpected conflict for a different node: %v", err)
+	}
+}
+
+func TestNodeCollectionTypeValid(t *testing.T) {
+	for _, valid := range []NodeCollectionType{DefaultType, TenantType, JobType, PartitionType} {
+		if !valid.Valid() {
+			t.Errorf("expected %q to be valid", valid)
+		}
+	}
+	for _, invalid := range []NodeCollectionType{"", "partiton", "Tenant"} {
+		if invalid.Valid() {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestCollectionAlias(t *testing.T) {
+	m := NewCollectionManager()
+	collection := &NodeCollection{Name: "compute", Alias: "batch", Type: DefaultType}
+	if err := m.CreateCollection(collection); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	if found, ok := m.GetCollection("batch"); !ok || found.ID != collection.ID {
+		t.Errorf("expected to find the collection by its alias")
+	}
+
+	for _, clash := range []*NodeCollection{
+		{Name: "other", Alias: "batch", Type: DefaultType},
+		{Name: "other", Alias: "compute", Type: DefaultType},
+		{Name: "batch", Type: DefaultType},
+	} {
+		if err := m.CreateCollection(clash); !errors.Is(err, ErrNameInUse) {
+			t.Errorf("expected name %q and alias %q to clash, got %v", clash.Name, clash.Alias, err)
+		}
+	}
+
+	renamed := &NodeCollection{ID: collection.ID, Name: "compute", Alias: "gpu", Type: DefaultType}
+	if err := m.UpdateCollection(renamed); err != nil {
+		t.Fatalf("failed to update collection: %v", err)
+	}
+	if _, ok := m.GetCollection("batch"); ok {
+		t.Errorf("expected the old alias to be released")
+	}
+	if found, ok := m.GetCollection("gpu"); !ok || found.ID != collection.ID {
+		t.Errorf("expected to find the collection by its new alias")
+	}
+
+	if err := m.DeleteCollection(collection.ID); err != nil {
+		t.Fatalf("failed to delete collection: %v", err)
+	}
+	if _, ok := m.GetCollection("gpu"); ok {
+		t.Errorf("expected the alias to go with the collection")
+	}
+}
@@ -0,0 +1,133 @@
+package nodes
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormatError is a field whose value doesn't match the format in its format tag
+type FormatError struct {
+	Field  string // JSON path of the field, e.g. network_interfaces.0.mac_address
+	Value  string
+	Format string
+}
+
+func (e FormatError) Error() string {
+	return fmt.Sprintf("%s: %q is not a valid %s", e.Field, e.Value, e.Format)
+}
+
+// formatCheckers validate the string formats that the address fields are tagged with
+var formatCheckers = map[string]func(string) bool{
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	},
+	"ipv6": func(s string) bool {
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	},
+	"mac-address": func(s string) bool {
+		_, err := net.ParseMAC(s)
+		return err == nil
+	},
+}
+
+// ValidateFormats checks every string field of v, a node or BMC, tagged with an ipv4, ipv6 or
+// mac-address format, including those of nested structs such as the network interfaces.
+// Empty fields are not checked, as they are optional or caught by other validation.
+func ValidateFormats(v interface{}) []FormatError {
+	var errs []FormatError
+	validateFormats(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateFormats(v reflect.Value, path string, errs *[]FormatError) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			validateFormats(v.Elem(), path, errs)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			validateFormats(v.Index(i), joinPath(path, strconv.Itoa(i)), errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fieldPath := joinPath(path, name)
+			check, ok := formatCheckers[field.Tag.Get("format")]
+			if ok && field.Type.Kind() == reflect.String {
+				if value := v.Field(i).String(); value != "" && !check(value) {
+					*errs = append(*errs, FormatError{Field: fieldPath, Value: value, Format: field.Tag.Get("format")})
+				}
+				continue
+			}
+			validateFormats(v.Field(i), fieldPath, errs)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// CanonicalMAC is mac in the form net.HardwareAddr prints, lowercase and colon separated, so
+// that MACs written with hyphens, dots or capitals compare equal.  An invalid MAC is returned
+// unchanged.
+func CanonicalMAC(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return mac
+	}
+	return hw.String()
+}
+
+// CanonicalizeMACs rewrites every field of v tagged with the mac-address format, including
+// those of nested structs, with CanonicalMAC.  v has to be a pointer for the fields to be set.
+func CanonicalizeMACs(v interface{}) {
+	canonicalizeMACs(reflect.ValueOf(v))
+}
+
+func canonicalizeMACs(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			canonicalizeMACs(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			canonicalizeMACs(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := v.Field(i)
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if t.Field(i).Tag.Get("format") == "mac-address" && field.Kind() == reflect.String {
+				if field.CanSet() && field.String() != "" {
+					field.SetString(CanonicalMAC(field.String()))
+				}
+				continue
+			}
+			canonicalizeMACs(field)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package nodes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateFormats(t *testing.T) {
+	node := ComputeNode{
+		BootMac:         "00:1a:2b:3c:4d:5e",
+		BootIPv4Address: "10.0.0.1",
+		BootIPv6Address: "",
+		NetworkInterfaces: []NetworkInterface{
+			{InterfaceName: "eth0", MACAddress: "00:1a:2b:3c:4d:5f", IPv6Address: "fd00::1"},
+			{InterfaceName: "eth1", MACAddress: "not-a-mac", IPv4Address: "fd00::2"},
+		},
+		BMC:  &BMC{MACAddress: "00:1a:2b:3c:4d:60", IPv4Address: "10.0.0.300"},
+		Spec: ComputeNodeSpec{BootIPv6Address: "10.0.0.2"},
+	}
+
+	var fields []string
+	for _, err := range ValidateFormats(node) {
+		fields = append(fields, err.Field)
+	}
+	want := []string{
+		"network_interfaces.1.ipv4_address",
+		"network_interfaces.1.mac_address",
+		"bmc.ipv4_address",
+		"spec.boot_ipv6_address",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("expected format errors in %v, got %v", want, fields)
+	}
+
+	if errs := ValidateFormats(&BMC{MACAddress: "00:1A:2B:3C:4D:5E", IPv6Address: "fd00::1"}); len(errs) != 0 {
+		t.Errorf("expected a valid BMC to pass, got %v", errs)
+	}
+}
+
+func TestCanonicalizeMACs(t *testing.T) {
+	node := ComputeNode{
+		BootMac: "00-1A-2B-3C-4D-5E",
+		BMC:     &BMC{MACAddress: "001a.2b3c.4d60"},
+		NetworkInterfaces: []NetworkInterface{
+			{InterfaceName: "eth0", MACAddress: "00:1A:2B:3C:4D:5F"},
+			{InterfaceName: "eth1", MACAddress: "not-a-mac"},
+		},
+	}
+	CanonicalizeMACs(&node)
+
+	got := []string{node.BootMac, node.BMC.MACAddress, node.NetworkInterfaces[0].MACAddress, node.NetworkInterfaces[1].MACAddress}
+	want := []string{"00:1a:2b:3c:4d:5e", "00:1a:2b:3c:4d:60", "00:1a:2b:3c:4d:5f", "not-a-mac"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,38 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidLabel is wrapped by label validation and parsing errors
+var ErrInvalidLabel = errors.New("invalid label")
+
+// labelKeyPattern keeps label keys usable as a quoted JSON path member, e.g. rack or
+// example.com/owner
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+
+// ValidateLabels checks that every label key starts with a letter or digit and is made of at
+// most 63 letters, digits, '.', '_', '-' and '/'.  Values are free form.
+func ValidateLabels(labels map[string]string) error {
+	for key := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w key %q", ErrInvalidLabel, key)
+		}
+	}
+	return nil
+}
+
+// ParseLabel splits a key=value label selector, such as rack=A3, and validates the key
+func ParseLabel(selector string) (string, string, error) {
+	key, value, ok := strings.Cut(selector, "=")
+	if !ok {
+		return "", "", fmt.Errorf("%w %q, expected key=value", ErrInvalidLabel, selector)
+	}
+	if err := ValidateLabels(map[string]string{key: value}); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
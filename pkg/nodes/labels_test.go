@@ -0,0 +1,36 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		selector  string
+		key       string
+		value     string
+		wantError bool
+	}{
+		{selector: "rack=A3", key: "rack", value: "A3"},
+		{selector: "example.com/owner=team-x", key: "example.com/owner", value: "team-x"},
+		{selector: "note=a=b", key: "note", value: "a=b"},
+		{selector: "empty=", key: "empty", value: ""},
+		{selector: "rack", wantError: true},
+		{selector: "=A3", wantError: true},
+		{selector: `ra"ck=A3`, wantError: true},
+		{selector: "-rack=A3", wantError: true},
+	}
+	for _, tt := range tests {
+		key, value, err := ParseLabel(tt.selector)
+		if tt.wantError {
+			if !errors.Is(err, ErrInvalidLabel) {
+				t.Errorf("ParseLabel(%q): expected ErrInvalidLabel, got %v", tt.selector, err)
+			}
+			continue
+		}
+		if err != nil || key != tt.key || value != tt.value {
+			t.Errorf("ParseLabel(%q) = %q, %q, %v, want %q, %q", tt.selector, key, value, err, tt.key, tt.value)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LifecycleState is how far a node has come in being provisioned
+type LifecycleState string
+
+const (
+	LifecycleDiscovered     LifecycleState = "discovered"
+	LifecycleProvisioning   LifecycleState = "provisioning"
+	LifecycleProvisioned    LifecycleState = "provisioned"
+	LifecycleFailed         LifecycleState = "failed"
+	LifecycleDecommissioned LifecycleState = "decommissioned"
+)
+
+// ErrInvalidLifecycleTransition is wrapped by Transition when a node can't move to a state
+var ErrInvalidLifecycleTransition = errors.New("invalid lifecycle transition")
+
+// lifecycleTransitions lists the states each state may move on to.  A failed or provisioned
+// node may be provisioned again, and a decommissioned node has to be rediscovered.
+var lifecycleTransitions = map[LifecycleState][]LifecycleState{
+	LifecycleDiscovered:     {LifecycleProvisioning, LifecycleDecommissioned},
+	LifecycleProvisioning:   {LifecycleProvisioned, LifecycleFailed},
+	LifecycleProvisioned:    {LifecycleProvisioning, LifecycleFailed, LifecycleDecommissioned},
+	LifecycleFailed:         {LifecycleProvisioning, LifecycleDecommissioned},
+	LifecycleDecommissioned: {LifecycleDiscovered},
+}
+
+// Valid reports whether s is one of the lifecycle states
+func (s LifecycleState) Valid() bool {
+	_, ok := lifecycleTransitions[s]
+	return ok
+}
+
+// Transition checks that a node in state s may move to next.  Nodes stored before lifecycle
+// states existed have none and count as discovered.  Staying in the same state is allowed.
+func (s LifecycleState) Transition(next LifecycleState) error {
+	if !next.Valid() {
+		return fmt.Errorf("invalid lifecycle state %q", next)
+	}
+	if s == "" {
+		s = LifecycleDiscovered
+	}
+	if s == next {
+		return nil
+	}
+	for _, allowed := range lifecycleTransitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w from %s to %s", ErrInvalidLifecycleTransition, s, next)
+}
@@ -0,0 +1,31 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLifecycleTransition(t *testing.T) {
+	tests := []struct {
+		from, to LifecycleState
+		wantErr  error
+	}{
+		{from: LifecycleDiscovered, to: LifecycleProvisioning},
+		{from: "", to: LifecycleProvisioning},
+		{from: LifecycleProvisioning, to: LifecycleFailed},
+		{from: LifecycleFailed, to: LifecycleProvisioning},
+		{from: LifecycleProvisioned, to: LifecycleProvisioned},
+		{from: LifecycleDecommissioned, to: LifecycleDiscovered},
+		{from: LifecycleDiscovered, to: LifecycleProvisioned, wantErr: ErrInvalidLifecycleTransition},
+		{from: LifecycleDecommissioned, to: LifecycleProvisioning, wantErr: ErrInvalidLifecycleTransition},
+	}
+	for _, tt := range tests {
+		if err := tt.from.Transition(tt.to); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q -> %q: expected %v, got %v", tt.from, tt.to, tt.wantErr, err)
+		}
+	}
+
+	if err := LifecycleDiscovered.Transition("booting"); err == nil || errors.Is(err, ErrInvalidLifecycleTransition) {
+		t.Errorf("expected an invalid state error, got %v", err)
+	}
+}
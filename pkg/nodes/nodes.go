@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
 )
 
 type CloudInitData struct {
@@ -21,9 +22,10 @@ type BootData struct {
 }
 
 type ComputeNode struct {
-	ID       uuid.UUID `json:"id,omitempty" db:"id"`
-	Hostname string    `json:"hostname" binding:"required" db:"hostname"`
-	//XName             xnames.NodeXname   `json:"xname,omitempty" db:"xname"`
+	ID                uuid.UUID          `json:"id,omitempty" db:"id"`
+	Hostname          string             `json:"hostname" binding:"required" db:"hostname"`
+	XName             xnames.NodeXname   `json:"xname,omitempty" db:"xname"`
+	NID               int                `json:"nid,omitempty" db:"nid"`
 	Architecture      string             `json:"architecture" binding:"required" db:"architecture"`
 	BootMac           string             `json:"boot_mac,omitempty" format:"mac-address" db:"boot_mac"`
 	BootIPv4Address   string             `json:"boot_ipv4_address,omitempty" format:"ipv4" db:"boot_ipv4_address"`
@@ -32,9 +34,64 @@ type ComputeNode struct {
 	BMC               *BMC               `json:"bmc,omitempty" db:"bmc"`
 	Description       string             `json:"description,omitempty" db:"description"`
 	BootData          *BootData          `json:"boot_data,omitempty" db:"boot_data"`
+	NextBootData      *BootData          `json:"next_boot_data,omitempty" db:"next_boot_data"`
+	LastGoodBootData  *BootData          `json:"last_good_boot_data,omitempty" jsonschema:"readOnly=true" db:"last_good_boot_data"`
 	LocationString    string             `json:"location_string,omitempty" db:"location_string"`
+	Labels            map[string]string  `json:"labels,omitempty" db:"labels"`
 	Spec              ComputeNodeSpec    `json:"spec,omitempty" db:"spec"`
 	Status            ComputeNodeStatus  `json:"status,omitempty" db:"status"`
+	LifecycleState    LifecycleState     `json:"lifecycle_state,omitempty" jsonschema:"enum=discovered,enum=provisioning,enum=provisioned,enum=failed,enum=decommissioned" db:"lifecycle_state"`
+	CreatedAt         time.Time          `json:"created_at,omitempty" jsonschema:"readOnly=true" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at,omitempty" jsonschema:"readOnly=true" db:"updated_at"`
+}
+
+// Touch records that the node is being saved at now, and created then if it never was before
+func (n *ComputeNode) Touch(now time.Time) {
+	now = Timestamp(now)
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = now
+	}
+	n.UpdatedAt = now
+}
+
+// NormalizeXNames rewrites the xnames of the node and its BMC in their canonical form, so
+// that padded and unpadded spellings are stored and looked up alike
+func (n *ComputeNode) NormalizeXNames() {
+	n.XName = n.XName.Normalize()
+	if n.BMC != nil {
+		bmc := *n.BMC
+		bmc.XName = bmc.XName.Normalize()
+		n.BMC = &bmc
+	}
+}
+
+// Timestamp is t as CreatedAt and UpdatedAt keep it: in UTC, to the microsecond that the
+// database stores.
+func Timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Redacted returns a copy of the node with the passwords of its BMC and spec redacted
+func (n ComputeNode) Redacted() ComputeNode {
+	if n.BMC != nil {
+		bmc := n.BMC.Redacted()
+		n.BMC = &bmc
+	}
+	if n.Spec.BMCPassword != "" {
+		n.Spec.BMCPassword = RedactedPassword
+	}
+	return n
+}
+
+// KeepPasswords is BMC.KeepPassword for the spec password of the node and the password of
+// its BMC, which is only taken from stored when it is the same BMC
+func (n *ComputeNode) KeepPasswords(stored ComputeNode) {
+	if n.Spec.BMCPassword == RedactedPassword {
+		n.Spec.BMCPassword = stored.Spec.BMCPassword
+	}
+	if n.BMC != nil && stored.BMC != nil && n.BMC.ID == stored.BMC.ID {
+		n.BMC.KeepPassword(*stored.BMC)
+	}
 }
 
 type ComputeNodeSpec struct {
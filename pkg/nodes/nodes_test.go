@@ -0,0 +1,55 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/openchami/node-orchestrator/pkg/xnames"
+)
+
+// Responses are compared byte for byte by clients diffing and caching them, which relies on
+// encoding/json writing map keys in sorted order
+func TestComputeNodeEncodingIsStable(t *testing.T) {
+	labels := make(map[string]string)
+	extended := make(map[string]interface{})
+	for _, key := range []string{"zeta", "alpha", "mu", "beta", "omega", "gamma", "kappa", "delta"} {
+		labels[key] = key
+		extended[key] = map[string]interface{}{"z": 1, "a": []interface{}{map[string]interface{}{"y": true, "b": false}}}
+	}
+	node := ComputeNode{
+		ID:           uuid.New(),
+		Hostname:     "nid000001",
+		XName:        xnames.NewNodeXname("x1000c0s0b0n0"),
+		Architecture: "x86_64",
+		Labels:       labels,
+		NetworkInterfaces: []NetworkInterface{
+			{InterfaceName: "eth0", MACAddress: "00:11:22:33:44:55", ExtendedData: extended},
+		},
+		Status: ComputeNodeStatus{ExtendedData: extended},
+	}
+
+	first, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(node); err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.TrimSuffix(buf.Bytes(), []byte("\n")); !bytes.Equal(got, first) {
+			t.Fatalf("expected identical output on every encode, got\n%s\nthen\n%s", first, got)
+		}
+	}
+
+	want := `"labels":{"alpha":"alpha","beta":"beta","delta":"delta","gamma":"gamma","kappa":"kappa","mu":"mu","omega":"omega","zeta":"zeta"}`
+	if !strings.Contains(string(first), want) {
+		t.Errorf("expected labels in key order, got %s", first)
+	}
+	if !strings.Contains(string(first), `{"a":[{"b":false,"y":true}],"z":1}`) {
+		t.Errorf("expected nested extended data in key order, got %s", first)
+	}
+}
@@ -0,0 +1,11 @@
+package nodes
+
+import "time"
+
+// Note is a dated comment an operator left on a node, e.g. "DIMM replaced".  Notes are kept
+// apart from the node document, under its ID, so that replacing the node keeps them.
+type Note struct {
+	Author    string    `json:"author" jsonschema:"readOnly=true"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp" jsonschema:"readOnly=true"`
+}
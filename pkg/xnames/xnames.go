@@ -9,6 +9,53 @@ import (
 	"github.com/invopop/jsonschema"
 )
 
+// Cabinet numbers are 3-5 digits by default, matching the CSM xname convention.
+// Some smaller systems number their cabinets with 1 or 2 digits (e.g. x1c0s0b0n0),
+// which is only accepted once SetRelaxedCabinetDigits(true) has been called.
+const (
+	strictMinCabinetDigits  = 3
+	relaxedMinCabinetDigits = 1
+	maxCabinetDigits        = 5
+)
+
+var minCabinetDigits = strictMinCabinetDigits
+
+// canonicalCabinetDigits is the cabinet width Normalize pads to.  It doesn't follow
+// SetRelaxedCabinetDigits, so that stored xnames keep one spelling whichever mode wrote them.
+const canonicalCabinetDigits = strictMinCabinetDigits
+
+// SetRelaxedCabinetDigits controls whether 1 and 2 digit cabinet numbers are accepted.
+// It affects Valid(), IsValidBMCXName and the generated JSON schema patterns, so it
+// should be called once at startup before any schemas are generated.
+func SetRelaxedCabinetDigits(relaxed bool) {
+	if relaxed {
+		minCabinetDigits = relaxedMinCabinetDigits
+	} else {
+		minCabinetDigits = strictMinCabinetDigits
+	}
+}
+
+// cabinetDigits returns the regex quantifier for the cabinet number, e.g. `\d{3,5}`
+func cabinetDigits() string {
+	return fmt.Sprintf(`\d{%d,%d}`, minCabinetDigits, maxCabinetDigits)
+}
+
+func nodeXnameRegex() *regexp.Regexp {
+	return regexp.MustCompile(`^x(?P<cabinet>` + cabinetDigits() + `)c(?P<chassis>\d{1,3})s(?P<slot>\d{1,3})b(?P<bmc>\d{1,3})n(?P<node>\d{1,3})$`)
+}
+
+func bmcXnameRegex() *regexp.Regexp {
+	return regexp.MustCompile(`^x(?P<cabinet>` + cabinetDigits() + `)c(?P<chassis>\d{1,3})s(?P<slot>\d{1,3})b(?P<bmc>\d{1,3})$`)
+}
+
+func routerXnameRegex() *regexp.Regexp {
+	return regexp.MustCompile(`^x(?P<cabinet>` + cabinetDigits() + `)c(?P<chassis>\d{1,3})r(?P<router>\d{1,3})b(?P<bmc>\d{1,3})$`)
+}
+
+func hsnXnameRegex() *regexp.Regexp {
+	return regexp.MustCompile(`^x(?P<cabinet>` + cabinetDigits() + `)c(?P<chassis>\d{1,3})r(?P<router>\d{1,3})j(?P<connector>\d{1,3})$`)
+}
+
 type NodeXname struct {
 	Value string
 }
@@ -52,28 +99,126 @@ func (n NodeXname) String() string {
 	return n.Value
 }
 
+// Key returns a normalized form of the xname for use as a map key, so that padded and
+// unpadded spellings of the same node (x0001c0s0b0n0 and x1c0s0b0n0) produce the same key.
+// Values that don't parse as a node xname are returned unchanged.
+func (n NodeXname) Key() string {
+	return n.Normalize().Value
+}
+
+// looseNodeXnameRegex and looseBMCXnameRegex accept fields of any width, so that spellings
+// Valid() rejects, like x1c0s0b0n0 with strict cabinet digits, can still be normalized
+var (
+	looseNodeXnameRegex = regexp.MustCompile(`^x\d+c\d+s\d+b\d+n\d+$`)
+	looseBMCXnameRegex  = regexp.MustCompile(`^x\d+c\d+s\d+b\d+$`)
+)
+
+// Normalize returns the canonical spelling of the xname: leading zeros are dropped and the
+// cabinet is padded to three digits, whether or not short cabinet numbers are allowed, so
+// x0001c0s0b0n0 and x1c0s0b0n0 both become x001c0s0b0n0.  Values that aren't node xnames
+// are returned unchanged.
+func (n NodeXname) Normalize() NodeXname {
+	if !looseNodeXnameRegex.MatchString(n.Value) {
+		return n
+	}
+	c := extractXNameComponents(n.Value)
+	if c.Type != TypeNode {
+		return n
+	}
+	return NewNodeXname(fmt.Sprintf("x%0*dc%ds%db%dn%d", canonicalCabinetDigits, c.Cabinet, c.Chassis, c.Slot, c.BMCPosition, c.NodePosition))
+}
+
+// cabinetPrefixRegex matches a complete cabinet at the start of an xname prefix
+var cabinetPrefixRegex = regexp.MustCompile(`^x(\d+)c`)
+
+// NormalizePrefix pads the cabinet of an xname prefix like Normalize pads it, so x1c0 matches
+// the x001c0 xnames that are stored.  A cabinet without its chassis after it may still be
+// missing digits, as in x1 for cabinet x1000, and is left alone.
+func NormalizePrefix(prefix string) string {
+	m := cabinetPrefixRegex.FindStringSubmatch(prefix)
+	if m == nil {
+		return prefix
+	}
+	cabinet, err := strconv.Atoi(m[1])
+	if err != nil {
+		return prefix
+	}
+	return fmt.Sprintf("x%0*dc", canonicalCabinetDigits, cabinet) + prefix[len(m[0]):]
+}
+
+// Values of XNameComponents.Type
+const (
+	TypeNode      = "n" // x#c#s#b#n#
+	TypeBMC       = "b" // x#c#s#b#
+	TypeRouterBMC = "r" // x#c#r#b#
+	TypeHSN       = "h" // x#c#r#j#, a high-speed-network connector on a router
+)
+
 type XNameComponents struct {
 	Cabinet      int    `json:"cabinet"`
 	Chassis      int    `json:"chassis"`
 	Slot         int    `json:"slot"`
+	Router       int    `json:"router"`
 	BMCPosition  int    `json:"bmc_position"`
 	NodePosition int    `json:"node_position"`
-	Type         string `json:"type"` // 'n' for node, 'b' for BMC
+	Connector    int    `json:"connector"`
+	Type         string `json:"type"` // one of the Type* constants, empty if the xname wasn't recognized
+}
+
+// NodeXName formats the components as a node xname, the inverse of parsing one.  The cabinet
+// number is zero-padded like Normalize pads it, so cabinet 1 is x001 even when short cabinet
+// numbers are allowed.
+func (c XNameComponents) NodeXName() string {
+	return fmt.Sprintf("x%0*dc%ds%db%dn%d", canonicalCabinetDigits, c.Cabinet, c.Chassis, c.Slot, c.BMCPosition, c.NodePosition)
+}
+
+// BMCXName formats the components as the xname of a node BMC, padded like NodeXName
+func (c XNameComponents) BMCXName() string {
+	return fmt.Sprintf("x%0*dc%ds%db%d", canonicalCabinetDigits, c.Cabinet, c.Chassis, c.Slot, c.BMCPosition)
 }
 
 func extractXNameComponents(xname string) XNameComponents {
 	var components XNameComponents
 	_, err := fmt.Sscanf(xname, "x%dc%ds%db%dn%d", &components.Cabinet, &components.Chassis, &components.Slot, &components.BMCPosition, &components.NodePosition)
 	if err == nil {
-		components.Type = "n"
+		components.Type = TypeNode
 		return components
 	}
 	_, err = fmt.Sscanf(xname, "x%dc%ds%db%d", &components.Cabinet, &components.Chassis, &components.Slot, &components.BMCPosition)
 	if err == nil {
-		components.Type = "b"
+		components.Type = TypeBMC
+		return components
+	}
+	components = XNameComponents{}
+	_, err = fmt.Sscanf(xname, "x%dc%dr%db%d", &components.Cabinet, &components.Chassis, &components.Router, &components.BMCPosition)
+	if err == nil {
+		components.Type = TypeRouterBMC
 		return components
 	}
-	return components
+	components = XNameComponents{}
+	_, err = fmt.Sscanf(xname, "x%dc%dr%dj%d", &components.Cabinet, &components.Chassis, &components.Router, &components.Connector)
+	if err == nil {
+		components.Type = TypeHSN
+		return components
+	}
+	return XNameComponents{}
+}
+
+// ParseXName validates xname against each of the known xname formats and returns its
+// components.  Unlike the Sscanf based parsing used by the accessors, trailing characters
+// and out of range numbers are rejected.
+func ParseXName(xname string) (XNameComponents, error) {
+	for _, re := range []*regexp.Regexp{nodeXnameRegex(), bmcXnameRegex(), routerXnameRegex(), hsnXnameRegex()} {
+		if !re.MatchString(xname) {
+			continue
+		}
+		components := extractXNameComponents(xname)
+		if components.Chassis >= 256 {
+			return XNameComponents{}, fmt.Errorf("chassis number %d exceeds the maximum allowed value of 255", components.Chassis)
+		}
+		return components, nil
+	}
+	return XNameComponents{}, fmt.Errorf("%q is not a recognized xname", xname)
 }
 
 func (NodeXname) JSONSchema() *jsonschema.Schema {
@@ -81,7 +226,7 @@ func (NodeXname) JSONSchema() *jsonschema.Schema {
 		Type:        "string",
 		Title:       "NodeXName",
 		Description: "XName for a compute node",
-		Pattern:     `^x(\d{3,5})c(\d{1,3})s(\d{1,3})b(\d{1,3})n(\d{1,3})$`,
+		Pattern:     `^x(` + cabinetDigits() + `)c(\d{1,3})s(\d{1,3})b(\d{1,3})n(\d{1,3})$`,
 	}
 }
 
@@ -99,15 +244,15 @@ func (xname *NodeXname) UnmarshalJSON(data []byte) error {
 }
 
 func (xname NodeXname) Valid() (bool, error) {
-	nodeXnameRegex := regexp.MustCompile(`^x(?P<cabinet>\d{3,5})c(?P<chassis>\d{1,3})s(?P<slot>\d{1,3})b(?P<bmc>\d{1,3})n(?P<node>\d{1,3})$`)
-	if !nodeXnameRegex.MatchString(xname.Value) {
+	re := nodeXnameRegex()
+	if !re.MatchString(xname.Value) {
 		return false, fmt.Errorf("XName does not match regex")
 	}
 
 	// Extract the named groups
-	match := nodeXnameRegex.FindStringSubmatch(xname.Value)
+	match := re.FindStringSubmatch(xname.Value)
 	result := make(map[string]string)
-	for i, name := range nodeXnameRegex.SubexpNames() {
+	for i, name := range re.SubexpNames() {
 		if i > 0 && i <= len(match) {
 			result[name] = match[i]
 		}
@@ -150,12 +295,25 @@ func (b BMCXname) String() string {
 	return b.Value
 }
 
+// Normalize returns the canonical spelling of the BMC xname, padded like NodeXname.Normalize.
+// Values that aren't BMC xnames are returned unchanged.
+func (b BMCXname) Normalize() BMCXname {
+	if !looseBMCXnameRegex.MatchString(b.Value) {
+		return b
+	}
+	c := extractXNameComponents(b.Value)
+	if c.Type != TypeBMC {
+		return b
+	}
+	return NewBMCXname(fmt.Sprintf("x%0*dc%ds%db%d", canonicalCabinetDigits, c.Cabinet, c.Chassis, c.Slot, c.BMCPosition))
+}
+
 func (b BMCXname) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type:        "string",
 		Title:       "BMCXName",
 		Description: "XName for a BMC",
-		Pattern:     `^x(\d{3,5})c(\d{1,3})s(\d{1,3})b(\d{1,3})$`,
+		Pattern:     `^x(` + cabinetDigits() + `)c(\d{1,3})s(\d{1,3})b(\d{1,3})$`,
 	}
 }
 
@@ -173,15 +331,15 @@ func (b *BMCXname) UnmarshalJSON(data []byte) error {
 }
 
 func (b BMCXname) Valid() (bool, error) {
-	bmcXnameRegex := regexp.MustCompile(`^x(?P<cabinet>\d{3,5})c(?P<chassis>\d{1,3})s(?P<slot>\d{1,3})b(?P<bmc>\d{1,3})$`)
-	if !bmcXnameRegex.MatchString(b.Value) {
+	re := bmcXnameRegex()
+	if !re.MatchString(b.Value) {
 		return false, fmt.Errorf("XName does not match regex")
 	}
 
 	// Extract the named groups
-	match := bmcXnameRegex.FindStringSubmatch(b.Value)
+	match := re.FindStringSubmatch(b.Value)
 	result := make(map[string]string)
-	for i, name := range bmcXnameRegex.SubexpNames() {
+	for i, name := range re.SubexpNames() {
 		if i > 0 && i <= len(match) {
 			result[name] = match[i]
 		}
@@ -198,9 +356,61 @@ func (b BMCXname) Valid() (bool, error) {
 	return true, nil
 }
 
+// RouterXname is the xname of a router BMC, e.g. x1000c0r2b0
+type RouterXname struct {
+	Value string
+}
+
+func NewRouterXname(xname string) RouterXname {
+	return RouterXname{Value: xname}
+}
+
+func (r RouterXname) String() string {
+	return r.Value
+}
+
+// Router returns the position of the router within its chassis
+func (r RouterXname) Router() (int, error) {
+	if r.Value == "" {
+		return 0, fmt.Errorf("router does not have an XName")
+	}
+	return extractXNameComponents(r.Value).Router, nil
+}
+
+func (r RouterXname) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type:        "string",
+		Title:       "RouterXName",
+		Description: "XName for a router BMC",
+		Pattern:     `^x(` + cabinetDigits() + `)c(\d{1,3})r(\d{1,3})b(\d{1,3})$`,
+	}
+}
+
+func (r RouterXname) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Value)
+}
+
+func (r *RouterXname) UnmarshalJSON(data []byte) error {
+	r.Value = string(data)
+	// Remove quotation marks if they exist
+	if len(r.Value) >= 2 && r.Value[0] == '"' && r.Value[len(r.Value)-1] == '"' {
+		r.Value = r.Value[1 : len(r.Value)-1]
+	}
+	return nil
+}
+
+func (r RouterXname) Valid() (bool, error) {
+	if !routerXnameRegex().MatchString(r.Value) {
+		return false, fmt.Errorf("XName does not match regex")
+	}
+	if _, err := ParseXName(r.Value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func IsValidBMCXName(xname string) bool {
-	// Compile the regular expression. This is the pattern from your requirement.
-	re := regexp.MustCompile(`^x(?P<cabinet>\d{3,5})c(?P<chassis>\d{1,3})s(?P<slot>\d{1,3})b(?P<bmc>\d{1,3})$`)
+	re := bmcXnameRegex()
 
 	// Use FindStringSubmatch to capture the parts of the xname.
 	matches := re.FindStringSubmatch(xname)
@@ -0,0 +1,191 @@
+package xnames
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestShortCabinetDigits(t *testing.T) {
+	t.Cleanup(func() { SetRelaxedCabinetDigits(false) })
+
+	tests := []struct {
+		xname   string
+		strict  bool
+		relaxed bool
+	}{
+		{"x1000c0s0b0n0", true, true},
+		{"x100c0s0b0n0", true, true},
+		{"x10c0s0b0n0", false, true},
+		{"x1c0s0b0n0", false, true},
+		{"xc0s0b0n0", false, false},
+		{"x100000c0s0b0n0", false, false},
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		SetRelaxedCabinetDigits(relaxed)
+		for _, tt := range tests {
+			want := tt.strict
+			if relaxed {
+				want = tt.relaxed
+			}
+			if ok, _ := NewNodeXname(tt.xname).Valid(); ok != want {
+				t.Errorf("relaxed=%v: NodeXname(%q).Valid() = %v, want %v", relaxed, tt.xname, ok, want)
+			}
+
+			pattern := regexp.MustCompile(NodeXname{}.JSONSchema().Pattern)
+			if got := pattern.MatchString(tt.xname); got != want {
+				t.Errorf("relaxed=%v: schema pattern match for %q = %v, want %v", relaxed, tt.xname, got, want)
+			}
+
+			bmcXname := tt.xname[:len(tt.xname)-2]
+			if got := IsValidBMCXName(bmcXname); got != want {
+				t.Errorf("relaxed=%v: IsValidBMCXName(%q) = %v, want %v", relaxed, bmcXname, got, want)
+			}
+			if ok, _ := NewBMCXname(bmcXname).Valid(); ok != want {
+				t.Errorf("relaxed=%v: BMCXname(%q).Valid() = %v, want %v", relaxed, bmcXname, ok, want)
+			}
+		}
+	}
+}
+
+func TestParseXNameTypes(t *testing.T) {
+	tests := []struct {
+		xname string
+		want  XNameComponents
+	}{
+		{"x1000c0s1b0n1", XNameComponents{Cabinet: 1000, Chassis: 0, Slot: 1, BMCPosition: 0, NodePosition: 1, Type: TypeNode}},
+		{"x1000c0s1b0", XNameComponents{Cabinet: 1000, Chassis: 0, Slot: 1, BMCPosition: 0, Type: TypeBMC}},
+		{"x1000c0r2b0", XNameComponents{Cabinet: 1000, Chassis: 0, Router: 2, BMCPosition: 0, Type: TypeRouterBMC}},
+		{"x1000c3r7j12", XNameComponents{Cabinet: 1000, Chassis: 3, Router: 7, Connector: 12, Type: TypeHSN}},
+	}
+	for _, tt := range tests {
+		got, err := ParseXName(tt.xname)
+		if err != nil {
+			t.Errorf("ParseXName(%q) unexpected error: %v", tt.xname, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseXName(%q) = %+v, want %+v", tt.xname, got, tt.want)
+		}
+	}
+}
+
+func TestRouterXnameMalformed(t *testing.T) {
+	if ok, err := NewRouterXname("x1000c0r2b0").Valid(); !ok {
+		t.Errorf("expected x1000c0r2b0 to be a valid router xname: %v", err)
+	}
+	if got, _ := NewRouterXname("x1000c0r2b0").Router(); got != 2 {
+		t.Errorf("expected router position 2, got %d", got)
+	}
+
+	for _, xname := range []string{
+		"x1000c0r2",      // missing BMC
+		"x1000c0r2b",     // missing BMC number
+		"x1000c0rb0",     // missing router number
+		"x1000c0r2b0n0",  // routers don't have nodes
+		"x1000c0r2b0x",   // trailing characters
+		"x1000c256r2b0",  // chassis out of range
+		"x1000c0r2j0",    // HSN connector, not a router BMC
+		"x1000c0s2b0",    // node BMC, not a router BMC
+		"x10c0r2b0",      // short cabinet in strict mode
+		"x1000c0r1234b0", // router number too long
+	} {
+		if ok, _ := NewRouterXname(xname).Valid(); ok {
+			t.Errorf("expected %q to be an invalid router xname", xname)
+		}
+	}
+
+	for _, xname := range []string{"x1000c0r2b0x", "x1000c0r2j", "x1000c0r-1b0", "x1000c0q2b0"} {
+		if _, err := ParseXName(xname); err == nil {
+			t.Errorf("expected ParseXName(%q) to fail", xname)
+		}
+	}
+}
+
+func TestBuildXNames(t *testing.T) {
+	t.Cleanup(func() { SetRelaxedCabinetDigits(false) })
+
+	components := XNameComponents{Cabinet: 1, Chassis: 2, Slot: 3, BMCPosition: 1, NodePosition: 0}
+	if got := components.NodeXName(); got != "x001c2s3b1n0" {
+		t.Errorf("NodeXName() = %q, want %q", got, "x001c2s3b1n0")
+	}
+	if got := components.BMCXName(); got != "x001c2s3b1" {
+		t.Errorf("BMCXName() = %q, want %q", got, "x001c2s3b1")
+	}
+	// Built xnames are canonical whatever the parsing mode, so they match stored keys
+	SetRelaxedCabinetDigits(true)
+	if got := components.NodeXName(); got != "x001c2s3b1n0" {
+		t.Errorf("relaxed NodeXName() = %q, want %q", got, "x001c2s3b1n0")
+	}
+	parsed, err := ParseXName("x1c2s3b1n0")
+	if err != nil {
+		t.Fatalf("ParseXName(%q): %v", "x1c2s3b1n0", err)
+	}
+	if got, want := parsed.NodeXName(), NewNodeXname("x1c2s3b1n0").Normalize().Value; got != want {
+		t.Errorf("relaxed NodeXName() = %q, want it to match Normalize() %q", got, want)
+	}
+	if got, want := parsed.BMCXName(), NewBMCXname("x1c2s3b1").Normalize().Value; got != want {
+		t.Errorf("relaxed BMCXName() = %q, want it to match Normalize() %q", got, want)
+	}
+
+	for _, xname := range []string{"x1000c0s7b1n1", "x3000c255s0b0n2"} {
+		parsed, err := ParseXName(xname)
+		if err != nil {
+			t.Fatalf("ParseXName(%q): %v", xname, err)
+		}
+		if got := parsed.NodeXName(); got != xname {
+			t.Errorf("building %q back from its components gave %q", xname, got)
+		}
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"x1c0":     "x001c0",
+		"x0001c":   "x001c",
+		"x1000c0s": "x1000c0s",
+		"x1":       "x1",
+		"":         "",
+	} {
+		if got := NormalizePrefix(prefix); got != want {
+			t.Errorf("NormalizePrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Cleanup(func() { SetRelaxedCabinetDigits(false) })
+
+	tests := []struct {
+		xname, want string
+	}{
+		{"x1c0s0b0n0", "x001c0s0b0n0"},
+		{"x0001c0s0b0n0", "x001c0s0b0n0"},
+		{"x01000c00s7b1n01", "x1000c0s7b1n1"},
+		{"x1000c0s0b0n0junk", "x1000c0s0b0n0junk"},
+		{"", ""},
+	}
+	// The canonical spelling is the same whether or not short cabinet numbers are allowed
+	for _, relaxed := range []bool{false, true} {
+		SetRelaxedCabinetDigits(relaxed)
+		for _, tt := range tests {
+			if got := NewNodeXname(tt.xname).Normalize().String(); got != tt.want {
+				t.Errorf("relaxed=%v: NodeXname(%q).Normalize() = %q, want %q", relaxed, tt.xname, got, tt.want)
+			}
+			if got := NewNodeXname(tt.xname).Key(); got != tt.want {
+				t.Errorf("relaxed=%v: NodeXname(%q).Key() = %q, want %q", relaxed, tt.xname, got, tt.want)
+			}
+		}
+		if got := NewBMCXname("x1c0s0b0").Normalize().String(); got != "x001c0s0b0" {
+			t.Errorf("relaxed=%v: BMCXname.Normalize() = %q, want %q", relaxed, got, "x001c0s0b0")
+		}
+	}
+
+	SetRelaxedCabinetDigits(false)
+	if got := NewBMCXname("x0001c0s0b0").Normalize().String(); got != "x001c0s0b0" {
+		t.Errorf("BMCXname.Normalize() = %q, want %q", got, "x001c0s0b0")
+	}
+	if got := NewBMCXname("x1000c0s0b0n0").Normalize().String(); got != "x1000c0s0b0n0" {
+		t.Errorf("expected a node xname left alone as a BMC xname, got %q", got)
+	}
+}
@@ -2,36 +2,54 @@ package main
 
 import (
 	"encoding/json"
-
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/invopop/jsonschema"
 	smd "github.com/openchami/node-orchestrator/internal/api/smd"
 	nodes "github.com/openchami/node-orchestrator/pkg/nodes"
 	"github.com/rs/zerolog/log"
 )
 
+// schemaModels are the models that JSON schemas are generated for, by name
+var schemaModels = map[string]interface{}{
+	"ComputeNode":      &nodes.ComputeNode{},
+	"NetworkInterface": &nodes.NetworkInterface{},
+	"BMC":              &nodes.BMC{},
+	"NodeCollection":   &nodes.NodeCollection{},
+	"Component":        &smd.Component{},
+	"RedfishEndpoint":  &smd.RedfishEndpoint{},
+}
+
+// generateSchemas returns the indented JSON schema of every model in schemaModels
+func generateSchemas() (map[string][]byte, error) {
+	schemas := make(map[string][]byte, len(schemaModels))
+	for name, model := range schemaModels {
+		data, err := json.MarshalIndent(jsonschema.Reflect(model), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		schemas[name] = data
+	}
+	return schemas, nil
+}
+
 func generateAndWriteSchemas(path string) {
-	schemas := map[string]interface{}{
-		"ComputeNode.json":      &nodes.ComputeNode{},
-		"NetworkInterface.json": &nodes.NetworkInterface{},
-		"BMC.json":              &nodes.BMC{},
-		"NodeCollection.json":   &nodes.NodeCollection{},
-		"Component.json":        &smd.Component{},
-		"RedfishEndpoint.json":  &smd.RedfishEndpoint{},
+	schemas, err := generateSchemas()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate JSON schemas")
 	}
 
 	if err := os.MkdirAll(path, 0755); err != nil {
 		log.Fatal().Err(err).Str("path", path).Msg("Failed to create schema directory")
 	}
 
-	for filename, model := range schemas {
-		schema := jsonschema.Reflect(model)
-		data, err := json.MarshalIndent(schema, "", "  ")
-		if err != nil {
-			log.Fatal().Err(err).Str("filename", filename).Msg("Failed to generate JSON schema")
-		}
+	for name, data := range schemas {
+		filename := name + ".json"
 		fullpath := filepath.Join(path, filename)
 		if err := os.WriteFile(fullpath, data, 0644); err != nil {
 			log.Fatal().Err(err).Str("filename", filename).Msg("Failed to write JSON schema to file")
@@ -39,3 +57,29 @@ func generateAndWriteSchemas(path string) {
 		log.Info().Str("fullpath", fullpath).Msg("Schema written")
 	}
 }
+
+// schemaRoutes serves schemas generated by generateSchemas: GET / lists their names and
+// GET /{name} returns one, with or without the .json suffix the schemas subcommand writes
+func schemaRoutes(schemas map[string][]byte) chi.Router {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(names)
+	})
+	r.Get("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		schema, ok := schemas[strings.TrimSuffix(chi.URLParam(r, "name"), ".json")]
+		if !ok {
+			http.Error(w, "schema not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(schema)
+	})
+	return r
+}
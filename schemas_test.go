@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestSchemaRoutes(t *testing.T) {
+	schemas, err := generateSchemas()
+	if err != nil {
+		t.Fatalf("failed to generate schemas: %v", err)
+	}
+	r := chi.NewRouter()
+	r.Mount("/schemas", schemaRoutes(schemas))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schemas", nil))
+	var names []string
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&names) != nil || len(names) != len(schemaModels) {
+		t.Fatalf("unexpected listing %d: %v", rec.Code, names)
+	}
+
+	for _, path := range []string{"/schemas/ComputeNode", "/schemas/ComputeNode.json"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var schema map[string]interface{}
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&schema) != nil || schema["$ref"] != "#/$defs/ComputeNode" {
+			t.Errorf("%s: unexpected schema %d: %v", path, rec.Code, schema["$ref"])
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schemas/Nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown schema, got %d", rec.Code)
+	}
+}
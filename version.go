@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+const duckDBModule = "github.com/marcboeker/go-duckdb"
+
+// VersionInfo is the body of GET /version
+type VersionInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	BuildDate     string `json:"build_date"`
+	GoVersion     string `json:"go_version"`
+	DuckDBVersion string `json:"duckdb_driver_version"`
+}
+
+// buildVersionInfo collects the version the binary was built with.  The DuckDB driver version
+// comes from the module information embedded in the binary, which tests don't have.
+func buildVersionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:       version,
+		Commit:        commit,
+		BuildDate:     buildDate,
+		GoVersion:     runtime.Version(),
+		DuckDBVersion: "unknown",
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range build.Deps {
+			if dep.Path == duckDBModule {
+				info.DuckDBVersion = dep.Version
+				if dep.Replace != nil {
+					info.DuckDBVersion = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return info
+}
+
+func versionHandler(info VersionInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
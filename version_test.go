@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	version, commit, buildDate = "v1.2.0", "abc123", "2024-06-01T00:00:00Z"
+	defer func() { version, commit, buildDate = "dev", "unknown", "unknown" }()
+
+	rec := httptest.NewRecorder()
+	versionHandler(buildVersionInfo())(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info VersionInfo
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&info) != nil {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	want := VersionInfo{Version: "v1.2.0", Commit: "abc123", BuildDate: "2024-06-01T00:00:00Z", GoVersion: runtime.Version(), DuckDBVersion: info.DuckDBVersion}
+	if info != want || info.DuckDBVersion == "" {
+		t.Errorf("expected %+v, got %+v", want, info)
+	}
+}